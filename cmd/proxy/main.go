@@ -3,6 +3,7 @@
 // Environment variables:
 //   GRPC_PORT           — gRPC server port (default: 50051)
 //   METRICS_PORT        — Prometheus metrics HTTP port (default: 9090)
+//   MAX_REQUEST_SIZE_MB — Largest gRPC request accepted, inline images and documents included (default: 20)
 //   REDIS_ADDR          — Redis address (default: localhost:6379)
 //   REDIS_PASSWORD      — Redis password (default: "")
 //   REDIS_DB            — Redis database (default: 0)
@@ -10,23 +11,99 @@
 //   QDRANT_URL          — Qdrant server URL (default: http://localhost:6333)
 //   QDRANT_COLLECTION   — Qdrant collection name (default: llm_cache)
 //   SIMILARITY_THRESHOLD — Semantic similarity threshold (default: 0.95)
-//   EMBEDDING_API_KEY   — API key for the embedding provider
+//   SEMANTIC_CACHE_ENABLED — Enable the semantic response cache (default: true if the legacy EMBEDDING_API_KEY is set, else false)
+//   EMBEDDING_MODEL     — Model the semantic cache embeds prompts with, through its provider's key pool (default: text-embedding-3-small)
+//   EMBEDDING_CACHE_TTL — TTL of cached embeddings, 0 to disable the embedding cache (default: 24h)
 //   OPENAI_API_KEYS     — Comma-separated OpenAI API keys
 //   GEMINI_API_KEYS     — Comma-separated Gemini API keys
+//   KEY_POOL_CONFIG     — JSON file with per-provider key strategy, weights, quotas and labels
+//   KEY_POOL_STRATEGY   — Default key selection strategy (default: round_robin)
+//   OPENAI_API_KEYS_PATH — File or directory (e.g. mounted Secret) of OpenAI keys, watched for changes
+//   GEMINI_API_KEYS_PATH — File or directory of Gemini keys, watched for changes
+//   KEY_RELOAD_INTERVAL — How often key files are re-read (default: 30s)
 //   REQUEST_TIMEOUT     — Request timeout duration (default: 30s)
 //   MAX_RETRIES         — Maximum retry attempts (default: 3)
 //   CB_FAILURE_THRESHOLD — Circuit breaker failure threshold (default: 5)
 //   CB_COOLDOWN         — Circuit breaker cooldown (default: 30s)
+//   HEDGE_ENABLED       — Enable hedged requests (default: false)
+//   HEDGE_DELAY         — Fixed hedge delay / fallback (default: 2s)
+//   HEDGE_PERCENTILE    — Latency (streams: time-to-first-chunk) quantile to hedge at, 0 for fixed delay (default: 0.95)
+//   HEDGE_MIN_DELAY     — Lower bound on the derived hedge delay (default: 50ms)
+//   HEDGE_MAX_PERCENT   — Max percentage of requests that may be hedged (default: 5)
+//   CONCURRENCY_LIMIT_ENABLED — Enable per-provider adaptive concurrency limits (default: false)
+//   CONCURRENCY_INITIAL_LIMIT — Starting per-provider limit (default: 20)
+//   CONCURRENCY_MIN_LIMIT     — Floor for the adaptive limit (default: 2)
+//   CONCURRENCY_MAX_LIMIT     — Ceiling for the adaptive limit (default: 200)
+//   CONCURRENCY_TARGET_LATENCY — Latency treated as congestion, 0 to disable (default: 10s)
+//   CONCURRENCY_QUEUE_SIZE    — Max requests queued per provider (default: 50)
+//   QUEUE_WEIGHT_HIGH/NORMAL/LOW  — Weighted fair queue shares per priority (default: 6/3/1)
+//   CONCURRENCY_QUEUE_TIMEOUT — Max time queued for every priority without its own QUEUE_TIMEOUT_* (default: per priority)
+//   QUEUE_TIMEOUT_HIGH/NORMAL/LOW — Max time queued per priority (default: CONCURRENCY_QUEUE_TIMEOUT, else 1s/2s/10s)
+//   MAX_IN_FLIGHT       — Global in-flight request cap, 0 for unlimited (default: 0)
+//   ADMIN_TOKEN         — Bearer token required by /admin endpoints and the AdminService RPCs (default: none)
+//   AUTH_ENABLED        — Require a virtual key on every inference RPC (default: false)
+//   VIRTUAL_KEYS_FILE   — JSON file where hashed virtual keys are persisted (default: in-memory)
+//   PRICE_TABLE         — JSON file of per-model prices per million tokens (default: built-in list prices)
+//   MODEL_REGISTRY      — JSON file of per-model aliases, context windows, output limits, features and encodings (default: built-in registry)
+//   MODEL_REFRESH_INTERVAL — How often provider model lists are merged into the registry, 0 to disable (default: 0)
+//   TOKENIZER_DIR       — Directory of tiktoken rank files (e.g. o200k_base.tiktoken) for exact OpenAI token counts (default: estimates)
+//   CONTEXT_OVERFLOW    — reject or truncate: what to do when prompt plus max_tokens exceeds the context window (default: reject)
+//   CHAT_SESSION_STORE  — memory or redis: where Chat sessions and conversations are kept between turns (default: memory)
+//   CHAT_SESSION_TTL    — How long a Chat session or conversation is kept after its last turn (default: 1h)
+//   CHAT_SESSION_KEY    — Base64 AES key (16, 24 or 32 bytes) sealing PII placeholder values in Redis sessions (default: none, placeholders refused in Redis sessions)
+//   SUMMARY_MODEL       — Model that summarizes the older turns of long conversations (default: none, no summaries)
+//   SUMMARY_THRESHOLD   — Fraction of the context window at which older turns are summarized (default: 0.8)
+//   SUMMARY_KEEP_TURNS  — Latest turns kept verbatim when summarizing (default: 4)
+//   BATCH_DIR           — Directory batch jobs are kept in, shared by all replicas (default: disabled)
+//   BATCH_INPUT_DIR     — Directory SubmitBatch input_path files are read from (default: disabled)
+//   BATCH_WORKERS       — Batch items each replica runs at once (default: 4)
+//   BATCH_NATIVE_PROVIDERS — Comma-separated providers whose native batch API runs batch items (default: none)
+//   BATCH_POLL_INTERVAL — How often batch jobs are picked up and native batches polled (default: 30s)
+//   LEDGER_PATH         — Append-only JSONL usage ledger file (default: disabled)
+//   LEDGER_MAX_SIZE_MB  — Rotate the ledger file at this size (default: 100)
+//   LEDGER_MAX_BACKUPS  — Rotated ledger files to keep, 0 for all (default: 0)
+//   LEDGER_REDIS_STREAM — Also append usage records to this Redis stream (default: disabled)
+//   LEDGER_SQL_DRIVER   — database/sql driver for a Postgres-compatible ledger table (driver must be linked in)
+//   LEDGER_SQL_DSN      — DSN for the SQL ledger sink
+//   LEDGER_SQL_TABLE    — Table for the SQL ledger sink (default: usage_ledger)
+//   LEDGER_SPILL_DIR    — Directory usage records are spilled to while a sink is failing (default: <LEDGER_PATH>.spill, else a temp dir)
+//   AUDIT_FILE          — Rotating JSONL audit log of prompts / responses (default: disabled)
+//   AUDIT_FILE_MAX_SIZE_MB — Rotate the audit file at this size (default: 100)
+//   AUDIT_FILE_MAX_BACKUPS — Rotated audit files to keep, 0 for all (default: 10)
+//   AUDIT_STDOUT        — Also write audit events to stdout (default: false)
+//   AUDIT_WEBHOOK_URL   — Also POST audit event batches to this URL (default: disabled)
+//   AUDIT_WEBHOOK_TOKEN — Bearer token for the audit webhook
+//   AUDIT_CONTENT       — full, hash or metadata: how prompts / responses are recorded (default: hash)
+//   AUDIT_SAMPLE_RATE   — Fraction of requests audited (default: 1.0)
+//   AUDIT_MAX_FIELD_BYTES — Truncate prompt / response beyond this size (default: 8192)
+//   AUDIT_REDACT_FIELDS — Comma-separated event fields to redact (e.g. tenant,error)
+//   PII_ACTION          — block, mask or placeholder: redact PII from prompts (default: disabled)
+//   PII_DETECTORS       — Comma-separated built-in PII detectors to enable (default: all)
+//   PII_CONFIG          — JSON file with per-detector actions and custom detectors (overrides PII_ACTION / PII_DETECTORS)
+//   OUTPUT_BANNED_TERMS — Comma-separated terms responses may not contain (default: none)
+//   OUTPUT_BLOCK_SECRETS — Block responses containing credentials such as API keys or private keys (default: false)
+//   OUTPUT_MAX_LENGTH   — Maximum response length in bytes, 0 for unlimited (default: 0)
+//   OUTPUT_POLICY       — JSON file with output rules, including named regexes (overrides the OUTPUT_* variables above)
+//   INJECTION_BLOCK_THRESHOLD — Prompt injection score (0–1) at which requests are rejected, 0 to disable (default: 0)
+//   INJECTION_FLAG_THRESHOLD  — Score at which responses are flagged with x-guardrail-injection-* headers (default: 0)
+//   INJECTION_LOG_THRESHOLD   — Score at which prompts are logged (default: 0)
+//   INJECTION_JUDGE_MODEL     — Model asked to score prompts in addition to the heuristics (default: none)
+//   INJECTION_JUDGE_TIMEOUT   — Time limit for one judge call (default: 5s)
+//   INJECTION_ESCALATE_AT     — Heuristic score from which prompts are also sent to the judge, 0 for every prompt (default: 0.3)
+//   INJECTION_CONFIG    — JSON file with per-tenant thresholds and judge settings (overrides the other INJECTION_* settings)
+//   TENANT_LIMITS_CONFIG — JSON file of per-tenant rate limits and budgets, enforced via Redis (requires AUTH_ENABLED)
 package main
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -37,13 +114,29 @@ import (
 	"google.golang.org/grpc/reflection"
 
 	pb "github.com/abdhe/llm-inference-proxy/proto"
+	"github.com/abdhe/llm-inference-proxy/pkg/admin"
+	"github.com/abdhe/llm-inference-proxy/pkg/audit"
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/batch"
 	"github.com/abdhe/llm-inference-proxy/pkg/cache"
+	"github.com/abdhe/llm-inference-proxy/pkg/guardrail"
+	"github.com/abdhe/llm-inference-proxy/pkg/ledger"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/models"
+	"github.com/abdhe/llm-inference-proxy/pkg/pricing"
 	"github.com/abdhe/llm-inference-proxy/pkg/provider"
 	"github.com/abdhe/llm-inference-proxy/pkg/proxy"
+	"github.com/abdhe/llm-inference-proxy/pkg/quota"
 	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	"github.com/abdhe/llm-inference-proxy/pkg/session"
+	"github.com/abdhe/llm-inference-proxy/pkg/tokenizer"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "usage" {
+		os.Exit(runUsage(os.Args[2:]))
+	}
+
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("Starting LLM Inference Proxy...")
 
@@ -52,6 +145,7 @@ func main() {
 	// -------------------------------------------------------------------------
 	grpcPort := envOrDefault("GRPC_PORT", "50051")
 	metricsPort := envOrDefault("METRICS_PORT", "9090")
+	maxRequestSizeMB := envIntOrDefault("MAX_REQUEST_SIZE_MB", 20)
 	redisAddr := envOrDefault("REDIS_ADDR", "localhost:6379")
 	redisPassword := envOrDefault("REDIS_PASSWORD", "")
 	redisDB := envIntOrDefault("REDIS_DB", 0)
@@ -59,13 +153,121 @@ func main() {
 	qdrantURL := envOrDefault("QDRANT_URL", "http://localhost:6333")
 	qdrantCollection := envOrDefault("QDRANT_COLLECTION", "llm_cache")
 	similarityThreshold := envFloatOrDefault("SIMILARITY_THRESHOLD", 0.95)
-	embeddingAPIKey := os.Getenv("EMBEDDING_API_KEY")
+	// Deployments from before SEMANTIC_CACHE_ENABLED turned the cache on by
+	// setting EMBEDDING_API_KEY; keep them cached.
+	semanticCacheEnabled := envBoolOrDefault("SEMANTIC_CACHE_ENABLED", os.Getenv("EMBEDDING_API_KEY") != "")
+	embeddingModel := envOrDefault("EMBEDDING_MODEL", "text-embedding-3-small")
+	embeddingCacheTTL := envDurationOrDefault("EMBEDDING_CACHE_TTL", 24*time.Hour)
 	openaiKeys := splitKeys(os.Getenv("OPENAI_API_KEYS"))
 	geminiKeys := splitKeys(os.Getenv("GEMINI_API_KEYS"))
+	keyPoolConfigPath := os.Getenv("KEY_POOL_CONFIG")
+	keyPoolStrategy := envOrDefault("KEY_POOL_STRATEGY", resilience.StrategyRoundRobin)
+	keyPaths := map[string]string{
+		"openai": os.Getenv("OPENAI_API_KEYS_PATH"),
+		"gemini": os.Getenv("GEMINI_API_KEYS_PATH"),
+	}
+	keyReloadInterval := envDurationOrDefault("KEY_RELOAD_INTERVAL", 30*time.Second)
 	requestTimeout := envDurationOrDefault("REQUEST_TIMEOUT", 30*time.Second)
 	maxRetries := envIntOrDefault("MAX_RETRIES", 3)
 	cbFailureThreshold := envIntOrDefault("CB_FAILURE_THRESHOLD", 5)
 	cbCooldown := envDurationOrDefault("CB_COOLDOWN", 30*time.Second)
+	hedgeDefaults := resilience.DefaultHedgeConfig()
+	hedgeCfg := resilience.HedgeConfig{
+		Enabled:    envBoolOrDefault("HEDGE_ENABLED", hedgeDefaults.Enabled),
+		Delay:      envDurationOrDefault("HEDGE_DELAY", hedgeDefaults.Delay),
+		Percentile: envFloatOrDefault("HEDGE_PERCENTILE", hedgeDefaults.Percentile),
+		MinDelay:   envDurationOrDefault("HEDGE_MIN_DELAY", hedgeDefaults.MinDelay),
+		MaxPercent: envFloatOrDefault("HEDGE_MAX_PERCENT", hedgeDefaults.MaxPercent),
+	}
+	limiterEnabled := envBoolOrDefault("CONCURRENCY_LIMIT_ENABLED", false)
+	limiterDefaults := resilience.DefaultLimiterConfig()
+	limiterCfg := resilience.LimiterConfig{
+		InitialLimit:  envIntOrDefault("CONCURRENCY_INITIAL_LIMIT", limiterDefaults.InitialLimit),
+		MinLimit:      envIntOrDefault("CONCURRENCY_MIN_LIMIT", limiterDefaults.MinLimit),
+		MaxLimit:      envIntOrDefault("CONCURRENCY_MAX_LIMIT", limiterDefaults.MaxLimit),
+		TargetLatency: envDurationOrDefault("CONCURRENCY_TARGET_LATENCY", limiterDefaults.TargetLatency),
+		BackoffRatio:  limiterDefaults.BackoffRatio,
+		MaxQueue:      envIntOrDefault("CONCURRENCY_QUEUE_SIZE", limiterDefaults.MaxQueue),
+		QueueTimeout:  envDurationOrDefault("CONCURRENCY_QUEUE_TIMEOUT", limiterDefaults.QueueTimeout),
+		Classes:       make(map[resilience.Priority]resilience.QueueClass),
+	}
+	for _, p := range resilience.Priorities {
+		class := limiterDefaults.Classes[p]
+		if os.Getenv("CONCURRENCY_QUEUE_TIMEOUT") != "" {
+			class.Timeout = limiterCfg.QueueTimeout
+		}
+		suffix := strings.ToUpper(p.String())
+		limiterCfg.Classes[p] = resilience.QueueClass{
+			Weight:  envIntOrDefault("QUEUE_WEIGHT_"+suffix, class.Weight),
+			Timeout: envDurationOrDefault("QUEUE_TIMEOUT_"+suffix, class.Timeout),
+		}
+	}
+	maxInFlight := envIntOrDefault("MAX_IN_FLIGHT", 0)
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	authEnabled := envBoolOrDefault("AUTH_ENABLED", false)
+	virtualKeysFile := os.Getenv("VIRTUAL_KEYS_FILE")
+	tenantLimitsPath := os.Getenv("TENANT_LIMITS_CONFIG")
+	priceTablePath := os.Getenv("PRICE_TABLE")
+	modelRegistryPath := os.Getenv("MODEL_REGISTRY")
+	modelRefreshInterval := envDurationOrDefault("MODEL_REFRESH_INTERVAL", 0)
+	tokenizerDir := os.Getenv("TOKENIZER_DIR")
+	contextOverflow := envOrDefault("CONTEXT_OVERFLOW", proxy.ContextReject)
+	chatSessionStore := envOrDefault("CHAT_SESSION_STORE", "memory")
+	chatSessionTTL := envDurationOrDefault("CHAT_SESSION_TTL", time.Hour)
+	chatSessionKey := os.Getenv("CHAT_SESSION_KEY")
+	summaryCfg := proxy.SummaryConfig{
+		Model:     os.Getenv("SUMMARY_MODEL"),
+		Threshold: envFloatOrDefault("SUMMARY_THRESHOLD", 0.8),
+		KeepTurns: envIntOrDefault("SUMMARY_KEEP_TURNS", 4),
+	}
+	batchDir := os.Getenv("BATCH_DIR")
+	batchCfg := proxy.BatchConfig{
+		InputDir:        os.Getenv("BATCH_INPUT_DIR"),
+		Workers:         envIntOrDefault("BATCH_WORKERS", 4),
+		NativeProviders: splitKeys(os.Getenv("BATCH_NATIVE_PROVIDERS")),
+		PollInterval:    envDurationOrDefault("BATCH_POLL_INTERVAL", 30*time.Second),
+	}
+	ledgerPath := os.Getenv("LEDGER_PATH")
+	ledgerMaxSizeMB := envIntOrDefault("LEDGER_MAX_SIZE_MB", 100)
+	ledgerMaxBackups := envIntOrDefault("LEDGER_MAX_BACKUPS", 0)
+	ledgerStream := os.Getenv("LEDGER_REDIS_STREAM")
+	ledgerSQLDriver := os.Getenv("LEDGER_SQL_DRIVER")
+	ledgerSQLDSN := os.Getenv("LEDGER_SQL_DSN")
+	ledgerSQLTable := envOrDefault("LEDGER_SQL_TABLE", "usage_ledger")
+	ledgerSpillDir := os.Getenv("LEDGER_SPILL_DIR")
+	auditFile := os.Getenv("AUDIT_FILE")
+	auditFileMaxSizeMB := envIntOrDefault("AUDIT_FILE_MAX_SIZE_MB", 100)
+	auditFileMaxBackups := envIntOrDefault("AUDIT_FILE_MAX_BACKUPS", 10)
+	auditStdout := envBoolOrDefault("AUDIT_STDOUT", false)
+	auditWebhookURL := os.Getenv("AUDIT_WEBHOOK_URL")
+	auditWebhookToken := os.Getenv("AUDIT_WEBHOOK_TOKEN")
+	auditDefaults := audit.DefaultPolicy()
+	auditPolicy := audit.Policy{
+		Content:       envOrDefault("AUDIT_CONTENT", auditDefaults.Content),
+		SampleRate:    envFloatOrDefault("AUDIT_SAMPLE_RATE", auditDefaults.SampleRate),
+		MaxFieldBytes: envIntOrDefault("AUDIT_MAX_FIELD_BYTES", auditDefaults.MaxFieldBytes),
+		RedactFields:  splitKeys(os.Getenv("AUDIT_REDACT_FIELDS")),
+	}
+	piiAction := os.Getenv("PII_ACTION")
+	piiDetectors := splitKeys(os.Getenv("PII_DETECTORS"))
+	piiConfigPath := os.Getenv("PII_CONFIG")
+	outputCfg := guardrail.OutputConfig{
+		BannedTerms:  splitKeys(os.Getenv("OUTPUT_BANNED_TERMS")),
+		BlockSecrets: envBoolOrDefault("OUTPUT_BLOCK_SECRETS", false),
+		MaxLength:    envIntOrDefault("OUTPUT_MAX_LENGTH", 0),
+	}
+	outputPolicyPath := os.Getenv("OUTPUT_POLICY")
+	injectionCfg := guardrail.InjectionConfig{
+		Default: guardrail.InjectionThresholds{
+			Block: envFloatOrDefault("INJECTION_BLOCK_THRESHOLD", 0),
+			Flag:  envFloatOrDefault("INJECTION_FLAG_THRESHOLD", 0),
+			Log:   envFloatOrDefault("INJECTION_LOG_THRESHOLD", 0),
+		},
+		JudgeModel: os.Getenv("INJECTION_JUDGE_MODEL"),
+		EscalateAt: envFloatOrDefault("INJECTION_ESCALATE_AT", guardrail.DefaultEscalateAt),
+	}
+	injectionJudgeTimeout := envDurationOrDefault("INJECTION_JUDGE_TIMEOUT", 5*time.Second)
+	injectionConfigPath := os.Getenv("INJECTION_CONFIG")
 
 	// -------------------------------------------------------------------------
 	// Initialize providers
@@ -78,14 +280,63 @@ func main() {
 	// -------------------------------------------------------------------------
 	// Initialize key pools
 	// -------------------------------------------------------------------------
+	keyPoolCfg := make(map[string]resilience.ProviderKeysConfig)
+	if keyPoolConfigPath != "" {
+		cfg, err := resilience.LoadKeyPoolConfig(keyPoolConfigPath)
+		if err != nil {
+			log.Fatalf("Failed to load key pool config: %v", err)
+		}
+		keyPoolCfg = cfg
+	}
+
 	keyPools := make(map[string]*resilience.KeyPool)
-	if len(openaiKeys) > 0 {
-		keyPools["openai"] = resilience.NewKeyPool(openaiKeys)
-		log.Printf("OpenAI key pool: %d keys", len(openaiKeys))
+	var keyReloaders []*resilience.KeyReloader
+	for name, envKeys := range map[string][]string{"openai": openaiKeys, "gemini": geminiKeys} {
+		name, envKeys := name, envKeys
+		pcfg := keyPoolCfg[name]
+		strategy := pcfg.Strategy
+		if strategy == "" {
+			strategy = keyPoolStrategy
+		}
+
+		// loadKeys builds the full key set: config file, env, then key files.
+		path := keyPaths[name]
+		loadKeys := func() ([]resilience.KeyConfig, error) {
+			keys := mergeKeys(pcfg.KeyConfigs(), plainKeys(envKeys))
+			if path == "" {
+				return keys, nil
+			}
+			fileKeys, err := resilience.LoadKeysFromPath(path)
+			if err != nil {
+				return nil, err
+			}
+			return mergeKeys(keys, pcfg.ApplySettings(fileKeys)), nil
+		}
+
+		keys, err := loadKeys()
+		if err != nil {
+			log.Fatalf("Failed to load %s keys: %v", name, err)
+		}
+		if len(keys) == 0 && path == "" {
+			continue
+		}
+
+		pool := resilience.NewKeyPoolWithConfig(strategy, keys)
+		keyPools[name] = pool
+		metrics.RecordKeyReload(name, len(keys), nil)
+		log.Printf("%s key pool: %d keys (strategy=%s)", name, len(keys), strategy)
+
+		if path != "" {
+			keyReloaders = append(keyReloaders, resilience.NewKeyReloader(pool, keyReloadInterval, loadKeys,
+				func(n int, err error) { metrics.RecordKeyReload(name, n, err) }))
+			log.Printf("%s keys watched at %s (every %s)", name, path, keyReloadInterval)
+		}
 	}
-	if len(geminiKeys) > 0 {
-		keyPools["gemini"] = resilience.NewKeyPool(geminiKeys)
-		log.Printf("Gemini key pool: %d keys", len(geminiKeys))
+
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	for _, r := range keyReloaders {
+		go r.Run(reloadCtx)
 	}
 
 	// -------------------------------------------------------------------------
@@ -100,12 +351,47 @@ func main() {
 		"gemini": resilience.NewCircuitBreaker(cbCfg),
 	}
 
+	// -------------------------------------------------------------------------
+	// Initialize concurrency limiters
+	// -------------------------------------------------------------------------
+	var limiters map[string]*resilience.ConcurrencyLimiter
+	if limiterEnabled {
+		limiters = map[string]*resilience.ConcurrencyLimiter{
+			"openai": resilience.NewConcurrencyLimiter(limiterCfg),
+			"gemini": resilience.NewConcurrencyLimiter(limiterCfg),
+		}
+		log.Printf("Adaptive concurrency limits enabled (initial=%d, min=%d, max=%d, queue=%d)",
+			limiterCfg.InitialLimit, limiterCfg.MinLimit, limiterCfg.MaxLimit, limiterCfg.MaxQueue)
+	}
+
+	var globalLimiter *resilience.ConcurrencyLimiter
+	if maxInFlight > 0 {
+		globalLimiter = resilience.NewFixedLimiter(maxInFlight)
+		log.Printf("Global in-flight cap: %d", maxInFlight)
+	}
+
 	// -------------------------------------------------------------------------
 	// Initialize semantic cache
 	// -------------------------------------------------------------------------
+	if os.Getenv("EMBEDDING_API_KEY") != "" {
+		log.Println("WARNING: EMBEDDING_API_KEY is deprecated — embeddings go through the provider key pools; set SEMANTIC_CACHE_ENABLED instead")
+	}
+
+	var embeddingCache *cache.EmbeddingCache
+	if embeddingCacheTTL > 0 {
+		embeddingCache = cache.NewEmbeddingCache(redisAddr, redisPassword, redisDB, embeddingCacheTTL)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := embeddingCache.Ping(ctx); err != nil {
+			log.Printf("WARNING: Redis connection failed: %v (embedding cache disabled)", err)
+			embeddingCache = nil
+		} else {
+			log.Printf("Embedding cache enabled (TTL=%s)", embeddingCacheTTL)
+		}
+		cancel()
+	}
+
 	var semanticCache *cache.SemanticCache
-	if embeddingAPIKey != "" {
-		embedder := cache.NewEmbedder(embeddingAPIKey)
+	if semanticCacheEnabled {
 		vectorStore := cache.NewVectorStore(qdrantURL, qdrantCollection)
 		redisCache := cache.NewRedisCache(redisAddr, redisPassword, redisDB, cacheTTL)
 
@@ -114,12 +400,255 @@ func main() {
 		if err := redisCache.Ping(ctx); err != nil {
 			log.Printf("WARNING: Redis connection failed: %v (cache disabled)", err)
 		} else {
-			semanticCache = cache.NewSemanticCache(embedder, vectorStore, redisCache, float32(similarityThreshold))
-			log.Printf("Semantic cache enabled (threshold=%.2f, TTL=%s)", similarityThreshold, cacheTTL)
+			semanticCache = cache.NewSemanticCache(vectorStore, redisCache, float32(similarityThreshold))
+			log.Printf("Semantic cache enabled (model=%s, threshold=%.2f, TTL=%s)", embeddingModel, similarityThreshold, cacheTTL)
 		}
 		cancel()
 	} else {
-		log.Println("WARNING: EMBEDDING_API_KEY not set — semantic cache disabled")
+		log.Println("Semantic cache disabled (set SEMANTIC_CACHE_ENABLED=true to enable)")
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize price table
+	// -------------------------------------------------------------------------
+	prices := pricing.DefaultTable()
+	if priceTablePath != "" {
+		var err error
+		prices, err = pricing.LoadTable(priceTablePath)
+		if err != nil {
+			log.Fatalf("Failed to load price table: %v", err)
+		}
+		log.Printf("Loaded price table from %s", priceTablePath)
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize model registry and tokenizers
+	// -------------------------------------------------------------------------
+	modelRegistry := models.DefaultRegistry()
+	if modelRegistryPath != "" {
+		var err error
+		modelRegistry, err = models.LoadRegistry(modelRegistryPath)
+		if err != nil {
+			log.Fatalf("Failed to load model registry: %v", err)
+		}
+		log.Printf("Loaded model registry from %s", modelRegistryPath)
+	}
+
+	var tokenizers *tokenizer.Set
+	if tokenizerDir != "" {
+		var err error
+		tokenizers, err = tokenizer.LoadSet(tokenizerDir)
+		if err != nil {
+			log.Fatalf("Failed to load tokenizers: %v", err)
+		}
+		log.Printf("Loaded tokenizer encodings %v from %s", tokenizers.Encodings(), tokenizerDir)
+	}
+
+	if contextOverflow != proxy.ContextReject && contextOverflow != proxy.ContextTruncate {
+		log.Fatalf("Invalid CONTEXT_OVERFLOW %q: must be %q or %q", contextOverflow, proxy.ContextReject, proxy.ContextTruncate)
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize chat session store
+	// -------------------------------------------------------------------------
+	var sessions session.Store
+	var redisSessions *session.RedisStore
+	switch chatSessionStore {
+	case "memory":
+		sessions = session.NewMemoryStore(chatSessionTTL)
+		log.Printf("Chat sessions kept in memory (TTL=%s)", chatSessionTTL)
+	case "redis":
+		key, err := base64.StdEncoding.DecodeString(chatSessionKey)
+		if err != nil {
+			log.Fatalf("Invalid CHAT_SESSION_KEY: %v", err)
+		}
+		redisSessions, err = session.NewRedisStore(redisAddr, redisPassword, redisDB, chatSessionTTL, key)
+		if err != nil {
+			log.Fatalf("Invalid CHAT_SESSION_KEY: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := redisSessions.Ping(ctx); err != nil {
+			log.Printf("WARNING: Redis connection failed: %v (chat sessions fail until it recovers)", err)
+		}
+		cancel()
+		sessions = redisSessions
+		log.Printf("Chat sessions kept in Redis (TTL=%s)", chatSessionTTL)
+	default:
+		log.Fatalf("Invalid CHAT_SESSION_STORE %q: must be memory or redis", chatSessionStore)
+	}
+	if summaryCfg.Model != "" {
+		if summaryCfg.Threshold <= 0 || summaryCfg.Threshold > 1 {
+			log.Fatalf("Invalid SUMMARY_THRESHOLD %v: must be in (0, 1]", summaryCfg.Threshold)
+		}
+		log.Printf("Conversation summaries enabled (model=%s, threshold=%.2f, keep=%d turns)",
+			summaryCfg.Model, summaryCfg.Threshold, summaryCfg.KeepTurns)
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize usage ledger
+	// -------------------------------------------------------------------------
+	var ledgerSinks []ledger.Sink
+	if ledgerPath != "" {
+		sink, err := ledger.NewFileSink(ledgerPath, int64(ledgerMaxSizeMB)*1024*1024, ledgerMaxBackups)
+		if err != nil {
+			log.Fatalf("Failed to open usage ledger: %v", err)
+		}
+		ledgerSinks = append(ledgerSinks, sink)
+	}
+	if ledgerStream != "" {
+		ledgerSinks = append(ledgerSinks, ledger.NewRedisStreamSink(redisAddr, redisPassword, redisDB, ledgerStream, 0))
+	}
+	if ledgerSQLDriver != "" {
+		sink, err := ledger.NewSQLSink(ledgerSQLDriver, ledgerSQLDSN, ledgerSQLTable)
+		if err != nil {
+			log.Fatalf("Failed to open SQL usage ledger: %v", err)
+		}
+		ledgerSinks = append(ledgerSinks, sink)
+	}
+
+	var usageLedger *ledger.Ledger
+	if len(ledgerSinks) > 0 {
+		if ledgerSpillDir == "" {
+			if ledgerPath != "" {
+				ledgerSpillDir = ledgerPath + ".spill"
+			} else {
+				ledgerSpillDir = filepath.Join(os.TempDir(), "llm-proxy-ledger-spill")
+				log.Printf("WARNING: LEDGER_SPILL_DIR not set — spilled usage records are kept in %s", ledgerSpillDir)
+			}
+		}
+		var err error
+		usageLedger, err = ledger.New(ledgerSpillDir, ledgerSinks...)
+		if err != nil {
+			log.Fatalf("Failed to start usage ledger: %v", err)
+		}
+		log.Printf("Usage ledger enabled (%d sinks, spill dir %s)", len(ledgerSinks), ledgerSpillDir)
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize audit log
+	// -------------------------------------------------------------------------
+	var auditSinks []audit.Sink
+	if auditFile != "" {
+		sink, err := audit.NewFileSink(auditFile, int64(auditFileMaxSizeMB)*1024*1024, auditFileMaxBackups)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		auditSinks = append(auditSinks, sink)
+	}
+	if auditStdout {
+		auditSinks = append(auditSinks, audit.NewStdoutSink())
+	}
+	if auditWebhookURL != "" {
+		auditSinks = append(auditSinks, audit.NewWebhookSink(auditWebhookURL, auditWebhookToken))
+	}
+
+	var auditLogger *audit.Logger
+	if len(auditSinks) > 0 {
+		auditLogger = audit.NewLogger(auditPolicy, auditSinks...)
+		log.Printf("Audit log enabled (%d sinks, content=%s, sample=%.2f)",
+			len(auditSinks), auditPolicy.Content, auditPolicy.SampleRate)
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize PII redaction
+	// -------------------------------------------------------------------------
+	var piiScanner *guardrail.PIIScanner
+	if piiConfigPath != "" || piiAction != "" {
+		var err error
+		piiCfg := guardrail.PIIConfig{Action: piiAction, Detectors: piiDetectors}
+		if piiConfigPath != "" {
+			piiCfg, err = guardrail.LoadPIIConfig(piiConfigPath)
+			if err != nil {
+				log.Fatalf("Failed to load PII config: %v", err)
+			}
+		}
+
+		piiScanner, err = guardrail.NewPIIScanner(piiCfg)
+		if err != nil {
+			log.Fatalf("Invalid PII config: %v", err)
+		}
+		log.Println("PII redaction enabled")
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize output policy
+	// -------------------------------------------------------------------------
+	if outputPolicyPath != "" {
+		var err error
+		outputCfg, err = guardrail.LoadOutputConfig(outputPolicyPath)
+		if err != nil {
+			log.Fatalf("Failed to load output policy: %v", err)
+		}
+	}
+
+	var outputPolicy *guardrail.OutputPolicy
+	if outputPolicyPath != "" || len(outputCfg.BannedTerms) > 0 || outputCfg.BlockSecrets || outputCfg.MaxLength > 0 {
+		var err error
+		outputPolicy, err = guardrail.NewOutputPolicy(outputCfg)
+		if err != nil {
+			log.Fatalf("Invalid output policy: %v", err)
+		}
+		log.Println("Output policy enabled")
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize prompt injection screening
+	// -------------------------------------------------------------------------
+	if injectionConfigPath != "" {
+		var err error
+		injectionCfg, err = guardrail.LoadInjectionConfig(injectionConfigPath)
+		if err != nil {
+			log.Fatalf("Failed to load injection config: %v", err)
+		}
+	}
+
+	var injectionDetector *guardrail.InjectionDetector
+	if injectionConfigPath != "" || injectionCfg.Default != (guardrail.InjectionThresholds{}) {
+		injectionDetector = guardrail.NewInjectionDetector(injectionCfg, guardrail.HeuristicClassifier{})
+		log.Printf("Prompt injection screening enabled (block=%.2f, flag=%.2f, log=%.2f)",
+			injectionCfg.Default.Block, injectionCfg.Default.Flag, injectionCfg.Default.Log)
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize tenant quotas
+	// -------------------------------------------------------------------------
+	var quotaEnforcer *quota.Enforcer
+	if tenantLimitsPath != "" {
+		quotaCfg, err := quota.LoadConfig(tenantLimitsPath)
+		if err != nil {
+			log.Fatalf("Failed to load tenant limits: %v", err)
+		}
+		if !authEnabled {
+			log.Println("WARNING: TENANT_LIMITS_CONFIG set without AUTH_ENABLED — callers have no tenant, quotas not enforced")
+		}
+
+		quotaEnforcer = quota.NewEnforcer(redisAddr, redisPassword, redisDB, quotaCfg)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := quotaEnforcer.Ping(ctx); err != nil {
+			log.Printf("WARNING: Redis connection failed: %v (quotas fail open until it recovers)", err)
+		}
+		cancel()
+		log.Printf("Tenant quotas enabled (%d tenants configured)", len(quotaCfg.Tenants))
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize batch job store
+	// -------------------------------------------------------------------------
+	if batchDir != "" {
+		store, err := batch.NewStore(batchDir)
+		if err != nil {
+			log.Fatalf("Failed to open batch job store: %v", err)
+		}
+		batchCfg.Store = store
+		if batchCfg.Workers <= 0 {
+			log.Fatalf("Invalid BATCH_WORKERS %d: must be positive", batchCfg.Workers)
+		}
+		for _, name := range batchCfg.NativeProviders {
+			if _, ok := providers[name].(provider.Batcher); !ok {
+				log.Fatalf("Invalid BATCH_NATIVE_PROVIDERS entry %q: provider has no native batch API", name)
+			}
+		}
+		log.Printf("Batch jobs enabled (dir=%s, workers=%d, native=%v)", batchDir, batchCfg.Workers, batchCfg.NativeProviders)
 	}
 
 	// -------------------------------------------------------------------------
@@ -131,6 +660,29 @@ func main() {
 		MaxDelay:   30 * time.Second,
 	}
 
+	if hedgeCfg.Enabled {
+		log.Printf("Request hedging enabled (p%.0f, fallback=%s, budget=%.1f%%)",
+			hedgeCfg.Percentile*100, hedgeCfg.Delay, hedgeCfg.MaxPercent)
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize virtual keys
+	// -------------------------------------------------------------------------
+	var keyStore *auth.Store
+	if authEnabled {
+		if virtualKeysFile != "" {
+			var err error
+			keyStore, err = auth.LoadStore(virtualKeysFile)
+			if err != nil {
+				log.Fatalf("Failed to load virtual keys: %v", err)
+			}
+		} else {
+			keyStore = auth.NewStore()
+			log.Println("WARNING: VIRTUAL_KEYS_FILE not set — virtual keys are lost on restart")
+		}
+		batchCfg.Keys = keyStore
+	}
+
 	// -------------------------------------------------------------------------
 	// Create gRPC handler
 	// -------------------------------------------------------------------------
@@ -139,18 +691,86 @@ func main() {
 		KeyPools:        keyPools,
 		CircuitBreakers: circuitBreakers,
 		SemanticCache:   semanticCache,
+		EmbeddingCache:  embeddingCache,
 		RetryConfig:     retryCfg,
+		HedgeConfig:     hedgeCfg,
+		Limiters:        limiters,
+		GlobalLimiter:   globalLimiter,
+		Quota:           quotaEnforcer,
+		Prices:          prices,
+		Models:          modelRegistry,
+		Tokenizers:      tokenizers,
+		ContextOverflow: contextOverflow,
+		Ledger:          usageLedger,
+		Audit:           auditLogger,
+		PII:             piiScanner,
+		Output:          outputPolicy,
+		Injection:       injectionDetector,
+		Sessions:        sessions,
+		Summary:         summaryCfg,
+		Batch:           batchCfg,
 		RequestTimeout:  requestTimeout,
 	})
 
+	// The semantic cache embeds prompts through the handler, sharing its key
+	// pools, resilience stack and embedding cache.
+	if semanticCache != nil {
+		semanticCache.UseEmbedder(handler.EmbedText(embeddingModel))
+	}
+
+	// Provider model lists are fetched through the handler's key pools and
+	// merged into the registry.
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	if modelRefreshInterval > 0 {
+		go handler.RunModelRefresh(refreshCtx, modelRefreshInterval)
+		log.Printf("Model registry refreshed from providers every %s", modelRefreshInterval)
+	}
+
+	// Batch jobs run through the handler at low priority, resuming those a
+	// stopped replica left unfinished.
+	batchCtx, stopBatches := context.WithCancel(context.Background())
+	defer stopBatches()
+	batchesDone := make(chan struct{})
+	go func() {
+		handler.RunBatches(batchCtx)
+		close(batchesDone)
+	}()
+
+	// The judge model is called through the handler's own providers and
+	// key pools.
+	if injectionDetector != nil && injectionDetector.JudgeModel() != "" {
+		judgeModel := injectionDetector.JudgeModel()
+		injectionDetector.Use(guardrail.NewJudgeClassifier(handler.Judge(judgeModel), injectionJudgeTimeout))
+		log.Printf("Prompt injection judge enabled (model=%s)", judgeModel)
+	}
+
 	// -------------------------------------------------------------------------
 	// Start gRPC server
 	// -------------------------------------------------------------------------
-	grpcServer := grpc.NewServer(
-		grpc.MaxRecvMsgSize(4*1024*1024),    // 4MB
-		grpc.MaxSendMsgSize(16*1024*1024),   // 16MB
-	)
+	serverOpts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxRequestSizeMB << 20), // Bounds inline content parts
+		grpc.MaxSendMsgSize(16*1024*1024),           // 16MB
+	}
+
+	if authEnabled {
+		if adminToken == "" {
+			log.Println("WARNING: ADMIN_TOKEN not set — AdminService is disabled")
+		}
+
+		authenticator := auth.NewAuthenticator(keyStore, adminToken)
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(authenticator.UnaryInterceptor()),
+			grpc.ChainStreamInterceptor(authenticator.StreamInterceptor()),
+		)
+		log.Printf("Client authentication enabled (%d virtual keys)", len(keyStore.List("")))
+	}
+
+	grpcServer := grpc.NewServer(serverOpts...)
 	pb.RegisterInferenceServiceServer(grpcServer, handler)
+	if keyStore != nil {
+		pb.RegisterAdminServiceServer(grpcServer, auth.NewAdminServer(keyStore))
+	}
 	reflection.Register(grpcServer) // Enable gRPC reflection for grpcurl
 
 	grpcLis, err := net.Listen("tcp", ":"+grpcPort)
@@ -174,6 +794,25 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, "ok")
 	})
+	keysAdmin := admin.NewKeysHandler(keyPools, adminToken)
+	metricsMux.Handle("/admin/keys", keysAdmin)
+	metricsMux.Handle("/admin/keys/", keysAdmin)
+
+	metrics.RegisterKeyHealth(func() []metrics.KeyHealthSample {
+		var samples []metrics.KeyHealthSample
+		for name, kp := range keyPools {
+			for _, kh := range kp.Health() {
+				samples = append(samples, metrics.KeyHealthSample{
+					Provider:    name,
+					Fingerprint: kh.Fingerprint,
+					Label:       kh.Label,
+					Status:      kh.Status,
+					Reason:      kh.Reason,
+				})
+			}
+		}
+		return samples
+	})
 
 	metricsServer := &http.Server{
 		Addr:         ":" + metricsPort,
@@ -198,10 +837,31 @@ func main() {
 	sig := <-sigCh
 	log.Printf("Received signal %v, shutting down...", sig)
 
+	// Stop key reloaders and model refreshes
+	stopReload()
+	stopRefresh()
+	stopBatches()
+
 	// Gracefully stop gRPC server
 	grpcServer.GracefulStop()
 	log.Println("gRPC server stopped")
 
+	// Batch items in flight are abandoned and run again after a restart
+	<-batchesDone
+
+	// Flush the usage ledger once no more requests can complete
+	if usageLedger != nil {
+		if err := usageLedger.Close(); err != nil {
+			log.Printf("Usage ledger close error: %v", err)
+		}
+		log.Println("Usage ledger flushed")
+	}
+	if auditLogger != nil {
+		if err := auditLogger.Close(); err != nil {
+			log.Printf("Audit log close error: %v", err)
+		}
+	}
+
 	// Shut down metrics server
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
@@ -210,6 +870,13 @@ func main() {
 	}
 	log.Println("Metrics server stopped")
 
+	if quotaEnforcer != nil {
+		quotaEnforcer.Close()
+	}
+	if redisSessions != nil {
+		redisSessions.Close()
+	}
+
 	log.Println("LLM Inference Proxy shut down successfully")
 }
 
@@ -233,6 +900,15 @@ func envIntOrDefault(key string, defaultVal int) int {
 	return defaultVal
 }
 
+func envBoolOrDefault(key string, defaultVal bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
+
 func envFloatOrDefault(key string, defaultVal float64) float64 {
 	if v := os.Getenv(key); v != "" {
 		if f, err := strconv.ParseFloat(v, 64); err == nil {
@@ -251,6 +927,30 @@ func envDurationOrDefault(key string, defaultVal time.Duration) time.Duration {
 	return defaultVal
 }
 
+// plainKeys wraps raw keys in default KeyConfigs.
+func plainKeys(keys []string) []resilience.KeyConfig {
+	cfgs := make([]resilience.KeyConfig, len(keys))
+	for i, k := range keys {
+		cfgs[i] = resilience.KeyConfig{Key: k}
+	}
+	return cfgs
+}
+
+// mergeKeys appends extra keys to base, skipping any key already present.
+func mergeKeys(base, extra []resilience.KeyConfig) []resilience.KeyConfig {
+	seen := make(map[string]bool, len(base))
+	merged := make([]resilience.KeyConfig, 0, len(base)+len(extra))
+	for _, keys := range [][]resilience.KeyConfig{base, extra} {
+		for _, k := range keys {
+			if !seen[k.Key] {
+				merged = append(merged, k)
+				seen[k.Key] = true
+			}
+		}
+	}
+	return merged
+}
+
 func splitKeys(s string) []string {
 	if s == "" {
 		return nil
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/ledger"
+)
+
+const usageHelp = `Usage: llm-proxy usage export [flags]
+
+Aggregates the usage ledger by tenant, model and UTC day for chargeback.
+
+Flags:
+`
+
+// runUsage implements the "usage" subcommand and returns the exit code.
+func runUsage(args []string) int {
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Fprint(os.Stderr, usageHelp)
+		fmt.Fprintln(os.Stderr, "  (run \"llm-proxy usage export -h\" for flags)")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("usage export", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usageHelp)
+		fs.PrintDefaults()
+	}
+	path := fs.String("ledger", envOrDefault("LEDGER_PATH", ""), "ledger file (rotated files next to it are included)")
+	format := fs.String("format", "csv", "output format: csv or json")
+	from := fs.String("from", "", "first day to include, YYYY-MM-DD (UTC)")
+	to := fs.String("to", "", "last day to include, YYYY-MM-DD (UTC)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "usage export: -ledger or LEDGER_PATH is required")
+		return 2
+	}
+
+	var start, end time.Time
+	var err error
+	if *from != "" {
+		if start, err = time.Parse("2006-01-02", *from); err != nil {
+			fmt.Fprintf(os.Stderr, "usage export: -from: %v\n", err)
+			return 2
+		}
+	}
+	if *to != "" {
+		if end, err = time.Parse("2006-01-02", *to); err != nil {
+			fmt.Fprintf(os.Stderr, "usage export: -to: %v\n", err)
+			return 2
+		}
+		end = end.AddDate(0, 0, 1) // Inclusive of the whole day
+	}
+
+	rows, err := ledger.Aggregate(*path, start, end)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "usage export: %v\n", err)
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "usage export: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "csv":
+		err = ledger.WriteCSV(w, rows)
+	case "json":
+		err = ledger.WriteJSON(w, rows)
+	default:
+		fmt.Fprintf(os.Stderr, "usage export: unknown format %q\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "usage export: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+// Package admin provides operator HTTP endpoints served on the metrics port.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+)
+
+// KeysHandler serves API key health and manual re-enable of quarantined keys:
+//
+//	GET  /admin/keys                                         — health of every key by provider
+//	POST /admin/keys/enable?provider=openai&key=<fingerprint|label> — return a key to rotation
+type KeysHandler struct {
+	pools map[string]*resilience.KeyPool
+	token string
+}
+
+// NewKeysHandler creates the key admin handler. Requests must carry
+// "Authorization: Bearer <token>"; if token is empty, every request is
+// refused.
+func NewKeysHandler(pools map[string]*resilience.KeyPool, token string) *KeysHandler {
+	return &KeysHandler{pools: pools, token: token}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *KeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token == "" {
+		http.Error(w, "admin token not configured", http.StatusForbidden)
+		return
+	}
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/admin/keys":
+		h.list(w)
+	case r.Method == http.MethodPost && r.URL.Path == "/admin/keys/enable":
+		h.enable(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *KeysHandler) list(w http.ResponseWriter) {
+	out := make(map[string][]resilience.KeyHealth, len(h.pools))
+	for name, kp := range h.pools {
+		out[name] = kp.Health()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *KeysHandler) enable(w http.ResponseWriter, r *http.Request) {
+	providerName := r.URL.Query().Get("provider")
+	id := r.URL.Query().Get("key")
+
+	kp, ok := h.pools[providerName]
+	if !ok || id == "" {
+		http.Error(w, "provider and key are required", http.StatusBadRequest)
+		return
+	}
+	if !kp.Enable(id) {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"provider": providerName, "key": id, "status": "enabled"})
+}
+
+func (h *KeysHandler) authorized(r *http.Request) bool {
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,44 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+)
+
+func TestKeysHandlerAuth(t *testing.T) {
+	pools := map[string]*resilience.KeyPool{"openai": resilience.NewKeyPool([]string{"sk-a"})}
+	enable := "/admin/keys/enable?provider=openai&key=" + resilience.Fingerprint("sk-a")
+
+	tests := []struct {
+		name   string
+		token  string // Configured admin token
+		method string
+		target string
+		auth   string // Authorization header
+		want   int
+	}{
+		{"no token configured: list", "", http.MethodGet, "/admin/keys", "", http.StatusForbidden},
+		{"no token configured: enable", "", http.MethodPost, enable, "", http.StatusForbidden},
+		{"no token configured: any bearer", "", http.MethodGet, "/admin/keys", "Bearer ", http.StatusForbidden},
+		{"missing bearer", "secret", http.MethodGet, "/admin/keys", "", http.StatusUnauthorized},
+		{"wrong bearer", "secret", http.MethodGet, "/admin/keys", "Bearer nope", http.StatusUnauthorized},
+		{"list", "secret", http.MethodGet, "/admin/keys", "Bearer secret", http.StatusOK},
+		{"enable", "secret", http.MethodPost, enable, "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			NewKeysHandler(pools, tt.token).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.target, w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+// Package audit records what was sent to and received from providers, for
+// compliance. Events are processed and written off the request path.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math/rand"
+	"time"
+	"unicode/utf8"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+)
+
+// Content modes control how prompts and responses are recorded.
+const (
+	ContentFull     = "full"     // Text and its hash
+	ContentHash     = "hash"     // SHA-256 only
+	ContentMetadata = "metadata" // Neither text nor hash
+)
+
+// redactedValue replaces the value of a redacted field.
+const redactedValue = "[REDACTED]"
+
+// Event is one audited request.
+type Event struct {
+	ID             string    `json:"id"`
+	Time           time.Time `json:"time"`
+	Method         string    `json:"method"` // Infer, InferStream, Chat, Embed, SubmitBatch, Judge, Summarize or SemanticCache
+	Tenant         string    `json:"tenant,omitempty"`
+	Team           string    `json:"team,omitempty"`
+	KeyID          string    `json:"key_id,omitempty"` // Virtual key id, never a secret
+	Model          string    `json:"model"`
+	Provider       string    `json:"provider,omitempty"`
+	Prompt         string    `json:"prompt,omitempty"`
+	PromptSHA256   string    `json:"prompt_sha256,omitempty"`
+	Response       string    `json:"response,omitempty"`
+	ResponseSHA256 string    `json:"response_sha256,omitempty"`
+	Truncated      bool      `json:"truncated,omitempty"`
+	PromptTokens   int32     `json:"prompt_tokens"`
+	OutputTokens   int32     `json:"output_tokens"`
+	CostUSD        float64   `json:"cost_usd"`
+	CacheHit       bool      `json:"cache_hit"`
+	LatencyMS      float64   `json:"latency_ms"`
+	Outcome        string    `json:"outcome"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Policy controls what an event retains.
+type Policy struct {
+	Content       string   // ContentFull, ContentHash or ContentMetadata
+	SampleRate    float64  // Fraction of requests audited, 0–1
+	MaxFieldBytes int      // Truncate prompt / response beyond this size, 0 = no limit
+	RedactFields  []string // Event fields to blank, by JSON name (e.g. "tenant", "error")
+}
+
+// DefaultPolicy records hashes of every request.
+func DefaultPolicy() Policy {
+	return Policy{
+		Content:       ContentHash,
+		SampleRate:    1,
+		MaxFieldBytes: 8 * 1024,
+	}
+}
+
+// Sink writes batches of events.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, events []Event) error
+	Close() error
+}
+
+const (
+	queueSize     = 4096
+	batchSize     = 128
+	flushInterval = time.Second
+	writeTimeout  = 10 * time.Second
+)
+
+// Logger applies the policy and writes events to every sink in the
+// background. When the queue is full, events are dropped rather than
+// slowing requests down.
+type Logger struct {
+	policy Policy
+	sinks  []Sink
+	ch     chan Event
+	done   chan struct{}
+}
+
+// NewLogger starts an audit logger.
+func NewLogger(policy Policy, sinks ...Sink) *Logger {
+	l := &Logger{
+		policy: policy,
+		sinks:  sinks,
+		ch:     make(chan Event, queueSize),
+		done:   make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Sampled reports whether the next request should be audited. Callers can
+// skip building the event when it returns false.
+func (l *Logger) Sampled() bool {
+	return l.policy.SampleRate >= 1 || rand.Float64() < l.policy.SampleRate
+}
+
+// Log queues an event without blocking.
+func (l *Logger) Log(e Event) {
+	select {
+	case l.ch <- e:
+	default:
+		metrics.AuditDroppedTotal.Inc()
+	}
+}
+
+// Close flushes queued events and closes the sinks. Log must not be called
+// afterward.
+func (l *Logger) Close() error {
+	close(l.ch)
+	<-l.done
+
+	var firstErr error
+	for _, s := range l.sinks {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, batchSize)
+	for {
+		select {
+		case e, ok := <-l.ch:
+			if !ok {
+				l.flush(batch)
+				return
+			}
+			batch = append(batch, l.apply(e))
+			if len(batch) >= batchSize {
+				l.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			l.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (l *Logger) flush(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	for _, s := range l.sinks {
+		if err := s.Write(ctx, batch); err != nil {
+			metrics.AuditWriteErrorsTotal.WithLabelValues(s.Name()).Inc()
+			log.Printf("[audit] %s: failed to write %d events: %v", s.Name(), len(batch), err)
+			continue
+		}
+		metrics.AuditEventsTotal.WithLabelValues(s.Name()).Add(float64(len(batch)))
+	}
+}
+
+// apply enforces the content mode, redaction and truncation.
+func (l *Logger) apply(e Event) Event {
+	switch l.policy.Content {
+	case ContentFull:
+		e.PromptSHA256, e.ResponseSHA256 = hash(e.Prompt), hash(e.Response)
+	case ContentMetadata:
+		e.Prompt, e.Response = "", ""
+	default:
+		e.PromptSHA256, e.ResponseSHA256 = hash(e.Prompt), hash(e.Response)
+		e.Prompt, e.Response = "", ""
+	}
+
+	for _, name := range l.policy.RedactFields {
+		if f := e.field(name); f != nil && *f != "" {
+			*f = redactedValue
+		}
+	}
+
+	if max := l.policy.MaxFieldBytes; max > 0 {
+		for _, f := range []*string{&e.Prompt, &e.Response} {
+			if len(*f) > max {
+				*f = truncate(*f, max)
+				e.Truncated = true
+			}
+		}
+	}
+	return e
+}
+
+// field returns a pointer to a redactable string field by JSON name.
+func (e *Event) field(name string) *string {
+	switch name {
+	case "tenant":
+		return &e.Tenant
+	case "team":
+		return &e.Team
+	case "key_id":
+		return &e.KeyID
+	case "model":
+		return &e.Model
+	case "provider":
+		return &e.Provider
+	case "prompt":
+		return &e.Prompt
+	case "response":
+		return &e.Response
+	case "error":
+		return &e.Error
+	default:
+		return nil
+	}
+}
+
+func hash(s string) string {
+	if s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncate cuts s to at most max bytes without splitting a UTF-8 sequence.
+func truncate(s string, max int) string {
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memorySink keeps the events written to it.
+type memorySink struct {
+	mu     sync.Mutex
+	events []Event
+	closed bool
+}
+
+func (s *memorySink) Name() string { return "memory" }
+
+func (s *memorySink) Write(ctx context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestApply(t *testing.T) {
+	in := Event{Method: "Chat", Tenant: "acme", Model: "gpt-4o", Prompt: "héllo world", Response: "hi", Error: "boom"}
+	tests := []struct {
+		name         string
+		policy       Policy
+		wantPrompt   string
+		wantHash     bool
+		wantTenant   string
+		wantError    string
+		wantTruncate bool
+	}{
+		{"full", Policy{Content: ContentFull}, "héllo world", true, "acme", "boom", false},
+		{"hash", Policy{Content: ContentHash}, "", true, "acme", "boom", false},
+		{"metadata", Policy{Content: ContentMetadata}, "", false, "acme", "boom", false},
+		{"redacted fields", Policy{Content: ContentFull, RedactFields: []string{"tenant", "error", "unknown"}}, "héllo world", true, redactedValue, redactedValue, false},
+		{"truncated without splitting a rune", Policy{Content: ContentFull, MaxFieldBytes: 2}, "h", true, "acme", "boom", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Logger{policy: tt.policy}
+			got := l.apply(in)
+			if got.Prompt != tt.wantPrompt {
+				t.Errorf("prompt = %q, want %q", got.Prompt, tt.wantPrompt)
+			}
+			if hashed := got.PromptSHA256 == hash(in.Prompt); hashed != tt.wantHash {
+				t.Errorf("prompt hashed = %v, want %v", hashed, tt.wantHash)
+			}
+			if got.Tenant != tt.wantTenant || got.Error != tt.wantError {
+				t.Errorf("tenant, error = %q, %q, want %q, %q", got.Tenant, got.Error, tt.wantTenant, tt.wantError)
+			}
+			if got.Truncated != tt.wantTruncate {
+				t.Errorf("truncated = %v, want %v", got.Truncated, tt.wantTruncate)
+			}
+			if got.Method != in.Method {
+				t.Errorf("method = %q, want %q", got.Method, in.Method)
+			}
+		})
+	}
+}
+
+func TestLoggerClose(t *testing.T) {
+	sink := &memorySink{}
+	l := NewLogger(DefaultPolicy(), sink)
+	for _, method := range []string{"Infer", "Summarize", "SubmitBatch"} {
+		l.Log(Event{Method: method, Prompt: strings.Repeat("x", 10)})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.events) != 3 || !sink.closed {
+		t.Fatalf("sink got %d events, closed %v, want 3 events and closed", len(sink.events), sink.closed)
+	}
+	for _, e := range sink.events {
+		if e.Prompt != "" || e.PromptSHA256 == "" {
+			t.Errorf("%s event prompt %q, hash %q, want only the hash", e.Method, e.Prompt, e.PromptSHA256)
+		}
+	}
+}
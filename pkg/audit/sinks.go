@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/logfile"
+)
+
+// encodeLines encodes events as JSON lines.
+func encodeLines(events []Event) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return nil, fmt.Errorf("audit: encode: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// ---------------------------------------------------------------------------
+// File sink
+// ---------------------------------------------------------------------------
+
+// FileSink appends events as JSON lines to a rotating file.
+type FileSink struct {
+	w *logfile.Writer
+}
+
+// NewFileSink opens a JSONL audit file, rotated at maxBytes and keeping
+// maxBackups rotated files (0 = all).
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	w, err := logfile.Open(path, maxBytes, maxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	return &FileSink{w: w}, nil
+}
+
+func (s *FileSink) Name() string { return "file" }
+
+func (s *FileSink) Write(ctx context.Context, events []Event) error {
+	data, err := encodeLines(events)
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(data); err != nil {
+		return fmt.Errorf("audit: write: %w", err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error { return s.w.Close() }
+
+// ---------------------------------------------------------------------------
+// Stdout sink
+// ---------------------------------------------------------------------------
+
+// StdoutSink writes events as JSON lines to stdout, for collection by the
+// container runtime's log pipeline.
+type StdoutSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutSink creates a stdout sink.
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{w: os.Stdout}
+}
+
+func (s *StdoutSink) Name() string { return "stdout" }
+
+func (s *StdoutSink) Write(ctx context.Context, events []Event) error {
+	data, err := encodeLines(events)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(data)
+	return err
+}
+
+func (s *StdoutSink) Close() error { return nil }
+
+// ---------------------------------------------------------------------------
+// Webhook sink
+// ---------------------------------------------------------------------------
+
+// WebhookSink POSTs each batch as a JSON array to an HTTP endpoint.
+type WebhookSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewWebhookSink creates a webhook sink. If token is set it is sent as a
+// bearer token.
+func NewWebhookSink(url, token string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Write(ctx context.Context, events []Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("audit: encode: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("audit: create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("audit: post: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit: webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *WebhookSink) Close() error { return nil }
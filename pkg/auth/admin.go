@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// AdminServer implements the AdminService RPCs for managing virtual keys.
+type AdminServer struct {
+	pb.UnimplementedAdminServiceServer
+
+	store *Store
+}
+
+// NewAdminServer creates an admin server backed by store.
+func NewAdminServer(store *Store) *AdminServer {
+	return &AdminServer{store: store}
+}
+
+// CreateVirtualKey issues a new key and returns its secret once.
+func (s *AdminServer) CreateVirtualKey(ctx context.Context, req *pb.CreateVirtualKeyRequest) (*pb.CreateVirtualKeyResponse, error) {
+	if req.GetTenant() == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant is required")
+	}
+
+	vk, secret, err := s.store.Create(req.GetTenant(), req.GetTeam(), req.GetAllowedModels(), req.GetAllowedProviders())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.CreateVirtualKeyResponse{Key: toProto(vk), Secret: secret}, nil
+}
+
+// ListVirtualKeys returns key metadata, never secrets or hashes.
+func (s *AdminServer) ListVirtualKeys(ctx context.Context, req *pb.ListVirtualKeysRequest) (*pb.ListVirtualKeysResponse, error) {
+	keys := s.store.List(req.GetTenant())
+	resp := &pb.ListVirtualKeysResponse{Keys: make([]*pb.VirtualKey, len(keys))}
+	for i, vk := range keys {
+		resp.Keys[i] = toProto(vk)
+	}
+	return resp, nil
+}
+
+// RevokeVirtualKey deletes a key; callers using it are rejected immediately.
+func (s *AdminServer) RevokeVirtualKey(ctx context.Context, req *pb.RevokeVirtualKeyRequest) (*pb.RevokeVirtualKeyResponse, error) {
+	err := s.store.Revoke(req.GetId())
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return &pb.RevokeVirtualKeyResponse{Revoked: false}, nil
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.RevokeVirtualKeyResponse{Revoked: true}, nil
+}
+
+func toProto(vk VirtualKey) *pb.VirtualKey {
+	return &pb.VirtualKey{
+		Id:               vk.ID,
+		Tenant:           vk.Tenant,
+		Team:             vk.Team,
+		AllowedModels:    vk.AllowedModels,
+		AllowedProviders: vk.AllowedProviders,
+		CreatedAt:        vk.CreatedAt.Unix(),
+	}
+}
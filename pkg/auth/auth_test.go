@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		list []string
+		v    string
+		want bool
+	}{
+		{nil, "gpt-4o", true},
+		{[]string{"gpt-4o"}, "gpt-4o", true},
+		{[]string{"gpt-4o"}, "gpt-4o-mini", false},
+		{[]string{"gpt-4*"}, "gpt-4o-mini", true},
+		{[]string{"gpt-4*"}, "gemini-1.5-pro", false},
+		{[]string{"gemini-*", "gpt-4o"}, "gpt-4o", true},
+	}
+	for _, tt := range tests {
+		if got := allowed(tt.list, tt.v); got != tt.want {
+			t.Errorf("allowed(%q, %q) = %v, want %v", tt.list, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, err := LoadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vk, secret, err := s.Create("acme", "search", []string{"gpt-4*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vk.Hash == secret || vk.Hash != hashSecret(secret) {
+		t.Errorf("stored hash %q, want the hash of the secret, not the secret", vk.Hash)
+	}
+	if _, _, err := s.Create("", "", nil, nil); err == nil {
+		t.Error("Create without a tenant succeeded, want an error")
+	}
+
+	// The key survives a reload from disk.
+	s, err = LoadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, ok := s.Authenticate(secret)
+	if !ok || id.KeyID != vk.ID || id.Tenant != "acme" || !id.AllowsModel("gpt-4o") || id.AllowsModel("gemini-1.5-pro") {
+		t.Fatalf("Authenticate after reload = %+v, %v, want key %s for tenant acme", id, ok, vk.ID)
+	}
+	if id, ok := s.Lookup(vk.ID); !ok || id.Tenant != "acme" {
+		t.Errorf("Lookup(%s) = %+v, %v, want the key", vk.ID, id, ok)
+	}
+	if _, ok := s.Authenticate(secret + "x"); ok {
+		t.Error("Authenticate accepted a wrong secret")
+	}
+
+	if err := s.Revoke(vk.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Authenticate(secret); ok {
+		t.Error("Authenticate accepted a revoked key")
+	}
+	if _, ok := s.Lookup(vk.ID); ok {
+		t.Error("Lookup found a revoked key")
+	}
+	if err := s.Revoke(vk.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Revoke twice = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := NewStore()
+	_, secret, err := s.Create("acme", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bearer := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+	const infer = "/inferenceproxy.InferenceService/Infer"
+	const admin = "/inferenceproxy.AdminService/ListVirtualKeys"
+
+	tests := []struct {
+		name       string
+		adminToken string
+		ctx        context.Context
+		method     string
+		want       codes.Code
+		wantTenant string
+	}{
+		{"virtual key", "", bearer(secret), infer, codes.OK, "acme"},
+		{"no token", "", context.Background(), infer, codes.Unauthenticated, ""},
+		{"unknown key", "", bearer("sk-proxy-nope"), infer, codes.Unauthenticated, ""},
+		{"reflection is public", "", context.Background(), "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", codes.OK, ""},
+		{"admin token", "root", bearer("root"), admin, codes.OK, ""},
+		{"virtual key on the admin API", "root", bearer(secret), admin, codes.Unauthenticated, ""},
+		{"admin API disabled", "", bearer("root"), admin, codes.PermissionDenied, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuthenticator(s, tt.adminToken)
+			ctx, err := a.authenticate(tt.ctx, tt.method)
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("authenticate(%s) error = %v, want %s", tt.method, err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			id, ok := FromContext(ctx)
+			if tt.wantTenant == "" {
+				if ok {
+					t.Errorf("identity %+v attached, want none", id)
+				}
+				return
+			}
+			if !ok || id.Tenant != tt.wantTenant {
+				t.Errorf("identity = %+v, want tenant %s", id, tt.wantTenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// adminServicePrefix identifies admin RPCs, which take the admin token
+// instead of a virtual key.
+const adminServicePrefix = "/inferenceproxy.AdminService/"
+
+// publicPrefixes are RPCs that need no credentials (reflection for grpcurl).
+var publicPrefixes = []string{"/grpc.reflection.", "/grpc.health."}
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	KeyID            string
+	Tenant           string
+	Team             string
+	AllowedModels    []string
+	AllowedProviders []string
+}
+
+// AllowsModel reports whether the caller may use model. An entry ending in
+// "*" matches by prefix.
+func (id *Identity) AllowsModel(model string) bool {
+	return allowed(id.AllowedModels, model)
+}
+
+// AllowsProvider reports whether the caller may use the provider.
+func (id *Identity) AllowsProvider(providerName string) bool {
+	return allowed(id.AllowedProviders, providerName)
+}
+
+func allowed(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, pattern := range list {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(v, prefix) {
+				return true
+			}
+		} else if pattern == v {
+			return true
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+// NewContext returns a context carrying the caller identity.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the caller identity, if the request was authenticated.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// Authenticator validates the credentials on incoming RPCs.
+type Authenticator struct {
+	store      *Store
+	adminToken string
+}
+
+// NewAuthenticator creates an authenticator. Inference RPCs must present a
+// virtual key from store; admin RPCs must present adminToken, and are
+// rejected outright if adminToken is empty.
+func NewAuthenticator(store *Store, adminToken string) *Authenticator {
+	return &Authenticator{store: store, adminToken: adminToken}
+}
+
+// UnaryInterceptor authenticates unary RPCs.
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor authenticates streaming RPCs.
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate checks the bearer token for method and attaches the caller
+// identity to the context.
+func (a *Authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	for _, prefix := range publicPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return ctx, nil
+		}
+	}
+
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "auth: missing bearer token")
+	}
+
+	if strings.HasPrefix(method, adminServicePrefix) {
+		if a.adminToken == "" {
+			return nil, status.Error(codes.PermissionDenied, "auth: admin API is disabled")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "auth: invalid admin token")
+		}
+		return ctx, nil
+	}
+
+	id, ok := a.store.Authenticate(token)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "auth: invalid API key")
+	}
+	return NewContext(ctx, id), nil
+}
+
+// bearerToken extracts the token from the "authorization" metadata.
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, v := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(v, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+// identityStream overrides the stream context with the authenticated one.
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,244 @@
+// Package auth authenticates callers with proxy-issued virtual keys so that
+// applications never hold the real upstream provider keys.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretPrefix marks proxy-issued secrets so they are easy to tell apart
+// from provider keys in logs and config.
+const secretPrefix = "sk-proxy-"
+
+// ErrNotFound is returned when no virtual key has the given id.
+var ErrNotFound = errors.New("auth: virtual key not found")
+
+// VirtualKey is a stored client credential. The secret itself is never
+// kept — only its SHA-256 hash.
+type VirtualKey struct {
+	ID               string    `json:"id"`
+	Hash             string    `json:"hash"` // hex SHA-256 of the secret
+	Tenant           string    `json:"tenant"`
+	Team             string    `json:"team,omitempty"`
+	AllowedModels    []string  `json:"allowed_models,omitempty"`    // Empty = all; "gpt-4*" matches a prefix
+	AllowedProviders []string  `json:"allowed_providers,omitempty"` // Empty = all
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Identity returns the caller identity carried by this key.
+func (vk VirtualKey) Identity() *Identity {
+	return &Identity{
+		KeyID:            vk.ID,
+		Tenant:           vk.Tenant,
+		Team:             vk.Team,
+		AllowedModels:    vk.AllowedModels,
+		AllowedProviders: vk.AllowedProviders,
+	}
+}
+
+// Store holds virtual keys indexed by hash. If path is set, every change
+// is written back to it so keys survive restarts.
+type Store struct {
+	mu     sync.RWMutex
+	byHash map[string]*VirtualKey
+	byID   map[string]*VirtualKey
+	path   string
+}
+
+// NewStore creates an in-memory store.
+func NewStore() *Store {
+	return &Store{
+		byHash: make(map[string]*VirtualKey),
+		byID:   make(map[string]*VirtualKey),
+	}
+}
+
+// LoadStore opens a file-backed store, creating it on first write if the
+// file does not exist yet.
+func LoadStore(path string) (*Store, error) {
+	s := NewStore()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: read %s: %w", path, err)
+	}
+
+	var keys []*VirtualKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("auth: parse %s: %w", path, err)
+	}
+	for _, vk := range keys {
+		s.byHash[vk.Hash] = vk
+		s.byID[vk.ID] = vk
+	}
+	return s, nil
+}
+
+// Authenticate looks up the key for a bearer secret.
+func (s *Store) Authenticate(secret string) (*Identity, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vk, ok := s.byHash[hashSecret(secret)]
+	if !ok {
+		return nil, false
+	}
+	return vk.Identity(), true
+}
+
+// Lookup returns the identity of the key with the given id, if it has not
+// been revoked.
+func (s *Store) Lookup(id string) (*Identity, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vk, ok := s.byID[id]
+	if !ok {
+		return nil, false
+	}
+	return vk.Identity(), true
+}
+
+// Create issues a new virtual key. The returned secret is the only copy;
+// it cannot be recovered from the store.
+func (s *Store) Create(tenant, team string, allowedModels, allowedProviders []string) (VirtualKey, string, error) {
+	if tenant == "" {
+		return VirtualKey{}, "", errors.New("auth: tenant is required")
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		return VirtualKey{}, "", err
+	}
+	raw, err := randomHex(24)
+	if err != nil {
+		return VirtualKey{}, "", err
+	}
+	secret := secretPrefix + raw
+
+	vk := &VirtualKey{
+		ID:               "vk_" + id,
+		Hash:             hashSecret(secret),
+		Tenant:           tenant,
+		Team:             team,
+		AllowedModels:    allowedModels,
+		AllowedProviders: allowedProviders,
+		CreatedAt:        time.Now().UTC().Truncate(time.Second),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byHash[vk.Hash] = vk
+	s.byID[vk.ID] = vk
+	if err := s.saveLocked(); err != nil {
+		delete(s.byHash, vk.Hash)
+		delete(s.byID, vk.ID)
+		return VirtualKey{}, "", err
+	}
+	return *vk, secret, nil
+}
+
+// Revoke deletes a virtual key by id.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vk, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.byHash, vk.Hash)
+	delete(s.byID, id)
+	if err := s.saveLocked(); err != nil {
+		s.byHash[vk.Hash] = vk
+		s.byID[id] = vk
+		return err
+	}
+	return nil
+}
+
+// List returns all keys, optionally filtered by tenant, ordered by creation.
+func (s *Store) List(tenant string) []VirtualKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]VirtualKey, 0, len(s.byID))
+	for _, vk := range s.byID {
+		if tenant == "" || vk.Tenant == tenant {
+			keys = append(keys, *vk)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// saveLocked writes the store to disk atomically. Callers hold s.mu.
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	keys := make([]*VirtualKey, 0, len(s.byID))
+	for _, vk := range s.byID {
+		keys = append(keys, vk)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("auth: encode keys: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("auth: save keys: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("auth: save keys: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("auth: save keys: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("auth: save keys: %w", err)
+	}
+	return nil
+}
+
+// hashSecret returns the hex SHA-256 of a secret.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(secret)))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("auth: generate key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,238 @@
+// Package batch stores asynchronous batch inference jobs — their items,
+// progress and results — as files in a directory shared by the proxy's
+// replicas, so a job outlives the pod that started it.
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/quota"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed" // Every item has a result, success or error
+	StatusFailed    = "failed"    // The job itself could not run
+	StatusCancelled = "cancelled"
+)
+
+// ErrNotFound is returned for jobs that do not exist.
+var ErrNotFound = errors.New("batch: job not found")
+
+// Job is a batch of inference requests run in the background.
+type Job struct {
+	ID        string        `json:"id"`
+	Tenant    string        `json:"tenant"`
+	Caller    auth.Identity `json:"caller"` // Submitter; items run with its access and quotas
+	Status    string        `json:"status"`
+	Total     int           `json:"total"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Native    []NativeBatch `json:"native,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Created   time.Time     `json:"created"`
+	Updated   time.Time     `json:"updated"`
+}
+
+// Ended reports whether the job has reached a final status.
+func (j *Job) Ended() bool {
+	switch j.Status {
+	case StatusCompleted, StatusFailed, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// Draining reports whether the job has ended with provider-side batches
+// whose results are still to be collected.
+func (j *Job) Draining() bool {
+	if !j.Ended() {
+		return false
+	}
+	for _, nb := range j.Native {
+		if !nb.Done {
+			return true
+		}
+	}
+	return false
+}
+
+// NativeItems returns the number of items sent to provider-side batches.
+func (j *Job) NativeItems() int {
+	n := 0
+	for _, nb := range j.Native {
+		n += len(nb.Items)
+	}
+	return n
+}
+
+// NativeBatch is a provider-side batch running some of a job's items.
+type NativeBatch struct {
+	Provider       string    `json:"provider"`
+	Model          string    `json:"model"`
+	ID             string    `json:"id"`              // The provider's batch ID
+	KeyFingerprint string    `json:"key_fingerprint"` // Key that submitted it; the key itself is never stored
+	Items          []int     `json:"items"`           // Job item indexes, in the order submitted
+	Done           bool      `json:"done"`            // Its results have been recorded
+	Submitted      time.Time `json:"submitted"`
+
+	// Reservations holds each item's tenant quota reservation, in Items
+	// order, settled once its result is known. Nil if quotas are off.
+	Reservations []*quota.Ticket `json:"reservations,omitempty"`
+}
+
+// Item is one request of a job. Its index is its position in the job.
+type Item struct {
+	CustomID string               `json:"custom_id,omitempty"`
+	Request  *pb.InferenceRequest `json:"request"`
+}
+
+// Result is the outcome of one item.
+type Result struct {
+	Index    int                   `json:"index"`
+	CustomID string                `json:"custom_id,omitempty"`
+	Response *pb.InferenceResponse `json:"response,omitempty"`
+	Error    *Error                `json:"error,omitempty"`
+}
+
+// Requests and responses are encoded with protojson, which knows how proto
+// oneofs and well-known types map to JSON, keeping the proto field names
+// and enum numbers encoding/json would have used. Unknown fields are
+// ignored.
+var (
+	protoMarshal   = protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true}
+	protoUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// marshalProto encodes m with protojson. Messages built without reflection
+// support, such as hand-written stubs, fall back to encoding/json.
+func marshalProto(m proto.Message) ([]byte, error) {
+	if m.ProtoReflect() == nil {
+		return json.Marshal(m)
+	}
+	return protoMarshal.Marshal(m)
+}
+
+// unmarshalProto decodes data into m; see marshalProto.
+func unmarshalProto(data []byte, m proto.Message) error {
+	if m.ProtoReflect() == nil {
+		return json.Unmarshal(data, m)
+	}
+	return protoUnmarshal.Unmarshal(data, m)
+}
+
+type itemJSON struct {
+	CustomID string          `json:"custom_id,omitempty"`
+	Request  json.RawMessage `json:"request"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (i Item) MarshalJSON() ([]byte, error) {
+	out := itemJSON{CustomID: i.CustomID, Request: json.RawMessage("null")}
+	if i.Request != nil {
+		req, err := marshalProto(i.Request)
+		if err != nil {
+			return nil, err
+		}
+		out.Request = req
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (i *Item) UnmarshalJSON(data []byte) error {
+	var in itemJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*i = Item{CustomID: in.CustomID}
+	if isNull(in.Request) {
+		return nil
+	}
+	i.Request = &pb.InferenceRequest{}
+	return unmarshalProto(in.Request, i.Request)
+}
+
+type resultJSON struct {
+	Index    int             `json:"index"`
+	CustomID string          `json:"custom_id,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    *Error          `json:"error,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r Result) MarshalJSON() ([]byte, error) {
+	out := resultJSON{Index: r.Index, CustomID: r.CustomID, Error: r.Error}
+	if r.Response != nil {
+		resp, err := marshalProto(r.Response)
+		if err != nil {
+			return nil, err
+		}
+		out.Response = resp
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var in resultJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*r = Result{Index: in.Index, CustomID: in.CustomID, Error: in.Error}
+	if isNull(in.Response) {
+		return nil
+	}
+	r.Response = &pb.InferenceResponse{}
+	return unmarshalProto(in.Response, r.Response)
+}
+
+// isNull reports whether a JSON value is absent or null.
+func isNull(data json.RawMessage) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) == 0 || bytes.Equal(data, []byte("null"))
+}
+
+// Error describes a failed item.
+type Error struct {
+	Code    string `json:"code"` // gRPC status code name, e.g. "INVALID_ARGUMENT"
+	Message string `json:"message"`
+	Reason  string `json:"reason,omitempty"` // google.rpc.ErrorInfo reason, if any
+}
+
+// ParseItems reads JSONL items, one per line; blank lines are skipped.
+// Requests are read with protojson, so fields may use their proto or JSON
+// names and enums their names or numbers.
+func ParseItems(r io.Reader) ([]Item, error) {
+	var items []Item
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			var item Item
+			if jsonErr := json.Unmarshal(data, &item); jsonErr != nil {
+				return nil, fmt.Errorf("batch: line %d: %w", line, jsonErr)
+			}
+			items = append(items, item)
+		}
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("batch: read items: %w", err)
+		}
+	}
+}
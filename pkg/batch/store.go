@@ -0,0 +1,332 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LeaseTTL is how long a replica's claim on a job lasts without renewal.
+// A job whose runner died is resumed by another replica once it lapses.
+const LeaseTTL = 2 * time.Minute
+
+// Files in a job's directory.
+const (
+	jobFile     = "job.json"
+	itemsFile   = "items.jsonl"
+	resultsFile = "results.jsonl"
+	leaseFile   = "lease"
+	cancelFile  = "cancel"
+)
+
+// Store keeps jobs in a directory, one subdirectory per job. Results are
+// appended as items finish and double as the job's checkpoint: a resumed
+// job skips the items that already have one.
+type Store struct {
+	dir string
+	mu  sync.Mutex // Serializes result appends
+}
+
+// NewStore opens the store in dir, creating it if needed.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("batch: create store: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// NewID returns a random job ID.
+func NewID() string {
+	return "batch_" + uuid.New().String()
+}
+
+// validID reports whether id has the form NewID returns, so it is safe to
+// use as a path element.
+func validID(id string) bool {
+	rest, ok := strings.CutPrefix(id, "batch_")
+	if !ok {
+		return false
+	}
+	_, err := uuid.Parse(rest)
+	return err == nil
+}
+
+func (s *Store) path(id, name string) string {
+	return filepath.Join(s.dir, id, name)
+}
+
+// Create stores a new job with its items.
+func (s *Store) Create(job *Job, items []Item) error {
+	if !validID(job.ID) {
+		return fmt.Errorf("batch: invalid job ID %q", job.ID)
+	}
+	if err := os.Mkdir(filepath.Join(s.dir, job.ID), 0o750); err != nil {
+		return fmt.Errorf("batch: create job: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return fmt.Errorf("batch: marshal item: %w", err)
+		}
+	}
+	if err := writeFile(s.path(job.ID, itemsFile), buf.Bytes()); err != nil {
+		return err
+	}
+	return s.Save(job)
+}
+
+// Get returns the job with id, or ErrNotFound.
+func (s *Store) Get(id string) (*Job, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(id, jobFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("batch: get: %w", err)
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("batch: decode job %s: %w", id, err)
+	}
+	return &job, nil
+}
+
+// Save writes job, replacing the stored copy atomically.
+func (s *Store) Save(job *Job) error {
+	job.Updated = time.Now()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("batch: marshal job: %w", err)
+	}
+	return writeFile(s.path(job.ID, jobFile), data)
+}
+
+// List returns every stored job, oldest first. Jobs that cannot be read
+// are skipped.
+func (s *Store) List() ([]*Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("batch: list: %w", err)
+	}
+	var jobs []*Job
+	for _, e := range entries {
+		if !e.IsDir() || !validID(e.Name()) {
+			continue
+		}
+		if job, err := s.Get(e.Name()); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+	return jobs, nil
+}
+
+// Items returns the job's items in order.
+func (s *Store) Items(id string) ([]Item, error) {
+	f, err := os.Open(s.path(id, itemsFile))
+	if err != nil {
+		return nil, fmt.Errorf("batch: open items: %w", err)
+	}
+	defer f.Close()
+	return ParseItems(f)
+}
+
+// Checkpoint returns the results recorded so far, indexed by item. A
+// partial last line left by a crash mid-append is truncated.
+func (s *Store) Checkpoint(id string) (map[int]*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(id, resultsFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[int]*Result{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("batch: read results: %w", err)
+	}
+	if end := bytes.LastIndexByte(data, '\n') + 1; end < len(data) {
+		if err := os.Truncate(path, int64(end)); err != nil {
+			return nil, fmt.Errorf("batch: truncate results: %w", err)
+		}
+		data = data[:end]
+	}
+
+	done := make(map[int]*Result)
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		var r Result
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, fmt.Errorf("batch: decode result: %w", err)
+		}
+		done[r.Index] = &r
+	}
+	return done, nil
+}
+
+// Append records finished items, flushed to disk before it returns.
+func (s *Store) Append(id string, results []Result) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range results {
+		if err := enc.Encode(&results[i]); err != nil {
+			return fmt.Errorf("batch: marshal result: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path(id, resultsFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("batch: open results: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("batch: append results: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("batch: sync results: %w", err)
+	}
+	return f.Close()
+}
+
+// Results returns up to limit result lines after the first offset, as
+// JSONL, and the offset of the line after them.
+func (s *Store) Results(id string, offset, limit int) ([]byte, int, error) {
+	f, err := os.Open(s.path(id, resultsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, offset, nil
+	}
+	if err != nil {
+		return nil, offset, fmt.Errorf("batch: open results: %w", err)
+	}
+	defer f.Close()
+
+	var out []byte
+	next := 0
+	br := bufio.NewReader(f)
+	for next < offset+limit {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			// A partial line is still being written.
+			break
+		}
+		if err != nil {
+			return nil, offset, fmt.Errorf("batch: read results: %w", err)
+		}
+		if next >= offset {
+			out = append(out, line...)
+		}
+		next++
+	}
+	return out, max(next, offset), nil
+}
+
+// RequestCancel marks the job for cancellation by whichever replica runs
+// it.
+func (s *Store) RequestCancel(id string) error {
+	return writeFile(s.path(id, cancelFile), nil)
+}
+
+// CancelRequested reports whether RequestCancel was called for the job.
+func (s *Store) CancelRequested(id string) bool {
+	_, err := os.Stat(s.path(id, cancelFile))
+	return err == nil
+}
+
+// Lock claims the job for owner for LeaseTTL. It fails if another owner
+// holds an unexpired lease.
+func (s *Store) Lock(id, owner string) (bool, error) {
+	path := s.path(id, leaseFile)
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return false, fmt.Errorf("batch: write lease: %w", err)
+			}
+			return true, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return false, fmt.Errorf("batch: create lease: %w", err)
+		}
+
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("batch: stat lease: %w", err)
+		}
+		if time.Since(info.ModTime()) < LeaseTTL {
+			return false, nil
+		}
+		// The holder stopped renewing. Moving the stale lease aside first
+		// lets only one contender remove it.
+		stale := fmt.Sprintf("%s.%s", path, uuid.New().String())
+		if err := os.Rename(path, stale); err != nil {
+			continue
+		}
+		os.Remove(stale)
+	}
+	return false, nil
+}
+
+// Renew extends owner's lease on the job. It fails if owner no longer
+// holds it.
+func (s *Store) Renew(id, owner string) error {
+	path := s.path(id, leaseFile)
+	holder, err := os.ReadFile(path)
+	if err != nil || string(holder) != owner {
+		return fmt.Errorf("batch: lease on %s lost", id)
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return fmt.Errorf("batch: renew lease: %w", err)
+	}
+	return nil
+}
+
+// Unlock releases owner's lease on the job.
+func (s *Store) Unlock(id, owner string) {
+	path := s.path(id, leaseFile)
+	if holder, err := os.ReadFile(path); err == nil && string(holder) == owner {
+		os.Remove(path)
+	}
+}
+
+// writeFile replaces path atomically with data.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp." + uuid.New().String()
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("batch: write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("batch: write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package batch
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// newTestJob creates a job with n items in a fresh store.
+func newTestJob(t *testing.T, n int) (*Store, *Job) {
+	t.Helper()
+	s, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{ID: NewID(), Status: StatusQueued, Total: n, Created: time.Now()}
+	items := make([]Item, n)
+	for i := range items {
+		items[i] = Item{CustomID: string(rune('a' + i)), Request: &pb.InferenceRequest{Model: "gpt-4o", Prompt: "hi"}}
+	}
+	if err := s.Create(job, items); err != nil {
+		t.Fatal(err)
+	}
+	return s, job
+}
+
+func TestParseItems(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []string // Custom IDs
+		wantErr string
+	}{
+		{"items", "{\"custom_id\":\"a\",\"request\":{\"model\":\"m\"}}\n{\"custom_id\":\"b\",\"request\":{}}\n", []string{"a", "b"}, ""},
+		{"blank lines and no final newline", "\n{\"custom_id\":\"a\"}\n  \n{\"custom_id\":\"b\"}", []string{"a", "b"}, ""},
+		{"empty", "", nil, ""},
+		{"bad line", "{\"custom_id\":\"a\"}\n{\n", nil, "batch: line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := ParseItems(strings.NewReader(tt.in))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseItems error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range items {
+				got = append(got, item.CustomID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseItems custom IDs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestItemJSON(t *testing.T) {
+	// Messages with reflection are encoded by protojson, which writes a
+	// wrapper as its bare value.
+	data, err := marshalProto(wrapperspb.String("hi"))
+	if err != nil || string(data) != `"hi"` {
+		t.Errorf("marshalProto(wrapper) = %s, %v, want %q", data, err, `"hi"`)
+	}
+
+	tests := []struct {
+		name string
+		in   any
+		out  any
+	}{
+		{"item", Item{CustomID: "a", Request: &pb.InferenceRequest{Model: "gpt-4o", Prompt: "hi"}}, &Item{}},
+		{"item without a request", Item{CustomID: "a"}, &Item{}},
+		{"result", Result{Index: 3, CustomID: "a", Response: &pb.InferenceResponse{Text: "hello"}}, &Result{}},
+		{"failed result", Result{Index: 3, Error: &Error{Code: "INTERNAL", Message: "boom"}}, &Result{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(data, tt.out); err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(tt.out).Elem().Interface(); !reflect.DeepEqual(got, tt.in) {
+				t.Errorf("round trip of %s = %+v, want %+v", data, got, tt.in)
+			}
+		})
+	}
+}
+
+func TestStoreJob(t *testing.T) {
+	s, job := newTestJob(t, 3)
+
+	got, err := s.Get(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusQueued || got.Total != 3 {
+		t.Errorf("Get = %+v", got)
+	}
+	items, err := s.Items(job.ID)
+	if err != nil || len(items) != 3 || items[2].CustomID != "c" || items[2].Request.GetModel() != "gpt-4o" {
+		t.Errorf("Items = %+v, %v", items, err)
+	}
+	jobs, err := s.List()
+	if err != nil || len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("List = %v, %v", jobs, err)
+	}
+
+	for _, id := range []string{"batch_nope", "../" + job.ID, NewID()} {
+		if _, err := s.Get(id); err != ErrNotFound {
+			t.Errorf("Get(%q) error = %v, want ErrNotFound", id, err)
+		}
+	}
+	if err := s.Create(&Job{ID: "../escape"}, nil); err == nil {
+		t.Error("Create accepted an invalid ID")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		appends [][]int // Item indexes of each Append
+		tail    string  // Written after the appends, as a crash mid-append would
+		want    []int
+	}{
+		{"no results", nil, "", nil},
+		{"appended results", [][]int{{0, 2}, {1}}, "", []int{0, 1, 2}},
+		{"partial last line is dropped", [][]int{{0}}, `{"index":1,"cust`, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, job := newTestJob(t, 3)
+			for _, indexes := range tt.appends {
+				results := make([]Result, len(indexes))
+				for i, index := range indexes {
+					results[i] = Result{Index: index, Response: &pb.InferenceResponse{Text: "ok"}}
+				}
+				if err := s.Append(job.ID, results); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.tail != "" {
+				f, err := os.OpenFile(s.path(job.ID, resultsFile), os.O_WRONLY|os.O_APPEND, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.WriteString(tt.tail)
+				f.Close()
+			}
+
+			done, err := s.Checkpoint(job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(done) != len(tt.want) {
+				t.Fatalf("Checkpoint has %d results, want %d", len(done), len(tt.want))
+			}
+			for _, i := range tt.want {
+				if r := done[i]; r == nil || r.Index != i || r.Response.GetText() != "ok" {
+					t.Errorf("result %d = %+v", i, r)
+				}
+			}
+
+			// A resumed run appends after the checkpoint without a
+			// corrupt line in between.
+			if err := s.Append(job.ID, []Result{{Index: 2, Error: &Error{Code: "INTERNAL"}}}); err != nil {
+				t.Fatal(err)
+			}
+			if done, err = s.Checkpoint(job.ID); err != nil || done[2] == nil {
+				t.Errorf("Checkpoint after resuming = %v, %v", done, err)
+			}
+		})
+	}
+}
+
+func TestResults(t *testing.T) {
+	s, job := newTestJob(t, 5)
+	for i := 0; i < 5; i++ {
+		if err := s.Append(job.ID, []Result{{Index: i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		offset, limit int
+		wantLines     int
+		wantNext      int
+	}{
+		{0, 2, 2, 2},
+		{2, 2, 2, 4},
+		{4, 2, 1, 5},
+		{5, 2, 0, 5},
+		{9, 2, 0, 9},
+	}
+	for _, tt := range tests {
+		data, next, err := s.Results(job.ID, tt.offset, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Count(string(data), "\n"); lines != tt.wantLines || next != tt.wantNext {
+			t.Errorf("Results(%d, %d) = %d lines, next %d, want %d lines, next %d", tt.offset, tt.limit, lines, next, tt.wantLines, tt.wantNext)
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	s, job := newTestJob(t, 1)
+
+	if ok, err := s.Lock(job.ID, "a"); !ok || err != nil {
+		t.Fatalf("Lock(a) = %v, %v, want the lease", ok, err)
+	}
+	if ok, _ := s.Lock(job.ID, "b"); ok {
+		t.Fatal("Lock(b) took a live lease")
+	}
+	if err := s.Renew(job.ID, "b"); err == nil {
+		t.Error("Renew(b) succeeded without the lease")
+	}
+	if err := s.Renew(job.ID, "a"); err != nil {
+		t.Errorf("Renew(a): %v", err)
+	}
+
+	// A lease that was not renewed lapses and can be taken over.
+	stale := time.Now().Add(-LeaseTTL - time.Second)
+	if err := os.Chtimes(s.path(job.ID, leaseFile), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.Lock(job.ID, "b"); !ok || err != nil {
+		t.Fatalf("Lock(b) on a stale lease = %v, %v", ok, err)
+	}
+	if err := s.Renew(job.ID, "a"); err == nil {
+		t.Error("Renew(a) succeeded after losing the lease")
+	}
+
+	s.Unlock(job.ID, "a") // Not the holder: no effect
+	if ok, _ := s.Lock(job.ID, "c"); ok {
+		t.Fatal("Unlock by a former holder released the lease")
+	}
+	s.Unlock(job.ID, "b")
+	if ok, _ := s.Lock(job.ID, "c"); !ok {
+		t.Error("Lock after Unlock failed")
+	}
+}
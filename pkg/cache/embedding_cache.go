@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// EmbeddingCache stores embeddings in Redis under an exact hash of the
+// model, dimensions and input text.
+type EmbeddingCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewEmbeddingCache creates a new Redis-backed embedding cache.
+func NewEmbeddingCache(addr, password string, db int, ttl time.Duration) *EmbeddingCache {
+	return &EmbeddingCache{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+		ttl: ttl,
+	}
+}
+
+// Get returns the cached embedding of each input, nil for misses.
+// dimensions is 0 for the model's default size.
+func (c *EmbeddingCache) Get(ctx context.Context, model string, dimensions int32, inputs []string) ([][]float32, error) {
+	keys := make([]string, len(inputs))
+	for i, input := range inputs {
+		keys[i] = embeddingKey(model, dimensions, input)
+	}
+
+	vals, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("embedding_cache: mget: %w", err)
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			vectors[i] = decodeVector(s)
+		}
+	}
+	return vectors, nil
+}
+
+// Set stores the embedding of each input with the configured TTL.
+func (c *EmbeddingCache) Set(ctx context.Context, model string, dimensions int32, inputs []string, vectors [][]float32) error {
+	pipe := c.client.Pipeline()
+	for i, input := range inputs {
+		pipe.Set(ctx, embeddingKey(model, dimensions, input), encodeVector(vectors[i]), c.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("embedding_cache: set: %w", err)
+	}
+	return nil
+}
+
+// Ping checks the Redis connection.
+func (c *EmbeddingCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Close closes the Redis connection.
+func (c *EmbeddingCache) Close() error {
+	return c.client.Close()
+}
+
+// embeddingKey generates the cache key of one input.
+func embeddingKey(model string, dimensions int32, input string) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", model, dimensions, input)))
+	return fmt.Sprintf("llm_embedding:%x", hash[:16])
+}
+
+// encodeVector packs v as little-endian float32s.
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b
+}
+
+// decodeVector unpacks a vector written by encodeVector.
+func decodeVector(s string) []float32 {
+	b := []byte(s)
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
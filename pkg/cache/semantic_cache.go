@@ -9,39 +9,54 @@ import (
 	"github.com/abdhe/llm-inference-proxy/pkg/provider"
 )
 
+// EmbedFunc returns the vector embedding of text.
+type EmbedFunc func(ctx context.Context, text string) ([]float32, error)
+
 // SemanticCache orchestrates the embed → search → hit/miss caching flow.
 type SemanticCache struct {
-	embedder    *Embedder
+	embed       EmbedFunc
 	vectorStore *VectorStore
 	redisCache  *RedisCache
 	threshold   float32 // Similarity threshold (e.g. 0.95)
 }
 
-// NewSemanticCache creates a new semantic cache.
-func NewSemanticCache(embedder *Embedder, vectorStore *VectorStore, redisCache *RedisCache, threshold float32) *SemanticCache {
+// NewSemanticCache creates a new semantic cache. Prompts are embedded with
+// the function set by UseEmbedder; until then every lookup is a miss.
+func NewSemanticCache(vectorStore *VectorStore, redisCache *RedisCache, threshold float32) *SemanticCache {
 	return &SemanticCache{
-		embedder:    embedder,
 		vectorStore: vectorStore,
 		redisCache:  redisCache,
 		threshold:   threshold,
 	}
 }
 
+// UseEmbedder sets the function prompts are embedded with. It must be called
+// before the cache is used concurrently.
+func (sc *SemanticCache) UseEmbedder(embed EmbedFunc) {
+	sc.embed = embed
+}
+
 // CacheResult holds the result of a cache lookup.
 type CacheResult struct {
 	Response provider.Response
 	Hit      bool
 }
 
-// Lookup checks the semantic cache for a similar query.
+// Lookup checks the semantic cache for a similar query. Only entries stored
+// with the same scope can match; callers use it to keep prompts with
+// attachments apart from text-only ones.
 // Flow:
 //  1. Generate an embedding for the prompt.
 //  2. Search the vector store for a neighbor above the similarity threshold.
 //  3. If found, retrieve the cached response from Redis.
 //  4. If not found, return a cache miss.
-func (sc *SemanticCache) Lookup(ctx context.Context, prompt string) (CacheResult, error) {
+func (sc *SemanticCache) Lookup(ctx context.Context, prompt, scope string) (CacheResult, error) {
+	if sc.embed == nil {
+		return CacheResult{Hit: false}, nil
+	}
+
 	// Step 1: Embed the query
-	vector, err := sc.embedder.Embed(ctx, prompt)
+	vector, err := sc.embed(ctx, prompt)
 	if err != nil {
 		// Log but don't fail — treat as cache miss
 		log.Printf("[semantic_cache] embedding error (treating as miss): %v", err)
@@ -49,7 +64,7 @@ func (sc *SemanticCache) Lookup(ctx context.Context, prompt string) (CacheResult
 	}
 
 	// Step 2: Search for similar vectors
-	result, err := sc.vectorStore.Search(ctx, vector, sc.threshold)
+	result, err := sc.vectorStore.Search(ctx, vector, sc.threshold, scope)
 	if err != nil {
 		log.Printf("[semantic_cache] vector search error (treating as miss): %v", err)
 		return CacheResult{Hit: false}, nil
@@ -78,22 +93,26 @@ func (sc *SemanticCache) Lookup(ctx context.Context, prompt string) (CacheResult
 	}, nil
 }
 
-// Store caches a prompt-response pair.
+// Store caches a prompt-response pair under scope.
 // Flow:
 //  1. Generate an embedding for the prompt.
-//  2. Create a deterministic cache key from the prompt.
+//  2. Create a deterministic cache key from the prompt and scope.
 //  3. Store the response in Redis.
 //  4. Upsert the embedding into the vector store with the cache key.
-func (sc *SemanticCache) Store(ctx context.Context, prompt string, resp provider.Response) {
+func (sc *SemanticCache) Store(ctx context.Context, prompt, scope string, resp provider.Response) {
+	if sc.embed == nil {
+		return
+	}
+
 	// Step 1: Embed
-	vector, err := sc.embedder.Embed(ctx, prompt)
+	vector, err := sc.embed(ctx, prompt)
 	if err != nil {
 		log.Printf("[semantic_cache] store embedding error: %v", err)
 		return
 	}
 
 	// Step 2: Deterministic cache key
-	cacheKey := cacheKeyFromPrompt(prompt)
+	cacheKey := cacheKeyFromPrompt(prompt, scope)
 
 	// Step 3: Store in Redis
 	if err := sc.redisCache.Set(ctx, cacheKey, resp); err != nil {
@@ -102,13 +121,17 @@ func (sc *SemanticCache) Store(ctx context.Context, prompt string, resp provider
 	}
 
 	// Step 4: Upsert vector
-	if err := sc.vectorStore.Upsert(ctx, cacheKey, vector); err != nil {
+	if err := sc.vectorStore.Upsert(ctx, cacheKey, vector, scope); err != nil {
 		log.Printf("[semantic_cache] vector upsert error: %v", err)
 	}
 }
 
-// cacheKeyFromPrompt generates a deterministic cache key for a prompt.
-func cacheKeyFromPrompt(prompt string) string {
+// cacheKeyFromPrompt generates a deterministic cache key for a prompt and
+// scope. Unscoped keys are unchanged from before scopes existed.
+func cacheKeyFromPrompt(prompt, scope string) string {
+	if scope != "" {
+		prompt = scope + "\x00" + prompt
+	}
 	hash := sha256.Sum256([]byte(prompt))
 	return fmt.Sprintf("llm_cache:%x", hash[:16])
 }
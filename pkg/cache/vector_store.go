@@ -39,10 +39,40 @@ func NewVectorStore(qdrantURL, collection string) *VectorStore {
 // ---------------------------------------------------------------------------
 
 type qdrantSearchRequest struct {
-	Vector      []float32 `json:"vector"`
-	Limit       int       `json:"limit"`
-	ScoreThresh float32   `json:"score_threshold"`
-	WithPayload bool      `json:"with_payload"`
+	Vector      []float32     `json:"vector"`
+	Limit       int           `json:"limit"`
+	ScoreThresh float32       `json:"score_threshold"`
+	WithPayload bool          `json:"with_payload"`
+	Filter      *qdrantFilter `json:"filter,omitempty"`
+}
+
+type qdrantFilter struct {
+	Must []qdrantCondition `json:"must"`
+}
+
+// qdrantCondition is a field match or, with IsEmpty, a missing-field check.
+type qdrantCondition struct {
+	Key     string         `json:"key,omitempty"`
+	Match   *qdrantMatch   `json:"match,omitempty"`
+	IsEmpty *qdrantIsEmpty `json:"is_empty,omitempty"`
+}
+
+type qdrantMatch struct {
+	Value string `json:"value"`
+}
+
+type qdrantIsEmpty struct {
+	Key string `json:"key"`
+}
+
+// scopeFilter restricts a search to points stored with the same scope.
+// Points without a scope (including those stored before scopes existed)
+// only match the empty scope.
+func scopeFilter(scope string) *qdrantFilter {
+	if scope == "" {
+		return &qdrantFilter{Must: []qdrantCondition{{IsEmpty: &qdrantIsEmpty{Key: "scope"}}}}
+	}
+	return &qdrantFilter{Must: []qdrantCondition{{Key: "scope", Match: &qdrantMatch{Value: scope}}}}
 }
 
 type qdrantSearchResponse struct {
@@ -67,13 +97,15 @@ type qdrantPoint struct {
 // Public API
 // ---------------------------------------------------------------------------
 
-// Search finds the nearest neighbor in the vector store above the given threshold.
-func (v *VectorStore) Search(ctx context.Context, vector []float32, threshold float32) (SearchResult, error) {
+// Search finds the nearest neighbor in the vector store above the given
+// threshold, among points stored with the same scope.
+func (v *VectorStore) Search(ctx context.Context, vector []float32, threshold float32, scope string) (SearchResult, error) {
 	body := qdrantSearchRequest{
 		Vector:      vector,
 		Limit:       1,
 		ScoreThresh: threshold,
 		WithPayload: true,
+		Filter:      scopeFilter(scope),
 	}
 
 	jsonBody, err := json.Marshal(body)
@@ -116,14 +148,18 @@ func (v *VectorStore) Search(ctx context.Context, vector []float32, threshold fl
 	}, nil
 }
 
-// Upsert stores a vector with the given cache key as payload.
-func (v *VectorStore) Upsert(ctx context.Context, cacheKey string, vector []float32) error {
+// Upsert stores a vector with the given cache key and scope as payload.
+func (v *VectorStore) Upsert(ctx context.Context, cacheKey string, vector []float32, scope string) error {
+	payload := map[string]string{"cache_key": cacheKey}
+	if scope != "" {
+		payload["scope"] = scope
+	}
 	body := qdrantUpsertRequest{
 		Points: []qdrantPoint{
 			{
 				ID:      uuid.New().String(),
 				Vector:  vector,
-				Payload: map[string]string{"cache_key": cacheKey},
+				Payload: payload,
 			},
 		},
 	}
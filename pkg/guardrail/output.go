@@ -0,0 +1,239 @@
+package guardrail
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Output rule names reported for built-in checks. Regex rules are reported
+// by their configured name.
+const (
+	RuleBannedTerms  = "banned_terms"
+	RuleMaxLength    = "max_length"
+	RuleSecretPrefix = "secret:" // Prefix of built-in secret rules, e.g. secret:aws_access_key
+)
+
+// DefaultLookahead is how many bytes of a stream are held back so a match
+// can be cut off before any of it reaches the client.
+const DefaultLookahead = 64
+
+// OutputConfig describes what provider responses may not contain.
+type OutputConfig struct {
+	BannedTerms    []string    `json:"banned_terms"`    // Case-insensitive whole words or phrases
+	Rules          []RegexRule `json:"rules"`           // Named regex rules
+	BlockSecrets   bool        `json:"block_secrets"`   // Enable the built-in credential patterns
+	MaxLength      int         `json:"max_length"`      // Maximum response length in bytes, 0 = unlimited
+	LookaheadBytes int         `json:"lookahead_bytes"` // Stream hold-back, 0 = DefaultLookahead
+}
+
+// RegexRule is a named regex an output must not match.
+type RegexRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// LoadOutputConfig reads an output policy JSON file.
+func LoadOutputConfig(path string) (OutputConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return OutputConfig{}, fmt.Errorf("guardrail: read %s: %w", path, err)
+	}
+
+	var cfg OutputConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return OutputConfig{}, fmt.Errorf("guardrail: parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// secretRules are well-known credential formats.
+var secretRules = []RegexRule{
+	{Name: "aws_access_key", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "private_key", Pattern: `-----BEGIN (?:[A-Z]+ )?PRIVATE KEY-----`},
+	{Name: "github_token", Pattern: `\bgh[pousr]_[A-Za-z0-9]{36}\b`},
+	{Name: "openai_key", Pattern: `\bsk-(?:proj-)?[A-Za-z0-9_-]{32,}`},
+	{Name: "google_api_key", Pattern: `\bAIza[0-9A-Za-z_-]{35}\b`},
+	{Name: "slack_token", Pattern: `\bxox[abpors]-[A-Za-z0-9-]{10,}`},
+	{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`},
+}
+
+type outputRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// OutputPolicy checks provider responses against an OutputConfig.
+type OutputPolicy struct {
+	rules     []outputRule
+	maxLength int
+	lookahead int
+}
+
+// NewOutputPolicy compiles the rules in cfg.
+func NewOutputPolicy(cfg OutputConfig) (*OutputPolicy, error) {
+	p := &OutputPolicy{
+		maxLength: cfg.MaxLength,
+		lookahead: cfg.LookaheadBytes,
+	}
+	if p.lookahead <= 0 {
+		p.lookahead = DefaultLookahead
+	}
+
+	if len(cfg.BannedTerms) > 0 {
+		quoted := make([]string, 0, len(cfg.BannedTerms))
+		for _, t := range cfg.BannedTerms {
+			if t = strings.TrimSpace(t); t != "" {
+				quoted = append(quoted, regexp.QuoteMeta(t))
+			}
+		}
+		if len(quoted) > 0 {
+			re := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+			p.rules = append(p.rules, outputRule{RuleBannedTerms, re})
+		}
+	}
+	for _, r := range cfg.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("guardrail: output rule without a name")
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("guardrail: output rule %s: %w", r.Name, err)
+		}
+		p.rules = append(p.rules, outputRule{r.Name, re})
+	}
+	if cfg.BlockSecrets {
+		for _, r := range secretRules {
+			p.rules = append(p.rules, outputRule{RuleSecretPrefix + r.Name, regexp.MustCompile(r.Pattern)})
+		}
+	}
+	return p, nil
+}
+
+// Violation is returned when a response breaks an output rule.
+type Violation struct {
+	Rule string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("guardrail: response violates output policy (%s)", v.Rule)
+}
+
+// Check evaluates a complete response.
+func (p *OutputPolicy) Check(text string) *Violation {
+	if p == nil {
+		return nil
+	}
+	if p.maxLength > 0 && len(text) > p.maxLength {
+		return &Violation{Rule: RuleMaxLength}
+	}
+	return p.match(text)
+}
+
+func (p *OutputPolicy) match(text string) *Violation {
+	for _, r := range p.rules {
+		if r.re.MatchString(text) {
+			return &Violation{Rule: r.name}
+		}
+	}
+	return nil
+}
+
+// NewStream starts checking a streamed response. A nil policy returns a
+// nil StreamGuard, which passes chunks through.
+func (p *OutputPolicy) NewStream() *StreamGuard {
+	if p == nil {
+		return nil
+	}
+	return &StreamGuard{policy: p}
+}
+
+// StreamGuard evaluates a response incrementally. It holds back the last
+// lookahead bytes, so any match no longer than that is detected before any
+// part of it has been released. A match that reaches the end of the text
+// received so far may still turn out not to be one — " ass" before "ume"
+// — so it is held back, from its start, until more text arrives or the
+// stream ends.
+type StreamGuard struct {
+	policy  *OutputPolicy
+	text    string // Everything received so far
+	emitted int    // Bytes of text released to the caller
+}
+
+// Write adds a chunk and returns the text that is now safe to send, or the
+// violation if the response so far breaks a rule. After a violation the
+// stream must be abandoned.
+func (g *StreamGuard) Write(chunk string) (string, *Violation) {
+	if g == nil {
+		return chunk, nil
+	}
+
+	g.text += chunk
+	pending, v := g.check(false)
+	if v != nil {
+		return "", v
+	}
+
+	end := min(len(g.text)-g.policy.lookahead, pending)
+	for end > g.emitted && !utf8.RuneStart(g.text[end]) {
+		end--
+	}
+	if end <= g.emitted {
+		return "", nil
+	}
+	out := g.text[g.emitted:end]
+	g.emitted = end
+	return out, nil
+}
+
+// Flush checks the held-back tail as the end of the response and releases
+// it, unless it completes a match.
+func (g *StreamGuard) Flush() (string, *Violation) {
+	if g == nil {
+		return "", nil
+	}
+	if _, v := g.check(true); v != nil {
+		return "", v
+	}
+	out := g.text[g.emitted:]
+	g.emitted = len(g.text)
+	return out, nil
+}
+
+// check scans the text that may contain a new match: everything not yet
+// released plus one lookahead of released text, for matches spanning the
+// boundary. The scan starts one rune earlier, so that word boundaries at
+// its start are judged as in the whole text, and ignores matches starting
+// in that rune. Unless the stream has ended, a match reaching the end of
+// the text is not a violation yet; pending is where the earliest such
+// match starts, or len(text) if there is none.
+func (g *StreamGuard) check(ended bool) (pending int, v *Violation) {
+	p := g.policy
+	pending = len(g.text)
+	if p.maxLength > 0 && len(g.text) > p.maxLength {
+		return pending, &Violation{Rule: RuleMaxLength}
+	}
+	from := max(g.emitted-p.lookahead, 0)
+	scan := from
+	if scan > 0 {
+		_, size := utf8.DecodeLastRuneInString(g.text[:scan])
+		scan -= size
+	}
+	window := g.text[scan:]
+	for _, r := range p.rules {
+		for _, m := range r.re.FindAllStringIndex(window, -1) {
+			start, end := scan+m[0], scan+m[1]
+			switch {
+			case start < from:
+			case end == len(g.text) && !ended:
+				pending = min(pending, start)
+			default:
+				return pending, &Violation{Rule: r.name}
+			}
+		}
+	}
+	return pending, nil
+}
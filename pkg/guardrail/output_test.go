@@ -0,0 +1,107 @@
+package guardrail
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestOutputPolicy(t *testing.T) *OutputPolicy {
+	t.Helper()
+	p, err := NewOutputPolicy(OutputConfig{
+		BannedTerms:    []string{"ass", "bad word"},
+		Rules:          []RegexRule{{Name: "key", Pattern: `sk-[a-z]{10,}`}},
+		LookaheadBytes: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOutputCheck(t *testing.T) {
+	p := newTestOutputPolicy(t)
+	tests := []struct {
+		text string
+		want string // Violated rule, or ""
+	}{
+		{"I assume so", ""},
+		{"a class act", ""},
+		{"you ass", RuleBannedTerms},
+		{"a BAD WORD here", RuleBannedTerms},
+		{"key sk-abcdefghij", "key"},
+		{"key sk-abc", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got string
+			if v := p.Check(tt.text); v != nil {
+				got = v.Rule
+			}
+			if got != tt.want {
+				t.Errorf("Check(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamGuard(t *testing.T) {
+	p := newTestOutputPolicy(t)
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string // Violated rule, or "" if the whole text passes
+	}{
+		{"word split after a banned prefix", []string{"I ", "ass", "ume so"}, ""},
+		{"banned prefix split across chunks", []string{"I a", "s", "sume so"}, ""},
+		{"released text starts mid-word", []string{"a class of ", "its own"}, ""},
+		{"banned word completed later", []string{"you a", "ss now"}, RuleBannedTerms},
+		{"banned word at the end of the stream", []string{"you ", "ass"}, RuleBannedTerms},
+		{"phrase split across chunks", []string{"a bad", " wo", "rd!"}, RuleBannedTerms},
+		{"long match is held from its start", []string{"key sk-", "abcdefghij", "klmnop", " end"}, "key"},
+		{"long match at the end of the stream", []string{"key sk-", "abcdefghijkl"}, "key"},
+		{"multibyte text", []string{"café ", "ass", "ez"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := p.NewStream()
+			var out strings.Builder
+			var rule string
+			for _, c := range tt.chunks {
+				safe, v := g.Write(c)
+				out.WriteString(safe)
+				if v != nil {
+					rule = v.Rule
+					break
+				}
+			}
+			if rule == "" {
+				tail, v := g.Flush()
+				out.WriteString(tail)
+				if v != nil {
+					rule = v.Rule
+				}
+			}
+
+			if rule != tt.want {
+				t.Fatalf("violation = %q, want %q (released %q)", rule, tt.want, out.String())
+			}
+			whole := strings.Join(tt.chunks, "")
+			switch {
+			case rule == "" && out.String() != whole:
+				t.Errorf("released %q, want %q", out.String(), whole)
+			case rule != "" && p.Check(out.String()) != nil:
+				t.Errorf("released %q, which already violates the policy", out.String())
+			case rule == "key" && strings.Contains(out.String(), "sk-"):
+				t.Errorf("released %q, part of the match", out.String())
+			}
+		})
+	}
+
+	var none *StreamGuard
+	if out, v := none.Write("x"); out != "x" || v != nil {
+		t.Errorf("nil guard Write = %q, %v", out, v)
+	}
+	if out, v := none.Flush(); out != "" || v != nil {
+		t.Errorf("nil guard Flush = %q, %v", out, v)
+	}
+}
@@ -0,0 +1,419 @@
+// Package guardrail inspects prompts and responses for content that must not
+// cross the proxy boundary.
+package guardrail
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PII actions.
+const (
+	ActionBlock       = "block"       // Reject the request
+	ActionMask        = "mask"        // Replace values with a fixed label, e.g. [EMAIL]
+	ActionPlaceholder = "placeholder" // Replace values with numbered placeholders restored in the response
+)
+
+// Built-in PII detectors, in the order overlapping matches are resolved.
+const (
+	DetectorCreditCard = "credit_card"
+	DetectorIBAN       = "iban"
+	DetectorUSSSN      = "us_ssn"
+	DetectorUKNINO     = "uk_nino"
+	DetectorEmail      = "email"
+	DetectorPhone      = "phone"
+)
+
+// PIIConfig selects detectors and what to do with their matches.
+type PIIConfig struct {
+	Action    string            `json:"action"`    // Default action for every detector
+	Detectors []string          `json:"detectors"` // Built-in detectors to enable, empty = all
+	Actions   map[string]string `json:"actions"`   // Per-detector action overrides
+	Custom    []CustomDetector  `json:"custom"`    // Additional regex detectors
+}
+
+// CustomDetector is a user-defined regex detector.
+type CustomDetector struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// LoadPIIConfig reads a PII policy JSON file.
+func LoadPIIConfig(path string) (PIIConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PIIConfig{}, fmt.Errorf("guardrail: read %s: %w", path, err)
+	}
+
+	var cfg PIIConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return PIIConfig{}, fmt.Errorf("guardrail: parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// detector finds candidate values with a regex and confirms them with an
+// optional checksum or format validator.
+type detector struct {
+	name   string
+	re     *regexp.Regexp
+	valid  func(string) bool
+	action string
+}
+
+var builtinDetectors = []detector{
+	{name: DetectorCreditCard, re: regexp.MustCompile(`\d(?:[ -]?\d){12,18}`), valid: validCard},
+	{name: DetectorIBAN, re: regexp.MustCompile(`[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?`), valid: validIBAN},
+	{name: DetectorUSSSN, re: regexp.MustCompile(`\d{3}-\d{2}-\d{4}`), valid: validSSN},
+	{name: DetectorUKNINO, re: regexp.MustCompile(`[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]`)},
+	{name: DetectorEmail, re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	{name: DetectorPhone, re: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?)?\d{2,4}(?:[ .-]?\d{2,4}){2,3}`), valid: validPhone},
+}
+
+// PIIScanner detects PII in text according to a PIIConfig.
+type PIIScanner struct {
+	detectors []detector
+}
+
+// NewPIIScanner compiles a scanner for cfg.
+func NewPIIScanner(cfg PIIConfig) (*PIIScanner, error) {
+	if cfg.Action == "" {
+		cfg.Action = ActionPlaceholder
+	}
+
+	enabled := make(map[string]bool, len(cfg.Detectors))
+	for _, name := range cfg.Detectors {
+		enabled[name] = true
+	}
+
+	s := &PIIScanner{}
+	for _, d := range builtinDetectors {
+		if len(enabled) > 0 && !enabled[d.name] {
+			continue
+		}
+		s.detectors = append(s.detectors, d)
+	}
+	for _, c := range cfg.Custom {
+		if c.Name == "" {
+			return nil, fmt.Errorf("guardrail: custom detector without a name")
+		}
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("guardrail: detector %s: %w", c.Name, err)
+		}
+		s.detectors = append(s.detectors, detector{name: c.Name, re: re})
+	}
+
+	for i := range s.detectors {
+		d := &s.detectors[i]
+		d.action = cfg.Action
+		if a, ok := cfg.Actions[d.name]; ok {
+			d.action = a
+		}
+		switch d.action {
+		case ActionBlock, ActionMask, ActionPlaceholder:
+		default:
+			return nil, fmt.Errorf("guardrail: detector %s: unknown action %q", d.name, d.action)
+		}
+	}
+	return s, nil
+}
+
+// NewRedaction starts redacting one request. Every text of the request
+// should go through the same Redaction so placeholders are numbered
+// consistently and can all be restored in the response.
+func (s *PIIScanner) NewRedaction() *Redaction {
+	return &Redaction{
+		scanner:  s,
+		tokens:   make(map[string]string),
+		values:   make(map[string]string),
+		counters: make(map[string]int),
+		Findings: make(map[string]int),
+	}
+}
+
+// ResumeRedaction continues the redaction of a conversation whose earlier
+// turns issued the placeholders in values (placeholder → original value),
+// as returned by Placeholders. Values seen before keep their placeholder and
+// new ones are numbered after the existing ones, so a placeholder means the
+// same value in every turn.
+func (s *PIIScanner) ResumeRedaction(values map[string]string) *Redaction {
+	r := s.NewRedaction()
+	for tok, value := range values {
+		label, n, ok := parsePlaceholder(tok)
+		if !ok {
+			continue
+		}
+		r.tokens[value] = tok
+		r.values[tok] = value
+		r.counters[label] = max(r.counters[label], n)
+	}
+	return r
+}
+
+// parsePlaceholder splits a placeholder such as <EMAIL_2> into its label
+// and number.
+func parsePlaceholder(tok string) (string, int, bool) {
+	if !strings.HasPrefix(tok, "<") || !strings.HasSuffix(tok, ">") {
+		return "", 0, false
+	}
+	i := strings.LastIndexByte(tok, '_')
+	if i < 2 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(tok[i+1 : len(tok)-1])
+	if err != nil || n <= 0 {
+		return "", 0, false
+	}
+	return tok[1:i], n, true
+}
+
+// Redaction holds the placeholders issued for one request, or for every
+// turn of a conversation; see ResumeRedaction.
+type Redaction struct {
+	scanner  *PIIScanner
+	tokens   map[string]string // original value → placeholder
+	values   map[string]string // placeholder → original value
+	counters map[string]int    // placeholder label → placeholders issued
+
+	Findings map[string]int // Matches per detector
+	Blocked  []string       // Detectors with the block action that matched
+}
+
+type span struct {
+	start, end int
+	det        *detector
+}
+
+// Redact masks or replaces every PII value in text. Values found by a
+// blocking detector are left in place and recorded in Blocked; the caller
+// must then reject the request.
+func (r *Redaction) Redact(text string) string {
+	var spans []span
+	for i := range r.scanner.detectors {
+		d := &r.scanner.detectors[i]
+		for _, loc := range d.re.FindAllStringIndex(text, -1) {
+			if !bounded(text, loc[0], loc[1]) || (d.valid != nil && !d.valid(text[loc[0]:loc[1]])) {
+				continue
+			}
+			if overlaps(spans, loc[0], loc[1]) {
+				continue
+			}
+			spans = append(spans, span{loc[0], loc[1], d})
+		}
+	}
+	if len(spans) == 0 {
+		return text
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	last := 0
+	for _, sp := range spans {
+		r.Findings[sp.det.name]++
+		value := text[sp.start:sp.end]
+
+		var repl string
+		switch sp.det.action {
+		case ActionBlock:
+			r.block(sp.det.name)
+			repl = value
+		case ActionMask:
+			repl = "[" + strings.ToUpper(sp.det.name) + "]"
+		default:
+			repl = r.placeholder(sp.det.name, value)
+		}
+		b.WriteString(text[last:sp.start])
+		b.WriteString(repl)
+		last = sp.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Actions returns the action applied to each detector that matched.
+func (r *Redaction) Actions() map[string]string {
+	out := make(map[string]string, len(r.Findings))
+	for _, d := range r.scanner.detectors {
+		if _, ok := r.Findings[d.name]; ok {
+			out[d.name] = d.action
+		}
+	}
+	return out
+}
+
+func (r *Redaction) block(name string) {
+	for _, b := range r.Blocked {
+		if b == name {
+			return
+		}
+	}
+	r.Blocked = append(r.Blocked, name)
+}
+
+// placeholder returns the placeholder for value, issuing a new one the
+// first time it is seen. The same value always maps to the same placeholder.
+func (r *Redaction) placeholder(name, value string) string {
+	if tok, ok := r.tokens[value]; ok {
+		return tok
+	}
+	label := strings.ToUpper(name)
+	r.counters[label]++
+	tok := fmt.Sprintf("<%s_%d>", label, r.counters[label])
+	r.tokens[value] = tok
+	r.values[tok] = value
+	return tok
+}
+
+// Reversible reports whether placeholders were issued, so that the
+// response needs Restore. It is safe to call on a nil Redaction.
+func (r *Redaction) Reversible() bool {
+	return r != nil && len(r.values) > 0
+}
+
+// Placeholders returns a copy of the placeholders issued so far, mapped to
+// their original values, for ResumeRedaction. It is safe to call on a nil
+// Redaction.
+func (r *Redaction) Placeholders() map[string]string {
+	if r == nil || len(r.values) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(r.values))
+	for tok, value := range r.values {
+		out[tok] = value
+	}
+	return out
+}
+
+// Restore replaces placeholders in text with their original values.
+// It is safe to call on a nil Redaction.
+func (r *Redaction) Restore(text string) string {
+	if r == nil || len(r.values) == 0 {
+		return text
+	}
+	return r.NewRestorer().Restore(text)
+}
+
+// BlockedError is returned when a prompt contains PII that policy forbids
+// sending to a provider.
+type BlockedError struct {
+	Detectors []string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("guardrail: prompt contains sensitive data (%s)", strings.Join(e.Detectors, ", "))
+}
+
+// Err returns a *BlockedError if a blocking detector matched.
+func (r *Redaction) Err() error {
+	if len(r.Blocked) == 0 {
+		return nil
+	}
+	return &BlockedError{Detectors: r.Blocked}
+}
+
+func overlaps(spans []span, start, end int) bool {
+	for _, s := range spans {
+		if start < s.end && s.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// bounded reports whether text[start:end] is not part of a longer word or
+// number.
+func bounded(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// validCard checks the length and Luhn checksum of a card number.
+func validCard(s string) bool {
+	d := digits(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(d) - 1; i >= 0; i-- {
+		n := int(d[i] - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validIBAN checks the length and ISO 7064 mod-97 checksum of an IBAN.
+func validIBAN(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	rearranged := s[4:] + s[:4]
+	rem := 0
+	for _, c := range rearranged {
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			rem = (rem*100 + int(c-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
+
+// validSSN rejects SSNs in ranges that are never issued.
+func validSSN(s string) bool {
+	area, group, serial := s[0:3], s[4:6], s[7:11]
+	if area == "000" || area == "666" || area[0] == '9' {
+		return false
+	}
+	return group != "00" && serial != "0000"
+}
+
+// validPhone accepts 9–15 digit numbers written with an international
+// prefix or separators, so bare integers such as ids and timestamps are
+// not mistaken for phone numbers.
+func validPhone(s string) bool {
+	n := len(digits(s))
+	if n < 9 || n > 15 {
+		return false
+	}
+	return strings.HasPrefix(s, "+") || strings.ContainsAny(s, " .-()")
+}
@@ -0,0 +1,170 @@
+package guardrail
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidCard(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want bool
+	}{
+		{"visa", "4111111111111111", true},
+		{"visa with spaces", "4111 1111 1111 1111", true},
+		{"mastercard with dashes", "5555-5555-5555-4444", true},
+		{"amex 15 digits", "378282246310005", true},
+		{"bad checksum", "4111111111111112", false},
+		{"too short", "411111111111", false},
+		{"too long", "41111111111111111111", false},
+		{"all zeros", "0000000000000", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validCard(tt.in); got != tt.want {
+				t.Errorf("validCard(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidIBAN(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want bool
+	}{
+		{"gb", "GB82WEST12345698765432", true},
+		{"gb grouped", "GB82 WEST 1234 5698 7654 32", true},
+		{"de", "DE89370400440532013000", true},
+		{"bad checksum", "GB82WEST12345698765433", false},
+		{"swapped digits", "DE89370400440532013003", false},
+		{"lower case", "gb82west12345698765432", false},
+		{"too short", "GB82WEST1234", false},
+		{"punctuation", "GB82-WEST-1234-5698-7654-32", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validIBAN(tt.in); got != tt.want {
+				t.Errorf("validIBAN(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      PIIConfig
+		in       string
+		want     string
+		findings map[string]int
+		blocked  bool
+	}{
+		{
+			name:     "placeholders number each value once",
+			cfg:      PIIConfig{Action: ActionPlaceholder},
+			in:       "mail a@example.com, then b@example.com, then a@example.com",
+			want:     "mail <EMAIL_1>, then <EMAIL_2>, then <EMAIL_1>",
+			findings: map[string]int{DetectorEmail: 3},
+		},
+		{
+			name:     "mask",
+			cfg:      PIIConfig{Action: ActionMask},
+			in:       "card 4111 1111 1111 1111 on file",
+			want:     "card [CREDIT_CARD] on file",
+			findings: map[string]int{DetectorCreditCard: 1},
+		},
+		{
+			name: "failed luhn check is not a card",
+			cfg:  PIIConfig{Action: ActionMask, Detectors: []string{DetectorCreditCard}},
+			in:   "order 4111 1111 1111 1112",
+			want: "order 4111 1111 1111 1112",
+		},
+		{
+			name:     "iban",
+			cfg:      PIIConfig{Action: ActionPlaceholder},
+			in:       "pay to DE89 3704 0044 0532 0130 00 today",
+			want:     "pay to <IBAN_1> today",
+			findings: map[string]int{DetectorIBAN: 1},
+		},
+		{
+			name:     "block leaves the value and reports it",
+			cfg:      PIIConfig{Action: ActionMask, Actions: map[string]string{DetectorUSSSN: ActionBlock}},
+			in:       "ssn 123-45-6789",
+			want:     "ssn 123-45-6789",
+			findings: map[string]int{DetectorUSSSN: 1},
+			blocked:  true,
+		},
+		{
+			name: "inside a word",
+			cfg:  PIIConfig{Action: ActionMask, Detectors: []string{DetectorCreditCard}},
+			in:   "ref x4111111111111111",
+			want: "ref x4111111111111111",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewPIIScanner(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := s.NewRedaction()
+			if got := r.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			for name, n := range tt.findings {
+				if r.Findings[name] != n {
+					t.Errorf("Findings[%s] = %d, want %d", name, r.Findings[name], n)
+				}
+			}
+			var blocked *BlockedError
+			if got := errors.As(r.Err(), &blocked); got != tt.blocked {
+				t.Errorf("Err() = %v, want blocked %v", r.Err(), tt.blocked)
+			}
+			if !tt.blocked {
+				if got := r.Restore(r.Redact(tt.in)); tt.cfg.Action == ActionPlaceholder && got != tt.in {
+					t.Errorf("Restore = %q, want %q", got, tt.in)
+				}
+			}
+		})
+	}
+}
+
+func TestResumeRedaction(t *testing.T) {
+	s, err := NewPIIScanner(PIIConfig{Action: ActionPlaceholder, Custom: []CustomDetector{{Name: "employee_id", Pattern: `E\d{6}`}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := s.NewRedaction()
+	first.Redact("a@example.com and b@example.com, badge E123456")
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		in     string
+		want   string
+	}{
+		{"earlier value keeps its placeholder", first.Placeholders(), "write to b@example.com", "write to <EMAIL_2>"},
+		{"new value gets the next number", first.Placeholders(), "write to c@example.com", "write to <EMAIL_3>"},
+		{"label with an underscore", first.Placeholders(), "badges E123456 and E654321", "badges <EMPLOYEE_ID_1> and <EMPLOYEE_ID_2>"},
+		{"no earlier turns", nil, "write to c@example.com", "write to <EMAIL_1>"},
+		{"malformed entries are ignored", map[string]string{"EMAIL_7": "x@example.com", "<EMAIL_x>": "y@example.com"}, "write to c@example.com", "write to <EMAIL_1>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := s.ResumeRedaction(tt.values)
+			if got := r.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	// A later turn's reply may repeat placeholders from any turn.
+	r := s.ResumeRedaction(first.Placeholders())
+	r.Redact("write to c@example.com")
+	if got, want := r.Restore("<EMAIL_1>, <EMAIL_3>, <EMPLOYEE_ID_1>"), "a@example.com, c@example.com, E123456"; got != want {
+		t.Errorf("Restore = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package guardrail
+
+import "strings"
+
+// Restorer substitutes original values back into placeholder text. Used on
+// a stream, it holds back a trailing partial placeholder until the next
+// chunk shows whether it completes one, so placeholders split across chunk
+// boundaries are still restored.
+type Restorer struct {
+	replacer *strings.Replacer
+	tokens   []string
+	pending  string
+}
+
+// NewRestorer returns a restorer for the placeholders issued so far, or nil
+// if there are none. A nil Restorer passes text through unchanged.
+func (r *Redaction) NewRestorer() *Restorer {
+	if r == nil || len(r.values) == 0 {
+		return nil
+	}
+
+	pairs := make([]string, 0, 2*len(r.values))
+	tokens := make([]string, 0, len(r.values))
+	for tok, value := range r.values {
+		pairs = append(pairs, tok, value)
+		tokens = append(tokens, tok)
+	}
+	return &Restorer{replacer: strings.NewReplacer(pairs...), tokens: tokens}
+}
+
+// Restore replaces every placeholder in a complete text.
+func (r *Restorer) Restore(text string) string {
+	if r == nil {
+		return text
+	}
+	return r.replacer.Replace(text)
+}
+
+// Write adds a stream chunk and returns the restored text that is safe to
+// emit now.
+func (r *Restorer) Write(chunk string) string {
+	if r == nil {
+		return chunk
+	}
+
+	r.pending += chunk
+	cut := len(r.pending)
+	// Placeholders contain a single '<', so only text from the last one can
+	// be an unfinished placeholder.
+	if i := strings.LastIndexByte(r.pending, '<'); i >= 0 && r.partial(r.pending[i:]) {
+		cut = i
+	}
+
+	out := r.replacer.Replace(r.pending[:cut])
+	r.pending = r.pending[cut:]
+	return out
+}
+
+// Flush returns whatever is still held back at the end of the stream.
+func (r *Restorer) Flush() string {
+	if r == nil {
+		return ""
+	}
+	out := r.replacer.Replace(r.pending)
+	r.pending = ""
+	return out
+}
+
+// partial reports whether s is a proper prefix of some placeholder.
+func (r *Restorer) partial(s string) bool {
+	for _, tok := range r.tokens {
+		if len(s) < len(tok) && strings.HasPrefix(tok, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/logfile"
+)
+
+// Summary is the aggregated usage of one tenant and model on one UTC day.
+type Summary struct {
+	Day          string  `json:"day"` // YYYY-MM-DD
+	Tenant       string  `json:"tenant"`
+	Model        string  `json:"model"`
+	Requests     int64   `json:"requests"`
+	CacheHits    int64   `json:"cache_hits"`
+	Errors       int64   `json:"errors"`
+	PromptTokens int64   `json:"prompt_tokens"`
+	CachedTokens int64   `json:"cached_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// ReadFiles calls fn for every record in the ledger at path, including its
+// rotated files, in write order.
+func ReadFiles(path string, fn func(Record) error) error {
+	files, err := logfile.Files(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		if err := readFile(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(name string, fn func(Record) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("ledger: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("ledger: %s:%d: %w", name, line, err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ledger: read %s: %w", name, err)
+	}
+	return nil
+}
+
+// Aggregate sums the ledger at path by tenant, model and UTC day for records
+// in [from, to). Zero times leave that end of the range open.
+func Aggregate(path string, from, to time.Time) ([]Summary, error) {
+	type key struct{ day, tenant, model string }
+	sums := make(map[key]*Summary)
+
+	err := ReadFiles(path, func(r Record) error {
+		if (!from.IsZero() && r.Time.Before(from)) || (!to.IsZero() && !r.Time.Before(to)) {
+			return nil
+		}
+
+		k := key{r.Time.UTC().Format("2006-01-02"), r.Tenant, r.Model}
+		s, ok := sums[k]
+		if !ok {
+			s = &Summary{Day: k.day, Tenant: k.tenant, Model: k.model}
+			sums[k] = s
+		}
+
+		s.Requests++
+		switch r.Outcome {
+		case "success":
+		case "cache_hit":
+			s.CacheHits++
+		default:
+			s.Errors++
+		}
+		s.PromptTokens += int64(r.PromptTokens)
+		s.CachedTokens += int64(r.CachedTokens)
+		s.OutputTokens += int64(r.OutputTokens)
+		s.CostUSD += r.CostUSD
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Summary, 0, len(sums))
+	for _, s := range sums {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Tenant != b.Tenant {
+			return a.Tenant < b.Tenant
+		}
+		return a.Model < b.Model
+	})
+	return out, nil
+}
+
+// WriteCSV writes summaries as CSV with a header row.
+func WriteCSV(w io.Writer, rows []Summary) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"day", "tenant", "model", "requests", "cache_hits", "errors",
+		"prompt_tokens", "cached_tokens", "output_tokens", "cost_usd"})
+	for _, s := range rows {
+		cw.Write([]string{
+			s.Day, s.Tenant, s.Model,
+			strconv.FormatInt(s.Requests, 10),
+			strconv.FormatInt(s.CacheHits, 10),
+			strconv.FormatInt(s.Errors, 10),
+			strconv.FormatInt(s.PromptTokens, 10),
+			strconv.FormatInt(s.CachedTokens, 10),
+			strconv.FormatInt(s.OutputTokens, 10),
+			strconv.FormatFloat(s.CostUSD, 'f', 6, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes summaries as an indented JSON array.
+func WriteJSON(w io.Writer, rows []Summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
@@ -0,0 +1,77 @@
+package ledger
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	// Small files so the records span rotated files.
+	sink, err := NewFileSink(path, 300, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day1 := time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	records := []Record{
+		{Time: day1, Tenant: "acme", Model: "gpt-4o", PromptTokens: 10, OutputTokens: 5, CostUSD: 0.5, Outcome: "success"},
+		{Time: day1, Tenant: "acme", Model: "gpt-4o", PromptTokens: 10, CachedTokens: 4, Outcome: "cache_hit"},
+		{Time: day1, Tenant: "acme", Model: "gpt-4o", PromptTokens: 3, CostUSD: 0.25, Outcome: "hedge_lost"},
+		{Time: day1, Tenant: "beta", Model: "gpt-4o", PromptTokens: 1, Outcome: "resource_exhausted"},
+		{Time: day2, Tenant: "acme", Model: "gpt-4o", PromptTokens: 7, OutputTokens: 1, CostUSD: 1, Outcome: "success"},
+	}
+	for _, r := range records {
+		if err := sink.Write(context.Background(), []Record{r}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.Close()
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []Summary
+	}{
+		{"everything", time.Time{}, time.Time{}, []Summary{
+			{Day: "2024-06-01", Tenant: "acme", Model: "gpt-4o", Requests: 3, CacheHits: 1, Errors: 1, PromptTokens: 23, CachedTokens: 4, OutputTokens: 5, CostUSD: 0.75},
+			{Day: "2024-06-01", Tenant: "beta", Model: "gpt-4o", Requests: 1, Errors: 1, PromptTokens: 1},
+			{Day: "2024-06-02", Tenant: "acme", Model: "gpt-4o", Requests: 1, PromptTokens: 7, OutputTokens: 1, CostUSD: 1},
+		}},
+		{"from the second day", day2, time.Time{}, []Summary{
+			{Day: "2024-06-02", Tenant: "acme", Model: "gpt-4o", Requests: 1, PromptTokens: 7, OutputTokens: 1, CostUSD: 1},
+		}},
+		{"up to the second day", time.Time{}, day2, []Summary{
+			{Day: "2024-06-01", Tenant: "acme", Model: "gpt-4o", Requests: 3, CacheHits: 1, Errors: 1, PromptTokens: 23, CachedTokens: 4, OutputTokens: 5, CostUSD: 0.75},
+			{Day: "2024-06-01", Tenant: "beta", Model: "gpt-4o", Requests: 1, Errors: 1, PromptTokens: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Aggregate(path, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Aggregate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b strings.Builder
+	rows := []Summary{{Day: "2024-06-01", Tenant: "acme", Model: "gpt-4o", Requests: 2, PromptTokens: 10, CostUSD: 0.5}}
+	if err := WriteCSV(&b, rows); err != nil {
+		t.Fatal(err)
+	}
+	want := "day,tenant,model,requests,cache_hits,errors,prompt_tokens,cached_tokens,output_tokens,cost_usd\n" +
+		"2024-06-01,acme,gpt-4o,2,0,0,10,0,0,0.500000\n"
+	if b.String() != want {
+		t.Errorf("WriteCSV = %q, want %q", b.String(), want)
+	}
+}
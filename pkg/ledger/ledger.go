@@ -0,0 +1,285 @@
+// Package ledger durably records the usage of every completed request so
+// it can be billed back to tenants.
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+)
+
+// Record is one completed request.
+type Record struct {
+	Time           time.Time `json:"time"`
+	Method         string    `json:"method,omitempty"` // Infer, InferStream, Chat, Embed, SubmitBatch, Judge, Summarize, SemanticCache, or Hedge for a hedged race's losing call
+	Tenant         string    `json:"tenant"`
+	Team           string    `json:"team,omitempty"`
+	Model          string    `json:"model"`
+	Provider       string    `json:"provider"`
+	KeyFingerprint string    `json:"key_fingerprint,omitempty"`
+	PromptTokens   int32     `json:"prompt_tokens"`
+	CachedTokens   int32     `json:"cached_tokens,omitempty"`
+	OutputTokens   int32     `json:"output_tokens"`
+	CostUSD        float64   `json:"cost_usd"`
+	CacheStatus    string    `json:"cache_status"` // hit or miss
+	LatencyMS      float64   `json:"latency_ms"`
+	Outcome        string    `json:"outcome"` // success, cache_hit, hedge_lost, or the gRPC status, e.g. resource_exhausted
+}
+
+// Sink persists batches of records.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, records []Record) error
+	Close() error
+}
+
+const (
+	queueSize     = 10000
+	batchSize     = 256
+	flushInterval = time.Second
+	writeTimeout  = 10 * time.Second
+	writeAttempts = 3
+	retryBackoff  = 200 * time.Millisecond
+)
+
+// Ledger batches records in the background and writes them to every sink,
+// so recording never waits on disk or network I/O. Batches a sink fails to
+// take after retries, and records that arrive while the queue is full, are
+// spilled to a JSONL file per sink and replayed once the sink recovers, so
+// no record is lost while the spill directory is writable.
+type Ledger struct {
+	sinks    []Sink
+	spillDir string
+	ch       chan Record
+	done     chan struct{}
+
+	spillMu sync.Mutex // Serializes appends to spill files
+}
+
+// New starts a ledger writing to sinks, spilling to files in spillDir. Records
+// spilled by an earlier run are replayed.
+func New(spillDir string, sinks ...Sink) (*Ledger, error) {
+	if err := os.MkdirAll(spillDir, 0o750); err != nil {
+		return nil, fmt.Errorf("ledger: create spill dir: %w", err)
+	}
+	l := &Ledger{
+		sinks:    sinks,
+		spillDir: spillDir,
+		ch:       make(chan Record, queueSize),
+		done:     make(chan struct{}),
+	}
+	go l.run()
+	return l, nil
+}
+
+// Append queues a record. It never blocks: if the sinks have fallen so far
+// behind that the queue is full, the record is spilled to disk instead.
+func (l *Ledger) Append(r Record) {
+	select {
+	case l.ch <- r:
+	default:
+		for _, s := range l.sinks {
+			l.spill(s, []Record{r})
+		}
+	}
+}
+
+// Close flushes queued records and closes the sinks. Append must not be
+// called afterward. Records still spilled are replayed on the next start.
+func (l *Ledger) Close() error {
+	close(l.ch)
+	<-l.done
+
+	var firstErr error
+	for _, s := range l.sinks {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (l *Ledger) run() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	l.replay()
+	batch := make([]Record, 0, batchSize)
+	for {
+		select {
+		case r, ok := <-l.ch:
+			if !ok {
+				l.flush(batch)
+				return
+			}
+			batch = append(batch, r)
+			if len(batch) >= batchSize {
+				l.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			l.flush(batch)
+			batch = batch[:0]
+			l.replay()
+		}
+	}
+}
+
+func (l *Ledger) flush(batch []Record) {
+	if len(batch) == 0 {
+		return
+	}
+	for _, s := range l.sinks {
+		if err := l.write(s, batch); err != nil {
+			log.Printf("[ledger] %s: failed to write %d records, spilling to disk: %v", s.Name(), len(batch), err)
+			l.spill(s, batch)
+		}
+	}
+}
+
+// write sends batch to s, retrying with exponential backoff.
+func (l *Ledger) write(s Sink, batch []Record) error {
+	var err error
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err = s.Write(ctx, batch)
+		cancel()
+		if err == nil {
+			metrics.LedgerRecordsTotal.WithLabelValues(s.Name()).Add(float64(len(batch)))
+			return nil
+		}
+		metrics.LedgerWriteErrorsTotal.WithLabelValues(s.Name()).Inc()
+		if attempt == writeAttempts {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Spill files
+// ---------------------------------------------------------------------------
+
+func (l *Ledger) spillPath(s Sink) string {
+	return filepath.Join(l.spillDir, s.Name()+".jsonl")
+}
+
+// spill appends records to s's spill file. If even that fails the records
+// are lost, and logged with their count so they can be reconciled.
+func (l *Ledger) spill(s Sink, records []Record) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			log.Printf("[ledger] %s: failed to encode spilled record: %v", s.Name(), err)
+			return
+		}
+	}
+
+	l.spillMu.Lock()
+	defer l.spillMu.Unlock()
+	f, err := os.OpenFile(l.spillPath(s), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err == nil {
+		_, err = f.Write(buf.Bytes())
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		metrics.LedgerDroppedTotal.WithLabelValues(s.Name()).Add(float64(len(records)))
+		log.Printf("[ledger] %s: LOST %d records, spill failed: %v", s.Name(), len(records), err)
+		return
+	}
+	metrics.LedgerSpilledTotal.WithLabelValues(s.Name()).Add(float64(len(records)))
+}
+
+// replay writes spilled records back to their sinks. Each spill file is
+// first moved aside, so records spilled meanwhile go to a fresh file;
+// whatever a sink still refuses is kept for the next replay.
+func (l *Ledger) replay() {
+	for _, s := range l.sinks {
+		path := l.spillPath(s)
+		pending := path + ".replay"
+		if _, err := os.Stat(pending); err != nil {
+			l.spillMu.Lock()
+			err := os.Rename(path, pending)
+			l.spillMu.Unlock()
+			if err != nil {
+				continue // Nothing spilled
+			}
+		}
+
+		records, err := readSpill(pending)
+		if err != nil {
+			log.Printf("[ledger] %s: failed to read spilled records: %v", s.Name(), err)
+			continue
+		}
+		for len(records) > 0 {
+			n := min(len(records), batchSize)
+			if err := l.write(s, records[:n]); err != nil {
+				break
+			}
+			records = records[n:]
+		}
+		if len(records) > 0 {
+			if err := rewriteSpill(pending, records); err != nil {
+				log.Printf("[ledger] %s: failed to update spilled records: %v", s.Name(), err)
+			}
+			continue
+		}
+		os.Remove(pending)
+		log.Printf("[ledger] %s: replayed spilled records", s.Name())
+	}
+}
+
+// readSpill reads a spill file. Lines that do not decode, such as a partial
+// last line left by a crash mid-spill, are skipped.
+func readSpill(path string) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(line, &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// rewriteSpill replaces path atomically with records.
+func rewriteSpill(path string, records []Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
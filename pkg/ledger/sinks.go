@@ -0,0 +1,192 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/logfile"
+)
+
+// ---------------------------------------------------------------------------
+// File sink
+// ---------------------------------------------------------------------------
+
+// FileSink appends records as JSON lines to a rotating local file and
+// fsyncs after every batch.
+type FileSink struct {
+	w *logfile.Writer
+}
+
+// NewFileSink opens a JSONL ledger file. maxBytes of 0 disables rotation;
+// maxBackups of 0 keeps every rotated file.
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	w, err := logfile.Open(path, maxBytes, maxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("ledger: %w", err)
+	}
+	return &FileSink{w: w}, nil
+}
+
+func (s *FileSink) Name() string { return "file" }
+
+func (s *FileSink) Write(ctx context.Context, records []Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("ledger: encode: %w", err)
+		}
+	}
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("ledger: write: %w", err)
+	}
+	return s.w.Sync()
+}
+
+func (s *FileSink) Close() error { return s.w.Close() }
+
+// ---------------------------------------------------------------------------
+// Redis stream sink
+// ---------------------------------------------------------------------------
+
+// RedisStreamSink adds each record to a Redis stream as a "record" field
+// holding its JSON, for consumption by downstream billing jobs.
+type RedisStreamSink struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewRedisStreamSink creates a sink writing to stream. If maxLen > 0 the
+// stream is approximately capped at that many entries.
+func NewRedisStreamSink(addr, password string, db int, stream string, maxLen int64) *RedisStreamSink {
+	return &RedisStreamSink{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+		stream: stream,
+		maxLen: maxLen,
+	}
+}
+
+func (s *RedisStreamSink) Name() string { return "redis" }
+
+func (s *RedisStreamSink) Write(ctx context.Context, records []Record) error {
+	pipe := s.client.Pipeline()
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("ledger: encode: %w", err)
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: s.stream,
+			MaxLen: s.maxLen,
+			Approx: s.maxLen > 0,
+			Values: map[string]interface{}{"record": data},
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("ledger: xadd: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStreamSink) Close() error { return s.client.Close() }
+
+// ---------------------------------------------------------------------------
+// SQL sink
+// ---------------------------------------------------------------------------
+
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLSink inserts records into a table in a Postgres-compatible database
+// (Postgres, CockroachDB, TimescaleDB, ...). The database/sql driver must be
+// linked into the binary.
+type SQLSink struct {
+	db     *sql.DB
+	insert string
+}
+
+// NewSQLSink opens the database and creates table if it does not exist,
+// adding columns introduced since to a table created by an older version.
+func NewSQLSink(driver, dsn, table string) (*SQLSink, error) {
+	if !identRe.MatchString(table) {
+		return nil, fmt.Errorf("ledger: invalid table name %q", table)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("ledger: open %s: %w", driver, err)
+	}
+
+	create := `CREATE TABLE IF NOT EXISTS ` + table + ` (
+		time            TIMESTAMPTZ      NOT NULL,
+		method          TEXT             NOT NULL DEFAULT '',
+		tenant          TEXT             NOT NULL,
+		team            TEXT             NOT NULL,
+		model           TEXT             NOT NULL,
+		provider        TEXT             NOT NULL,
+		key_fingerprint TEXT             NOT NULL,
+		prompt_tokens   INTEGER          NOT NULL,
+		cached_tokens   INTEGER          NOT NULL,
+		output_tokens   INTEGER          NOT NULL,
+		cost_usd        DOUBLE PRECISION NOT NULL,
+		cache_status    TEXT             NOT NULL,
+		latency_ms      DOUBLE PRECISION NOT NULL,
+		outcome         TEXT             NOT NULL
+	)`
+	if _, err := db.Exec(create); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ledger: create table: %w", err)
+	}
+	// Tables created before records had a method lack the column; their
+	// existing rows keep an empty one.
+	if _, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN IF NOT EXISTS method TEXT NOT NULL DEFAULT ''`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ledger: add method column: %w", err)
+	}
+
+	return &SQLSink{
+		db: db,
+		insert: `INSERT INTO ` + table + ` (time, method, tenant, team, model, provider, key_fingerprint,
+			prompt_tokens, cached_tokens, output_tokens, cost_usd, cache_status, latency_ms, outcome)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+	}, nil
+}
+
+func (s *SQLSink) Name() string { return "sql" }
+
+func (s *SQLSink) Write(ctx context.Context, records []Record) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ledger: begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.insert)
+	if err != nil {
+		return fmt.Errorf("ledger: prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, r := range records {
+		if _, err := stmt.ExecContext(ctx, r.Time, r.Method, r.Tenant, r.Team, r.Model, r.Provider, r.KeyFingerprint,
+			r.PromptTokens, r.CachedTokens, r.OutputTokens, r.CostUSD, r.CacheStatus, r.LatencyMS, r.Outcome); err != nil {
+			return fmt.Errorf("ledger: insert: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ledger: commit: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLSink) Close() error { return s.db.Close() }
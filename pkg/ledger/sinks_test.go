@@ -0,0 +1,109 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingDriver is a database/sql driver that records the statements it
+// is sent and their arguments, and returns no rows.
+type recordingDriver struct {
+	mu    sync.Mutex
+	execs []recordedExec
+}
+
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{c.d, query}, nil
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *recordingConn) Commit() error             { return nil }
+func (c *recordingConn) Rollback() error           { return nil }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, recordedExec{s.query, args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("recording driver: queries are not supported")
+}
+
+var testDriver = &recordingDriver{}
+
+func init() { sql.Register("ledgertest", testDriver) }
+
+func TestSQLSink(t *testing.T) {
+	if _, err := NewSQLSink("ledgertest", "", "bad-name"); err == nil {
+		t.Error("NewSQLSink accepted an invalid table name")
+	}
+
+	s, err := NewSQLSink("ledgertest", "", "usage_ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	at := time.Date(2024, 8, 6, 12, 0, 0, 0, time.UTC)
+	err = s.Write(context.Background(), []Record{
+		{Time: at, Method: "SubmitBatch", Tenant: "acme", Model: "gpt-4o", Provider: "openai", PromptTokens: 10, OutputTokens: 5, CostUSD: 0.01, CacheStatus: "miss", Outcome: "success"},
+		{Time: at, Method: "Summarize", Tenant: "acme", Model: "gpt-4o-mini", Provider: "openai", CacheStatus: "miss", Outcome: "success"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDriver.mu.Lock()
+	defer testDriver.mu.Unlock()
+	var creates, alters int
+	var methods []string
+	for _, e := range testDriver.execs {
+		switch {
+		case strings.HasPrefix(e.query, "CREATE TABLE"):
+			creates++
+			if !strings.Contains(e.query, "method ") {
+				t.Errorf("CREATE TABLE has no method column:\n%s", e.query)
+			}
+		case strings.HasPrefix(e.query, "ALTER TABLE usage_ledger ADD COLUMN IF NOT EXISTS method"):
+			alters++
+		case strings.HasPrefix(e.query, "INSERT INTO usage_ledger (time, method,"):
+			if len(e.args) != 14 {
+				t.Fatalf("INSERT bound %d arguments, want 14", len(e.args))
+			}
+			methods = append(methods, e.args[1].(string))
+		default:
+			t.Errorf("unexpected statement %q", e.query)
+		}
+	}
+	if creates != 1 || alters != 1 {
+		t.Errorf("ran %d CREATE and %d ALTER statements, want one of each", creates, alters)
+	}
+	if strings.Join(methods, ",") != "SubmitBatch,Summarize" {
+		t.Errorf("inserted methods %q, want SubmitBatch and Summarize", methods)
+	}
+}
@@ -0,0 +1,142 @@
+// Package logfile provides an append-only file writer with size-based
+// rotation, shared by the usage ledger and the audit log.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotateFormat names rotated files; it sorts lexically in time order.
+const rotateFormat = "20060102T150405.000000000"
+
+// Writer appends to a file, rotating it to "<path>.<timestamp>" when it
+// would grow past maxBytes. It is safe for concurrent use.
+type Writer struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64 // 0 = never rotate
+	maxBackups int   // 0 = keep all rotated files
+	f          *os.File
+	size       int64
+}
+
+// Open opens path for appending, creating it and its directory if needed.
+func Open(path string, maxBytes int64, maxBackups int) (*Writer, error) {
+	w := &Writer{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("logfile: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends p, rotating first if p would push the file past maxBytes.
+// A single Write is never split across files.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxBytes > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Sync flushes the file to stable storage.
+func (w *Writer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Sync()
+}
+
+// Close closes the file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("logfile: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("logfile: %w", err)
+	}
+	w.f = f
+	w.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file aside and starts a new one. Callers hold w.mu.
+func (w *Writer) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("logfile: close for rotation: %w", err)
+	}
+	rotated := w.path + "." + time.Now().UTC().Format(rotateFormat)
+	if err := os.Rename(w.path, rotated); err != nil {
+		return fmt.Errorf("logfile: rotate: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if w.maxBackups > 0 {
+		backups, err := Backups(w.path)
+		if err != nil {
+			return err
+		}
+		for len(backups) > w.maxBackups {
+			os.Remove(backups[0])
+			backups = backups[1:]
+		}
+	}
+	return nil
+}
+
+// Backups returns the rotated files of path, oldest first.
+func Backups(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("logfile: %w", err)
+	}
+
+	backups := matches[:0]
+	for _, m := range matches {
+		suffix := strings.TrimPrefix(m, path+".")
+		if _, err := time.Parse(rotateFormat, suffix); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// Files returns every file of path in write order: rotated files oldest
+// first, then the live file if it exists.
+func Files(path string) ([]string, error) {
+	files, err := Backups(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files, nil
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// KeyHealthSample is one key's health as reported by key_status.
+type KeyHealthSample struct {
+	Provider    string
+	Fingerprint string
+	Label       string
+	Status      string // active, rate_limited or quarantined
+	Reason      string
+}
+
+var keyStatusDesc = prometheus.NewDesc(
+	"key_status",
+	"Current status of each API key (1 for the key's status). Keys are identified by fingerprint, never the raw key.",
+	[]string{"provider", "fingerprint", "key", "status", "reason"},
+	nil,
+)
+
+// keyHealthCollector reports key health at scrape time so time-based
+// transitions (e.g. a rate limit expiring) are never stale.
+type keyHealthCollector struct {
+	source func() []KeyHealthSample
+}
+
+// RegisterKeyHealth registers a collector that exports key_status from source.
+func RegisterKeyHealth(source func() []KeyHealthSample) {
+	prometheus.MustRegister(&keyHealthCollector{source: source})
+}
+
+func (c *keyHealthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- keyStatusDesc
+}
+
+func (c *keyHealthCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.source() {
+		ch <- prometheus.MustNewConstMetric(keyStatusDesc, prometheus.GaugeValue, 1,
+			s.Provider, s.Fingerprint, s.Label, s.Status, s.Reason)
+	}
+}
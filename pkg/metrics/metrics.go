@@ -2,8 +2,11 @@
 package metrics
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
 )
 
 var (
@@ -17,6 +20,17 @@ var (
 		[]string{"provider", "model", "cache_status"},
 	)
 
+	// TimeToFirstChunk tracks how long provider streams take to deliver
+	// their first chunk.
+	TimeToFirstChunk = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "time_to_first_chunk_seconds",
+			Help:    "Time from opening a provider stream to its first chunk, in seconds.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"provider", "model"},
+	)
+
 	// TokenUsageTotal tracks the total number of tokens consumed.
 	TokenUsageTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -76,7 +90,386 @@ var (
 			Name: "requests_total",
 			Help: "Total number of requests by status.",
 		},
-		[]string{"status"}, // "success", "error", "cache_hit"
+		[]string{"status"}, // "success", "error", "cache_hit", "shed", "denied", "quota_exceeded", "pii_blocked", "output_blocked", "injection_blocked", "invalid_format", "invalid_params", "context_exceeded"
+	)
+
+	// HedgeRequestsTotal tracks hedged requests by outcome.
+	HedgeRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hedge_requests_total",
+			Help: "Total number of hedged requests by outcome.",
+		},
+		[]string{"provider", "outcome"}, // "sent", "hedge_won", "primary_won", "budget_exhausted", "at_limit"
+	)
+
+	// ConcurrencyLimit tracks the current adaptive concurrency limit.
+	ConcurrencyLimit = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "concurrency_limit",
+			Help: "Current adaptive concurrency limit per provider.",
+		},
+		[]string{"provider"},
+	)
+
+	// ConcurrencyInFlight tracks requests holding a concurrency slot.
+	ConcurrencyInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "concurrency_in_flight",
+			Help: "Number of requests holding a concurrency slot per provider.",
+		},
+		[]string{"provider"},
+	)
+
+	// ConcurrencyQueued tracks requests waiting for a concurrency slot.
+	ConcurrencyQueued = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "concurrency_queued",
+			Help: "Number of requests waiting for a concurrency slot per provider and priority.",
+		},
+		[]string{"provider", "priority"},
+	)
+
+	// QueueWaitSeconds tracks how long requests waited for a concurrency slot.
+	QueueWaitSeconds = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "queue_wait_seconds",
+			Help:    "Time spent waiting for a concurrency slot in seconds.",
+			Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"provider", "priority", "outcome"}, // outcome: "admitted" or "shed"
+	)
+
+	// LoadShedTotal tracks requests rejected by the concurrency limiters.
+	LoadShedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "load_shed_total",
+			Help: "Total number of requests shed by concurrency limiting.",
+		},
+		[]string{"provider", "reason"}, // "queue_full", "queue_timeout", "global_cap"
+	)
+
+	// KeyRequestsTotal tracks requests sent per API key. Keys are identified
+	// by their configured label (or fingerprint), never the raw key.
+	KeyRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "key_requests_total",
+			Help: "Total number of provider requests per API key.",
+		},
+		[]string{"provider", "key", "org", "project", "tier"},
+	)
+
+	// KeyTokensTotal tracks tokens consumed per API key.
+	KeyTokensTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "key_tokens_total",
+			Help: "Total number of tokens consumed per API key.",
+		},
+		[]string{"provider", "key", "org", "project", "tier"},
+	)
+
+	// KeyPoolKeys tracks the number of keys in each provider's pool.
+	KeyPoolKeys = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "keypool_keys",
+			Help: "Number of API keys currently in the pool.",
+		},
+		[]string{"provider"},
+	)
+
+	// KeyPoolLastReload tracks when each key pool was last reloaded.
+	KeyPoolLastReload = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "keypool_last_reload_timestamp_seconds",
+			Help: "Unix time of the last successful key pool reload that changed the pool.",
+		},
+		[]string{"provider"},
+	)
+
+	// KeyPoolReloadErrorsTotal tracks failed key pool reloads.
+	KeyPoolReloadErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "keypool_reload_errors_total",
+			Help: "Total number of failed key pool reloads.",
+		},
+		[]string{"provider"},
+	)
+
+	// KeyPoolLastReloadSuccess is 1 if the last reload attempt succeeded, 0 otherwise.
+	KeyPoolLastReloadSuccess = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "keypool_last_reload_success",
+			Help: "Whether the last key pool reload attempt succeeded (1) or failed (0).",
+		},
+		[]string{"provider"},
+	)
+
+	// KeyQuarantinesTotal tracks keys quarantined after auth failures.
+	KeyQuarantinesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "key_quarantines_total",
+			Help: "Total number of API keys quarantined after upstream auth failures.",
+		},
+		[]string{"provider", "fingerprint", "key", "reason"},
+	)
+
+	// CostUSDTotal tracks provider spend in USD.
+	CostUSDTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cost_usd_total",
+			Help: "Total provider cost in USD.",
+		},
+		[]string{"provider", "model", "tenant"},
+	)
+
+	// CacheSavedCostUSDTotal tracks the cost avoided by semantic cache hits.
+	CacheSavedCostUSDTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_saved_cost_usd_total",
+			Help: "Total provider cost in USD avoided by serving responses from the semantic cache.",
+		},
+		[]string{"provider", "model", "tenant"},
+	)
+
+	// UnpricedRequestsTotal counts requests for models missing from the price table.
+	UnpricedRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "unpriced_requests_total",
+			Help: "Total number of requests whose model has no entry in the price table.",
+		},
+		[]string{"model"},
+	)
+
+	// LedgerRecordsTotal counts usage records written, by sink.
+	LedgerRecordsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ledger_records_total",
+			Help: "Total number of usage ledger records written.",
+		},
+		[]string{"sink"},
+	)
+
+	// LedgerWriteErrorsTotal counts failed ledger batch writes, by sink.
+	LedgerWriteErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ledger_write_errors_total",
+			Help: "Total number of usage ledger batches that failed to write.",
+		},
+		[]string{"sink"},
+	)
+
+	// LedgerSpilledTotal counts usage records spilled to disk for a sink.
+	LedgerSpilledTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ledger_spilled_total",
+			Help: "Total number of usage ledger records spilled to disk after failed writes or a full queue.",
+		},
+		[]string{"sink"},
+	)
+
+	// LedgerDroppedTotal counts usage records lost because they could not
+	// be spilled either.
+	LedgerDroppedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ledger_dropped_total",
+			Help: "Total number of usage ledger records lost because spilling them failed.",
+		},
+		[]string{"sink"},
+	)
+
+	// AuditEventsTotal counts audit events written, by sink.
+	AuditEventsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "audit_events_total",
+			Help: "Total number of audit events written.",
+		},
+		[]string{"sink"},
+	)
+
+	// AuditWriteErrorsTotal counts failed audit batch writes, by sink.
+	AuditWriteErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "audit_write_errors_total",
+			Help: "Total number of audit event batches that failed to write.",
+		},
+		[]string{"sink"},
+	)
+
+	// AuditDroppedTotal counts audit events dropped because the queue was full.
+	AuditDroppedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "audit_dropped_total",
+			Help: "Total number of audit events dropped because the audit queue was full.",
+		},
+	)
+
+	// QuotaRejectionsTotal counts requests rejected by tenant quotas.
+	QuotaRejectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "quota_rejections_total",
+			Help: "Total number of requests rejected by per-tenant quotas.",
+		},
+		[]string{"tenant", "limit"},
+	)
+
+	// QuotaErrorsTotal counts quota store failures; requests are admitted
+	// when the store is unavailable.
+	QuotaErrorsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "quota_errors_total",
+			Help: "Total number of quota checks that failed open because the store was unavailable.",
+		},
+	)
+
+	// PIIDetectionsTotal counts PII values found in prompts, by detector and
+	// the action taken.
+	PIIDetectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pii_detections_total",
+			Help: "Total number of PII values detected in prompts.",
+		},
+		[]string{"detector", "action"},
+	)
+
+	// OutputViolationsTotal counts responses blocked by the output policy,
+	// by rule.
+	OutputViolationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "output_violations_total",
+			Help: "Total number of provider responses blocked by the output policy.",
+		},
+		[]string{"rule"},
+	)
+
+	// PromptInjectionTotal counts prompts that reached an injection
+	// threshold, by the action taken.
+	PromptInjectionTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prompt_injection_total",
+			Help: "Total number of prompts blocked, flagged or logged as likely injection attempts.",
+		},
+		[]string{"action"},
+	)
+
+	// PromptInjectionSignalsTotal counts injection signals seen in prompts.
+	PromptInjectionSignalsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prompt_injection_signals_total",
+			Help: "Total number of prompt injection signals detected, by signal.",
+		},
+		[]string{"signal"},
+	)
+
+	// InjectionClassifierErrorsTotal counts failed injection classifications;
+	// the classifier is skipped for that prompt.
+	InjectionClassifierErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "injection_classifier_errors_total",
+			Help: "Total number of prompt injection classifier failures.",
+		},
+		[]string{"classifier"},
+	)
+
+	// FinishReasonsTotal counts provider responses by normalized finish
+	// reason, e.g. to spot truncation at max_tokens.
+	FinishReasonsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "finish_reasons_total",
+			Help: "Total number of provider responses by normalized finish reason.",
+		},
+		[]string{"provider", "reason"},
+	)
+
+	// ResponseFormatTotal counts structured-output requests by validation
+	// outcome: valid, repaired or invalid.
+	ResponseFormatTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "response_format_total",
+			Help: "Total number of response_format requests by validation outcome.",
+		},
+		[]string{"outcome"},
+	)
+
+	// ResponseFormatRepairsTotal counts repair calls made after a response
+	// failed validation.
+	ResponseFormatRepairsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "response_format_repairs_total",
+			Help: "Total number of repair calls made for responses that failed response_format validation.",
+		},
+	)
+
+	// EmbeddingInputsTotal counts embedded inputs by whether the embedding
+	// cache served them.
+	EmbeddingInputsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "embedding_inputs_total",
+			Help: "Total number of inputs embedded, by embedding cache status.",
+		},
+		[]string{"provider", "model", "cache_status"}, // cache_status: "hit" or "miss"
+	)
+
+	// ContextOverflowTotal counts requests that did not fit the model's
+	// context window, by whether they were rejected or truncated.
+	ContextOverflowTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "context_overflow_total",
+			Help: "Total number of requests exceeding the model's context window, by action taken.",
+		},
+		[]string{"model", "action"}, // action: "rejected" or "truncated"
+	)
+
+	// ModelRefreshesTotal counts model list refreshes from each provider.
+	ModelRefreshesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "model_refreshes_total",
+			Help: "Total number of provider model list refreshes, by outcome.",
+		},
+		[]string{"provider", "outcome"}, // outcome: "success" or "error"
+	)
+
+	// ActiveChatStreams tracks open Chat streams.
+	ActiveChatStreams = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "active_chat_streams",
+			Help: "Number of open Chat streams.",
+		},
+	)
+
+	// ChatTurnsTotal counts Chat turns by outcome.
+	ChatTurnsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_turns_total",
+			Help: "Total number of Chat turns, by outcome.",
+		},
+		[]string{"outcome"}, // outcome: "success", "cancelled" or "error"
+	)
+
+	// ConversationSummariesTotal counts summaries of older conversation
+	// turns.
+	ConversationSummariesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "conversation_summaries_total",
+			Help: "Total number of conversation history summaries, by outcome.",
+		},
+		[]string{"outcome"}, // outcome: "success" or "error"
+	)
+
+	// BatchItemsTotal counts finished batch job items.
+	BatchItemsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "batch_items_total",
+			Help: "Total number of finished batch job items, by mode and outcome.",
+		},
+		[]string{"mode", "outcome"}, // mode: "proxy" or "native"; outcome: "success" or "error"
+	)
+
+	// BatchJobsTotal counts batch jobs that reached a final status.
+	BatchJobsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "batch_jobs_total",
+			Help: "Total number of batch jobs ended, by status.",
+		},
+		[]string{"status"}, // status: "completed", "failed" or "cancelled"
 	)
 
 	// trackingMu guards the ratio update — not needed since gauge.Set is atomic
@@ -99,3 +492,82 @@ func RecordCacheLookup(hit bool) {
 		CacheHitRatio.Set(totalHits / totalLookups)
 	}
 }
+
+// RecordKeyReload records the outcome of a key pool reload.
+func RecordKeyReload(provider string, keys int, err error) {
+	if err != nil {
+		KeyPoolReloadErrorsTotal.WithLabelValues(provider).Inc()
+		KeyPoolLastReloadSuccess.WithLabelValues(provider).Set(0)
+		return
+	}
+	KeyPoolKeys.WithLabelValues(provider).Set(float64(keys))
+	KeyPoolLastReload.WithLabelValues(provider).SetToCurrentTime()
+	KeyPoolLastReloadSuccess.WithLabelValues(provider).Set(1)
+}
+
+// minQuantileSamples is the number of observations required before
+// LatencyQuantile trusts the histogram.
+const minQuantileSamples = 20
+
+// LatencyQuantile estimates the q-th quantile (0–1) of request_latency_seconds
+// for the given labels by linear interpolation within histogram buckets.
+// Returns false if there are too few samples to be meaningful.
+func LatencyQuantile(provider, model, cacheStatus string, q float64) (time.Duration, bool) {
+	observer, err := RequestLatency.GetMetricWithLabelValues(provider, model, cacheStatus)
+	if err != nil {
+		return 0, false
+	}
+	return quantile(observer, q)
+}
+
+// FirstChunkQuantile estimates the q-th quantile (0–1) of
+// time_to_first_chunk_seconds, like LatencyQuantile.
+func FirstChunkQuantile(provider, model string, q float64) (time.Duration, bool) {
+	observer, err := TimeToFirstChunk.GetMetricWithLabelValues(provider, model)
+	if err != nil {
+		return 0, false
+	}
+	return quantile(observer, q)
+}
+
+// quantile estimates the q-th quantile of a histogram.
+func quantile(observer prometheus.Observer, q float64) (time.Duration, bool) {
+	metric, ok := observer.(prometheus.Metric)
+	if !ok {
+		return 0, false
+	}
+
+	var m dto.Metric
+	if err := metric.Write(&m); err != nil || m.Histogram == nil {
+		return 0, false
+	}
+
+	h := m.Histogram
+	total := h.GetSampleCount()
+	if total < minQuantileSamples {
+		return 0, false
+	}
+
+	target := q * float64(total)
+	var prevBound float64
+	var prevCount uint64
+	for _, b := range h.GetBucket() {
+		count := b.GetCumulativeCount()
+		bound := b.GetUpperBound()
+		if float64(count) >= target {
+			// Interpolate within [prevBound, bound]
+			inBucket := float64(count - prevCount)
+			frac := 1.0
+			if inBucket > 0 {
+				frac = (target - float64(prevCount)) / inBucket
+			}
+			seconds := prevBound + (bound-prevBound)*frac
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+		prevBound = bound
+		prevCount = count
+	}
+
+	// Quantile falls in the +Inf bucket — the best we can say is the last bound
+	return time.Duration(prevBound * float64(time.Second)), true
+}
@@ -0,0 +1,250 @@
+// Package models holds the registry of model capabilities: context
+// windows, output limits, features, aliases and the tokenizer encoding of
+// each model.
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Tokenizer encodings of OpenAI model families.
+const (
+	EncodingO200K  = "o200k_base"
+	EncodingCL100K = "cl100k_base"
+)
+
+// Sources of registry entries.
+const (
+	SourceConfig   = "config"   // Built in or loaded from a registry file
+	SourceProvider = "provider" // Discovered from a provider's model list
+)
+
+// Features lists what a model supports.
+type Features struct {
+	Streaming  bool `json:"streaming"`
+	Tools      bool `json:"tools"`
+	Vision     bool `json:"vision"`
+	JSONMode   bool `json:"json_mode"`
+	Embeddings bool `json:"embeddings"`
+}
+
+// Model describes the limits and features of one model.
+type Model struct {
+	Name            string   `json:"-"`
+	Provider        string   `json:"provider"`
+	Aliases         []string `json:"aliases,omitempty"`  // Other names that route to this model
+	ContextWindow   int      `json:"context_window"`     // Prompt plus output tokens
+	MaxOutputTokens int      `json:"max_output_tokens"`  // Largest accepted max_tokens
+	Encoding        string   `json:"encoding,omitempty"` // BPE encoding, empty if the provider's tokenizer is not public
+	Features        Features `json:"features"`
+	Source          string   `json:"-"` // SourceConfig or SourceProvider
+}
+
+// Registry holds the known models. It is safe for concurrent use; entries
+// discovered from providers are merged in while requests are served.
+type Registry struct {
+	mu      sync.RWMutex
+	models  map[string]Model
+	aliases map[string]string // Alias → model name
+}
+
+// NewRegistry creates a registry from models keyed by name.
+func NewRegistry(models map[string]Model) *Registry {
+	r := &Registry{
+		models:  make(map[string]Model, len(models)),
+		aliases: make(map[string]string),
+	}
+	for name, m := range models {
+		r.put(name, m)
+	}
+	return r
+}
+
+// put adds or replaces a configured model and its aliases. Callers hold
+// r.mu or own r exclusively.
+func (r *Registry) put(name string, m Model) {
+	if old, ok := r.models[name]; ok {
+		for _, alias := range old.Aliases {
+			delete(r.aliases, alias)
+		}
+	}
+	m.Name = name
+	m.Source = SourceConfig
+	r.models[name] = m
+	for _, alias := range m.Aliases {
+		r.aliases[alias] = name
+	}
+}
+
+// DefaultRegistry returns the published limits of common models at the
+// time of writing. Extend or override them with a registry file.
+func DefaultRegistry() *Registry {
+	chat := Features{Streaming: true, Tools: true, Vision: true, JSONMode: true}
+	text := Features{Streaming: true, Tools: true, JSONMode: true}
+	embed := Features{Embeddings: true}
+	return NewRegistry(map[string]Model{
+		"gpt-4o":                 {Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 16384, Encoding: EncodingO200K, Features: chat},
+		"gpt-4o-mini":            {Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 16384, Encoding: EncodingO200K, Features: chat},
+		"gpt-4-turbo":            {Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 4096, Encoding: EncodingCL100K, Features: chat},
+		"gpt-4":                  {Provider: "openai", ContextWindow: 8192, MaxOutputTokens: 8192, Encoding: EncodingCL100K, Features: Features{Streaming: true, Tools: true}},
+		"gpt-3.5-turbo":          {Provider: "openai", ContextWindow: 16385, MaxOutputTokens: 4096, Encoding: EncodingCL100K, Features: text},
+		"gemini-1.5-pro":         {Provider: "gemini", ContextWindow: 2097152, MaxOutputTokens: 8192, Features: chat},
+		"gemini-1.5-flash":       {Provider: "gemini", ContextWindow: 1048576, MaxOutputTokens: 8192, Features: chat},
+		"gemini-pro":             {Provider: "gemini", ContextWindow: 32760, MaxOutputTokens: 8192, Features: Features{Streaming: true, Tools: true}},
+		"text-embedding-3-small": {Provider: "openai", ContextWindow: 8191, Encoding: EncodingCL100K, Features: embed},
+		"text-embedding-3-large": {Provider: "openai", ContextWindow: 8191, Encoding: EncodingCL100K, Features: embed},
+		"text-embedding-ada-002": {Provider: "openai", ContextWindow: 8191, Encoding: EncodingCL100K, Features: embed},
+		"text-embedding-004":     {Provider: "gemini", ContextWindow: 2048, Features: embed},
+	})
+}
+
+// LoadRegistry reads a JSON file of models keyed by name and layers it
+// over the defaults:
+//
+//	{"gpt-4o": {"provider": "openai", "aliases": ["default"], "context_window": 128000, "max_output_tokens": 16384,
+//	            "encoding": "o200k_base", "features": {"streaming": true, "vision": true, "json_mode": true}}}
+func LoadRegistry(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("models: read %s: %w", path, err)
+	}
+
+	var raw map[string]Model
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("models: parse %s: %w", path, err)
+	}
+
+	r := DefaultRegistry()
+	for name, m := range raw {
+		if m.ContextWindow < 0 || m.MaxOutputTokens < 0 {
+			return nil, fmt.Errorf("models: %s: limits must not be negative", name)
+		}
+		if m.Provider == "" {
+			return nil, fmt.Errorf("models: %s: provider is required", name)
+		}
+		r.put(name, m)
+	}
+	for alias, name := range r.aliases {
+		if _, ok := r.models[alias]; ok {
+			return nil, fmt.Errorf("models: alias %q of %s is also a model name", alias, name)
+		}
+	}
+	return r, nil
+}
+
+// Resolve returns the model an alias stands for, or model itself.
+func (r *Registry) Resolve(model string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name, ok := r.aliases[model]; ok {
+		return name
+	}
+	return model
+}
+
+// Lookup returns the entry of model or of the alias model. Models without
+// an exact entry match the longest model name they start with, so
+// "gpt-4o-2024-08-06" has the limits of "gpt-4o".
+func (r *Registry) Lookup(model string) (Model, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name, ok := r.aliases[model]; ok {
+		model = name
+	}
+	if m, ok := r.models[model]; ok {
+		return m, true
+	}
+	return r.longestPrefix(model)
+}
+
+// longestPrefix returns the configured model with the longest name model
+// starts with. Callers hold r.mu.
+func (r *Registry) longestPrefix(model string) (Model, bool) {
+	best := ""
+	for name, m := range r.models {
+		if m.Source == SourceConfig && strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Model{}, false
+	}
+	return r.models[best], true
+}
+
+// List returns every model, sorted by name.
+func (r *Registry) List() []Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Model, 0, len(r.models))
+	for _, m := range r.models {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Merge records the models a provider reports serving. Unknown models are
+// added with SourceProvider, taking the limits, encoding and features the
+// provider does not report from the configured model they are a version of
+// ("gpt-4o-2024-08-06" from "gpt-4o"). Configured models keep their entries
+// but gain any limits they lack. Models the provider no longer reports are
+// dropped if they were discovered. Returns the number of models added.
+func (r *Registry) Merge(providerName string, found []Model) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]bool, len(found))
+	added := 0
+	for _, f := range found {
+		seen[f.Name] = true
+		m, ok := r.models[f.Name]
+		if !ok {
+			if _, isAlias := r.aliases[f.Name]; isAlias {
+				continue
+			}
+			if base, ok := r.longestPrefix(f.Name); ok && base.Provider == providerName {
+				if f.ContextWindow == 0 {
+					f.ContextWindow = base.ContextWindow
+				}
+				if f.MaxOutputTokens == 0 {
+					f.MaxOutputTokens = base.MaxOutputTokens
+				}
+				if f.Encoding == "" {
+					f.Encoding = base.Encoding
+				}
+				if f.Features == (Features{}) {
+					f.Features = base.Features
+				}
+			}
+			f.Provider = providerName
+			f.Source = SourceProvider
+			f.Aliases = nil
+			r.models[f.Name] = f
+			added++
+			continue
+		}
+		if m.ContextWindow == 0 {
+			m.ContextWindow = f.ContextWindow
+		}
+		if m.MaxOutputTokens == 0 {
+			m.MaxOutputTokens = f.MaxOutputTokens
+		}
+		r.models[f.Name] = m
+	}
+
+	for name, m := range r.models {
+		if m.Source == SourceProvider && m.Provider == providerName && !seen[name] {
+			delete(r.models, name)
+		}
+	}
+	return added
+}
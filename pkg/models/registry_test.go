@@ -0,0 +1,99 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	r := NewRegistry(map[string]Model{
+		"gpt-4o":      {Provider: "openai", Aliases: []string{"default"}, ContextWindow: 128000},
+		"gpt-4o-mini": {Provider: "openai", ContextWindow: 64000},
+	})
+	tests := []struct {
+		model string
+		want  string // Matched entry, or "" if none
+	}{
+		{"gpt-4o", "gpt-4o"},
+		{"default", "gpt-4o"},
+		{"gpt-4o-2024-08-06", "gpt-4o"},
+		{"gpt-4o-mini-2024-07-18", "gpt-4o-mini"},
+		{"claude-3", ""},
+	}
+	for _, tt := range tests {
+		m, ok := r.Lookup(tt.model)
+		if ok != (tt.want != "") || m.Name != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v, want %q", tt.model, m.Name, ok, tt.want)
+		}
+	}
+	if got := r.Resolve("default"); got != "gpt-4o" {
+		t.Errorf("Resolve(default) = %q, want gpt-4o", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	r := NewRegistry(map[string]Model{
+		"gpt-4o": {Provider: "openai", Aliases: []string{"default"}, ContextWindow: 128000, Encoding: EncodingO200K, Features: Features{Streaming: true}},
+		"gpt-4":  {Provider: "openai"},
+	})
+
+	added := r.Merge("openai", []Model{
+		{Name: "gpt-4o-2024-08-06"},
+		{Name: "gpt-4", ContextWindow: 8192},
+		{Name: "default"}, // An alias, not a new model
+	})
+	if added != 1 {
+		t.Errorf("Merge added %d models, want 1", added)
+	}
+	m, _ := r.Lookup("gpt-4o-2024-08-06")
+	if m.Source != SourceProvider || m.ContextWindow != 128000 || m.Encoding != EncodingO200K || !m.Features.Streaming {
+		t.Errorf("discovered model = %+v, want the limits of gpt-4o", m)
+	}
+	if m, _ := r.Lookup("gpt-4"); m.Source != SourceConfig || m.ContextWindow != 8192 {
+		t.Errorf("configured model = %+v, want its entry with the reported context window", m)
+	}
+
+	// A later list without the discovered model drops it.
+	r.Merge("openai", []Model{{Name: "gpt-4"}})
+	if m, _ := r.Lookup("gpt-4o-2024-08-06"); m.Source != SourceConfig {
+		t.Errorf("after the provider stopped listing it, lookup = %+v, want the configured gpt-4o", m)
+	}
+	if got := len(r.List()); got != 2 {
+		t.Errorf("List has %d models, want 2", got)
+	}
+}
+
+func TestLoadRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"override", `{"gpt-4o": {"provider": "openai", "aliases": ["default"], "context_window": 1000}}`, false},
+		{"no provider", `{"custom": {"context_window": 1000}}`, true},
+		{"negative limit", `{"custom": {"provider": "openai", "context_window": -1}}`, true},
+		{"alias shadows a model", `{"custom": {"provider": "openai", "aliases": ["gpt-4"]}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "models.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			r, err := LoadRegistry(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadRegistry error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if m, _ := r.Lookup("default"); m.Name != "gpt-4o" || m.ContextWindow != 1000 {
+				t.Errorf("Lookup(default) = %+v, want the overridden gpt-4o", m)
+			}
+			if _, ok := r.Lookup("gemini-1.5-pro"); !ok {
+				t.Error("defaults missing after loading a registry file")
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Package pricing computes the dollar cost of requests from a per-model
+// price table.
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Price is a model's list price in USD per million tokens, effective from
+// a given date.
+type Price struct {
+	InputPerMillion       float64   `json:"input_per_million"`
+	OutputPerMillion      float64   `json:"output_per_million"`
+	CachedInputPerMillion float64   `json:"cached_input_per_million"` // 0 = same as input
+	EffectiveFrom         time.Time `json:"-"`
+}
+
+// Usage is the token usage of one request.
+type Usage struct {
+	PromptTokens int32
+	CachedTokens int32 // Portion of PromptTokens billed at the cached rate
+	OutputTokens int32
+}
+
+// Cost returns the cost of u in USD.
+func (p Price) Cost(u Usage) float64 {
+	cachedRate := p.CachedInputPerMillion
+	if cachedRate == 0 {
+		cachedRate = p.InputPerMillion
+	}
+
+	uncached := u.PromptTokens - u.CachedTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+	return (float64(uncached)*p.InputPerMillion +
+		float64(u.CachedTokens)*cachedRate +
+		float64(u.OutputTokens)*p.OutputPerMillion) / 1e6
+}
+
+// Table holds the price history of each model.
+type Table struct {
+	models map[string][]Price // Sorted by EffectiveFrom, oldest first
+}
+
+// NewTable creates a table from per-model price histories.
+func NewTable(prices map[string][]Price) *Table {
+	t := &Table{models: make(map[string][]Price, len(prices))}
+	for model, history := range prices {
+		sorted := append([]Price(nil), history...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].EffectiveFrom.Before(sorted[j].EffectiveFrom)
+		})
+		t.models[model] = sorted
+	}
+	return t
+}
+
+// DefaultTable returns list prices for common models at the time of
+// writing. Override with a price file for accurate accounting.
+func DefaultTable() *Table {
+	return NewTable(map[string][]Price{
+		"gpt-4o":           {{InputPerMillion: 2.50, OutputPerMillion: 10.00, CachedInputPerMillion: 1.25}},
+		"gpt-4o-mini":      {{InputPerMillion: 0.15, OutputPerMillion: 0.60, CachedInputPerMillion: 0.075}},
+		"gpt-4-turbo":      {{InputPerMillion: 10.00, OutputPerMillion: 30.00}},
+		"gpt-4":            {{InputPerMillion: 30.00, OutputPerMillion: 60.00}},
+		"gpt-3.5-turbo":    {{InputPerMillion: 0.50, OutputPerMillion: 1.50}},
+		"gemini-1.5-pro":   {{InputPerMillion: 1.25, OutputPerMillion: 5.00, CachedInputPerMillion: 0.3125}},
+		"gemini-1.5-flash": {{InputPerMillion: 0.075, OutputPerMillion: 0.30, CachedInputPerMillion: 0.01875}},
+		"gemini-pro":       {{InputPerMillion: 0.50, OutputPerMillion: 1.50}},
+
+		"text-embedding-3-small": {{InputPerMillion: 0.02}},
+		"text-embedding-3-large": {{InputPerMillion: 0.13}},
+		"text-embedding-ada-002": {{InputPerMillion: 0.10}},
+		"text-embedding-004":     {{InputPerMillion: 0}},
+	})
+}
+
+// priceFile is one entry in a price table file.
+type priceFile struct {
+	Price
+	EffectiveFrom string `json:"effective_from"` // YYYY-MM-DD, empty = always
+}
+
+// LoadTable reads a JSON price file mapping model names to price histories:
+//
+//	{"gpt-4o": [{"input_per_million": 5, "output_per_million": 15},
+//	            {"input_per_million": 2.5, "output_per_million": 10, "effective_from": "2024-08-06"}]}
+func LoadTable(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("pricing: read %s: %w", path, err)
+	}
+
+	var raw map[string][]priceFile
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("pricing: parse %s: %w", path, err)
+	}
+
+	prices := make(map[string][]Price, len(raw))
+	for model, entries := range raw {
+		for _, e := range entries {
+			p := e.Price
+			if e.EffectiveFrom != "" {
+				p.EffectiveFrom, err = time.Parse("2006-01-02", e.EffectiveFrom)
+				if err != nil {
+					return nil, fmt.Errorf("pricing: %s: effective_from %q: %w", model, e.EffectiveFrom, err)
+				}
+			}
+			prices[model] = append(prices[model], p)
+		}
+	}
+	return NewTable(prices), nil
+}
+
+// Lookup returns the price of model in effect at the given time. Models
+// without an exact entry match the longest model name they start with, so
+// "gpt-4o-2024-08-06" is priced as "gpt-4o".
+func (t *Table) Lookup(model string, at time.Time) (Price, bool) {
+	history, ok := t.models[model]
+	if !ok {
+		best := ""
+		for name := range t.models {
+			if strings.HasPrefix(model, name) && len(name) > len(best) {
+				best = name
+			}
+		}
+		if best == "" {
+			return Price{}, false
+		}
+		history = t.models[best]
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if !history[i].EffectiveFrom.After(at) {
+			return history[i], true
+		}
+	}
+	return Price{}, false
+}
+
+// Cost returns the cost of u for model at the given time, and false if the
+// model has no price.
+func (t *Table) Cost(model string, at time.Time, u Usage) (float64, bool) {
+	p, ok := t.Lookup(model, at)
+	if !ok {
+		return 0, false
+	}
+	return p.Cost(u), true
+}
@@ -0,0 +1,128 @@
+package pricing
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestPriceCost(t *testing.T) {
+	p := Price{InputPerMillion: 2, OutputPerMillion: 8, CachedInputPerMillion: 0.5}
+
+	tests := []struct {
+		name  string
+		price Price
+		usage Usage
+		want  float64
+	}{
+		{"input and output", p, Usage{PromptTokens: 1_000_000, OutputTokens: 500_000}, 6},
+		{"cached input", p, Usage{PromptTokens: 1_000_000, CachedTokens: 400_000}, 1.2 + 0.2},
+		{"no cached rate bills at the input rate", Price{InputPerMillion: 2}, Usage{PromptTokens: 1_000_000, CachedTokens: 400_000}, 2},
+		{"cached above prompt", p, Usage{PromptTokens: 100, CachedTokens: 1_000_000}, 0.5},
+		{"nothing", p, Usage{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.price.Cost(tt.usage); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Cost(%+v) = %v, want %v", tt.usage, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupEffectiveDates(t *testing.T) {
+	table := NewTable(map[string][]Price{
+		// Deliberately out of order: NewTable sorts each history.
+		"gpt-4o": {
+			{InputPerMillion: 2.5, EffectiveFrom: date("2024-08-06")},
+			{InputPerMillion: 5},
+			{InputPerMillion: 2, EffectiveFrom: date("2025-01-01")},
+		},
+		"gpt-4o-mini": {{InputPerMillion: 0.15}},
+		"o1":          {{InputPerMillion: 15, EffectiveFrom: date("2024-12-17")}},
+	})
+
+	tests := []struct {
+		name   string
+		model  string
+		at     time.Time
+		want   float64
+		wantOK bool
+	}{
+		{"before any change", "gpt-4o", date("2024-05-13"), 5, true},
+		{"on the effective date", "gpt-4o", date("2024-08-06"), 2.5, true},
+		{"just before the effective date", "gpt-4o", date("2024-08-06").Add(-time.Nanosecond), 5, true},
+		{"latest price", "gpt-4o", date("2026-01-01"), 2, true},
+		{"dated model matches its prefix", "gpt-4o-2024-08-06", date("2024-09-01"), 2.5, true},
+		{"longest prefix wins", "gpt-4o-mini-2024-07-18", date("2024-09-01"), 0.15, true},
+		{"before the first price", "o1", date("2024-12-01"), 0, false},
+		{"first price", "o1-preview", date("2024-12-17"), 15, true},
+		{"unknown model", "claude", date("2024-09-01"), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := table.Lookup(tt.model, tt.at)
+			if ok != tt.wantOK || p.InputPerMillion != tt.want {
+				t.Errorf("Lookup(%q, %s) = %v, %v, want %v, %v", tt.model, tt.at.Format(time.RFC3339Nano), p.InputPerMillion, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := table.Cost("unknown", date("2024-09-01"), Usage{PromptTokens: 1}); ok {
+		t.Error("Cost of an unpriced model reported ok")
+	}
+}
+
+func TestLoadTable(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"missing", filepath.Join(dir, "missing.json"), "pricing: read"},
+		{"not json", write("bad.json", `{`), "pricing: parse"},
+		{"bad date", write("date.json", `{"gpt-4o": [{"input_per_million": 5, "effective_from": "06/08/2024"}]}`), `effective_from "06/08/2024"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadTable(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadTable error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	table, err := LoadTable(write("prices.json", `{"gpt-4o": [
+		{"input_per_million": 2.5, "output_per_million": 10, "effective_from": "2024-08-06"},
+		{"input_per_million": 5, "output_per_million": 15}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := Usage{PromptTokens: 1_000_000, OutputTokens: 1_000_000}
+	for at, want := range map[string]float64{"2024-08-05": 20, "2024-08-06": 12.5} {
+		if got, ok := table.Cost("gpt-4o", date(at), usage); !ok || got != want {
+			t.Errorf("Cost on %s = %v, %v, want %v", at, got, ok, want)
+		}
+	}
+}
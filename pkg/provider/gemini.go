@@ -6,7 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 // GeminiProvider implements the Provider interface for Google's Gemini API.
@@ -27,51 +32,395 @@ func (g *GeminiProvider) Name() string { return "gemini" }
 
 // geminiRequest is the Gemini API request body.
 type geminiRequest struct {
-	Contents         []geminiContent   `json:"contents"`
-	GenerationConfig *geminiGenConfig  `json:"generationConfig,omitempty"`
+	Contents          []geminiContent  `json:"contents"`
+	SystemInstruction *geminiContent   `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenConfig `json:"generationConfig,omitempty"`
 }
 
 type geminiContent struct {
+	Role  string       `json:"role,omitempty"` // "user" or "model"
 	Parts []geminiPart `json:"parts"`
 }
 
+// newGeminiRequest translates req to a generateContent request body. System
+// messages in the history become the system instruction.
+func newGeminiRequest(req Request) geminiRequest {
+	body := geminiRequest{
+		Contents:         geminiContents(req),
+		GenerationConfig: geminiGenerationConfig(req),
+	}
+	var system []geminiPart
+	for _, m := range req.History {
+		if m.Role == RoleSystem {
+			system = append(system, geminiPart{Text: m.Text})
+		}
+	}
+	if len(system) > 0 {
+		body.SystemInstruction = &geminiContent{Parts: system}
+	}
+	return body
+}
+
+// geminiContents returns the user and assistant turns of the history, then
+// the prompt and its attachments.
+func geminiContents(req Request) []geminiContent {
+	contents := make([]geminiContent, 0, len(req.History)+1)
+	for _, m := range req.History {
+		switch m.Role {
+		case RoleUser:
+			contents = append(contents, geminiContent{Role: "user", Parts: []geminiPart{{Text: m.Text}}})
+		case RoleAssistant:
+			contents = append(contents, geminiContent{Role: "model", Parts: []geminiPart{{Text: m.Text}}})
+		}
+	}
+	return append(contents, geminiContent{Role: "user", Parts: geminiParts(req)})
+}
+
 type geminiPart struct {
-	Text string `json:"text"`
+	Text       string          `json:"text,omitempty"`
+	InlineData *geminiBlob     `json:"inlineData,omitempty"`
+	FileData   *geminiFileData `json:"fileData,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     []byte `json:"data"` // base64 in JSON
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mimeType"`
+	FileURI  string `json:"fileUri"`
 }
 
+// geminiParts returns the prompt followed by its attachments, as inlineData
+// or fileData parts.
+func geminiParts(req Request) []geminiPart {
+	parts := make([]geminiPart, 0, len(req.Parts)+1)
+	if req.Prompt != "" || len(req.Parts) == 0 {
+		parts = append(parts, geminiPart{Text: req.Prompt})
+	}
+	for _, p := range req.Parts {
+		if len(p.Data) > 0 {
+			parts = append(parts, geminiPart{InlineData: &geminiBlob{MimeType: p.MIMEType, Data: p.Data}})
+		} else {
+			parts = append(parts, geminiPart{FileData: &geminiFileData{MimeType: p.MIMEType, FileURI: p.URI}})
+		}
+	}
+	return parts
+}
+
+// geminiMediaTypes are the MIME type families Gemini accepts as parts.
+var geminiMediaTypes = []string{"image/", "audio/", "video/", "text/", "application/pdf"}
+
 type geminiGenConfig struct {
-	Temperature  float32 `json:"temperature,omitempty"`
-	MaxOutputTokens int32 `json:"maxOutputTokens,omitempty"`
+	Temperature      *float32 `json:"temperature,omitempty"`
+	MaxOutputTokens  int32    `json:"maxOutputTokens,omitempty"`
+	TopP             *float32 `json:"topP,omitempty"`
+	TopK             *int32   `json:"topK,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	PresencePenalty  *float32 `json:"presencePenalty,omitempty"`
+	FrequencyPenalty *float32 `json:"frequencyPenalty,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+	CandidateCount   *int32   `json:"candidateCount,omitempty"`
+	ResponseLogprobs bool     `json:"responseLogprobs,omitempty"`
+	Logprobs         *int32   `json:"logprobs,omitempty"`
+	ResponseMimeType string   `json:"responseMimeType,omitempty"`
+	ResponseSchema   any      `json:"responseSchema,omitempty"`
+}
+
+// Validate rejects logit_bias, which Gemini has no equivalent for, and
+// values outside Gemini's accepted ranges.
+func (g *GeminiProvider) Validate(req Request) error {
+	if len(req.LogitBias) > 0 {
+		return &ParamError{"gemini", "logit_bias", "is not supported"}
+	}
+	for _, p := range req.Parts {
+		supported := false
+		for _, prefix := range geminiMediaTypes {
+			supported = supported || strings.HasPrefix(p.MIMEType, prefix)
+		}
+		if !supported {
+			return &ParamError{"gemini", "parts", fmt.Sprintf("of type %q are not supported", p.MIMEType)}
+		}
+	}
+	if len(req.Stop) > 5 {
+		return &ParamError{"gemini", "stop", "accepts at most 5 sequences"}
+	}
+	if req.Seed != nil && (*req.Seed < math.MinInt32 || *req.Seed > math.MaxInt32) {
+		return &ParamError{"gemini", "seed", "must fit in 32 bits"}
+	}
+	return firstErr(
+		checkInlineSize("gemini", req),
+		checkLogprobs("gemini", req),
+		checkFloat("gemini", "temperature", req.Temperature, 0, 2),
+		checkFloat("gemini", "top_p", req.TopP, 0, 1),
+		checkInt("gemini", "top_k", req.TopK, 1, math.MaxInt32),
+		checkFloat("gemini", "presence_penalty", req.PresencePenalty, -2, 2),
+		checkFloat("gemini", "frequency_penalty", req.FrequencyPenalty, -2, 2),
+		checkInt("gemini", "n", req.N, 1, 8),
+	)
+}
+
+// geminiGenerationConfig builds the generation config for req, mapping a
+// response format to responseMimeType / responseSchema.
+func geminiGenerationConfig(req Request) *geminiGenConfig {
+	cfg := &geminiGenConfig{
+		Temperature:      req.Temperature,
+		MaxOutputTokens:  req.MaxTokens,
+		TopP:             req.TopP,
+		TopK:             req.TopK,
+		StopSequences:    req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		Seed:             req.Seed,
+		CandidateCount:   req.N,
+		ResponseLogprobs: req.Logprobs,
+		Logprobs:         req.TopLogprobs,
+	}
+	if req.Format != nil {
+		cfg.ResponseMimeType = "application/json"
+		if req.Format.Type == FormatJSONSchema {
+			var doc map[string]any
+			if err := json.Unmarshal(req.Format.Schema, &doc); err == nil {
+				cfg.ResponseSchema = geminiSchema(doc, doc, 0)
+			}
+		}
+	}
+	return cfg
+}
+
+// geminiSchemaKeys are the JSON Schema keywords Gemini's OpenAPI-style
+// responseSchema accepts.
+var geminiSchemaKeys = map[string]bool{
+	"type": true, "format": true, "description": true, "nullable": true, "enum": true,
+	"properties": true, "required": true, "items": true, "minItems": true, "maxItems": true,
+	"minimum": true, "maximum": true, "anyOf": true, "propertyOrdering": true,
+}
+
+// maxSchemaDepth bounds $ref inlining, which would otherwise loop on
+// recursive schemas.
+const maxSchemaDepth = 16
+
+// geminiSchema converts a JSON Schema node to Gemini's schema dialect:
+// local $refs are inlined, type arrays become a single type plus nullable,
+// type names are upper-cased and unsupported keywords are dropped. The proxy
+// still validates the full schema on the response.
+func geminiSchema(root, node map[string]any, depth int) map[string]any {
+	if depth > maxSchemaDepth {
+		return map[string]any{"type": "OBJECT"}
+	}
+	if ref, ok := node["$ref"].(string); ok {
+		if target := resolveLocalRef(root, ref); target != nil {
+			node = target
+		}
+	}
+
+	out := make(map[string]any, len(node))
+	for k, v := range node {
+		if !geminiSchemaKeys[k] {
+			continue
+		}
+		switch k {
+		case "type":
+			switch t := v.(type) {
+			case string:
+				out["type"] = strings.ToUpper(t)
+			case []any:
+				for _, x := range t {
+					if name, _ := x.(string); name == "null" {
+						out["nullable"] = true
+					} else if name != "" {
+						out["type"] = strings.ToUpper(name)
+					}
+				}
+			}
+		case "properties":
+			props, _ := v.(map[string]any)
+			converted := make(map[string]any, len(props))
+			for name, p := range props {
+				if m, ok := p.(map[string]any); ok {
+					converted[name] = geminiSchema(root, m, depth+1)
+				}
+			}
+			out[k] = converted
+		case "items":
+			if m, ok := v.(map[string]any); ok {
+				out[k] = geminiSchema(root, m, depth+1)
+			}
+		case "anyOf":
+			list, _ := v.([]any)
+			converted := make([]any, 0, len(list))
+			for _, sub := range list {
+				if m, ok := sub.(map[string]any); ok {
+					converted = append(converted, geminiSchema(root, m, depth+1))
+				}
+			}
+			out[k] = converted
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// resolveLocalRef follows a "#/..." JSON pointer within root.
+func resolveLocalRef(root map[string]any, ref string) map[string]any {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var cur any = root
+	for _, part := range strings.Split(ref[2:], "/") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	m, _ := cur.(map[string]any)
+	return m
 }
 
 // geminiResponse is the Gemini API response body.
 type geminiResponse struct {
-	Candidates []struct {
-		Content struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		} `json:"content"`
-	} `json:"candidates"`
+	Candidates     []geminiCandidate `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason   string               `json:"blockReason"`
+		SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+	} `json:"promptFeedback"`
 	UsageMetadata struct {
-		PromptTokenCount     int32 `json:"promptTokenCount"`
-		CandidatesTokenCount int32 `json:"candidatesTokenCount"`
+		PromptTokenCount        int32 `json:"promptTokenCount"`
+		CachedContentTokenCount int32 `json:"cachedContentTokenCount"`
+		CandidatesTokenCount    int32 `json:"candidatesTokenCount"`
 	} `json:"usageMetadata"`
+	ResponseID   string `json:"responseId"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+type geminiCandidate struct {
+	Index   int `json:"index"`
+	Content struct {
+		Parts []struct {
+			Text string `json:"text"`
+		} `json:"parts"`
+	} `json:"content"`
+	FinishReason   string                `json:"finishReason"`
+	SafetyRatings  []geminiSafetyRating  `json:"safetyRatings"`
+	LogprobsResult *geminiLogprobsResult `json:"logprobsResult"`
+}
+
+type geminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+type geminiLogprobsResult struct {
+	TopCandidates []struct {
+		Candidates []geminiLogprob `json:"candidates"`
+	} `json:"topCandidates"`
+	ChosenCandidates []geminiLogprob `json:"chosenCandidates"`
+}
+
+type geminiLogprob struct {
+	Token          string  `json:"token"`
+	LogProbability float32 `json:"logProbability"`
+}
+
+// text joins the text of all parts.
+func (c *geminiCandidate) text() string {
+	var b strings.Builder
+	for _, p := range c.Content.Parts {
+		b.WriteString(p.Text)
+	}
+	return b.String()
+}
+
+// candidate converts c, pairing each chosen token with the alternatives at
+// its position.
+func (c *geminiCandidate) candidate() Candidate {
+	out := Candidate{
+		Index:              c.Index,
+		Text:               c.text(),
+		FinishReason:       geminiFinishReason(c.FinishReason),
+		NativeFinishReason: c.FinishReason,
+	}
+	for _, sr := range c.SafetyRatings {
+		out.SafetyRatings = append(out.SafetyRatings, SafetyRating(sr))
+	}
+	if lr := c.LogprobsResult; lr != nil {
+		for i, chosen := range lr.ChosenCandidates {
+			t := TokenLogprob{Token: chosen.Token, Logprob: chosen.LogProbability}
+			if i < len(lr.TopCandidates) {
+				for _, top := range lr.TopCandidates[i].Candidates {
+					t.Top = append(t.Top, TopLogprob{Token: top.Token, Logprob: top.LogProbability})
+				}
+			}
+			out.Logprobs = append(out.Logprobs, t)
+		}
+	}
+	return out
+}
+
+// metadata returns the first candidate's finish reason and safety ratings,
+// or the prompt feedback if the prompt itself was blocked.
+func (r *geminiResponse) metadata() Metadata {
+	m := Metadata{ID: r.ResponseID, Model: r.ModelVersion}
+	ratings := r.PromptFeedback.SafetyRatings
+	if r.PromptFeedback.BlockReason != "" {
+		m.BlockReason = r.PromptFeedback.BlockReason
+		m.NativeFinishReason = r.PromptFeedback.BlockReason
+		m.FinishReason = FinishContentFilter
+	} else if len(r.Candidates) > 0 {
+		m.NativeFinishReason = r.Candidates[0].FinishReason
+		m.FinishReason = geminiFinishReason(m.NativeFinishReason)
+		ratings = r.Candidates[0].SafetyRatings
+	}
+	for _, sr := range ratings {
+		m.SafetyRatings = append(m.SafetyRatings, SafetyRating(sr))
+	}
+	return m
+}
+
+// geminiFinishReason normalizes a Gemini finishReason.
+func geminiFinishReason(reason string) string {
+	switch reason {
+	case "", "FINISH_REASON_UNSPECIFIED":
+		return ""
+	case "STOP":
+		return FinishStop
+	case "MAX_TOKENS":
+		return FinishLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return FinishContentFilter
+	default:
+		return FinishOther
+	}
+}
+
+// update overlays the fields a stream chunk reported onto m.
+func (m *Metadata) update(next Metadata) {
+	if next.ID != "" {
+		m.ID = next.ID
+	}
+	if next.Model != "" {
+		m.Model = next.Model
+	}
+	if next.NativeFinishReason != "" {
+		m.FinishReason, m.NativeFinishReason = next.FinishReason, next.NativeFinishReason
+	}
+	if next.BlockReason != "" {
+		m.BlockReason = next.BlockReason
+	}
+	if len(next.SafetyRatings) > 0 {
+		m.SafetyRatings = next.SafetyRatings
+	}
 }
 
 // Infer performs a unary inference call to the Gemini API.
 func (g *GeminiProvider) Infer(ctx context.Context, req Request) (Response, error) {
 	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", g.baseURL, req.Model, req.APIKey)
 
-	body := geminiRequest{
-		Contents: []geminiContent{
-			{Parts: []geminiPart{{Text: req.Prompt}}},
-		},
-		GenerationConfig: &geminiGenConfig{
-			Temperature:     req.Temperature,
-			MaxOutputTokens: req.MaxTokens,
-		},
-	}
+	body := newGeminiRequest(req)
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
@@ -99,32 +448,31 @@ func (g *GeminiProvider) Infer(ctx context.Context, req Request) (Response, erro
 	if err := json.NewDecoder(httpResp.Body).Decode(&gemResp); err != nil {
 		return Response{}, fmt.Errorf("gemini: decode response: %w", err)
 	}
+	return gemResp.response(), nil
+}
 
-	var text string
-	if len(gemResp.Candidates) > 0 && len(gemResp.Candidates[0].Content.Parts) > 0 {
-		text = gemResp.Candidates[0].Content.Parts[0].Text
+// response converts a generateContent response to a Response.
+func (r *geminiResponse) response() Response {
+	resp := Response{
+		PromptTokens: r.UsageMetadata.PromptTokenCount,
+		CachedTokens: r.UsageMetadata.CachedContentTokenCount,
+		OutputTokens: r.UsageMetadata.CandidatesTokenCount,
+		Metadata:     r.metadata(),
 	}
-
-	return Response{
-		Text:         text,
-		PromptTokens: gemResp.UsageMetadata.PromptTokenCount,
-		OutputTokens: gemResp.UsageMetadata.CandidatesTokenCount,
-	}, nil
+	for i := range r.Candidates {
+		resp.Candidates = append(resp.Candidates, r.Candidates[i].candidate())
+	}
+	if len(resp.Candidates) > 0 {
+		resp.Text = resp.Candidates[0].Text
+	}
+	return resp
 }
 
 // InferStream performs a streaming inference call to the Gemini API.
 func (g *GeminiProvider) InferStream(ctx context.Context, req Request) (<-chan StreamChunk, error) {
 	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?key=%s&alt=sse", g.baseURL, req.Model, req.APIKey)
 
-	body := geminiRequest{
-		Contents: []geminiContent{
-			{Parts: []geminiPart{{Text: req.Prompt}}},
-		},
-		GenerationConfig: &geminiGenConfig{
-			Temperature:     req.Temperature,
-			MaxOutputTokens: req.MaxTokens,
-		},
-	}
+	body := newGeminiRequest(req)
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
@@ -155,6 +503,7 @@ func (g *GeminiProvider) InferStream(ctx context.Context, req Request) (<-chan S
 		defer httpResp.Body.Close()
 
 		decoder := json.NewDecoder(httpResp.Body)
+		var meta Metadata
 		for {
 			select {
 			case <-ctx.Done():
@@ -169,22 +518,377 @@ func (g *GeminiProvider) InferStream(ctx context.Context, req Request) (<-chan S
 					ch <- StreamChunk{Err: fmt.Errorf("gemini: stream decode: %w", err)}
 				}
 				// Send final chunk
-				ch <- StreamChunk{Done: true}
+				ch <- StreamChunk{Done: true, Metadata: meta}
 				return
 			}
+			meta.update(gemResp.metadata())
 
-			var text string
-			if len(gemResp.Candidates) > 0 && len(gemResp.Candidates[0].Content.Parts) > 0 {
-				text = gemResp.Candidates[0].Content.Parts[0].Text
-			}
-
-			ch <- StreamChunk{
-				Text:         text,
+			// One chunk per candidate in the event; usage rides on the
+			// first.
+			usage := StreamChunk{
 				PromptTokens: gemResp.UsageMetadata.PromptTokenCount,
+				CachedTokens: gemResp.UsageMetadata.CachedContentTokenCount,
 				OutputTokens: gemResp.UsageMetadata.CandidatesTokenCount,
 			}
+			if len(gemResp.Candidates) == 0 {
+				ch <- usage
+				continue
+			}
+			for i := range gemResp.Candidates {
+				c := gemResp.Candidates[i].candidate()
+				out := StreamChunk{Text: c.Text, Index: c.Index, Logprobs: c.Logprobs}
+				out.FinishReason, out.NativeFinishReason = c.FinishReason, c.NativeFinishReason
+				if i == 0 {
+					out.PromptTokens, out.CachedTokens, out.OutputTokens = usage.PromptTokens, usage.CachedTokens, usage.OutputTokens
+				}
+				ch <- out
+			}
 		}
 	}()
 
 	return ch, nil
 }
+
+type geminiCountTokensResponse struct {
+	TotalTokens int32 `json:"totalTokens"`
+}
+
+// CountTokens calls countTokens with the prompt and its parts.
+func (g *GeminiProvider) CountTokens(ctx context.Context, req Request) (int32, error) {
+	url := fmt.Sprintf("%s/models/%s:countTokens?key=%s", g.baseURL, req.Model, req.APIKey)
+
+	body := geminiRequest{Contents: geminiContents(req)}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("gemini: marshal count tokens request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return 0, fmt.Errorf("gemini: create count tokens request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := g.client.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("gemini: count tokens request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(httpResp.Body)
+		return 0, fmt.Errorf("gemini: count tokens API error %d: %s", httpResp.StatusCode, string(respBody))
+	}
+
+	var countResp geminiCountTokensResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&countResp); err != nil {
+		return 0, fmt.Errorf("gemini: decode count tokens response: %w", err)
+	}
+	return countResp.TotalTokens, nil
+}
+
+// geminiMaxEmbedInputs is the largest batch batchEmbedContents accepts.
+const geminiMaxEmbedInputs = 100
+
+type geminiEmbedRequest struct {
+	Requests []geminiEmbedContent `json:"requests"`
+}
+
+type geminiEmbedContent struct {
+	Model                string        `json:"model"`
+	Content              geminiContent `json:"content"`
+	OutputDimensionality *int32        `json:"outputDimensionality,omitempty"`
+}
+
+type geminiEmbedResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+// ValidateEmbed enforces the batch limit of batchEmbedContents.
+func (g *GeminiProvider) ValidateEmbed(req EmbedRequest) error {
+	if len(req.Inputs) > geminiMaxEmbedInputs {
+		return &ParamError{"gemini", "inputs", fmt.Sprintf("accepts at most %d texts", geminiMaxEmbedInputs)}
+	}
+	return checkInt("gemini", "dimensions", req.Dimensions, 1, 3072)
+}
+
+// Embed calls batchEmbedContents. Gemini does not report token usage for
+// embeddings, so PromptTokens is always 0.
+func (g *GeminiProvider) Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	url := fmt.Sprintf("%s/models/%s:batchEmbedContents?key=%s", g.baseURL, req.Model, req.APIKey)
+
+	var body geminiEmbedRequest
+	for _, input := range req.Inputs {
+		body.Requests = append(body.Requests, geminiEmbedContent{
+			Model:                "models/" + req.Model,
+			Content:              geminiContent{Parts: []geminiPart{{Text: input}}},
+			OutputDimensionality: req.Dimensions,
+		})
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return EmbedResponse{}, fmt.Errorf("gemini: marshal embed request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return EmbedResponse{}, fmt.Errorf("gemini: create embed request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := g.client.Do(httpReq)
+	if err != nil {
+		return EmbedResponse{}, fmt.Errorf("gemini: embed request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(httpResp.Body)
+		return EmbedResponse{}, fmt.Errorf("gemini: embed API error %d: %s", httpResp.StatusCode, string(respBody))
+	}
+
+	var gemResp geminiEmbedResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&gemResp); err != nil {
+		return EmbedResponse{}, fmt.Errorf("gemini: decode embed response: %w", err)
+	}
+	if len(gemResp.Embeddings) != len(req.Inputs) {
+		return EmbedResponse{}, fmt.Errorf("gemini: got %d embeddings for %d inputs", len(gemResp.Embeddings), len(req.Inputs))
+	}
+
+	resp := EmbedResponse{Model: req.Model}
+	for _, e := range gemResp.Embeddings {
+		resp.Embeddings = append(resp.Embeddings, e.Values)
+	}
+	return resp, nil
+}
+
+type geminiModelList struct {
+	Models []struct {
+		Name                       string   `json:"name"`
+		InputTokenLimit            int      `json:"inputTokenLimit"`
+		OutputTokenLimit           int      `json:"outputTokenLimit"`
+		SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	} `json:"models"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// ListModels calls models.list, following pages, and keeps the models that
+// support generateContent or embedContent.
+func (g *GeminiProvider) ListModels(ctx context.Context, apiKey string) ([]ModelInfo, error) {
+	var models []ModelInfo
+	pageToken := ""
+	for {
+		endpoint := fmt.Sprintf("%s/models?key=%s&pageSize=1000", g.baseURL, apiKey)
+		if pageToken != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("gemini: create list models request: %w", err)
+		}
+
+		httpResp, err := g.client.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("gemini: list models request: %w", err)
+		}
+		var list geminiModelList
+		if httpResp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(httpResp.Body)
+			httpResp.Body.Close()
+			return nil, fmt.Errorf("gemini: list models API error %d: %s", httpResp.StatusCode, string(respBody))
+		}
+		err = json.NewDecoder(httpResp.Body).Decode(&list)
+		httpResp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("gemini: decode list models response: %w", err)
+		}
+
+		for _, m := range list.Models {
+			info := ModelInfo{
+				ID:              strings.TrimPrefix(m.Name, "models/"),
+				ContextWindow:   m.InputTokenLimit,
+				MaxOutputTokens: m.OutputTokenLimit,
+			}
+			switch {
+			case slices.Contains(m.SupportedGenerationMethods, "generateContent"):
+			case slices.Contains(m.SupportedGenerationMethods, "embedContent"):
+				info.Embeddings = true
+				info.MaxOutputTokens = 0
+			default:
+				continue
+			}
+			models = append(models, info)
+		}
+
+		if list.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+// geminiBatchRequest is a batchGenerateContent request body with the
+// requests inline.
+type geminiBatchRequest struct {
+	Batch struct {
+		DisplayName string `json:"displayName"`
+		InputConfig struct {
+			Requests struct {
+				Requests []geminiInlinedRequest `json:"requests"`
+			} `json:"requests"`
+		} `json:"inputConfig"`
+	} `json:"batch"`
+}
+
+type geminiInlinedRequest struct {
+	Request  geminiRequest     `json:"request"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// geminiBatchOperation is the long-running operation of a batch. The
+// results are in the response once it is done; older API versions put them
+// in the metadata's output.
+type geminiBatchOperation struct {
+	Name     string `json:"name"`
+	Metadata struct {
+		State  string            `json:"state"`
+		Output geminiBatchOutput `json:"output"`
+	} `json:"metadata"`
+	Response geminiBatchOutput `json:"response"`
+	Error    *geminiStatus     `json:"error"`
+}
+
+type geminiBatchOutput struct {
+	InlinedResponses struct {
+		InlinedResponses []geminiInlinedResponse `json:"inlinedResponses"`
+	} `json:"inlinedResponses"`
+}
+
+type geminiInlinedResponse struct {
+	Response *geminiResponse   `json:"response"`
+	Error    *geminiStatus     `json:"error"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type geminiStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// SubmitBatch creates a batch with reqs inline. Each request's index is
+// kept in its metadata to match it with its response.
+func (g *GeminiProvider) SubmitBatch(ctx context.Context, apiKey string, reqs []Request) (string, error) {
+	if len(reqs) == 0 {
+		return "", fmt.Errorf("gemini: empty batch")
+	}
+	var body geminiBatchRequest
+	body.Batch.DisplayName = "llm-inference-proxy"
+	for i, req := range reqs {
+		body.Batch.InputConfig.Requests.Requests = append(body.Batch.InputConfig.Requests.Requests, geminiInlinedRequest{
+			Request:  newGeminiRequest(req),
+			Metadata: map[string]string{"key": strconv.Itoa(i)},
+		})
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("gemini: marshal batch request: %w", err)
+	}
+	endpoint := fmt.Sprintf("%s/models/%s:batchGenerateContent?key=%s", g.baseURL, reqs[0].Model, apiKey)
+	respBody, err := g.batchCall(ctx, http.MethodPost, endpoint, jsonBody)
+	if err != nil {
+		return "", err
+	}
+	var op geminiBatchOperation
+	if err := json.Unmarshal(respBody, &op); err != nil {
+		return "", fmt.Errorf("gemini: decode batch response: %w", err)
+	}
+	return op.Name, nil
+}
+
+// PollBatch gets the batch operation and, once it has ended, its inline
+// responses.
+func (g *GeminiProvider) PollBatch(ctx context.Context, apiKey, id string) (BatchStatus, error) {
+	respBody, err := g.batchCall(ctx, http.MethodGet, fmt.Sprintf("%s/%s?key=%s", g.baseURL, id, apiKey), nil)
+	if err != nil {
+		return BatchStatus{}, err
+	}
+	var op geminiBatchOperation
+	if err := json.Unmarshal(respBody, &op); err != nil {
+		return BatchStatus{}, fmt.Errorf("gemini: decode batch response: %w", err)
+	}
+
+	var st BatchStatus
+	switch strings.TrimPrefix(strings.TrimPrefix(op.Metadata.State, "BATCH_STATE_"), "JOB_STATE_") {
+	case "SUCCEEDED":
+		st.State = BatchDone
+	case "CANCELLED":
+		st.State = BatchCancelled
+	case "EXPIRED":
+		st.State, st.Error = BatchFailed, "gemini: batch expired before completing"
+	case "FAILED":
+		st.State, st.Error = BatchFailed, "gemini: batch failed"
+		if op.Error != nil {
+			st.Error += ": " + op.Error.Message
+		}
+	default:
+		return BatchStatus{State: BatchRunning}, nil
+	}
+
+	inlined := op.Response.InlinedResponses.InlinedResponses
+	if len(inlined) == 0 {
+		inlined = op.Metadata.Output.InlinedResponses.InlinedResponses
+	}
+	for i, ir := range inlined {
+		r := BatchResult{Index: i}
+		if key, err := strconv.Atoi(ir.Metadata["key"]); err == nil {
+			r.Index = key
+		}
+		switch {
+		case ir.Response != nil:
+			r.Response = ir.Response.response()
+		case ir.Error != nil:
+			r.Err = fmt.Errorf("gemini: batch request failed (code %d): %s", ir.Error.Code, ir.Error.Message)
+		default:
+			r.Err = fmt.Errorf("gemini: batch request has no response")
+		}
+		st.Results = append(st.Results, r)
+	}
+	return st, nil
+}
+
+// CancelBatch cancels the batch operation.
+func (g *GeminiProvider) CancelBatch(ctx context.Context, apiKey, id string) error {
+	_, err := g.batchCall(ctx, http.MethodPost, fmt.Sprintf("%s/%s:cancel?key=%s", g.baseURL, id, apiKey), nil)
+	return err
+}
+
+// batchCall sends a batch API request and returns the response body.
+func (g *GeminiProvider) batchCall(ctx context.Context, method, endpoint string, body []byte) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("gemini: create batch request: %w", err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: batch request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: read batch response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gemini: batch API error %d: %s", httpResp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGeminiGenerationConfig(t *testing.T) {
+	zero := float32(0)
+	topK, n := int32(40), int32(2)
+	tests := []struct {
+		name string
+		req  Request
+		want string // Expected in the config, or absent if prefixed with "!"
+	}{
+		{"unset temperature is left out", Request{}, `!"temperature"`},
+		{"zero temperature is sent", Request{Temperature: &zero}, `"temperature":0`},
+		{"top_k", Request{TopK: &topK}, `"topK":40`},
+		{"stop sequences", Request{Stop: []string{"END"}}, `"stopSequences":["END"]`},
+		{"candidates", Request{N: &n}, `"candidateCount":2`},
+		{"max tokens", Request{MaxTokens: 64}, `"maxOutputTokens":64`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(geminiGenerationConfig(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, absent := strings.CutPrefix(tt.want, "!")
+			if strings.Contains(string(body), want) == absent {
+				t.Errorf("config %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeminiValidate(t *testing.T) {
+	f := func(v float32) *float32 { return &v }
+	i := func(v int32) *int32 { return &v }
+	i64 := func(v int64) *int64 { return &v }
+	tests := []struct {
+		name      string
+		req       Request
+		wantParam string // Rejected parameter, or "" if valid
+	}{
+		{"defaults", Request{}, ""},
+		{"every supported parameter", Request{Temperature: f(0), TopP: f(1), TopK: i(40), Stop: []string{"a"}, Seed: i64(7), N: i(8), Logprobs: true, TopLogprobs: i(5)}, ""},
+		{"logit_bias", Request{LogitBias: map[string]float32{"1": 1}}, "logit_bias"},
+		{"top_k of zero", Request{TopK: i(0)}, "top_k"},
+		{"too many stop sequences", Request{Stop: []string{"a", "b", "c", "d", "e", "f"}}, "stop"},
+		{"64-bit seed", Request{Seed: i64(math.MaxInt32 + 1)}, "seed"},
+		{"too many candidates", Request{N: i(9)}, "n"},
+		{"media parts", Request{Parts: []Part{{MIMEType: "image/png", Data: []byte{1}}, {MIMEType: "audio/wav", Data: []byte{1}}, {MIMEType: "application/pdf", URI: "gs://bucket/a.pdf"}}}, ""},
+		{"unsupported part", Request{Parts: []Part{{MIMEType: "application/zip", Data: []byte{1}}}}, "parts"},
+		{"too much inline data", Request{Parts: []Part{{MIMEType: "video/mp4", Data: make([]byte, maxInlineBytes+1)}}}, "parts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewGeminiProvider().Validate(tt.req)
+			checkParamError(t, err, tt.wantParam)
+		})
+	}
+}
+
+func TestGeminiFinishReason(t *testing.T) {
+	tests := []struct {
+		native string
+		want   string
+	}{
+		{"", ""},
+		{"FINISH_REASON_UNSPECIFIED", ""},
+		{"STOP", FinishStop},
+		{"MAX_TOKENS", FinishLength},
+		{"SAFETY", FinishContentFilter},
+		{"RECITATION", FinishContentFilter},
+		{"PROHIBITED_CONTENT", FinishContentFilter},
+		{"MALFORMED_FUNCTION_CALL", FinishOther},
+	}
+	for _, tt := range tests {
+		if got := geminiFinishReason(tt.native); got != tt.want {
+			t.Errorf("geminiFinishReason(%q) = %q, want %q", tt.native, got, tt.want)
+		}
+	}
+}
+
+func TestGeminiMetadata(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantFinish   string
+		wantNative   string
+		wantBlock    string
+		wantCategory string
+	}{
+		{
+			"candidate",
+			`{"responseId":"r-1","modelVersion":"gemini-1.5-pro-002","candidates":[{"finishReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"HIGH","blocked":true}]}]}`,
+			FinishContentFilter, "SAFETY", "", "HARM_CATEGORY_HARASSMENT",
+		},
+		{
+			"blocked prompt",
+			`{"responseId":"r-1","modelVersion":"gemini-1.5-pro-002","promptFeedback":{"blockReason":"PROHIBITED_CONTENT","safetyRatings":[{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"MEDIUM"}]}}`,
+			FinishContentFilter, "PROHIBITED_CONTENT", "PROHIBITED_CONTENT", "HARM_CATEGORY_DANGEROUS_CONTENT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r geminiResponse
+			if err := json.Unmarshal([]byte(tt.body), &r); err != nil {
+				t.Fatal(err)
+			}
+			m := r.metadata()
+			if m.ID != "r-1" || m.Model != "gemini-1.5-pro-002" {
+				t.Errorf("ID, model = %q, %q, want the response ID and model version", m.ID, m.Model)
+			}
+			if m.FinishReason != tt.wantFinish || m.NativeFinishReason != tt.wantNative || m.BlockReason != tt.wantBlock {
+				t.Errorf("finish, native, block = %q, %q, %q, want %q, %q, %q", m.FinishReason, m.NativeFinishReason, m.BlockReason, tt.wantFinish, tt.wantNative, tt.wantBlock)
+			}
+			if len(m.SafetyRatings) != 1 || m.SafetyRatings[0].Category != tt.wantCategory {
+				t.Errorf("safety ratings = %+v, want one for %s", m.SafetyRatings, tt.wantCategory)
+			}
+		})
+	}
+}
+
+func TestGeminiCandidate(t *testing.T) {
+	var c geminiCandidate
+	body := `{"index":1,"content":{"parts":[{"text":"Hello"},{"text":" there"}]},"finishReason":"STOP",
+		"logprobsResult":{
+			"chosenCandidates":[{"token":"Hello","logProbability":-0.2},{"token":" there","logProbability":-0.4}],
+			"topCandidates":[{"candidates":[{"token":"Hello","logProbability":-0.2},{"token":"Hi","logProbability":-1.8}]}]
+		}}`
+	if err := json.Unmarshal([]byte(body), &c); err != nil {
+		t.Fatal(err)
+	}
+	want := Candidate{
+		Index: 1, Text: "Hello there", FinishReason: FinishStop, NativeFinishReason: "STOP",
+		Logprobs: []TokenLogprob{
+			{Token: "Hello", Logprob: -0.2, Top: []TopLogprob{{Token: "Hello", Logprob: -0.2}, {Token: "Hi", Logprob: -1.8}}},
+			{Token: " there", Logprob: -0.4}, // No alternatives reported at this position
+		},
+	}
+	if got := c.candidate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("candidate() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGeminiParts(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"text only", Request{Prompt: "hi"}, `[{"text":"hi"}]`},
+		{
+			"inline data",
+			Request{Prompt: "what is this?", Parts: []Part{{MIMEType: "image/png", Data: []byte("png")}}},
+			`[{"text":"what is this?"},{"inlineData":{"mimeType":"image/png","data":"cG5n"}}]`,
+		},
+		{
+			"file URI without a prompt",
+			Request{Parts: []Part{{MIMEType: "application/pdf", URI: "gs://bucket/a.pdf"}}},
+			`[{"fileData":{"mimeType":"application/pdf","fileUri":"gs://bucket/a.pdf"}}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(geminiParts(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("geminiParts = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeminiEmbed(t *testing.T) {
+	var got geminiEmbedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/text-embedding-004:batchEmbedContents" || r.URL.Query().Get("key") != "k" {
+			http.Error(w, "unexpected "+r.URL.String(), http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"embeddings":[{"values":[1,0]},{"values":[0,1]}]}`)
+	}))
+	defer srv.Close()
+
+	g := NewGeminiProvider()
+	g.baseURL = srv.URL
+	dims := int32(2)
+	resp, err := g.Embed(context.Background(), EmbedRequest{Model: "text-embedding-004", Inputs: []string{"a", "b"}, Dimensions: &dims, APIKey: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Requests) != 2 || got.Requests[1].Model != "models/text-embedding-004" || *got.Requests[1].OutputDimensionality != 2 {
+		t.Errorf("sent %+v, want one request per input with the model and dimensions", got.Requests)
+	}
+	want := [][]float32{{1, 0}, {0, 1}}
+	if !reflect.DeepEqual(resp.Embeddings, want) || resp.PromptTokens != 0 {
+		t.Errorf("Embed = %+v, want %v and no usage", resp, want)
+	}
+
+	if err := g.ValidateEmbed(EmbedRequest{Inputs: make([]string, geminiMaxEmbedInputs+1)}); err == nil {
+		t.Errorf("ValidateEmbed accepted %d inputs, want an error", geminiMaxEmbedInputs+1)
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -32,41 +36,262 @@ func (o *OpenAIProvider) Name() string { return "openai" }
 // ---------------------------------------------------------------------------
 
 type openAIRequest struct {
-	Model       string           `json:"model"`
-	Messages    []openAIMessage  `json:"messages"`
-	Temperature float32          `json:"temperature,omitempty"`
-	MaxTokens   int32            `json:"max_tokens,omitempty"`
-	Stream      bool             `json:"stream,omitempty"`
+	Model            string                `json:"model"`
+	Messages         []openAIMessage       `json:"messages"`
+	Temperature      *float32              `json:"temperature,omitempty"`
+	MaxTokens        int32                 `json:"max_tokens,omitempty"`
+	TopP             *float32              `json:"top_p,omitempty"`
+	Stop             []string              `json:"stop,omitempty"`
+	PresencePenalty  *float32              `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32              `json:"frequency_penalty,omitempty"`
+	Seed             *int64                `json:"seed,omitempty"`
+	N                *int32                `json:"n,omitempty"`
+	LogitBias        map[string]float32    `json:"logit_bias,omitempty"`
+	Logprobs         bool                  `json:"logprobs,omitempty"`
+	TopLogprobs      *int32                `json:"top_logprobs,omitempty"`
+	Stream           bool                  `json:"stream,omitempty"`
+	StreamOptions    *openAIStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat   *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+// openAIStreamOptions asks for a final chunk with the stream's usage,
+// which is otherwise never sent.
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// newOpenAIRequest translates req to a Chat Completions request body.
+func newOpenAIRequest(req Request, stream bool) openAIRequest {
+	var streamOptions *openAIStreamOptions
+	if stream {
+		streamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	return openAIRequest{
+		Model:            req.Model,
+		Messages:         openAIMessages(req),
+		Temperature:      req.Temperature,
+		MaxTokens:        req.MaxTokens,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		Seed:             req.Seed,
+		N:                req.N,
+		LogitBias:        req.LogitBias,
+		Logprobs:         req.Logprobs,
+		TopLogprobs:      req.TopLogprobs,
+		Stream:           stream,
+		StreamOptions:    streamOptions,
+		ResponseFormat:   openAIFormat(req.Format),
+	}
+}
+
+// Validate rejects top_k, which Chat Completions has no equivalent for, and
+// values outside OpenAI's documented ranges.
+func (o *OpenAIProvider) Validate(req Request) error {
+	if req.TopK != nil {
+		return &ParamError{"openai", "top_k", "is not supported"}
+	}
+	for _, p := range req.Parts {
+		switch {
+		case openAIImageTypes[p.MIMEType]:
+		case p.MIMEType == "application/pdf" && len(p.Data) > 0:
+		case p.MIMEType == "application/pdf":
+			return &ParamError{"openai", "parts", "with a PDF URI are not supported; send PDFs inline"}
+		default:
+			return &ParamError{"openai", "parts", fmt.Sprintf("of type %q are not supported", p.MIMEType)}
+		}
+	}
+	if len(req.Stop) > 4 {
+		return &ParamError{"openai", "stop", "accepts at most 4 sequences"}
+	}
+	for token, bias := range req.LogitBias {
+		if _, err := strconv.Atoi(token); err != nil {
+			return &ParamError{"openai", "logit_bias", fmt.Sprintf("key %q is not a token ID", token)}
+		}
+		if bias < -100 || bias > 100 {
+			return &ParamError{"openai", "logit_bias", "values must be between -100 and 100"}
+		}
+	}
+	return firstErr(
+		checkInlineSize("openai", req),
+		checkLogprobs("openai", req),
+		checkFloat("openai", "temperature", req.Temperature, 0, 2),
+		checkFloat("openai", "top_p", req.TopP, 0, 1),
+		checkFloat("openai", "presence_penalty", req.PresencePenalty, -2, 2),
+		checkFloat("openai", "frequency_penalty", req.FrequencyPenalty, -2, 2),
+		checkInt("openai", "n", req.N, 1, 128),
+	)
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict,omitempty"`
+}
+
+// openAIFormat maps a response format to JSON mode or structured outputs.
+func openAIFormat(f *ResponseFormat) *openAIResponseFormat {
+	if f == nil {
+		return nil
+	}
+	if f.Type != FormatJSONSchema {
+		return &openAIResponseFormat{Type: "json_object"}
+	}
+	return &openAIResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &openAIJSONSchema{Name: f.Name, Schema: f.Schema, Strict: f.Strict},
+	}
 }
 
 type openAIMessage struct {
 	Role    string `json:"role"`
-	Content string `json:"content"`
+	Content any    `json:"content"` // string, or []openAIContentPart with attachments
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+	File     *openAIFile     `json:"file,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIFile struct {
+	Filename string `json:"filename"`
+	FileData string `json:"file_data"`
+}
+
+// openAIMessages returns the conversation history followed by the user
+// message.
+func openAIMessages(req Request) []openAIMessage {
+	msgs := make([]openAIMessage, 0, len(req.History)+1)
+	for _, m := range req.History {
+		msgs = append(msgs, openAIMessage{Role: m.Role, Content: m.Text})
+	}
+	return append(msgs, openAIMessage{Role: "user", Content: openAIContent(req)})
+}
+
+// openAIContent returns the user message content: the prompt alone, or
+// content parts with images as image_url (inline data as a data: URL) and
+// PDFs as inline files.
+func openAIContent(req Request) any {
+	if len(req.Parts) == 0 {
+		return req.Prompt
+	}
+	parts := make([]openAIContentPart, 0, len(req.Parts)+1)
+	if req.Prompt != "" {
+		parts = append(parts, openAIContentPart{Type: "text", Text: req.Prompt})
+	}
+	for i, p := range req.Parts {
+		url := p.URI
+		if len(p.Data) > 0 {
+			url = "data:" + p.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(p.Data)
+		}
+		if strings.HasPrefix(p.MIMEType, "image/") {
+			parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+			continue
+		}
+		parts = append(parts, openAIContentPart{
+			Type: "file",
+			File: &openAIFile{Filename: fmt.Sprintf("document-%d.pdf", i+1), FileData: url},
+		})
+	}
+	return parts
+}
+
+// openAIImageTypes are the image formats Chat Completions accepts.
+var openAIImageTypes = map[string]bool{
+	"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true,
 }
 
 type openAIResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
 	Choices []struct {
+		Index   int `json:"index"`
 		Message struct {
 			Content string `json:"content"`
 		} `json:"message"`
+		FinishReason string          `json:"finish_reason"`
+		Logprobs     *openAILogprobs `json:"logprobs"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int32 `json:"prompt_tokens"`
-		CompletionTokens int32 `json:"completion_tokens"`
-	} `json:"usage"`
+	Usage openAIUsage `json:"usage"`
 }
 
 type openAIStreamChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
 	Choices []struct {
+		Index int `json:"index"`
 		Delta struct {
 			Content string `json:"content"`
 		} `json:"delta"`
-		FinishReason *string `json:"finish_reason"`
+		FinishReason *string         `json:"finish_reason"`
+		Logprobs     *openAILogprobs `json:"logprobs"`
 	} `json:"choices"`
-	Usage *struct {
-		PromptTokens     int32 `json:"prompt_tokens"`
-		CompletionTokens int32 `json:"completion_tokens"`
-	} `json:"usage,omitempty"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens        int32 `json:"prompt_tokens"`
+	CompletionTokens    int32 `json:"completion_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int32 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+type openAILogprobs struct {
+	Content []struct {
+		Token       string  `json:"token"`
+		Logprob     float32 `json:"logprob"`
+		TopLogprobs []struct {
+			Token   string  `json:"token"`
+			Logprob float32 `json:"logprob"`
+		} `json:"top_logprobs"`
+	} `json:"content"`
+}
+
+// tokens converts OpenAI logprobs; nil-safe.
+func (l *openAILogprobs) tokens() []TokenLogprob {
+	if l == nil || len(l.Content) == 0 {
+		return nil
+	}
+	out := make([]TokenLogprob, 0, len(l.Content))
+	for _, c := range l.Content {
+		t := TokenLogprob{Token: c.Token, Logprob: c.Logprob}
+		for _, top := range c.TopLogprobs {
+			t.Top = append(t.Top, TopLogprob(top))
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// openAIFinishReason normalizes an OpenAI finish_reason.
+func openAIFinishReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "stop":
+		return FinishStop
+	case "length":
+		return FinishLength
+	case "content_filter":
+		return FinishContentFilter
+	case "tool_calls", "function_call":
+		return FinishToolCalls
+	default:
+		return FinishOther
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -74,12 +299,7 @@ type openAIStreamChunk struct {
 // ---------------------------------------------------------------------------
 
 func (o *OpenAIProvider) Infer(ctx context.Context, req Request) (Response, error) {
-	body := openAIRequest{
-		Model:       req.Model,
-		Messages:    []openAIMessage{{Role: "user", Content: req.Prompt}},
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-	}
+	body := newOpenAIRequest(req, false)
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
@@ -108,17 +328,32 @@ func (o *OpenAIProvider) Infer(ctx context.Context, req Request) (Response, erro
 	if err := json.NewDecoder(httpResp.Body).Decode(&oaiResp); err != nil {
 		return Response{}, fmt.Errorf("openai: decode response: %w", err)
 	}
+	return oaiResp.response(), nil
+}
 
-	var text string
-	if len(oaiResp.Choices) > 0 {
-		text = oaiResp.Choices[0].Message.Content
+// response converts a chat completion to a Response.
+func (r *openAIResponse) response() Response {
+	resp := Response{
+		PromptTokens: r.Usage.PromptTokens,
+		CachedTokens: r.Usage.PromptTokensDetails.CachedTokens,
+		OutputTokens: r.Usage.CompletionTokens,
+		Metadata:     Metadata{ID: r.ID, Model: r.Model},
 	}
-
-	return Response{
-		Text:         text,
-		PromptTokens: oaiResp.Usage.PromptTokens,
-		OutputTokens: oaiResp.Usage.CompletionTokens,
-	}, nil
+	for _, c := range r.Choices {
+		resp.Candidates = append(resp.Candidates, Candidate{
+			Index:              c.Index,
+			Text:               c.Message.Content,
+			FinishReason:       openAIFinishReason(c.FinishReason),
+			NativeFinishReason: c.FinishReason,
+			Logprobs:           c.Logprobs.tokens(),
+		})
+	}
+	if len(resp.Candidates) > 0 {
+		first := resp.Candidates[0]
+		resp.Text = first.Text
+		resp.FinishReason, resp.NativeFinishReason = first.FinishReason, first.NativeFinishReason
+	}
+	return resp
 }
 
 // ---------------------------------------------------------------------------
@@ -126,13 +361,7 @@ func (o *OpenAIProvider) Infer(ctx context.Context, req Request) (Response, erro
 // ---------------------------------------------------------------------------
 
 func (o *OpenAIProvider) InferStream(ctx context.Context, req Request) (<-chan StreamChunk, error) {
-	body := openAIRequest{
-		Model:       req.Model,
-		Messages:    []openAIMessage{{Role: "user", Content: req.Prompt}},
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		Stream:      true,
-	}
+	body := newOpenAIRequest(req, true)
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
@@ -164,7 +393,8 @@ func (o *OpenAIProvider) InferStream(ctx context.Context, req Request) (<-chan S
 		defer httpResp.Body.Close()
 
 		scanner := bufio.NewScanner(httpResp.Body)
-		var totalPromptTokens, totalOutputTokens int32
+		var totalPromptTokens, totalCachedTokens, totalOutputTokens int32
+		var meta Metadata
 
 		for scanner.Scan() {
 			select {
@@ -188,7 +418,9 @@ func (o *OpenAIProvider) InferStream(ctx context.Context, req Request) (<-chan S
 				ch <- StreamChunk{
 					Done:         true,
 					PromptTokens: totalPromptTokens,
+					CachedTokens: totalCachedTokens,
 					OutputTokens: totalOutputTokens,
+					Metadata:     meta,
 				}
 				return
 			}
@@ -202,16 +434,28 @@ func (o *OpenAIProvider) InferStream(ctx context.Context, req Request) (<-chan S
 			// Track usage if reported
 			if chunk.Usage != nil {
 				totalPromptTokens = chunk.Usage.PromptTokens
+				totalCachedTokens = chunk.Usage.PromptTokensDetails.CachedTokens
 				totalOutputTokens = chunk.Usage.CompletionTokens
 			}
 
-			var text string
-			if len(chunk.Choices) > 0 {
-				text = chunk.Choices[0].Delta.Content
+			if chunk.ID != "" {
+				meta.ID, meta.Model = chunk.ID, chunk.Model
 			}
 
-			if text != "" {
-				ch <- StreamChunk{Text: text}
+			// With n > 1 the choices' deltas are interleaved; each is
+			// forwarded tagged with its index.
+			for _, c := range chunk.Choices {
+				out := StreamChunk{Text: c.Delta.Content, Index: c.Index, Logprobs: c.Logprobs.tokens()}
+				if c.FinishReason != nil {
+					out.NativeFinishReason = *c.FinishReason
+					out.FinishReason = openAIFinishReason(*c.FinishReason)
+					if c.Index == 0 {
+						meta.FinishReason, meta.NativeFinishReason = out.FinishReason, out.NativeFinishReason
+					}
+				}
+				if out.Text != "" || out.Logprobs != nil || out.FinishReason != "" {
+					ch <- out
+				}
 			}
 		}
 
@@ -222,3 +466,351 @@ func (o *OpenAIProvider) InferStream(ctx context.Context, req Request) (<-chan S
 
 	return ch, nil
 }
+
+// ---------------------------------------------------------------------------
+// Embed — Embeddings API
+// ---------------------------------------------------------------------------
+
+// openAIMaxEmbedInputs is the largest batch the embeddings endpoint accepts.
+const openAIMaxEmbedInputs = 2048
+
+type openAIEmbedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions *int32   `json:"dimensions,omitempty"`
+}
+
+type openAIEmbedResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Model string `json:"model"`
+	Usage struct {
+		PromptTokens int32 `json:"prompt_tokens"`
+	} `json:"usage"`
+}
+
+// ValidateEmbed enforces the batch limit; only the text-embedding-3 models
+// can shorten their vectors.
+func (o *OpenAIProvider) ValidateEmbed(req EmbedRequest) error {
+	if len(req.Inputs) > openAIMaxEmbedInputs {
+		return &ParamError{"openai", "inputs", fmt.Sprintf("accepts at most %d texts", openAIMaxEmbedInputs)}
+	}
+	if req.Dimensions != nil && !strings.HasPrefix(req.Model, "text-embedding-3") {
+		return &ParamError{"openai", "dimensions", fmt.Sprintf("is not supported by %s", req.Model)}
+	}
+	return checkInt("openai", "dimensions", req.Dimensions, 1, 3072)
+}
+
+// Embed calls the embeddings endpoint.
+func (o *OpenAIProvider) Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	body := openAIEmbedRequest{
+		Model:      req.Model,
+		Input:      req.Inputs,
+		Dimensions: req.Dimensions,
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return EmbedResponse{}, fmt.Errorf("openai: marshal embed request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/embeddings", bytes.NewReader(jsonBody))
+	if err != nil {
+		return EmbedResponse{}, fmt.Errorf("openai: create embed request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
+
+	httpResp, err := o.client.Do(httpReq)
+	if err != nil {
+		return EmbedResponse{}, fmt.Errorf("openai: embed request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(httpResp.Body)
+		return EmbedResponse{}, fmt.Errorf("openai: embed API error %d: %s", httpResp.StatusCode, string(respBody))
+	}
+
+	var oaiResp openAIEmbedResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&oaiResp); err != nil {
+		return EmbedResponse{}, fmt.Errorf("openai: decode embed response: %w", err)
+	}
+
+	resp := EmbedResponse{
+		Embeddings:   make([][]float32, len(req.Inputs)),
+		PromptTokens: oaiResp.Usage.PromptTokens,
+		Model:        oaiResp.Model,
+	}
+	for _, d := range oaiResp.Data {
+		if d.Index < 0 || d.Index >= len(resp.Embeddings) {
+			return EmbedResponse{}, fmt.Errorf("openai: embedding index %d out of range", d.Index)
+		}
+		resp.Embeddings[d.Index] = d.Embedding
+	}
+	for i, e := range resp.Embeddings {
+		if e == nil {
+			return EmbedResponse{}, fmt.Errorf("openai: no embedding for input %d", i)
+		}
+	}
+	return resp, nil
+}
+
+type openAIModelList struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// openAIModelPrefixes are the model families served by chat completions or
+// embeddings; the model list also holds image, audio and moderation models.
+var openAIModelPrefixes = []string{"gpt-", "chatgpt-", "o1", "o3", "o4", "text-embedding-"}
+
+// ListModels calls /models. OpenAI does not report model limits.
+func (o *OpenAIProvider) ListModels(ctx context.Context, apiKey string) ([]ModelInfo, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("openai: create list models request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+
+	httpResp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai: list models request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(httpResp.Body)
+		return nil, fmt.Errorf("openai: list models API error %d: %s", httpResp.StatusCode, string(respBody))
+	}
+
+	var list openAIModelList
+	if err := json.NewDecoder(httpResp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("openai: decode list models response: %w", err)
+	}
+
+	var models []ModelInfo
+	for _, m := range list.Data {
+		for _, prefix := range openAIModelPrefixes {
+			if strings.HasPrefix(m.ID, prefix) {
+				models = append(models, ModelInfo{ID: m.ID, Embeddings: prefix == "text-embedding-"})
+				break
+			}
+		}
+	}
+	return models, nil
+}
+
+// ---------------------------------------------------------------------------
+// Batch API
+// ---------------------------------------------------------------------------
+
+// openAIBatchEndpoint is the endpoint batched requests are run against.
+const openAIBatchEndpoint = "/v1/chat/completions"
+
+// openAIBatchLine is one request of a batch input file.
+type openAIBatchLine struct {
+	CustomID string        `json:"custom_id"`
+	Method   string        `json:"method"`
+	URL      string        `json:"url"`
+	Body     openAIRequest `json:"body"`
+}
+
+type openAIBatch struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	OutputFileID string `json:"output_file_id"`
+	ErrorFileID  string `json:"error_file_id"`
+	Errors       struct {
+		Data []struct {
+			Message string `json:"message"`
+		} `json:"data"`
+	} `json:"errors"`
+}
+
+// openAIBatchOutput is one line of a batch output or error file.
+type openAIBatchOutput struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// SubmitBatch uploads reqs as a batch input file and creates a batch from
+// it with a 24h completion window.
+func (o *OpenAIProvider) SubmitBatch(ctx context.Context, apiKey string, reqs []Request) (string, error) {
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	if err := mw.WriteField("purpose", "batch"); err != nil {
+		return "", fmt.Errorf("openai: create batch file: %w", err)
+	}
+	fw, err := mw.CreateFormFile("file", "batch.jsonl")
+	if err != nil {
+		return "", fmt.Errorf("openai: create batch file: %w", err)
+	}
+	enc := json.NewEncoder(fw)
+	for i, req := range reqs {
+		line := openAIBatchLine{CustomID: strconv.Itoa(i), Method: http.MethodPost, URL: openAIBatchEndpoint, Body: newOpenAIRequest(req, false)}
+		if err := enc.Encode(line); err != nil {
+			return "", fmt.Errorf("openai: marshal batch request: %w", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("openai: create batch file: %w", err)
+	}
+
+	respBody, err := o.batchCall(ctx, apiKey, http.MethodPost, "/files", mw.FormDataContentType(), &form)
+	if err != nil {
+		return "", err
+	}
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &file); err != nil {
+		return "", fmt.Errorf("openai: decode file response: %w", err)
+	}
+
+	jsonBody, err := json.Marshal(map[string]string{
+		"input_file_id":     file.ID,
+		"endpoint":          openAIBatchEndpoint,
+		"completion_window": "24h",
+	})
+	if err != nil {
+		return "", fmt.Errorf("openai: marshal batch: %w", err)
+	}
+	respBody, err = o.batchCall(ctx, apiKey, http.MethodPost, "/batches", "application/json", bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", err
+	}
+	var batch openAIBatch
+	if err := json.Unmarshal(respBody, &batch); err != nil {
+		return "", fmt.Errorf("openai: decode batch response: %w", err)
+	}
+	return batch.ID, nil
+}
+
+// PollBatch retrieves the batch and, once it has ended, reads its output
+// and error files. An expired batch is reported as failed, with the results
+// it finished.
+func (o *OpenAIProvider) PollBatch(ctx context.Context, apiKey, id string) (BatchStatus, error) {
+	respBody, err := o.batchCall(ctx, apiKey, http.MethodGet, "/batches/"+url.PathEscape(id), "", nil)
+	if err != nil {
+		return BatchStatus{}, err
+	}
+	var batch openAIBatch
+	if err := json.Unmarshal(respBody, &batch); err != nil {
+		return BatchStatus{}, fmt.Errorf("openai: decode batch response: %w", err)
+	}
+
+	var st BatchStatus
+	switch batch.Status {
+	case "completed":
+		st.State = BatchDone
+	case "cancelled":
+		st.State = BatchCancelled
+	case "expired":
+		st.State, st.Error = BatchFailed, "openai: batch expired before completing"
+	case "failed":
+		st.State, st.Error = BatchFailed, "openai: batch failed"
+		for _, e := range batch.Errors.Data {
+			st.Error += ": " + e.Message
+		}
+	default:
+		return BatchStatus{State: BatchRunning}, nil
+	}
+
+	for _, fileID := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		content, err := o.batchCall(ctx, apiKey, http.MethodGet, "/files/"+url.PathEscape(fileID)+"/content", "", nil)
+		if err != nil {
+			return BatchStatus{}, err
+		}
+		results, err := openAIBatchResults(content)
+		if err != nil {
+			return BatchStatus{}, err
+		}
+		st.Results = append(st.Results, results...)
+	}
+	return st, nil
+}
+
+// openAIBatchResults parses a batch output or error file.
+func openAIBatchResults(content []byte) ([]BatchResult, error) {
+	var results []BatchResult
+	dec := json.NewDecoder(bytes.NewReader(content))
+	for {
+		var line openAIBatchOutput
+		if err := dec.Decode(&line); err == io.EOF {
+			return results, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("openai: decode batch output: %w", err)
+		}
+		index, err := strconv.Atoi(line.CustomID)
+		if err != nil {
+			continue
+		}
+
+		r := BatchResult{Index: index}
+		switch {
+		case line.Response != nil && line.Response.StatusCode == http.StatusOK:
+			var oaiResp openAIResponse
+			if err := json.Unmarshal(line.Response.Body, &oaiResp); err != nil {
+				r.Err = fmt.Errorf("openai: decode response: %w", err)
+			} else {
+				r.Response = oaiResp.response()
+			}
+		case line.Response != nil:
+			r.Err = fmt.Errorf("openai: API error %d: %s", line.Response.StatusCode, string(line.Response.Body))
+		case line.Error != nil:
+			r.Err = fmt.Errorf("openai: batch request failed: %s: %s", line.Error.Code, line.Error.Message)
+		default:
+			r.Err = fmt.Errorf("openai: batch request has no response")
+		}
+		results = append(results, r)
+	}
+}
+
+// CancelBatch cancels the batch; requests already finished keep their
+// results.
+func (o *OpenAIProvider) CancelBatch(ctx context.Context, apiKey, id string) error {
+	_, err := o.batchCall(ctx, apiKey, http.MethodPost, "/batches/"+url.PathEscape(id)+"/cancel", "", nil)
+	return err
+}
+
+// batchCall sends a Files or Batch API request and returns the response
+// body.
+func (o *OpenAIProvider) batchCall(ctx context.Context, apiKey, method, path, contentType string, body io.Reader) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, o.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("openai: create batch request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+
+	httpResp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai: batch request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("openai: read batch response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("openai: batch API error %d: %s", httpResp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
@@ -0,0 +1,214 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewOpenAIRequestStreamUsage(t *testing.T) {
+	tests := []struct {
+		stream bool
+		want   bool
+	}{
+		{false, false},
+		{true, true},
+	}
+	for _, tt := range tests {
+		body, err := json.Marshal(newOpenAIRequest(Request{Model: "gpt-4o", Prompt: "hi"}, tt.stream))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(body), `"stream_options":{"include_usage":true}`); got != tt.want {
+			t.Errorf("stream %v: body %s asks for usage = %v, want %v", tt.stream, body, got, tt.want)
+		}
+	}
+}
+
+func TestNewOpenAIRequestSampling(t *testing.T) {
+	zero := float32(0)
+	tests := []struct {
+		name string
+		req  Request
+		want string // Expected in the body, or absent if prefixed with "!"
+	}{
+		{"unset temperature is left out", Request{}, `!"temperature"`},
+		{"zero temperature is sent", Request{Temperature: &zero}, `"temperature":0`},
+		{"zero presence penalty is sent", Request{PresencePenalty: &zero}, `"presence_penalty":0`},
+		{"stop sequences", Request{Stop: []string{"END"}}, `"stop":["END"]`},
+		{"logit bias", Request{LogitBias: map[string]float32{"50256": -100}}, `"logit_bias":{"50256":-100}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Model, tt.req.Prompt = "gpt-4o", "hi"
+			body, err := json.Marshal(newOpenAIRequest(tt.req, false))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, absent := strings.CutPrefix(tt.want, "!")
+			if strings.Contains(string(body), want) == absent {
+				t.Errorf("body %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIValidate(t *testing.T) {
+	f := func(v float32) *float32 { return &v }
+	i := func(v int32) *int32 { return &v }
+	tests := []struct {
+		name      string
+		req       Request
+		wantParam string // Rejected parameter, or "" if valid
+	}{
+		{"defaults", Request{}, ""},
+		{"every supported parameter", Request{Temperature: f(0), TopP: f(1), Stop: []string{"a"}, PresencePenalty: f(-2), FrequencyPenalty: f(2), N: i(2), LogitBias: map[string]float32{"1": 5}, Logprobs: true, TopLogprobs: i(5)}, ""},
+		{"top_k", Request{TopK: i(40)}, "top_k"},
+		{"temperature out of range", Request{Temperature: f(2.5)}, "temperature"},
+		{"too many stop sequences", Request{Stop: []string{"a", "b", "c", "d", "e"}}, "stop"},
+		{"logit bias on a word", Request{LogitBias: map[string]float32{"hello": 1}}, "logit_bias"},
+		{"logit bias out of range", Request{LogitBias: map[string]float32{"1": 101}}, "logit_bias"},
+		{"top logprobs without logprobs", Request{TopLogprobs: i(2)}, "top_logprobs"},
+		{"no candidates", Request{N: i(0)}, "n"},
+		{"inline image and PDF", Request{Parts: []Part{{MIMEType: "image/png", Data: []byte{1}}, {MIMEType: "application/pdf", Data: []byte{1}}}}, ""},
+		{"image URI", Request{Parts: []Part{{MIMEType: "image/jpeg", URI: "https://example.com/a.jpg"}}}, ""},
+		{"PDF URI", Request{Parts: []Part{{MIMEType: "application/pdf", URI: "https://example.com/a.pdf"}}}, "parts"},
+		{"audio", Request{Parts: []Part{{MIMEType: "audio/wav", Data: []byte{1}}}}, "parts"},
+		{"too much inline data", Request{Parts: []Part{{MIMEType: "image/png", Data: make([]byte, maxInlineBytes/2+1)}, {MIMEType: "image/png", Data: make([]byte, maxInlineBytes/2)}}}, "parts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewOpenAIProvider().Validate(tt.req)
+			checkParamError(t, err, tt.wantParam)
+		})
+	}
+}
+
+// checkParamError fails t unless err is a ParamError for wantParam, or nil
+// if wantParam is empty.
+func checkParamError(t *testing.T, err error, wantParam string) {
+	t.Helper()
+	var pe *ParamError
+	switch {
+	case wantParam == "" && err != nil:
+		t.Errorf("Validate = %v, want no error", err)
+	case wantParam != "" && (!errors.As(err, &pe) || pe.Param != wantParam):
+		t.Errorf("Validate = %v, want a ParamError for %s", err, wantParam)
+	}
+}
+
+func TestOpenAIFinishReason(t *testing.T) {
+	tests := []struct {
+		native string
+		want   string
+	}{
+		{"", ""},
+		{"stop", FinishStop},
+		{"length", FinishLength},
+		{"content_filter", FinishContentFilter},
+		{"tool_calls", FinishToolCalls},
+		{"function_call", FinishToolCalls},
+		{"something_new", FinishOther},
+	}
+	for _, tt := range tests {
+		if got := openAIFinishReason(tt.native); got != tt.want {
+			t.Errorf("openAIFinishReason(%q) = %q, want %q", tt.native, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAIResponseMetadata(t *testing.T) {
+	var r openAIResponse
+	body := `{"id":"chatcmpl-1","model":"gpt-4o-2024-08-06","choices":[{"message":{"content":"hi"},"finish_reason":"length"}]}`
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		t.Fatal(err)
+	}
+	resp := r.response()
+	if resp.Metadata.ID != "chatcmpl-1" || resp.Metadata.Model != "gpt-4o-2024-08-06" {
+		t.Errorf("metadata = %+v, want the response ID and model", resp.Metadata)
+	}
+	if resp.FinishReason != FinishLength || resp.NativeFinishReason != "length" {
+		t.Errorf("finish reason = %q (%q), want %q (length)", resp.FinishReason, resp.NativeFinishReason, FinishLength)
+	}
+}
+
+func TestOpenAIResponseCandidates(t *testing.T) {
+	var r openAIResponse
+	body := `{"choices":[
+		{"index":0,"message":{"content":"yes"},"finish_reason":"stop","logprobs":{"content":[{"token":"yes","logprob":-0.1,"top_logprobs":[{"token":"yes","logprob":-0.1},{"token":"no","logprob":-2.5}]}]}},
+		{"index":1,"message":{"content":"no"},"finish_reason":"length"}
+	]}`
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		t.Fatal(err)
+	}
+	resp := r.response()
+	want := []Candidate{
+		{Index: 0, Text: "yes", FinishReason: FinishStop, NativeFinishReason: "stop", Logprobs: []TokenLogprob{
+			{Token: "yes", Logprob: -0.1, Top: []TopLogprob{{Token: "yes", Logprob: -0.1}, {Token: "no", Logprob: -2.5}}},
+		}},
+		{Index: 1, Text: "no", FinishReason: FinishLength, NativeFinishReason: "length"},
+	}
+	if !reflect.DeepEqual(resp.Candidates, want) {
+		t.Errorf("candidates = %+v, want %+v", resp.Candidates, want)
+	}
+	if resp.Text != "yes" || resp.FinishReason != FinishStop {
+		t.Errorf("text, finish reason = %q, %q, want the first candidate's", resp.Text, resp.FinishReason)
+	}
+}
+
+func TestOpenAIContent(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"text only", Request{Prompt: "hi"}, `"hi"`},
+		{
+			"inline image",
+			Request{Prompt: "what is this?", Parts: []Part{{MIMEType: "image/png", Data: []byte("png")}}},
+			`[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,cG5n"}}]`,
+		},
+		{
+			"image URI",
+			Request{Parts: []Part{{MIMEType: "image/jpeg", URI: "https://example.com/a.jpg"}}},
+			`[{"type":"image_url","image_url":{"url":"https://example.com/a.jpg"}}]`,
+		},
+		{
+			"inline PDF",
+			Request{Prompt: "summarize", Parts: []Part{{MIMEType: "application/pdf", Data: []byte("pdf")}}},
+			`[{"type":"text","text":"summarize"},{"type":"file","file":{"filename":"document-1.pdf","file_data":"data:application/pdf;base64,cGRm"}}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(openAIContent(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("openAIContent = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIValidateEmbed(t *testing.T) {
+	dims := int32(256)
+	tests := []struct {
+		name      string
+		req       EmbedRequest
+		wantParam string // Rejected parameter, or "" if valid
+	}{
+		{"defaults", EmbedRequest{Model: "text-embedding-ada-002", Inputs: []string{"a"}}, ""},
+		{"dimensions", EmbedRequest{Model: "text-embedding-3-small", Inputs: []string{"a"}, Dimensions: &dims}, ""},
+		{"dimensions on ada", EmbedRequest{Model: "text-embedding-ada-002", Inputs: []string{"a"}, Dimensions: &dims}, "dimensions"},
+		{"too many inputs", EmbedRequest{Model: "text-embedding-3-small", Inputs: make([]string, openAIMaxEmbedInputs+1)}, "inputs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkParamError(t, NewOpenAIProvider().ValidateEmbed(tt.req), tt.wantParam)
+		})
+	}
+}
@@ -1,31 +1,206 @@
 // Package provider defines the LLM provider interface and shared types.
 package provider
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
 
 // Request represents an inference request to an LLM provider.
+// Pointer-typed sampling parameters are optional: nil leaves the provider's
+// default, while a set value, zero included, is always sent.
 type Request struct {
-	Model       string
-	Prompt      string
-	Temperature float32
-	MaxTokens   int32
-	APIKey      string // Injected by the key pool
+	Model            string
+	Prompt           string
+	Temperature      *float32
+	MaxTokens        int32
+	TopP             *float32
+	TopK             *int32
+	Stop             []string
+	PresencePenalty  *float32
+	FrequencyPenalty *float32
+	Seed             *int64
+	N                *int32             // Number of candidates
+	LogitBias        map[string]float32 // Token ID → bias
+	Logprobs         bool               // Return per-token log probabilities
+	TopLogprobs      *int32             // Alternatives per token (requires Logprobs)
+	Parts            []Part             // Images and documents sent after Prompt
+	History          []Message          // Earlier conversation turns, oldest first, sent before Prompt
+	Format           *ResponseFormat    // Structured output (optional)
+	APIKey           string             // Injected by the key pool
 }
 
-// Response represents a complete inference response.
+// Message roles.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is an earlier turn of a conversation.
+type Message struct {
+	Role string // One of the Role* constants
+	Text string
+}
+
+// Part is a non-text prompt input: inline bytes or a URI, with its MIME type.
+type Part struct {
+	MIMEType string
+	Data     []byte // Inline content
+	URI      string // Remote content, when Data is empty
+}
+
+// maxInlineBytes is the inline payload both providers accept per request.
+const maxInlineBytes = 20 << 20
+
+// checkInlineSize returns a ParamError if the inline parts of req exceed
+// maxInlineBytes in total.
+func checkInlineSize(provider string, req Request) error {
+	total := 0
+	for _, p := range req.Parts {
+		total += len(p.Data)
+	}
+	if total > maxInlineBytes {
+		return &ParamError{provider, "parts", fmt.Sprintf("exceed %d MiB of inline data", maxInlineBytes>>20)}
+	}
+	return nil
+}
+
+// ParamError reports a request parameter a provider does not support or
+// whose value is outside the provider's accepted range.
+type ParamError struct {
+	Provider string
+	Param    string
+	Reason   string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("%s: %s %s", e.Provider, e.Param, e.Reason)
+}
+
+// checkFloat returns a ParamError if v is set and outside [min, max].
+func checkFloat(provider, param string, v *float32, min, max float32) error {
+	if v != nil && (*v < min || *v > max) {
+		return &ParamError{provider, param, fmt.Sprintf("must be between %g and %g", min, max)}
+	}
+	return nil
+}
+
+// checkInt returns a ParamError if v is set and outside [min, max].
+func checkInt(provider, param string, v *int32, min, max int32) error {
+	if v != nil && (*v < min || *v > max) {
+		return &ParamError{provider, param, fmt.Sprintf("must be between %d and %d", min, max)}
+	}
+	return nil
+}
+
+// checkLogprobs validates the logprobs parameters shared by all providers.
+func checkLogprobs(provider string, req Request) error {
+	if req.TopLogprobs != nil && !req.Logprobs {
+		return &ParamError{provider, "top_logprobs", "requires logprobs"}
+	}
+	return checkInt(provider, "top_logprobs", req.TopLogprobs, 0, 20)
+}
+
+// firstErr returns the first non-nil error.
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Response format types.
+const (
+	FormatJSONObject = "json_object"
+	FormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat asks the provider for JSON output.
+type ResponseFormat struct {
+	Type   string          // FormatJSONObject or FormatJSONSchema
+	Name   string          // Schema name
+	Schema json.RawMessage // JSON Schema document, for FormatJSONSchema
+	Strict bool            // Ask for strict schema adherence, where supported
+}
+
+// Normalized finish reasons.
+const (
+	FinishStop          = "stop"
+	FinishLength        = "length"
+	FinishContentFilter = "content_filter"
+	FinishToolCalls     = "tool_calls"
+	FinishOther         = "other"
+)
+
+// SafetyRating is a provider's safety assessment for one harm category.
+type SafetyRating struct {
+	Category    string
+	Probability string
+	Blocked     bool
+}
+
+// Metadata describes how a response ended and which model served it.
+type Metadata struct {
+	FinishReason       string // One of the Finish* constants, empty if not reported
+	NativeFinishReason string // As reported by the provider
+	SafetyRatings      []SafetyRating
+	BlockReason        string // Set when the provider refused the prompt itself
+	ID                 string // Provider's response ID
+	Model              string // Model version reported by the provider
+}
+
+// TopLogprob is an alternative token at a position.
+type TopLogprob struct {
+	Token   string
+	Logprob float32
+}
+
+// TokenLogprob is the log probability of one generated token, with the most
+// likely alternatives at its position.
+type TokenLogprob struct {
+	Token   string
+	Logprob float32
+	Top     []TopLogprob
+}
+
+// Candidate is one completion of a response.
+type Candidate struct {
+	Index              int
+	Text               string
+	FinishReason       string // One of the Finish* constants
+	NativeFinishReason string
+	SafetyRatings      []SafetyRating
+	Logprobs           []TokenLogprob // Set when the request asked for logprobs
+}
+
+// Response represents a complete inference response. Text and Metadata
+// describe the first candidate; Candidates lists all of them.
 type Response struct {
 	Text         string
 	PromptTokens int32
+	CachedTokens int32 // Portion of PromptTokens served from the provider's prompt cache
 	OutputTokens int32
+	Candidates   []Candidate
+	Metadata
 }
 
-// StreamChunk represents a single chunk in a streaming response.
+// StreamChunk represents a single chunk in a streaming response. With
+// several candidates, each chunk carries the text of one of them; the chunk
+// that ends a candidate has its FinishReason set.
 type StreamChunk struct {
 	Text         string
+	Index        int            // Candidate index
+	Logprobs     []TokenLogprob // Logprobs of the tokens in Text
 	Done         bool
-	PromptTokens int32  // Set on final chunk
-	OutputTokens int32  // Set on final chunk
-	Err          error  // Non-nil if the stream encountered an error
+	PromptTokens int32 // Set on final chunk
+	CachedTokens int32 // Set on final chunk
+	OutputTokens int32 // Set on final chunk
+	Metadata           // Set on final chunk
+	Err          error // Non-nil if the stream encountered an error
 }
 
 // Provider is the interface that all LLM backends must implement.
@@ -33,6 +208,11 @@ type Provider interface {
 	// Name returns a human-readable identifier for this provider (e.g. "openai", "gemini").
 	Name() string
 
+	// Validate returns a *ParamError if req uses a parameter this provider
+	// does not support, or a value outside its accepted range. It is called
+	// before any key is used, so nothing is silently dropped upstream.
+	Validate(req Request) error
+
 	// Infer performs a unary (non-streaming) inference call.
 	// The context should carry a deadline/timeout.
 	Infer(ctx context.Context, req Request) (Response, error)
@@ -42,3 +222,88 @@ type Provider interface {
 	// the context is cancelled.
 	InferStream(ctx context.Context, req Request) (<-chan StreamChunk, error)
 }
+
+// EmbedRequest represents an embedding request to an LLM provider.
+type EmbedRequest struct {
+	Model      string
+	Inputs     []string
+	Dimensions *int32 // Shortened vector size; nil leaves the model default
+	APIKey     string // Injected by the key pool
+}
+
+// EmbedResponse holds one vector per input, in input order.
+type EmbedResponse struct {
+	Embeddings   [][]float32
+	PromptTokens int32  // 0 if the provider does not report usage
+	Model        string // Model reported by the provider
+}
+
+// Embedder is implemented by providers that serve embedding models.
+type Embedder interface {
+	// ValidateEmbed returns a *ParamError if req exceeds the provider's
+	// limits. Like Validate, it is called before any key is used.
+	ValidateEmbed(req EmbedRequest) error
+
+	// Embed returns the embeddings of req.Inputs.
+	Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error)
+}
+
+// TokenCounter is implemented by providers that can count a request's
+// prompt tokens without running the model.
+type TokenCounter interface {
+	// CountTokens returns the prompt tokens of req, parts included.
+	CountTokens(ctx context.Context, req Request) (int32, error)
+}
+
+// ModelInfo is a model a provider reports serving.
+type ModelInfo struct {
+	ID              string
+	ContextWindow   int  // 0 if not reported
+	MaxOutputTokens int  // 0 if not reported
+	Embeddings      bool // Served by Embed rather than Infer
+}
+
+// ModelLister is implemented by providers that can list the models
+// available to a key.
+type ModelLister interface {
+	// ListModels returns the models the proxy can route to this provider:
+	// text generation and embedding models, nothing else.
+	ListModels(ctx context.Context, apiKey string) ([]ModelInfo, error)
+}
+
+// Provider-side batch states.
+const (
+	BatchRunning   = "running"
+	BatchDone      = "done"
+	BatchFailed    = "failed"
+	BatchCancelled = "cancelled"
+)
+
+// BatchStatus is the state of a provider-side batch.
+type BatchStatus struct {
+	State   string        // One of the Batch* states
+	Error   string        // Why the batch failed, if it did
+	Results []BatchResult // Set once the batch has ended; expired and cancelled batches may have some
+}
+
+// BatchResult is the outcome of one request of a provider-side batch.
+type BatchResult struct {
+	Index    int // Position of the request in the submitted batch
+	Response Response
+	Err      error
+}
+
+// Batcher is implemented by providers with a native batch API, which runs
+// requests asynchronously, within a day, at a discount.
+type Batcher interface {
+	// SubmitBatch starts a batch of reqs, all for the same model, with
+	// apiKey and returns its ID. The requests' own APIKey is ignored.
+	SubmitBatch(ctx context.Context, apiKey string, reqs []Request) (string, error)
+
+	// PollBatch reports the state of batch id and, once it has ended, the
+	// results of its requests. A request without a result failed.
+	PollBatch(ctx context.Context, apiKey, id string) (BatchStatus, error)
+
+	// CancelBatch asks the provider to stop batch id.
+	CancelBatch(ctx context.Context, apiKey, id string) error
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+)
+
+// authorize checks the caller's virtual key allows the model and provider.
+// Requests without an identity (auth disabled) are always allowed.
+func authorize(ctx context.Context, providerName, model string) error {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	if !id.AllowsProvider(providerName) {
+		metrics.RequestsTotal.WithLabelValues("denied").Inc()
+		return status.Errorf(codes.PermissionDenied, "proxy: key %s may not use provider %q", id.KeyID, providerName)
+	}
+	if !id.AllowsModel(model) {
+		metrics.RequestsTotal.WithLabelValues("denied").Inc()
+		return status.Errorf(codes.PermissionDenied, "proxy: key %s may not use model %q", id.KeyID, model)
+	}
+	return nil
+}
+
+// allows reports whether the caller may use model, like authorize but
+// without counting a denied request.
+func allows(ctx context.Context, providerName, model string) bool {
+	id, ok := auth.FromContext(ctx)
+	return !ok || (id.AllowsProvider(providerName) && id.AllowsModel(model))
+}
@@ -0,0 +1,1117 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/batch"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/quota"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// maxBatchItems bounds the items of one batch job.
+const maxBatchItems = 50000
+
+// nativeBatchSize bounds the items sent in one provider-side batch.
+const nativeBatchSize = 1000
+
+// nativeBatchDiscount is the fraction of the list price providers charge
+// for requests run through their batch APIs.
+const nativeBatchDiscount = 0.5
+
+// nativeBatchTimeout bounds one call to a provider's batch API; uploads
+// can be large.
+const nativeBatchTimeout = 2 * time.Minute
+
+// batchItemAttempts bounds the runs of an item that keeps failing because
+// keys, slots or quota are exhausted.
+const batchItemAttempts = 6
+
+// batchCheckpointInterval is how often a running job's progress is saved
+// and its cancellation checked.
+const batchCheckpointInterval = 5 * time.Second
+
+// Result page sizes of GetBatch and job page sizes of ListBatches.
+const (
+	defaultBatchResults  = 1000
+	maxBatchResults      = 10000
+	defaultBatchPageSize = 50
+	maxBatchPageSize     = 1000
+)
+
+// BatchConfig controls asynchronous batch jobs.
+type BatchConfig struct {
+	Store           *batch.Store  // Job store shared by the replicas; nil disables batch jobs
+	InputDir        string        // Directory SubmitBatch input_path is resolved in; empty disables input_path
+	Workers         int           // Items each replica runs at once (default: 4)
+	NativeProviders []string      // Providers whose native batch API runs the items (optional)
+	PollInterval    time.Duration // How often jobs are picked up and native batches polled (default: 30s)
+	Keys            *auth.Store   // Virtual keys; a job whose submitter's key was revoked is failed (optional)
+}
+
+// batchRunner tracks the jobs this replica is running.
+type batchRunner struct {
+	BatchConfig
+	owner string // Lease owner ID of this replica
+
+	mu   sync.Mutex
+	runs map[string]*batchRun
+}
+
+func newBatchRunner(cfg BatchConfig) *batchRunner {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 30 * time.Second
+	}
+	host, _ := os.Hostname()
+	return &batchRunner{
+		BatchConfig: cfg,
+		owner:       host + "/" + uuid.New().String(),
+		runs:        make(map[string]*batchRun),
+	}
+}
+
+// run returns the job's run on this replica, if any.
+func (r *batchRunner) run(id string) *batchRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.runs[id]
+}
+
+// SubmitBatch validates the items and queues the job. Items are checked
+// against the caller's access and the providers' parameters up front; the
+// job runs later with the caller's identity.
+func (h *Handler) SubmitBatch(ctx context.Context, req *pb.SubmitBatchRequest) (*pb.Batch, error) {
+	store := h.batches.Store
+	if store == nil {
+		return nil, status.Error(codes.FailedPrecondition, "proxy: batch jobs are not enabled")
+	}
+	items, err := h.batchItems(req)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		if err := h.checkBatchItem(ctx, item); err != nil {
+			st := status.Convert(err)
+			return nil, status.Errorf(st.Code(), "proxy: item %d: %s", i, strings.TrimPrefix(st.Message(), "proxy: "))
+		}
+	}
+
+	job := &batch.Job{
+		ID:      batch.NewID(),
+		Tenant:  tenantOf(ctx),
+		Status:  batch.StatusQueued,
+		Total:   len(items),
+		Created: time.Now(),
+	}
+	if id, ok := auth.FromContext(ctx); ok {
+		job.Caller = *id
+	}
+	if err := store.Create(job, items); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+	log.Printf("[proxy] batch %s queued: %d items for tenant %q", job.ID, job.Total, job.Tenant)
+	return h.batchOf(job), nil
+}
+
+// batchItems returns the items of req, inline or read from its input file.
+func (h *Handler) batchItems(req *pb.SubmitBatchRequest) ([]batch.Item, error) {
+	var items []batch.Item
+	switch {
+	case req.InputPath != "" && len(req.Items) > 0:
+		return nil, status.Error(codes.InvalidArgument, "proxy: set either items or input_path, not both")
+	case req.InputPath != "":
+		if h.batches.InputDir == "" {
+			return nil, status.Error(codes.FailedPrecondition, "proxy: input_path is not enabled")
+		}
+		if !filepath.IsLocal(req.InputPath) {
+			return nil, status.Error(codes.InvalidArgument, "proxy: input_path must be a relative path inside the batch input directory")
+		}
+		f, err := os.Open(filepath.Join(h.batches.InputDir, req.InputPath))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, status.Errorf(codes.NotFound, "proxy: input file %q not found", req.InputPath)
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+		}
+		defer f.Close()
+		if items, err = batch.ParseItems(f); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "proxy: %v", err)
+		}
+	default:
+		for _, item := range req.Items {
+			items = append(items, batch.Item{CustomID: item.CustomId, Request: item.Request})
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "proxy: batch has no items")
+	}
+	if len(items) > maxBatchItems {
+		return nil, status.Errorf(codes.InvalidArgument, "proxy: batch has %d items, more than %d", len(items), maxBatchItems)
+	}
+	return items, nil
+}
+
+// checkBatchItem rejects an item the caller may not run or whose
+// parameters the provider does not accept.
+func (h *Handler) checkBatchItem(ctx context.Context, item batch.Item) error {
+	req := item.Request
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "proxy: request is required")
+	}
+	if req.GetConversationId() != "" {
+		return status.Error(codes.InvalidArgument, "proxy: conversation_id is not supported in batches")
+	}
+	model := h.models.Resolve(req.Model)
+	providerName := h.providerFor(model)
+	if err := authorize(ctx, providerName, model); err != nil {
+		return err
+	}
+	format, err := parseResponseFormat(req.ResponseFormat)
+	if err != nil {
+		return err
+	}
+	provReq, err := requestParams(req, format)
+	if err != nil {
+		return err
+	}
+	provReq.Model = model
+	return h.validateParams(providerName, provReq)
+}
+
+// GetBatch returns one of the caller's batch jobs and, if asked, a page of
+// its results.
+func (h *Handler) GetBatch(ctx context.Context, req *pb.GetBatchRequest) (*pb.GetBatchResponse, error) {
+	job, err := h.batchJob(ctx, req.BatchId)
+	if err != nil {
+		return nil, err
+	}
+	out := &pb.GetBatchResponse{Batch: h.batchOf(job), NextResultsOffset: req.ResultsOffset}
+	if !req.IncludeResults {
+		return out, nil
+	}
+
+	limit := int(req.ResultsLimit)
+	if limit <= 0 {
+		limit = defaultBatchResults
+	}
+	limit = min(limit, maxBatchResults)
+	results, next, err := h.batches.Store.Results(job.ID, max(int(req.ResultsOffset), 0), limit)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+	out.Results, out.NextResultsOffset = results, int32(next)
+	return out, nil
+}
+
+// CancelBatch stops one of the caller's batch jobs. A queued job is
+// cancelled at once; a running one by the replica running it, within a
+// few seconds.
+func (h *Handler) CancelBatch(ctx context.Context, req *pb.CancelBatchRequest) (*pb.Batch, error) {
+	job, err := h.batchJob(ctx, req.BatchId)
+	if err != nil {
+		return nil, err
+	}
+	if job.Ended() {
+		return h.batchOf(job), nil
+	}
+
+	store := h.batches.Store
+	if err := store.RequestCancel(job.ID); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+	if job.Status != batch.StatusQueued {
+		return h.batchOf(job), nil
+	}
+	if ok, _ := store.Lock(job.ID, h.batches.owner); ok {
+		job, err = cancelQueuedBatch(store, job.ID)
+		store.Unlock(req.BatchId, h.batches.owner)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+		}
+	}
+	return h.batchOf(job), nil
+}
+
+// cancelQueuedBatch cancels a job, under its lease, unless a runner
+// started it in the meantime.
+func cancelQueuedBatch(store *batch.Store, id string) (*batch.Job, error) {
+	job, err := store.Get(id)
+	if err != nil || job.Status != batch.StatusQueued {
+		return job, err
+	}
+	job.Status = batch.StatusCancelled
+	if err := store.Save(job); err != nil {
+		return nil, err
+	}
+	metrics.BatchJobsTotal.WithLabelValues(job.Status).Inc()
+	log.Printf("[proxy] batch %s cancelled", id)
+	return job, nil
+}
+
+// ListBatches returns a page of the caller's batch jobs, newest first.
+func (h *Handler) ListBatches(ctx context.Context, req *pb.ListBatchesRequest) (*pb.ListBatchesResponse, error) {
+	store := h.batches.Store
+	if store == nil {
+		return nil, status.Error(codes.FailedPrecondition, "proxy: batch jobs are not enabled")
+	}
+	offset := 0
+	if req.PageToken != "" {
+		n, err := strconv.Atoi(req.PageToken)
+		if err != nil || n < 0 {
+			return nil, status.Error(codes.InvalidArgument, "proxy: invalid page_token")
+		}
+		offset = n
+	}
+	size := int(req.PageSize)
+	if size <= 0 {
+		size = defaultBatchPageSize
+	}
+	size = min(size, maxBatchPageSize)
+
+	jobs, err := store.List()
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+	tenant := tenantOf(ctx)
+	var own []*batch.Job
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if jobs[i].Tenant == tenant {
+			own = append(own, jobs[i])
+		}
+	}
+
+	out := &pb.ListBatchesResponse{}
+	for i := offset; i < len(own) && i < offset+size; i++ {
+		out.Batches = append(out.Batches, h.batchOf(h.liveJob(own[i])))
+	}
+	if offset+size < len(own) {
+		out.NextPageToken = strconv.Itoa(offset + size)
+	}
+	return out, nil
+}
+
+// batchJob returns the caller's job with id. Jobs belong to the tenant that
+// submitted them; another tenant's job is reported as not found.
+func (h *Handler) batchJob(ctx context.Context, id string) (*batch.Job, error) {
+	if h.batches.Store == nil {
+		return nil, status.Error(codes.FailedPrecondition, "proxy: batch jobs are not enabled")
+	}
+	job, err := h.batches.Store.Get(id)
+	if errors.Is(err, batch.ErrNotFound) || (err == nil && job.Tenant != tenantOf(ctx)) {
+		return nil, status.Errorf(codes.NotFound, "proxy: batch %q not found", id)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+	return h.liveJob(job), nil
+}
+
+// liveJob returns the current progress of job if this replica is running
+// it; the stored copy is saved every few seconds.
+func (h *Handler) liveJob(job *batch.Job) *batch.Job {
+	if run := h.batches.run(job.ID); run != nil {
+		return run.snapshot()
+	}
+	return job
+}
+
+// batchOf converts a job for the client.
+func (h *Handler) batchOf(job *batch.Job) *pb.Batch {
+	return &pb.Batch{
+		BatchId:         job.ID,
+		Status:          job.Status,
+		Total:           int32(job.Total),
+		Succeeded:       int32(job.Succeeded),
+		Failed:          int32(job.Failed),
+		Native:          int32(job.NativeItems()),
+		CancelRequested: !job.Ended() && h.batches.Store.CancelRequested(job.ID),
+		Error:           job.Error,
+		CreatedAt:       job.Created.Unix(),
+		UpdatedAt:       job.Updated.Unix(),
+	}
+}
+
+// RunBatches runs queued jobs, resumes those whose replica stopped and
+// polls native batches every PollInterval, until ctx is cancelled. Each
+// replica runs one job at a time, claimed through a lease in the store.
+func (h *Handler) RunBatches(ctx context.Context) {
+	if h.batches.Store == nil {
+		return
+	}
+	ticker := time.NewTicker(h.batches.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		h.runPendingBatches(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runPendingBatches runs each unfinished job no other replica holds, oldest
+// first.
+func (h *Handler) runPendingBatches(ctx context.Context) {
+	store := h.batches.Store
+	jobs, err := store.List()
+	if err != nil {
+		log.Printf("[proxy] batch jobs not listed: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if job.Ended() && !job.Draining() {
+			continue
+		}
+		ok, err := store.Lock(job.ID, h.batches.owner)
+		if err != nil {
+			log.Printf("[proxy] batch %s not claimed: %v", job.ID, err)
+		}
+		if !ok {
+			continue
+		}
+		h.runBatch(ctx, job.ID)
+		store.Unlock(job.ID, h.batches.owner)
+	}
+}
+
+// runBatch advances a job this replica holds the lease on: it collects the
+// results of finished native batches, submits eligible items to native
+// batches, and runs the rest through the proxy with the worker pool. A job
+// still waiting on native batches stays running for the next poll. A job
+// that ended, e.g. cancelled, with native batches still running only has
+// their results collected, so that what the provider ran is recorded and
+// billed.
+func (h *Handler) runBatch(ctx context.Context, id string) {
+	store := h.batches.Store
+	job, err := store.Get(id)
+	if err != nil || (job.Ended() && !job.Draining()) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go h.renewBatchLease(ctx, id, cancel)
+
+	run := &batchRun{store: store, owner: h.batches.owner, job: job}
+	if !job.Ended() && store.CancelRequested(id) {
+		h.stopBatch(ctx, run, batch.StatusCancelled, "")
+	}
+	if !job.Ended() && !h.refreshBatchCaller(job) {
+		log.Printf("[proxy] batch %s stopped: virtual key %s was revoked", id, job.Caller.KeyID)
+		h.stopBatch(ctx, run, batch.StatusFailed, "submitter's virtual key was revoked")
+	}
+	items, err := store.Items(id)
+	if err != nil {
+		if !job.Ended() {
+			run.end(batch.StatusFailed, err.Error())
+		}
+		return
+	}
+	if run.done, err = store.Checkpoint(id); err != nil {
+		log.Printf("[proxy] batch %s not resumed: %v", id, err)
+		return
+	}
+	if job.Ended() {
+		h.pollNativeBatches(auth.NewContext(ctx, &job.Caller), run, items)
+		return
+	}
+	job.Succeeded, job.Failed = 0, 0
+	for _, r := range run.done {
+		if r.Error == nil {
+			job.Succeeded++
+		} else {
+			job.Failed++
+		}
+	}
+	if job.Status == batch.StatusQueued {
+		log.Printf("[proxy] batch %s started", id)
+	}
+	job.Status = batch.StatusRunning
+	for _, item := range items {
+		if item.Request != nil {
+			item.Request.Priority = pb.Priority_PRIORITY_LOW
+		}
+	}
+
+	h.batches.mu.Lock()
+	h.batches.runs[id] = run
+	h.batches.mu.Unlock()
+	defer func() {
+		h.batches.mu.Lock()
+		delete(h.batches.runs, id)
+		h.batches.mu.Unlock()
+	}()
+	run.save()
+
+	ctx = auth.NewContext(ctx, &job.Caller)
+	h.pollNativeBatches(ctx, run, items)
+	h.submitNativeBatches(ctx, run, items)
+	h.runBatchItems(ctx, run, items)
+
+	switch {
+	case ctx.Err() != nil:
+		// Shutting down or the lease was lost; another replica resumes it.
+	case store.CancelRequested(id):
+		h.stopBatch(ctx, run, batch.StatusCancelled, "")
+	case run.finished():
+		run.end(batch.StatusCompleted, "")
+	default:
+		run.save()
+	}
+}
+
+// renewBatchLease keeps the job's lease until ctx is done, cancelling the
+// run if the lease is lost.
+func (h *Handler) renewBatchLease(ctx context.Context, id string, cancel context.CancelFunc) {
+	ticker := time.NewTicker(batch.LeaseTTL / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.batches.Store.Renew(id, h.batches.owner); err != nil {
+				log.Printf("[proxy] batch %s stopped: %v", id, err)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// refreshBatchCaller reloads the job's caller from the virtual key store,
+// so that a run picks up the key's current access. Returns false if the
+// key was revoked since the job was submitted.
+func (h *Handler) refreshBatchCaller(job *batch.Job) bool {
+	keys := h.batches.Keys
+	if keys == nil || job.Caller.KeyID == "" {
+		return true
+	}
+	id, ok := keys.Lookup(job.Caller.KeyID)
+	if !ok {
+		return false
+	}
+	job.Caller = *id
+	return true
+}
+
+// stopBatch asks the providers to stop the job's native batches and ends
+// the job with status. Items without a result by then get none, except
+// those the native batches finished before stopping.
+func (h *Handler) stopBatch(ctx context.Context, run *batchRun, status, msg string) {
+	for _, nb := range run.snapshot().Native {
+		if nb.Done {
+			continue
+		}
+		b, kp, apiKey, err := h.nativeBatcher(nb)
+		if err == nil {
+			callCtx, cancel := context.WithTimeout(ctx, nativeBatchTimeout)
+			err = h.execute(callCtx, nb.Provider, kp, apiKey, func(ctx context.Context) error {
+				return b.CancelBatch(ctx, apiKey, nb.ID)
+			})
+			cancel()
+		}
+		if err != nil {
+			log.Printf("[proxy] batch %s: %s batch %s not cancelled: %v", run.job.ID, nb.Provider, nb.ID, err)
+		}
+	}
+	run.end(status, msg)
+}
+
+// runBatchItems runs the items that have no result and are not in a
+// pending native batch through the proxy, Workers at a time.
+func (h *Handler) runBatchItems(ctx context.Context, run *batchRun, items []batch.Item) {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < h.batches.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				res, ok := h.runBatchItem(ctx, i, items[i])
+				if !ok {
+					continue
+				}
+				if err := run.record("proxy", res); err != nil {
+					log.Printf("[proxy] batch %s: result not saved, stopping: %v", run.job.ID, err)
+					stop()
+				}
+			}
+		}()
+	}
+
+	pending := run.pendingNative()
+	checkpoint := time.Now()
+feed:
+	for i := range items {
+		if run.hasResult(i) || pending[i] {
+			continue
+		}
+		if time.Since(checkpoint) >= batchCheckpointInterval {
+			checkpoint = time.Now()
+			if run.store.CancelRequested(run.job.ID) {
+				break
+			}
+			run.save()
+		}
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+}
+
+// runBatchItem runs one item through Infer's path at low priority. Items
+// turned away because keys, slots or quota are exhausted are retried with
+// backoff. Returns false if ctx ended first, leaving the item to be run
+// again.
+func (h *Handler) runBatchItem(ctx context.Context, index int, item batch.Item) (batch.Result, bool) {
+	res := batch.Result{Index: index, CustomID: item.CustomID}
+	if item.Request == nil {
+		res.Error = resultError(status.Error(codes.InvalidArgument, "proxy: request is required"))
+		return res, true
+	}
+
+	backoff := 2 * time.Second
+	for attempt := 1; ; attempt++ {
+		_, _, out, err := h.inferReply(ctx, "SubmitBatch", item.Request, nil)
+		if ctx.Err() != nil {
+			return res, false
+		}
+		if err == nil {
+			res.Response = out
+			return res, true
+		}
+		if attempt >= batchItemAttempts || !retryableBatchError(err) {
+			res.Error = resultError(err)
+			return res, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return res, false
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, time.Minute)
+	}
+}
+
+// retryableBatchError reports whether an item failed only because capacity
+// was exhausted: no key available, shed from a queue or over quota.
+func retryableBatchError(err error) bool {
+	switch status.Code(err) {
+	case codes.ResourceExhausted, codes.Unavailable:
+		return true
+	}
+	return strings.HasPrefix(err.Error(), "key pool: ")
+}
+
+// resultError converts an item's error for its result.
+func resultError(err error) *batch.Error {
+	st := statusOf(err)
+	return &batch.Error{
+		Code:    strings.ToUpper(outcomeOf(st.Err())),
+		Message: st.Message(),
+		Reason:  errorReason(st),
+	}
+}
+
+// submitNativeBatches sends the items without a result whose provider is
+// configured for native batches, and that were not sent before, to the
+// provider's batch API, one batch per model of up to nativeBatchSize items.
+// Items that cannot be sent are left for the proxy.
+func (h *Handler) submitNativeBatches(ctx context.Context, run *batchRun, items []batch.Item) {
+	if len(h.batches.NativeProviders) == 0 {
+		return
+	}
+	sent := run.sentNative()
+
+	type group struct{ providerName, model string }
+	groups := make(map[group][]int)
+	reqs := make(map[int]provider.Request)
+	var order []group
+	for i, item := range items {
+		if ctx.Err() != nil {
+			return
+		}
+		if run.hasResult(i) || sent[i] || item.Request == nil {
+			continue
+		}
+		model := h.models.Resolve(item.Request.Model)
+		providerName := h.providerFor(model)
+		if !slices.Contains(h.batches.NativeProviders, providerName) {
+			continue
+		}
+		if _, ok := h.providers[providerName].(provider.Batcher); !ok {
+			continue
+		}
+
+		provReq, ok, err := h.nativeRequest(ctx, providerName, item.Request)
+		if err != nil {
+			if err := run.record("native", batch.Result{Index: i, CustomID: item.CustomID, Error: resultError(err)}); err != nil {
+				log.Printf("[proxy] batch %s: result not saved: %v", run.job.ID, err)
+				return
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		g := group{providerName, provReq.Model}
+		if _, seen := groups[g]; !seen {
+			order = append(order, g)
+		}
+		groups[g] = append(groups[g], i)
+		reqs[i] = provReq
+	}
+
+	for _, g := range order {
+		indexes := groups[g]
+		for start := 0; start < len(indexes); start += nativeBatchSize {
+			chunk := indexes[start:min(start+nativeBatchSize, len(indexes))]
+			if err := h.submitNativeBatch(ctx, run, g.providerName, g.model, chunk, reqs); err != nil {
+				log.Printf("[proxy] batch %s: %d items not sent to the %s batch API, running them through the proxy: %v", run.job.ID, len(chunk), g.providerName, err)
+			}
+		}
+	}
+}
+
+// nativeRequest applies Infer's request checks and prompt guardrails to an
+// item bound for a native batch. It returns false for items whose PII
+// placeholders would have to be restored in the response; those stay with
+// the proxy, which keeps the originals only in memory.
+func (h *Handler) nativeRequest(ctx context.Context, providerName string, req *pb.InferenceRequest) (provider.Request, bool, error) {
+	req.Model = h.models.Resolve(req.Model)
+	if err := authorize(ctx, providerName, req.Model); err != nil {
+		return provider.Request{}, false, err
+	}
+	format, err := parseResponseFormat(req.ResponseFormat)
+	if err != nil {
+		return provider.Request{}, false, err
+	}
+	provReq, err := requestParams(req, format)
+	if err != nil {
+		return provider.Request{}, false, err
+	}
+	if err := h.validateParams(providerName, provReq); err != nil {
+		return provider.Request{}, false, err
+	}
+
+	prompt, redaction, err := h.redactPrompt(req.Prompt, nil)
+	if err != nil {
+		return provider.Request{}, false, err
+	}
+	if redaction.Reversible() {
+		return provider.Request{}, false, nil
+	}
+	ignoreHeader := func(metadata.MD) {}
+	if err := h.screenInjection(ctx, prompt, ignoreHeader); err != nil {
+		return provider.Request{}, false, err
+	}
+	if _, prompt, err = h.fitPrompt(providerName, req, nil, prompt, provReq.Parts, ignoreHeader); err != nil {
+		return provider.Request{}, false, err
+	}
+	provReq.Prompt = prompt
+	return provReq, true, nil
+}
+
+// submitNativeBatch admits the chunk's items against the tenant's quotas,
+// reserving their max_tokens, and submits those admitted as one
+// provider-side batch. The reservations are kept with the batch until its
+// results arrive, and released if it cannot be submitted. The batch is
+// saved in the job before returning, so that a restart polls it instead of
+// submitting it again.
+func (h *Handler) submitNativeBatch(ctx context.Context, run *batchRun, providerName, model string, chunk []int, reqs map[int]provider.Request) error {
+	b := h.providers[providerName].(provider.Batcher)
+	kp, ok := h.keyPools[providerName]
+	if !ok {
+		return fmt.Errorf("no key pool for provider %q", providerName)
+	}
+
+	var admitted []int
+	var batchReqs []provider.Request
+	var reservations []*quota.Reservation
+	for _, i := range chunk {
+		req := &pb.InferenceRequest{MaxTokens: reqs[i].MaxTokens, N: reqs[i].N}
+		res, err := h.reserveQuota(ctx, req, func(metadata.MD) {})
+		if err != nil {
+			continue
+		}
+		admitted = append(admitted, i)
+		batchReqs = append(batchReqs, reqs[i])
+		reservations = append(reservations, res)
+	}
+	if len(admitted) == 0 {
+		return errors.New("tenant quota exhausted")
+	}
+	release := func() {
+		for _, res := range reservations {
+			settleQuota(res, 0, 0)
+		}
+	}
+
+	apiKey, err := h.nextKey(providerName, kp)
+	if err != nil {
+		release()
+		return fmt.Errorf("key pool: %w", err)
+	}
+	var id string
+	callCtx, cancel := context.WithTimeout(ctx, nativeBatchTimeout)
+	defer cancel()
+	err = h.execute(callCtx, providerName, kp, apiKey, func(ctx context.Context) error {
+		var callErr error
+		id, callErr = b.SubmitBatch(ctx, apiKey, batchReqs)
+		return callErr
+	})
+	if err != nil {
+		release()
+		return err
+	}
+
+	nb := batch.NativeBatch{
+		Provider:       providerName,
+		Model:          model,
+		ID:             id,
+		KeyFingerprint: resilience.Fingerprint(apiKey),
+		Items:          admitted,
+		Submitted:      time.Now(),
+	}
+	if h.quota != nil {
+		nb.Reservations = make([]*quota.Ticket, len(reservations))
+		for k, res := range reservations {
+			nb.Reservations[k] = res.Ticket()
+		}
+	}
+	run.addNative(nb)
+	log.Printf("[proxy] batch %s: %d items sent to %s batch %s", run.job.ID, len(admitted), providerName, id)
+	return run.save()
+}
+
+// pollNativeBatches records the results of the job's native batches that
+// have ended and settles their items' quota reservations. Items a batch
+// ended without are refunded and left for the proxy.
+func (h *Handler) pollNativeBatches(ctx context.Context, run *batchRun, items []batch.Item) {
+	for i, nb := range run.snapshot().Native {
+		if nb.Done || ctx.Err() != nil {
+			continue
+		}
+		b, _, apiKey, err := h.nativeBatcher(nb)
+		if err != nil {
+			// The results cannot be fetched without the key; the items
+			// are run again through the proxy.
+			log.Printf("[proxy] batch %s: %s batch %s abandoned: %v", run.job.ID, nb.Provider, nb.ID, err)
+			run.nativeDone(i)
+			if run.save() == nil {
+				h.settleNative(nb, nil)
+			}
+			continue
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, nativeBatchTimeout)
+		st, err := b.PollBatch(callCtx, apiKey, nb.ID)
+		cancel()
+		if err != nil {
+			log.Printf("[proxy] batch %s: %s batch %s not polled: %v", run.job.ID, nb.Provider, nb.ID, err)
+			continue
+		}
+		if st.State == provider.BatchRunning {
+			continue
+		}
+		if st.Error != "" {
+			log.Printf("[proxy] batch %s: %s batch %s ended: %s", run.job.ID, nb.Provider, nb.ID, st.Error)
+		}
+
+		var results []batch.Result
+		used := make([]nativeUsage, len(nb.Items))
+		for _, r := range st.Results {
+			if r.Index < 0 || r.Index >= len(nb.Items) {
+				continue
+			}
+			index := nb.Items[r.Index]
+			if run.hasResult(index) {
+				continue
+			}
+			var res batch.Result
+			res, used[r.Index] = h.nativeResult(ctx, nb, index, items[index], r)
+			results = append(results, res)
+		}
+		if err := run.record("native", results...); err != nil {
+			log.Printf("[proxy] batch %s: %s batch %s results not saved: %v", run.job.ID, nb.Provider, nb.ID, err)
+			continue
+		}
+		run.nativeDone(i)
+		// Settling after the save never charges an item twice; a crash in
+		// between leaves the reservations as they are.
+		if run.save() == nil {
+			h.settleNative(nb, used)
+		}
+	}
+}
+
+// nativeUsage is what one native batch item used, to settle its quota
+// reservation.
+type nativeUsage struct {
+	tokens  int32
+	costUSD float64
+}
+
+// settleNative settles the quota reservations of a native batch's items
+// with their usage, in Items order. Items without usage are refunded.
+func (h *Handler) settleNative(nb batch.NativeBatch, used []nativeUsage) {
+	if h.quota == nil {
+		return
+	}
+	for k, t := range nb.Reservations {
+		var u nativeUsage
+		if k < len(used) {
+			u = used[k]
+		}
+		settleQuota(h.quota.Resume(t), u.tokens, u.costUSD)
+	}
+}
+
+// nativeBatcher returns the provider, key pool and key of a native batch.
+func (h *Handler) nativeBatcher(nb batch.NativeBatch) (provider.Batcher, *resilience.KeyPool, string, error) {
+	b, ok := h.providers[nb.Provider].(provider.Batcher)
+	if !ok {
+		return nil, nil, "", fmt.Errorf("provider %q has no batch API", nb.Provider)
+	}
+	kp, ok := h.keyPools[nb.Provider]
+	if !ok {
+		return nil, nil, "", fmt.Errorf("no key pool for provider %q", nb.Provider)
+	}
+	apiKey, ok := kp.ByFingerprint(nb.KeyFingerprint)
+	if !ok {
+		return nil, nil, "", fmt.Errorf("key %s is no longer configured", nb.KeyFingerprint)
+	}
+	return b, kp, apiKey, nil
+}
+
+// nativeResult accounts for one item of a native batch like Infer does,
+// at the batch discount, and applies the response format and output
+// policy. It also returns the usage to settle the item's quota with.
+func (h *Handler) nativeResult(ctx context.Context, nb batch.NativeBatch, index int, item batch.Item, r provider.BatchResult) (res batch.Result, used nativeUsage) {
+	res = batch.Result{Index: index, CustomID: item.CustomID}
+	req := item.Request
+	rec := newRecord(ctx, nb.Model, nb.Submitted)
+	rec.Provider = nb.Provider
+	rec.KeyFingerprint = nb.KeyFingerprint
+	requestID := newRequestID()
+	prompt := req.Prompt
+	if h.pii != nil {
+		prompt = h.pii.NewRedaction().Redact(prompt)
+	}
+	var reply string
+	var err error
+	defer func() {
+		h.finishRequest(ctx, "SubmitBatch", requestID, prompt, reply, &rec, nb.Submitted, err)
+		if err != nil {
+			res.Error = resultError(err)
+		}
+	}()
+
+	if r.Err != nil {
+		metrics.RequestsTotal.WithLabelValues("error").Inc()
+		err = fmt.Errorf("inference failed: %w", r.Err)
+		return res, used
+	}
+
+	resp := r.Response
+	metrics.TokenUsageTotal.WithLabelValues(nb.Provider, nb.Model, "input").Add(float64(resp.PromptTokens))
+	metrics.TokenUsageTotal.WithLabelValues(nb.Provider, nb.Model, "output").Add(float64(resp.OutputTokens))
+	recordFinishReason(nb.Provider, resp.Metadata)
+	costUSD := h.priceUsage(nb.Model, usageOf(resp)) * nativeBatchDiscount
+	recordCost(ctx, nb.Provider, nb.Model, costUSD)
+	if kp, ok := h.keyPools[nb.Provider]; ok {
+		if apiKey, ok := kp.ByFingerprint(nb.KeyFingerprint); ok {
+			h.recordKeyUsage(nb.Provider, kp, apiKey, resp.PromptTokens+resp.OutputTokens, costUSD)
+		}
+	}
+	rec.PromptTokens, rec.CachedTokens, rec.OutputTokens = resp.PromptTokens, resp.CachedTokens, resp.OutputTokens
+	rec.CostUSD = costUSD
+	reply = resp.Text
+	used = nativeUsage{tokens: resp.PromptTokens + resp.OutputTokens, costUSD: costUSD}
+
+	format, _ := parseResponseFormat(req.ResponseFormat)
+	if errs := format.checkResponse(&resp); len(errs) > 0 {
+		err = responseFormatError(errs)
+		return res, used
+	}
+	if v := h.checkOutput(resp); v != nil {
+		err = outputViolation(v)
+		return res, used
+	}
+	metrics.RequestsTotal.WithLabelValues("success").Inc()
+
+	res.Response = &pb.InferenceResponse{
+		Text:         resp.Text,
+		PromptTokens: resp.PromptTokens,
+		OutputTokens: resp.OutputTokens,
+		LatencyMs:    float64(time.Since(nb.Submitted).Milliseconds()),
+		CostUsd:      costUSD,
+		Metadata:     responseMetadata(requestID, nb.Provider, nb.Model, resp.Metadata),
+	}
+	if wantsCandidates(req) {
+		res.Response.Candidates = candidatesOf(resp, nil)
+	}
+	return res, used
+}
+
+// batchRun is a job being run by this replica. Workers record results
+// through it concurrently.
+type batchRun struct {
+	store *batch.Store
+	owner string
+
+	mu   sync.Mutex
+	job  *batch.Job
+	done map[int]*batch.Result
+}
+
+// record appends the results of items that have none yet and counts them.
+func (r *batchRun) record(mode string, results ...batch.Result) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fresh := make([]batch.Result, 0, len(results))
+	for _, res := range results {
+		if _, ok := r.done[res.Index]; !ok {
+			fresh = append(fresh, res)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	if err := r.store.Append(r.job.ID, fresh); err != nil {
+		return err
+	}
+	for i := range fresh {
+		r.done[fresh[i].Index] = &fresh[i]
+		outcome := "success"
+		if fresh[i].Error != nil {
+			outcome = "error"
+			r.job.Failed++
+		} else {
+			r.job.Succeeded++
+		}
+		metrics.BatchItemsTotal.WithLabelValues(mode, outcome).Inc()
+	}
+	return nil
+}
+
+func (r *batchRun) hasResult(index int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.done[index]
+	return ok
+}
+
+// finished reports whether every item has a result.
+func (r *batchRun) finished() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.done) >= r.job.Total
+}
+
+// sentNative returns the items ever sent to a native batch.
+func (r *batchRun) sentNative() map[int]bool {
+	return r.nativeItems(func(batch.NativeBatch) bool { return true })
+}
+
+// pendingNative returns the items of native batches not yet ended.
+func (r *batchRun) pendingNative() map[int]bool {
+	return r.nativeItems(func(nb batch.NativeBatch) bool { return !nb.Done })
+}
+
+func (r *batchRun) nativeItems(include func(batch.NativeBatch) bool) map[int]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	items := make(map[int]bool)
+	for _, nb := range r.job.Native {
+		if include(nb) {
+			for _, i := range nb.Items {
+				items[i] = true
+			}
+		}
+	}
+	return items
+}
+
+func (r *batchRun) addNative(nb batch.NativeBatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.job.Native = append(r.job.Native, nb)
+}
+
+func (r *batchRun) nativeDone(i int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.job.Native[i].Done = true
+}
+
+// snapshot returns a copy of the job.
+func (r *batchRun) snapshot() *batch.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := *r.job
+	job.Native = slices.Clone(r.job.Native)
+	return &job
+}
+
+// save stores the job's progress if this replica still holds its lease.
+func (r *batchRun) save() error {
+	job := r.snapshot()
+	if err := r.store.Renew(job.ID, r.owner); err != nil {
+		return err
+	}
+	if err := r.store.Save(job); err != nil {
+		log.Printf("[proxy] batch %s progress not saved: %v", job.ID, err)
+		return err
+	}
+	return nil
+}
+
+// end gives the job a final status and saves it.
+func (r *batchRun) end(status, msg string) {
+	r.mu.Lock()
+	r.job.Status, r.job.Error = status, msg
+	r.mu.Unlock()
+	if r.save() == nil {
+		metrics.BatchJobsTotal.WithLabelValues(status).Inc()
+		log.Printf("[proxy] batch %s %s", r.job.ID, status)
+	}
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/batch"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+func TestRunBatchResumes(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		done       []int  // Items with a result from an earlier run
+		tail       string // Partial result line left by a crash
+		cancel     bool
+		revoked    bool // The submitter's virtual key was revoked
+		wantStatus string
+		wantCalls  int
+	}{
+		{"fresh job", batch.StatusQueued, nil, "", false, false, batch.StatusCompleted, 4},
+		{"resumed after two items", batch.StatusRunning, []int{0, 2}, "", false, false, batch.StatusCompleted, 2},
+		{"partial result is run again", batch.StatusRunning, []int{0}, `{"index":1,"respo`, false, false, batch.StatusCompleted, 3},
+		{"everything done", batch.StatusRunning, []int{0, 1, 2, 3}, "", false, false, batch.StatusCompleted, 0},
+		{"cancelled before resuming", batch.StatusRunning, []int{0}, "", true, false, batch.StatusCancelled, 0},
+		{"key revoked before resuming", batch.StatusRunning, []int{0}, "", false, true, batch.StatusFailed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := batch.NewStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := auth.NewStore()
+			vk, _, err := keys.Create("acme", "", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.revoked {
+				keys.Revoke(vk.ID)
+			}
+			p := &fakeProvider{replies: []string{"r1", "r2", "r3", "r4"}}
+			h := NewHandler(Config{
+				Providers: map[string]provider.Provider{"openai": p},
+				KeyPools:  map[string]*resilience.KeyPool{"openai": resilience.NewKeyPool([]string{"sk-test"})},
+				Batch:     BatchConfig{Store: store, Workers: 1, Keys: keys},
+			})
+
+			job := &batch.Job{ID: batch.NewID(), Tenant: "acme", Status: tt.status, Total: 4, Created: time.Now(), Caller: *vk.Identity()}
+			items := make([]batch.Item, job.Total)
+			for i := range items {
+				items[i] = batch.Item{Request: &pb.InferenceRequest{Model: "gpt-4o", Prompt: "hi"}}
+			}
+			if err := store.Create(job, items); err != nil {
+				t.Fatal(err)
+			}
+			var earlier []batch.Result
+			for _, i := range tt.done {
+				earlier = append(earlier, batch.Result{Index: i, Response: &pb.InferenceResponse{Text: "earlier"}})
+			}
+			if err := store.Append(job.ID, earlier); err != nil {
+				t.Fatal(err)
+			}
+			if tt.tail != "" {
+				f, err := os.OpenFile(filepath.Join(dir, job.ID, "results.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.WriteString(tt.tail)
+				f.Close()
+			}
+			if tt.cancel {
+				store.RequestCancel(job.ID)
+			}
+			if ok, err := store.Lock(job.ID, h.batches.owner); !ok || err != nil {
+				t.Fatalf("Lock = %v, %v", ok, err)
+			}
+
+			h.runBatch(context.Background(), job.ID)
+
+			got, err := store.Get(job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			if len(p.reqs) != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", len(p.reqs), tt.wantCalls)
+			}
+			if got.Status != batch.StatusCompleted {
+				return
+			}
+			if got.Succeeded != job.Total || got.Failed != 0 {
+				t.Errorf("succeeded %d, failed %d, want %d, 0", got.Succeeded, got.Failed, job.Total)
+			}
+			results, err := store.Checkpoint(job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != job.Total {
+				t.Fatalf("%d results, want %d", len(results), job.Total)
+			}
+			for _, i := range tt.done {
+				if text := results[i].Response.GetText(); text != "earlier" {
+					t.Errorf("item %d = %q, want the earlier result kept", i, text)
+				}
+			}
+		})
+	}
+}
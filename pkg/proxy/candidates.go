@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"github.com/abdhe/llm-inference-proxy/pkg/guardrail"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// wantsCandidates reports whether the client asked for more than the first
+// candidate's text: several candidates or logprobs. Such requests bypass the
+// semantic cache, which stores a single text.
+func wantsCandidates(req *pb.InferenceRequest) bool {
+	return req.GetN() > 1 || req.GetLogprobs()
+}
+
+// checkOutput runs the output policy over every candidate of resp.
+func (h *Handler) checkOutput(resp provider.Response) *guardrail.Violation {
+	if v := h.output.Check(resp.Text); v != nil {
+		return v
+	}
+	for i := 1; i < len(resp.Candidates); i++ {
+		if v := h.output.Check(resp.Candidates[i].Text); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// candidatesOf converts the candidates of resp for the client, restoring
+// PII placeholders in each text.
+func candidatesOf(resp provider.Response, redaction *guardrail.Redaction) []*pb.Candidate {
+	out := make([]*pb.Candidate, 0, len(resp.Candidates))
+	for _, c := range resp.Candidates {
+		pc := &pb.Candidate{
+			Index:              int32(c.Index),
+			Text:               redaction.Restore(c.Text),
+			FinishReason:       finishReasons[c.FinishReason],
+			NativeFinishReason: c.NativeFinishReason,
+			Logprobs:           logprobsOf(c.Logprobs),
+		}
+		for _, sr := range c.SafetyRatings {
+			pc.SafetyRatings = append(pc.SafetyRatings, &pb.SafetyRating{
+				Category:    sr.Category,
+				Probability: sr.Probability,
+				Blocked:     sr.Blocked,
+			})
+		}
+		out = append(out, pc)
+	}
+	return out
+}
+
+// logprobsOf converts provider logprobs for the client.
+func logprobsOf(tokens []provider.TokenLogprob) []*pb.TokenLogprob {
+	if len(tokens) == 0 {
+		return nil
+	}
+	out := make([]*pb.TokenLogprob, 0, len(tokens))
+	for _, t := range tokens {
+		pt := &pb.TokenLogprob{Token: t.Token, Logprob: t.Logprob}
+		for _, top := range t.Top {
+			pt.TopLogprobs = append(pt.TopLogprobs, &pb.TopLogprob{Token: top.Token, Logprob: top.Logprob})
+		}
+		out = append(out, pt)
+	}
+	return out
+}
+
+// candidateStream is the state of one candidate in a streamed response: its
+// own output guard and placeholder restorer, and the provider text so far.
+type candidateStream struct {
+	guard    *guardrail.StreamGuard
+	restorer *guardrail.Restorer
+	text     string
+}
+
+// write passes a chunk of provider text through the guard, then restores
+// placeholders in what passed.
+func (cs *candidateStream) write(chunk string) (string, *guardrail.Violation) {
+	cs.text += chunk
+	safe, v := cs.guard.Write(chunk)
+	if v != nil {
+		return "", v
+	}
+	return cs.restorer.Write(safe), nil
+}
+
+// flush releases the text both stages held back, or returns the violation
+// if the guard's tail completes one.
+func (cs *candidateStream) flush() (string, *guardrail.Violation) {
+	safe, v := cs.guard.Flush()
+	if v != nil {
+		return "", v
+	}
+	return cs.restorer.Write(safe) + cs.restorer.Flush(), nil
+}
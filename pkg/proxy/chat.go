@@ -0,0 +1,262 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/session"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// maxSessionTurns bounds the turns kept in a chat session; the oldest are
+// dropped first.
+const maxSessionTurns = 200
+
+// maxQueuedTurns bounds the turns a client may send ahead of the one being
+// answered. The stream is read without waiting for the queue, so a CANCEL
+// is seen at once; a turn beyond the bound fails the stream.
+const maxQueuedTurns = 8
+
+// chatTurn is a SEND message waiting to be answered, with a context that a
+// CANCEL message cancels.
+type chatTurn struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	index  int32
+	msg    *pb.ChatClientMessage
+}
+
+// Chat holds a conversation over a bidirectional stream. Turns are
+// answered one at a time in the order they were sent, each through the
+// same guardrails, cache, limits and accounting as InferStream, with the
+// session's earlier turns as history. A CANCEL stops the reply being
+// generated and drops the turns queued behind it; the text already sent is
+// kept in the session. A failed turn is reported in a message and the
+// stream stays open.
+func (h *Handler) Chat(stream pb.InferenceService_ChatServer) error {
+	metrics.ActiveChatStreams.Inc()
+	defer metrics.ActiveChatStreams.Dec()
+
+	ctx := stream.Context()
+	turns := make(chan chatTurn, maxQueuedTurns)
+	recvErr := make(chan error, 1)
+	pending := &chatPending{cancels: map[int32]context.CancelFunc{}}
+	go receiveChat(ctx, stream, pending, turns, recvErr)
+
+	var sess *session.Session
+	for t := range turns {
+		if sess == nil {
+			var err error
+			if sess, err = h.openSession(ctx, t.msg); err != nil {
+				return err
+			}
+		} else if id := t.msg.GetSessionId(); id != "" && id != sess.ID {
+			return status.Errorf(codes.InvalidArgument, "proxy: this stream is bound to session %q", sess.ID)
+		}
+		err := h.answerTurn(ctx, stream, sess, t)
+		pending.done(t.index)
+		t.cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return <-recvErr
+}
+
+// receiveChat reads the client's messages until the stream ends, queueing
+// SEND messages on turns. A CANCEL cancels the turns received before it
+// that are still being answered or queued, but not those after. A SEND
+// that finds the queue full cancels every pending turn and ends the stream
+// with ResourceExhausted.
+func receiveChat(ctx context.Context, stream pb.InferenceService_ChatServer, pending *chatPending, turns chan<- chatTurn, recvErr chan<- error) {
+	defer close(turns)
+
+	var index int32
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			recvErr <- err
+			return
+		}
+
+		if msg.GetAction() == pb.ChatAction_CHAT_ACTION_CANCEL {
+			pending.cancelAll()
+			continue
+		}
+		turnCtx, cancel := context.WithCancel(ctx)
+		pending.add(index, cancel)
+		select {
+		case turns <- chatTurn{ctx: turnCtx, cancel: cancel, index: index, msg: msg}:
+			index++
+		default:
+			pending.cancelAll()
+			recvErr <- status.Errorf(codes.ResourceExhausted, "proxy: more than %d chat turns queued", maxQueuedTurns)
+			return
+		}
+	}
+}
+
+// chatPending holds the cancel functions of a stream's turns that have been
+// received but not yet answered.
+type chatPending struct {
+	mu      sync.Mutex
+	cancels map[int32]context.CancelFunc
+}
+
+func (p *chatPending) add(index int32, cancel context.CancelFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cancels[index] = cancel
+}
+
+// done forgets an answered turn.
+func (p *chatPending) done(index int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cancels, index)
+}
+
+// cancelAll cancels every pending turn.
+func (p *chatPending) cancelAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for index, cancel := range p.cancels {
+		cancel()
+		delete(p.cancels, index)
+	}
+}
+
+// openSession resumes the session the first message names, or starts a new
+// one for the caller. Sessions belong to the tenant that started them;
+// another tenant's session is reported as not found.
+func (h *Handler) openSession(ctx context.Context, msg *pb.ChatClientMessage) (*session.Session, error) {
+	tenant := tenantOf(ctx)
+	if msg.GetSessionId() == "" {
+		return session.New(tenant, h.models.Resolve(msg.GetTurn().GetModel())), nil
+	}
+	s, err := h.sessions.Get(ctx, msg.GetSessionId())
+	if errors.Is(err, session.ErrNotFound) || (err == nil && s.Tenant != tenant) {
+		return nil, status.Errorf(codes.NotFound, "proxy: chat session %q not found", msg.GetSessionId())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+	return s, nil
+}
+
+// answerTurn streams the reply to one turn and saves it in the session.
+// Only errors sending to the client are returned; a failed turn is
+// reported to the client instead.
+func (h *Handler) answerTurn(ctx context.Context, stream pb.InferenceService_ChatServer, sess *session.Session, t chatTurn) error {
+	out := &chatTurnStream{stream: stream, sessionID: sess.ID, turn: t.index}
+	req := t.msg.GetTurn()
+	if req == nil {
+		metrics.ChatTurnsTotal.WithLabelValues("error").Inc()
+		return out.fail(status.Error(codes.InvalidArgument, "proxy: turn is required"))
+	}
+	if t.ctx.Err() != nil && ctx.Err() == nil {
+		// Cancelled while queued.
+		metrics.ChatTurnsTotal.WithLabelValues("cancelled").Inc()
+		return out.send(&pb.ChatServerMessage{Cancelled: true})
+	}
+	if req.GetConversationId() != "" {
+		metrics.ChatTurnsTotal.WithLabelValues("error").Inc()
+		return out.fail(status.Error(codes.InvalidArgument, "proxy: Chat turns belong to the stream's session; conversation_id is for Infer and InferStream"))
+	}
+	if req.Model == "" {
+		req.Model = sess.Model
+	}
+
+	prompt, reply, err := h.streamReply(t.ctx, "Chat", req, sess, out)
+	cancelled := err != nil && t.ctx.Err() != nil && ctx.Err() == nil
+	if err != nil && !cancelled {
+		metrics.ChatTurnsTotal.WithLabelValues("error").Inc()
+		return out.fail(err)
+	}
+
+	if err := h.saveTurn(ctx, sess, req.Model, prompt, reply); err != nil {
+		metrics.ChatTurnsTotal.WithLabelValues("error").Inc()
+		return out.fail(err)
+	}
+
+	if cancelled {
+		metrics.ChatTurnsTotal.WithLabelValues("cancelled").Inc()
+		return out.send(&pb.ChatServerMessage{Cancelled: true})
+	}
+	metrics.ChatTurnsTotal.WithLabelValues("success").Inc()
+	return nil
+}
+
+// historyOf converts a session's turns to provider messages; nil for no
+// session.
+func historyOf(s *session.Session) []provider.Message {
+	if s == nil {
+		return nil
+	}
+	out := make([]provider.Message, len(s.Turns))
+	for i, t := range s.Turns {
+		out[i] = provider.Message{Role: t.Role, Text: t.Text}
+	}
+	return out
+}
+
+// chatTurnStream is the replyStream of one Chat turn. It wraps each chunk
+// in a ChatServerMessage, and carries the headers and trailers set for the
+// turn in the next message sent.
+type chatTurnStream struct {
+	stream    pb.InferenceService_ChatServer
+	sessionID string
+	turn      int32
+	headers   map[string]string
+}
+
+func (s *chatTurnStream) Send(chunk *pb.StreamChunk) error {
+	return s.send(&pb.ChatServerMessage{Chunk: chunk})
+}
+
+func (s *chatTurnStream) SetHeader(md metadata.MD) error {
+	s.add(md)
+	return nil
+}
+
+func (s *chatTurnStream) SetTrailer(md metadata.MD) {
+	s.add(md)
+}
+
+func (s *chatTurnStream) add(md metadata.MD) {
+	if s.headers == nil {
+		s.headers = make(map[string]string, len(md))
+	}
+	for k, v := range md {
+		if len(v) > 0 {
+			s.headers[k] = v[len(v)-1]
+		}
+	}
+}
+
+// send fills in the turn's session, index and pending headers and sends
+// msg.
+func (s *chatTurnStream) send(msg *pb.ChatServerMessage) error {
+	msg.SessionId = s.sessionID
+	msg.Turn = s.turn
+	msg.Headers, s.headers = s.headers, nil
+	return s.stream.Send(msg)
+}
+
+// fail reports a failed turn with its status code, message and ErrorInfo
+// reason.
+func (s *chatTurnStream) fail(err error) error {
+	st := statusOf(err)
+	return s.send(&pb.ChatServerMessage{ErrorCode: int32(st.Code()), ErrorMessage: st.Message(), ErrorReason: errorReason(st)})
+}
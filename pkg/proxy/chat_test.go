@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// fakeChatStream is a Chat stream whose client sends msgs and then closes
+// its side.
+type fakeChatStream struct {
+	grpc.ServerStream
+	msgs []*pb.ChatClientMessage
+}
+
+func (s *fakeChatStream) Context() context.Context         { return context.Background() }
+func (s *fakeChatStream) Send(*pb.ChatServerMessage) error { return nil }
+func (s *fakeChatStream) Recv() (*pb.ChatClientMessage, error) {
+	if len(s.msgs) == 0 {
+		return nil, io.EOF
+	}
+	msg := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return msg, nil
+}
+
+func TestReceiveChat(t *testing.T) {
+	send := &pb.ChatClientMessage{Turn: &pb.InferenceRequest{Prompt: "hi"}}
+	cancel := &pb.ChatClientMessage{Action: pb.ChatAction_CHAT_ACTION_CANCEL}
+	sends := func(n int) []*pb.ChatClientMessage {
+		msgs := make([]*pb.ChatClientMessage, n)
+		for i := range msgs {
+			msgs[i] = send
+		}
+		return msgs
+	}
+
+	tests := []struct {
+		name          string
+		msgs          []*pb.ChatClientMessage
+		wantCancelled []bool // Per turn queued
+		wantErr       codes.Code
+	}{
+		{"cancel applies to earlier turns only", []*pb.ChatClientMessage{send, cancel, send}, []bool{true, false}, codes.OK},
+		{"cancel is read with the queue full", append(sends(maxQueuedTurns), cancel), []bool{true, true, true, true, true, true, true, true}, codes.OK},
+		{"too many turns queued", sends(maxQueuedTurns + 1), []bool{true, true, true, true, true, true, true, true}, codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nothing answers the turns while the client is read.
+			turns := make(chan chatTurn, maxQueuedTurns)
+			recvErr := make(chan error, 1)
+			pending := &chatPending{cancels: map[int32]context.CancelFunc{}}
+			stream := &fakeChatStream{msgs: tt.msgs}
+			receiveChat(stream.Context(), stream, pending, turns, recvErr)
+
+			if got := status.Code(<-recvErr); got != tt.wantErr {
+				t.Errorf("receive error = %s, want %s", got, tt.wantErr)
+			}
+			var got []bool
+			for turn := range turns {
+				got = append(got, turn.ctx.Err() != nil)
+				pending.done(turn.index)
+			}
+			if len(got) != len(tt.wantCancelled) {
+				t.Fatalf("%d turns queued, want %d", len(got), len(tt.wantCancelled))
+			}
+			for i := range got {
+				if got[i] != tt.wantCancelled[i] {
+					t.Errorf("turn %d cancelled = %v, want %v", i, got[i], tt.wantCancelled[i])
+				}
+			}
+			if n := len(pending.cancels); n != 0 {
+				t.Errorf("%d answered turns still pending", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/session"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// maxConversationIDLength bounds client-chosen conversation IDs.
+const maxConversationIDLength = 128
+
+// summaryMaxTokens bounds the length of a conversation summary.
+const summaryMaxTokens = 1024
+
+// summaryPrefix introduces a summary turn to the model.
+const summaryPrefix = "Summary of the earlier conversation: "
+
+// summaryPrompt asks the summary model to condense earlier turns; the
+// transcript follows it.
+const summaryPrompt = "Summarize the conversation below for the assistant that will continue it. " +
+	"Keep facts, names, numbers, decisions, open questions and anything the user asked to remember; " +
+	"drop greetings and repetition. Write plain prose in the third person, at most 300 words.\n\n"
+
+// SummaryConfig controls summaries of long conversation histories.
+type SummaryConfig struct {
+	Model     string  // Model that writes the summaries; empty disables them
+	Threshold float64 // Fraction of the context window at which older turns are summarized (default: 0.8)
+	KeepTurns int     // Latest turns kept verbatim (default: 4)
+}
+
+// GetConversation returns the stored transcript of one of the caller's
+// conversations.
+func (h *Handler) GetConversation(ctx context.Context, req *pb.GetConversationRequest) (*pb.Conversation, error) {
+	if err := checkConversationID(req.ConversationId); err != nil {
+		return nil, err
+	}
+	s, err := h.sessions.Get(ctx, conversationKey(tenantOf(ctx), req.ConversationId))
+	if errors.Is(err, session.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "proxy: conversation %q not found", req.ConversationId)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+
+	out := &pb.Conversation{
+		ConversationId: req.ConversationId,
+		Model:          s.Model,
+		CreatedAt:      s.Created.Unix(),
+		UpdatedAt:      s.Updated.Unix(),
+	}
+	for _, t := range s.Turns {
+		out.Turns = append(out.Turns, &pb.ConversationTurn{Role: t.Role, Text: t.Text, CreatedAt: t.At.Unix()})
+	}
+	return out, nil
+}
+
+// DeleteConversation deletes one of the caller's conversations.
+func (h *Handler) DeleteConversation(ctx context.Context, req *pb.DeleteConversationRequest) (*pb.DeleteConversationResponse, error) {
+	if err := checkConversationID(req.ConversationId); err != nil {
+		return nil, err
+	}
+	key := conversationKey(tenantOf(ctx), req.ConversationId)
+	_, err := h.sessions.Get(ctx, key)
+	if errors.Is(err, session.ErrNotFound) {
+		return &pb.DeleteConversationResponse{}, nil
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+	if err := h.sessions.Delete(ctx, key); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+	return &pb.DeleteConversationResponse{Deleted: true}, nil
+}
+
+// openConversation loads the caller's conversation named by req, or starts
+// it if it does not exist. A request without a model continues with the
+// conversation's.
+func (h *Handler) openConversation(ctx context.Context, req *pb.InferenceRequest) (*session.Session, error) {
+	if err := checkConversationID(req.ConversationId); err != nil {
+		return nil, err
+	}
+	tenant := tenantOf(ctx)
+	key := conversationKey(tenant, req.ConversationId)
+	s, err := h.sessions.Get(ctx, key)
+	switch {
+	case errors.Is(err, session.ErrNotFound):
+		s = session.New(tenant, h.models.Resolve(req.Model))
+		s.ID = key
+	case err != nil:
+		return nil, status.Errorf(codes.Unavailable, "proxy: %v", err)
+	}
+	if req.Model == "" {
+		req.Model = s.Model
+	}
+	return s, nil
+}
+
+// checkConversationID rejects conversation IDs that are empty, too long or
+// use characters other than letters, digits, '.', '_' and '-'.
+func checkConversationID(id string) error {
+	if id == "" || len(id) > maxConversationIDLength {
+		return status.Errorf(codes.InvalidArgument, "proxy: conversation_id must be 1 to %d characters", maxConversationIDLength)
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			return status.Errorf(codes.InvalidArgument, "proxy: conversation_id may only contain letters, digits, '.', '_' and '-'")
+		}
+	}
+	return nil
+}
+
+// conversationKey is the session store ID of a tenant's conversation.
+// Conversation IDs are chosen by clients, so each tenant has its own
+// namespace.
+func conversationKey(tenant, id string) string {
+	return "conv:" + tenant + ":" + id
+}
+
+// saveTurn appends a prompt and its reply to s and stores it, with the PII
+// placeholders the turn's redaction added to s. A turn without a reply is
+// not saved, so the history keeps alternating user and assistant turns.
+func (h *Handler) saveTurn(ctx context.Context, s *session.Session, model, prompt, reply string) error {
+	if reply == "" {
+		return nil
+	}
+	s.Model = model
+	s.Append(provider.RoleUser, prompt, maxSessionTurns)
+	s.Append(provider.RoleAssistant, reply, maxSessionTurns)
+	if err := h.sessions.Put(ctx, s); err != nil {
+		log.Printf("[proxy] session %s not saved: %v", s.ID, err)
+		return status.Errorf(codes.Unavailable, "proxy: conversation not saved: %v", err)
+	}
+	return nil
+}
+
+// summarySplit returns how many of the oldest turns of s are to be
+// summarized before req is answered: all but the latest KeepTurns, once
+// the turns, req's prompt and max_tokens exceed Threshold of the model's
+// context window. It returns 0 when no summary is due, and without a
+// session.
+func (h *Handler) summarySplit(s *session.Session, req *pb.InferenceRequest) int {
+	if s == nil || h.summary.Model == "" {
+		return 0
+	}
+	tok, m, ok := h.tokenizerFor(h.models.Resolve(req.Model))
+	if !ok || m.ContextWindow == 0 {
+		return 0
+	}
+	total := tok.Count(req.Prompt) + int(req.MaxTokens) + len(req.Parts)*partTokens
+	for _, t := range s.Turns {
+		total += tok.Count(t.Text)
+	}
+	if float64(total) <= h.summary.Threshold*float64(m.ContextWindow) {
+		return 0
+	}
+
+	// The kept turns start with a user turn, after the summary.
+	split := len(s.Turns) - h.summary.KeepTurns
+	for split > 0 && split < len(s.Turns) && s.Turns[split].Role != provider.RoleUser {
+		split++
+	}
+	if split <= 0 || split >= len(s.Turns) || (split == 1 && s.Turns[0].Role == provider.RoleSystem) {
+		return 0
+	}
+	return split
+}
+
+// summarize replaces the first split turns of s with a summary written by
+// the summary model. It runs once the request has been admitted against the
+// tenant's quotas, so the summary call is only paid for requests that are
+// answered, and is charged to those quotas without being admitted again. A
+// failed summary is logged and leaves s as it was, for the context policy
+// to handle.
+func (h *Handler) summarize(ctx context.Context, s *session.Session, split int) {
+	summary, err := h.complete(ctx, "Summarize", h.summary.Model, summaryPrompt+h.transcript(s.Turns[:split]), summaryMaxTokens, false)
+	if err == nil && strings.TrimSpace(summary) == "" {
+		err = errors.New("empty summary")
+	}
+	if err != nil {
+		metrics.ConversationSummariesTotal.WithLabelValues("error").Inc()
+		log.Printf("[proxy] session %s not summarized: %v", s.ID, err)
+		return
+	}
+	metrics.ConversationSummariesTotal.WithLabelValues("success").Inc()
+
+	turns := make([]session.Turn, 0, len(s.Turns)-split+1)
+	turns = append(turns, session.Turn{Role: provider.RoleSystem, Text: summaryPrefix + strings.TrimSpace(summary), At: time.Now()})
+	s.Turns = append(turns, s.Turns[split:]...)
+	if err := h.sessions.Put(ctx, s); err != nil {
+		log.Printf("[proxy] session %s summary not saved: %v", s.ID, err)
+	}
+}
+
+// transcript formats turns for the summary model, keeping the latest part
+// if they do not fit its context window.
+func (h *Handler) transcript(turns []session.Turn) string {
+	var b strings.Builder
+	for _, t := range turns {
+		switch t.Role {
+		case provider.RoleSystem:
+			fmt.Fprintf(&b, "Earlier summary: %s\n\n", strings.TrimPrefix(t.Text, summaryPrefix))
+		case provider.RoleAssistant:
+			fmt.Fprintf(&b, "Assistant: %s\n\n", t.Text)
+		default:
+			fmt.Fprintf(&b, "User: %s\n\n", t.Text)
+		}
+	}
+
+	text := b.String()
+	tok, m, ok := h.tokenizerFor(h.models.Resolve(h.summary.Model))
+	if budget := m.ContextWindow - summaryMaxTokens - tok.Count(summaryPrompt); ok && m.ContextWindow > 0 && budget > 0 {
+		text, _ = keepLast(tok, text, budget)
+	}
+	return text
+}
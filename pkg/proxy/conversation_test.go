@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/models"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	"github.com/abdhe/llm-inference-proxy/pkg/session"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+func TestSummarizeBeforeSlot(t *testing.T) {
+	// With room for a single call, the summary only succeeds if it is not
+	// made while the request holds the provider's slot.
+	p := &fakeProvider{replies: []string{"the story so far", "answer"}}
+	lim := resilience.NewFixedLimiter(1)
+	h := NewHandler(Config{
+		Providers: map[string]provider.Provider{"openai": p},
+		KeyPools:  map[string]*resilience.KeyPool{"openai": resilience.NewKeyPool([]string{"sk-test"})},
+		Models: models.NewRegistry(map[string]models.Model{
+			"small": {Provider: "openai", ContextWindow: 100, MaxOutputTokens: 10},
+		}),
+		Limiters: map[string]*resilience.ConcurrencyLimiter{"openai": lim},
+		Summary:  SummaryConfig{Model: "small", Threshold: 0.5, KeepTurns: 2},
+	})
+
+	conv := session.New(anonymousTenant, "small")
+	for i := 0; i < 2; i++ {
+		conv.Append(provider.RoleUser, words(20), maxSessionTurns)
+		conv.Append(provider.RoleAssistant, words(20), maxSessionTurns)
+	}
+
+	req := &pb.InferenceRequest{Model: "small", Prompt: "and then?"}
+	_, reply, _, err := h.inferReply(context.Background(), "Infer", req, conv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "answer" {
+		t.Errorf("reply = %q, want %q", reply, "answer")
+	}
+	if len(conv.Turns) != 3 || conv.Turns[0].Role != provider.RoleSystem || !strings.Contains(conv.Turns[0].Text, "the story so far") {
+		t.Fatalf("turns after the summary = %+v, want the summary and the last two turns", conv.Turns)
+	}
+	if len(p.reqs) != 2 || len(p.reqs[1].History) != 3 {
+		t.Errorf("provider sent %d requests, want the summary and the request with the summarized history", len(p.reqs))
+	}
+	if got := lim.Stats().InFlight; got != 0 {
+		t.Errorf("in flight after the request = %d, want 0", got)
+	}
+}
+
+func TestCheckConversationID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"support-42", true},
+		{"a.b_C-9", true},
+		{"", false},
+		{strings.Repeat("x", maxConversationIDLength), true},
+		{strings.Repeat("x", maxConversationIDLength+1), false},
+		{"acme:other", false},
+		{"héllo", false},
+	}
+	for _, tt := range tests {
+		if err := checkConversationID(tt.id); (err == nil) != tt.want {
+			t.Errorf("checkConversationID(%q) = %v, want valid %v", tt.id, err, tt.want)
+		}
+	}
+}
+
+func TestConversationTenants(t *testing.T) {
+	h := newTestHandler(&fakeProvider{})
+	acme := auth.NewContext(context.Background(), &auth.Identity{KeyID: "vk-1", Tenant: "acme"})
+	other := auth.NewContext(context.Background(), &auth.Identity{KeyID: "vk-2", Tenant: "other"})
+
+	req := &pb.InferenceRequest{Model: "gpt-4o", Prompt: "hi", ConversationId: "support-42"}
+	s, err := h.openConversation(acme, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.saveTurn(acme, s, "gpt-4o", "hi", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.GetConversation(acme, &pb.GetConversationRequest{ConversationId: "support-42"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetConversation after a turn without a reply = %v, want NotFound", err)
+	}
+	if err := h.saveTurn(acme, s, "gpt-4o", "hi", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A request without a model continues with the conversation's.
+	next := &pb.InferenceRequest{Prompt: "and then?", ConversationId: "support-42"}
+	if s, err := h.openConversation(acme, next); err != nil || len(s.Turns) != 2 || next.Model != "gpt-4o" {
+		t.Errorf("reopened conversation = %+v, %v with model %q, want 2 turns with gpt-4o", s, err, next.Model)
+	}
+
+	conv, err := h.GetConversation(acme, &pb.GetConversationRequest{ConversationId: "support-42"})
+	if err != nil || len(conv.Turns) != 2 || conv.Turns[1].Text != "hello" {
+		t.Fatalf("GetConversation = %+v, %v, want the prompt and reply", conv, err)
+	}
+	if _, err := h.GetConversation(other, &pb.GetConversationRequest{ConversationId: "support-42"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetConversation by another tenant = %v, want NotFound", err)
+	}
+	if resp, err := h.DeleteConversation(other, &pb.DeleteConversationRequest{ConversationId: "support-42"}); err != nil || resp.Deleted {
+		t.Errorf("DeleteConversation by another tenant = %+v, %v, want nothing deleted", resp, err)
+	}
+	if resp, err := h.DeleteConversation(acme, &pb.DeleteConversationRequest{ConversationId: "support-42"}); err != nil || !resp.Deleted {
+		t.Errorf("DeleteConversation = %+v, %v, want deleted", resp, err)
+	}
+	if _, err := h.GetConversation(acme, &pb.GetConversationRequest{ConversationId: "support-42"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetConversation after delete = %v, want NotFound", err)
+	}
+}
+
+func TestSummarySplit(t *testing.T) {
+	long := func(roles ...string) *session.Session {
+		s := session.New(anonymousTenant, "small")
+		for _, role := range roles {
+			s.Append(role, words(20), 0)
+		}
+		return s
+	}
+	user, assistant, system := provider.RoleUser, provider.RoleAssistant, provider.RoleSystem
+
+	tests := []struct {
+		name      string
+		model     string // Summary model
+		keepTurns int
+		s         *session.Session
+		want      int
+	}{
+		{"no session", "small", 2, nil, 0},
+		{"summaries disabled", "", 2, long(user, assistant, user, assistant), 0},
+		{"under the threshold", "small", 2, long(user, assistant), 0},
+		{"all but the kept turns", "small", 2, long(user, assistant, user, assistant), 2},
+		{"kept turns start with a user turn", "small", 3, long(user, assistant, user, assistant), 2},
+		{"nothing left to summarize", "small", 1, long(user, assistant, user, assistant), 0},
+		{"only an earlier summary", "small", 2, long(system, user, assistant), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(Config{
+				Models: models.NewRegistry(map[string]models.Model{
+					"small": {Provider: "openai", ContextWindow: 100, MaxOutputTokens: 10},
+				}),
+				Summary: SummaryConfig{Model: tt.model, Threshold: 0.5, KeepTurns: tt.keepTurns},
+			})
+			req := &pb.InferenceRequest{Model: "small", Prompt: "and then?"}
+			if got := h.summarySplit(tt.s, req); got != tt.want {
+				t.Errorf("summarySplit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/pricing"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+)
+
+// anonymousTenant labels requests made without a virtual key.
+const anonymousTenant = "anonymous"
+
+// tenantOf returns the caller's tenant for metrics.
+func tenantOf(ctx context.Context) string {
+	if id, ok := auth.FromContext(ctx); ok {
+		return id.Tenant
+	}
+	return anonymousTenant
+}
+
+// priceUsage returns the cost of usage for model, or 0 if it has no price.
+func (h *Handler) priceUsage(model string, usage pricing.Usage) float64 {
+	if h.prices == nil {
+		return 0
+	}
+	cost, ok := h.prices.Cost(model, time.Now(), usage)
+	if !ok {
+		metrics.UnpricedRequestsTotal.WithLabelValues(model).Inc()
+	}
+	return cost
+}
+
+// recordCost records the spend of a provider call for the tenant.
+func recordCost(ctx context.Context, providerName, model string, cost float64) {
+	metrics.CostUSDTotal.WithLabelValues(providerName, model, tenantOf(ctx)).Add(cost)
+}
+
+// recordSavedCost records what a cache hit would have cost at the provider.
+func (h *Handler) recordSavedCost(ctx context.Context, providerName, model string, resp provider.Response) {
+	cost := h.priceUsage(model, usageOf(resp))
+	metrics.CacheSavedCostUSDTotal.WithLabelValues(providerName, model, tenantOf(ctx)).Add(cost)
+}
+
+// usageOf extracts the billable usage of a response.
+func usageOf(resp provider.Response) pricing.Usage {
+	return pricing.Usage{
+		PromptTokens: resp.PromptTokens,
+		CachedTokens: resp.CachedTokens,
+		OutputTokens: resp.OutputTokens,
+	}
+}
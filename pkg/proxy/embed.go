@@ -0,0 +1,315 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/cache"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/pricing"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// Embed handles an embedding request. Inputs found in the embedding cache
+// are served from it; the rest are sent upstream in one call through the
+// provider's key pool, circuit breaker and retry policy.
+func (h *Handler) Embed(ctx context.Context, req *pb.EmbedRequest) (_ *pb.EmbedResponse, err error) {
+	start := time.Now()
+	metrics.ActiveRequests.Inc()
+	defer metrics.ActiveRequests.Dec()
+
+	req.Model = h.models.Resolve(req.Model)
+	rec := newRecord(ctx, req.Model, start)
+	requestID := newRequestID()
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID))
+	defer func() {
+		h.finishRequest(ctx, "Embed", requestID, strings.Join(req.Inputs, "\n"), "", &rec, start, err)
+	}()
+
+	if len(req.Inputs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "proxy: inputs is empty")
+	}
+	for i, input := range req.Inputs {
+		if input == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "proxy: input %d is empty", i)
+		}
+	}
+
+	priority := requestPriority(req.GetPriority())
+	releaseGlobal, err := h.acquireGlobal(ctx, priority)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseGlobal(0, resilience.OutcomeIgnore)
+
+	ctx, cancel := context.WithTimeout(ctx, h.requestTimeout)
+	defer cancel()
+
+	providerName := h.providerFor(req.Model)
+	rec.Provider = providerName
+	if err := authorize(ctx, providerName, req.Model); err != nil {
+		return nil, err
+	}
+	e, kp, err := h.embedderFor(providerName, req.Model)
+	if err != nil {
+		return nil, err
+	}
+	embedReq := provider.EmbedRequest{
+		Model:      req.Model,
+		Inputs:     req.Inputs,
+		Dimensions: req.Dimensions,
+	}
+	if err := paramStatus(providerName, e.ValidateEmbed(embedReq)); err != nil {
+		return nil, err
+	}
+
+	// -------------------------------------------------------------------------
+	// Step 1: Embedding cache lookup
+	// -------------------------------------------------------------------------
+	vectors := h.cachedEmbeddings(ctx, providerName, embedReq)
+	out := &pb.EmbedResponse{Embeddings: make([]*pb.Embedding, len(req.Inputs))}
+	var missing []int
+	for i, v := range vectors {
+		if v == nil {
+			missing = append(missing, i)
+			continue
+		}
+		out.Embeddings[i] = &pb.Embedding{Index: int32(i), Values: v, CacheHit: true}
+	}
+
+	var m provider.Metadata
+	if len(missing) == 0 {
+		rec.CacheStatus = "hit"
+		metrics.RequestsTotal.WithLabelValues("cache_hit").Inc()
+		latency := time.Since(start)
+		metrics.RequestLatency.WithLabelValues(providerName, req.Model, "hit").Observe(latency.Seconds())
+		out.LatencyMs = float64(latency.Milliseconds())
+		out.Metadata = responseMetadata(requestID, providerName, req.Model, m)
+		return out, nil
+	}
+
+	// -------------------------------------------------------------------------
+	// Step 2: Check tenant quota and acquire a concurrency slot
+	// -------------------------------------------------------------------------
+	missReq := embedReq
+	missReq.Inputs = make([]string, len(missing))
+	for j, i := range missing {
+		missReq.Inputs[j] = req.Inputs[i]
+	}
+
+	reservation, err := h.reserveTokens(ctx, h.countInputs(req.Model, missReq.Inputs), func(md metadata.MD) { grpc.SetTrailer(ctx, md) })
+	if err != nil {
+		return nil, err
+	}
+	var usedTokens int32
+	var costUSD float64
+	defer func() { settleQuota(reservation, usedTokens, costUSD) }()
+
+	release, err := h.acquireSlot(ctx, providerName, priority)
+	if err != nil {
+		return nil, err
+	}
+	defer release(0, resilience.OutcomeIgnore)
+
+	// -------------------------------------------------------------------------
+	// Step 3: Embed the misses upstream
+	// -------------------------------------------------------------------------
+	callStart := time.Now()
+	resp, cost, apiKey, err := h.embedUpstream(ctx, providerName, e, kp, missReq)
+	release(time.Since(callStart), limitOutcome(err))
+
+	rec.KeyFingerprint = resilience.Fingerprint(apiKey)
+	if err != nil {
+		metrics.RequestsTotal.WithLabelValues("error").Inc()
+		metrics.RequestLatency.WithLabelValues(providerName, req.Model, "error").Observe(time.Since(start).Seconds())
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	metrics.RequestsTotal.WithLabelValues("success").Inc()
+
+	usedTokens, costUSD = resp.PromptTokens, cost
+	rec.PromptTokens, rec.CostUSD = resp.PromptTokens, cost
+	for j, i := range missing {
+		out.Embeddings[i] = &pb.Embedding{Index: int32(i), Values: resp.Embeddings[j]}
+	}
+	go h.storeEmbeddings(context.Background(), missReq, resp.Embeddings)
+
+	latency := time.Since(start)
+	metrics.RequestLatency.WithLabelValues(providerName, req.Model, "miss").Observe(latency.Seconds())
+	m.Model = resp.Model
+	out.PromptTokens = resp.PromptTokens
+	out.LatencyMs = float64(latency.Milliseconds())
+	out.CostUsd = cost
+	out.Metadata = responseMetadata(requestID, providerName, req.Model, m)
+	return out, nil
+}
+
+// EmbedText embeds text with model through the embedding cache and the
+// provider's key pool, circuit breaker and retry policy. Cache misses are
+// admitted against the caller's tenant quotas and the provider's
+// concurrency limit, and recorded in the usage ledger and audit log under
+// method SemanticCache. It is meant to back a cache.SemanticCache.
+func (h *Handler) EmbedText(model string) cache.EmbedFunc {
+	return func(ctx context.Context, text string) (vec []float32, err error) {
+		model := h.models.Resolve(model)
+		providerName := h.providerFor(model)
+		e, kp, err := h.embedderFor(providerName, model)
+		if err != nil {
+			return nil, err
+		}
+
+		req := provider.EmbedRequest{Model: model, Inputs: []string{text}}
+		if v := h.cachedEmbeddings(ctx, providerName, req)[0]; v != nil {
+			return v, nil
+		}
+
+		start := time.Now()
+		rec := newRecord(ctx, model, start)
+		rec.Provider = providerName
+		defer func() { h.finishRequest(ctx, "SemanticCache", newRequestID(), text, "", &rec, start, err) }()
+
+		reservation, err := h.reserveTokens(ctx, h.countInputs(model, req.Inputs), func(metadata.MD) {})
+		if err != nil {
+			return nil, err
+		}
+		var usedTokens int32
+		var costUSD float64
+		defer func() { settleQuota(reservation, usedTokens, costUSD) }()
+
+		release, err := h.acquireSlot(ctx, providerName, resilience.PriorityNormal)
+		if err != nil {
+			return nil, err
+		}
+		defer release(0, resilience.OutcomeIgnore)
+
+		callStart := time.Now()
+		resp, cost, apiKey, err := h.embedUpstream(ctx, providerName, e, kp, req)
+		release(time.Since(callStart), limitOutcome(err))
+		rec.KeyFingerprint = resilience.Fingerprint(apiKey)
+		if err != nil {
+			return nil, err
+		}
+
+		usedTokens, costUSD = resp.PromptTokens, cost
+		rec.PromptTokens, rec.CostUSD = resp.PromptTokens, cost
+		h.storeEmbeddings(ctx, req, resp.Embeddings)
+		return resp.Embeddings[0], nil
+	}
+}
+
+// embedderFor returns the provider serving model's embeddings and its key
+// pool. Providers without embedding models fail with Unimplemented.
+func (h *Handler) embedderFor(providerName, model string) (provider.Embedder, *resilience.KeyPool, error) {
+	p, ok := h.providers[providerName]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown provider for model %q", model)
+	}
+	e, ok := p.(provider.Embedder)
+	if !ok {
+		return nil, nil, status.Errorf(codes.Unimplemented, "proxy: provider %q does not serve embeddings", providerName)
+	}
+	kp, ok := h.keyPools[providerName]
+	if !ok {
+		return nil, nil, fmt.Errorf("no key pool for provider %q", providerName)
+	}
+	return e, kp, nil
+}
+
+// cachedEmbeddings returns the cached embedding of each input of req, nil
+// for misses. Cache errors are logged and treated as misses.
+func (h *Handler) cachedEmbeddings(ctx context.Context, providerName string, req provider.EmbedRequest) [][]float32 {
+	vectors := make([][]float32, len(req.Inputs))
+	if h.embeddings != nil {
+		cached, err := h.embeddings.Get(ctx, req.Model, dimensionsOf(req), req.Inputs)
+		if err != nil {
+			log.Printf("[proxy] embedding cache lookup error (treating as miss): %v", err)
+		} else {
+			vectors = cached
+		}
+	}
+
+	for _, v := range vectors {
+		cacheStatus := "miss"
+		if v != nil {
+			cacheStatus = "hit"
+		}
+		metrics.EmbeddingInputsTotal.WithLabelValues(providerName, req.Model, cacheStatus).Inc()
+	}
+	return vectors
+}
+
+// storeEmbeddings adds freshly computed embeddings to the embedding cache.
+func (h *Handler) storeEmbeddings(ctx context.Context, req provider.EmbedRequest, vectors [][]float32) {
+	if h.embeddings == nil {
+		return
+	}
+	if err := h.embeddings.Set(ctx, req.Model, dimensionsOf(req), req.Inputs, vectors); err != nil {
+		log.Printf("[proxy] embedding cache store error: %v", err)
+	}
+}
+
+// embedUpstream sends req to the provider with the next key from the pool,
+// failing over to another key if the provider rejects it as invalid, and
+// records the call's tokens, estimated if the provider reports none, and
+// cost. Returns the cost and the key used.
+func (h *Handler) embedUpstream(ctx context.Context, providerName string, e provider.Embedder, kp *resilience.KeyPool, req provider.EmbedRequest) (provider.EmbedResponse, float64, string, error) {
+	apiKey, err := h.nextKey(providerName, kp)
+	if err != nil {
+		return provider.EmbedResponse{}, 0, "", fmt.Errorf("key pool: %w", err)
+	}
+	req.APIKey = apiKey
+
+	var resp provider.EmbedResponse
+	for attempt := 0; ; attempt++ {
+		err = h.execute(ctx, providerName, kp, req.APIKey, func(ctx context.Context) error {
+			var callErr error
+			resp, callErr = e.Embed(ctx, req)
+			return callErr
+		})
+		if !h.failoverKey(providerName, kp, &req.APIKey, err, attempt) {
+			break
+		}
+	}
+	if err != nil {
+		return provider.EmbedResponse{}, 0, req.APIKey, err
+	}
+	// Gemini does not report embedding usage; the estimate the quota was
+	// reserved with stands in, so the call is still charged.
+	if resp.PromptTokens == 0 {
+		resp.PromptTokens = int32(h.countInputs(req.Model, req.Inputs))
+	}
+
+	metrics.TokenUsageTotal.WithLabelValues(providerName, req.Model, "input").Add(float64(resp.PromptTokens))
+	cost := h.priceUsage(req.Model, pricing.Usage{PromptTokens: resp.PromptTokens})
+	recordCost(ctx, providerName, req.Model, cost)
+	h.recordKeyUsage(providerName, kp, req.APIKey, resp.PromptTokens, cost)
+	return resp, cost, req.APIKey, nil
+}
+
+// dimensionsOf returns the requested vector size, 0 for the model default.
+func dimensionsOf(req provider.EmbedRequest) int32 {
+	if req.Dimensions == nil {
+		return 0
+	}
+	return *req.Dimensions
+}
+
+// countInputs returns the tokens of inputs with model's tokenizer, for
+// quota reservations made before the provider reports usage.
+func (h *Handler) countInputs(model string, inputs []string) int64 {
+	tok, _, _ := h.tokenizerFor(model)
+	var tokens int64
+	for _, input := range inputs {
+		tokens += int64(tok.Count(input))
+	}
+	return tokens
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/tokenizer"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// fakeEmbedder returns a vector per input and reports tokens as its usage.
+type fakeEmbedder struct {
+	fakeProvider
+	tokens int32
+}
+
+func (f *fakeEmbedder) ValidateEmbed(provider.EmbedRequest) error { return nil }
+
+func (f *fakeEmbedder) Embed(_ context.Context, req provider.EmbedRequest) (provider.EmbedResponse, error) {
+	vectors := make([][]float32, len(req.Inputs))
+	for i := range vectors {
+		vectors[i] = []float32{1, 0}
+	}
+	return provider.EmbedResponse{Embeddings: vectors, PromptTokens: f.tokens}, nil
+}
+
+func TestEmbedUsage(t *testing.T) {
+	var e tokenizer.Estimator
+	inputs := []string{"the quick brown fox", "jumps over the lazy dog"}
+	estimate := int32(e.Count(inputs[0]) + e.Count(inputs[1]))
+
+	tests := []struct {
+		name     string
+		reported int32
+		want     int32
+	}{
+		{"reported usage", 7, 7},
+		{"no usage reported", 0, estimate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, sink, flush := newLedgerHandler(t, &fakeEmbedder{tokens: tt.reported})
+			resp, err := h.Embed(context.Background(), &pb.EmbedRequest{Model: "text-embedding-3-small", Inputs: inputs})
+			if err != nil {
+				t.Fatalf("Embed: %v", err)
+			}
+			flush()
+
+			if resp.PromptTokens != tt.want {
+				t.Errorf("response prompt tokens = %d, want %d", resp.PromptTokens, tt.want)
+			}
+			if len(sink.records) != 1 {
+				t.Fatalf("%d usage records, want 1", len(sink.records))
+			}
+			if rec := sink.records[0]; rec.PromptTokens != tt.want || rec.CostUSD <= 0 {
+				t.Errorf("recorded %d tokens costing %v, want %d tokens and a cost", rec.PromptTokens, rec.CostUSD, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbedRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		p      provider.Provider
+		inputs []string
+		want   codes.Code
+	}{
+		{"no inputs", &fakeEmbedder{}, nil, codes.InvalidArgument},
+		{"empty input", &fakeEmbedder{}, []string{"a", ""}, codes.InvalidArgument},
+		{"provider without embeddings", &fakeProvider{}, []string{"a"}, codes.Unimplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(tt.p)
+			_, err := h.Embed(context.Background(), &pb.EmbedRequest{Model: "text-embedding-3-small", Inputs: tt.inputs})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("Embed error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
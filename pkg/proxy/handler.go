@@ -5,25 +5,55 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	pb "github.com/abdhe/llm-inference-proxy/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/audit"
 	"github.com/abdhe/llm-inference-proxy/pkg/cache"
+	"github.com/abdhe/llm-inference-proxy/pkg/guardrail"
+	"github.com/abdhe/llm-inference-proxy/pkg/ledger"
 	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/models"
+	"github.com/abdhe/llm-inference-proxy/pkg/pricing"
 	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/quota"
 	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	"github.com/abdhe/llm-inference-proxy/pkg/session"
+	"github.com/abdhe/llm-inference-proxy/pkg/tokenizer"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
 )
 
 // Handler implements the gRPC InferenceServiceServer.
 type Handler struct {
 	pb.UnimplementedInferenceServiceServer
 
-	providers      map[string]provider.Provider // model-prefix → provider
-	keyPools       map[string]*resilience.KeyPool
+	providers       map[string]provider.Provider // model-prefix → provider
+	keyPools        map[string]*resilience.KeyPool
 	circuitBreakers map[string]*resilience.CircuitBreaker
-	semanticCache  *cache.SemanticCache
-	retryCfg       resilience.RetryConfig
-	requestTimeout time.Duration
+	semanticCache   *cache.SemanticCache
+	embeddings      *cache.EmbeddingCache
+	models          *models.Registry
+	tokenizers      *tokenizer.Set
+	contextPolicy   string
+	retryCfg        resilience.RetryConfig
+	hedgeCfg        resilience.HedgeConfig
+	hedgeBudget     *resilience.HedgeBudget
+	limiters        map[string]*resilience.ConcurrencyLimiter
+	globalLimiter   *resilience.ConcurrencyLimiter
+	quota           *quota.Enforcer
+	prices          *pricing.Table
+	ledger          *ledger.Ledger
+	audit           *audit.Logger
+	pii             *guardrail.PIIScanner
+	output          *guardrail.OutputPolicy
+	injection       *guardrail.InjectionDetector
+	sessions        session.Store
+	summary         SummaryConfig
+	batches         *batchRunner
+	requestTimeout  time.Duration
 }
 
 // Config holds the handler configuration.
@@ -32,7 +62,24 @@ type Config struct {
 	KeyPools        map[string]*resilience.KeyPool
 	CircuitBreakers map[string]*resilience.CircuitBreaker
 	SemanticCache   *cache.SemanticCache
+	EmbeddingCache  *cache.EmbeddingCache // Exact-match cache for the Embed RPC (optional)
+	Models          *models.Registry      // Context windows and output limits (default: built-in registry)
+	Tokenizers      *tokenizer.Set        // BPE encodings for pre-flight token counts (default: estimates only)
+	ContextOverflow string                // ContextReject or ContextTruncate (default: ContextReject)
 	RetryConfig     resilience.RetryConfig
+	HedgeConfig     resilience.HedgeConfig
+	Limiters        map[string]*resilience.ConcurrencyLimiter // Per-provider adaptive limiters
+	GlobalLimiter   *resilience.ConcurrencyLimiter            // Global in-flight cap (optional)
+	Quota           *quota.Enforcer                           // Per-tenant quotas (optional)
+	Prices          *pricing.Table                            // Model prices for cost accounting (optional)
+	Ledger          *ledger.Ledger                            // Durable usage ledger (optional)
+	Audit           *audit.Logger                             // Prompt / response audit log (optional)
+	PII             *guardrail.PIIScanner                     // PII redaction before prompts leave the proxy (optional)
+	Output          *guardrail.OutputPolicy                   // Checks on provider responses (optional)
+	Injection       *guardrail.InjectionDetector              // Prompt injection screening (optional)
+	Sessions        session.Store                             // Chat session and conversation store (default: in memory, 1h TTL)
+	Summary         SummaryConfig                             // Summaries of long conversations (optional)
+	Batch           BatchConfig                               // Asynchronous batch jobs (optional)
 	RequestTimeout  time.Duration
 }
 
@@ -41,51 +88,168 @@ func NewHandler(cfg Config) *Handler {
 	if cfg.RequestTimeout == 0 {
 		cfg.RequestTimeout = 30 * time.Second
 	}
+	if cfg.Models == nil {
+		cfg.Models = models.DefaultRegistry()
+	}
+	if cfg.ContextOverflow == "" {
+		cfg.ContextOverflow = ContextReject
+	}
+	if cfg.Sessions == nil {
+		cfg.Sessions = session.NewMemoryStore(time.Hour)
+	}
+	if cfg.Summary.Threshold == 0 {
+		cfg.Summary.Threshold = 0.8
+	}
+	if cfg.Summary.KeepTurns == 0 {
+		cfg.Summary.KeepTurns = 4
+	}
 	return &Handler{
 		providers:       cfg.Providers,
 		keyPools:        cfg.KeyPools,
 		circuitBreakers: cfg.CircuitBreakers,
 		semanticCache:   cfg.SemanticCache,
+		embeddings:      cfg.EmbeddingCache,
+		models:          cfg.Models,
+		tokenizers:      cfg.Tokenizers,
+		contextPolicy:   cfg.ContextOverflow,
 		retryCfg:        cfg.RetryConfig,
+		hedgeCfg:        cfg.HedgeConfig,
+		hedgeBudget:     resilience.NewHedgeBudget(cfg.HedgeConfig.MaxPercent),
+		limiters:        cfg.Limiters,
+		globalLimiter:   cfg.GlobalLimiter,
+		quota:           cfg.Quota,
+		prices:          cfg.Prices,
+		ledger:          cfg.Ledger,
+		audit:           cfg.Audit,
+		pii:             cfg.PII,
+		output:          cfg.Output,
+		injection:       cfg.Injection,
+		sessions:        cfg.Sessions,
+		summary:         cfg.Summary,
+		batches:         newBatchRunner(cfg.Batch),
 		requestTimeout:  cfg.RequestTimeout,
 	}
 }
 
-// Infer handles a unary inference request.
+// Infer handles a unary inference request. A request in a conversation
+// is answered after its earlier turns and saved with the reply.
 func (h *Handler) Infer(ctx context.Context, req *pb.InferenceRequest) (*pb.InferenceResponse, error) {
+	if req.GetConversationId() == "" {
+		_, _, out, err := h.inferReply(ctx, "Infer", req, nil)
+		return out, err
+	}
+
+	conv, err := h.openConversation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	prompt, reply, out, err := h.inferReply(ctx, "Infer", req, conv)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.saveTurn(ctx, conv, req.Model, prompt, reply); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// inferReply answers req, after the earlier turns of conv if it is in a
+// conversation, with a single response. Like streamReply, it also returns the prompt as sent to
+// the provider and the response text as the provider returned it.
+func (h *Handler) inferReply(ctx context.Context, method string, req *pb.InferenceRequest, conv *session.Session) (prompt, reply string, _ *pb.InferenceResponse, err error) {
 	start := time.Now()
 	metrics.ActiveRequests.Inc()
 	defer metrics.ActiveRequests.Dec()
 
+	// Aliases are resolved first so that limits, cache, metrics and usage
+	// records all see the model's own name.
+	req.Model = h.models.Resolve(req.Model)
+	rec := newRecord(ctx, req.Model, start)
+	prompt = req.Prompt
+	requestID := newRequestID()
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID))
+	defer func() { h.finishRequest(ctx, method, requestID, prompt, reply, &rec, start, err) }()
+
+	priority := requestPriority(req.GetPriority())
+	releaseGlobal, err := h.acquireGlobal(ctx, priority)
+	if err != nil {
+		return prompt, reply, nil, err
+	}
+	defer releaseGlobal(0, resilience.OutcomeIgnore)
+
 	// Apply timeout
 	ctx, cancel := context.WithTimeout(ctx, h.requestTimeout)
 	defer cancel()
 
-	providerName := resolveProvider(req.Model)
+	providerName := h.providerFor(req.Model)
+	rec.Provider = providerName
+	if err := authorize(ctx, providerName, req.Model); err != nil {
+		return prompt, reply, nil, err
+	}
+	format, err := parseResponseFormat(req.ResponseFormat)
+	if err != nil {
+		return prompt, reply, nil, err
+	}
+	provReq, err := requestParams(req, format)
+	if err != nil {
+		return prompt, reply, nil, err
+	}
+	if err := h.validateParams(providerName, provReq); err != nil {
+		return prompt, reply, nil, err
+	}
+
+	// PII is redacted before the cache or any provider sees the prompt.
+	var redaction *guardrail.Redaction
+	prompt, redaction, err = h.redactPrompt(prompt, conv)
+	if err != nil {
+		return prompt, reply, nil, err
+	}
+	if err := h.screenInjection(ctx, prompt, func(md metadata.MD) { grpc.SetHeader(ctx, md) }); err != nil {
+		return prompt, reply, nil, err
+	}
+	// A conversation due for a summary is only summarized once the request
+	// is admitted, and fitted to the context window after that.
+	history, split := historyOf(conv), h.summarySplit(conv, req)
+	if split == 0 {
+		history, prompt, err = h.fitPrompt(providerName, req, history, prompt, provReq.Parts, func(md metadata.MD) { grpc.SetHeader(ctx, md) })
+		if err != nil {
+			return prompt, reply, nil, err
+		}
+	}
+	scope := cacheScope(rec.Tenant, provReq, history)
 
 	// -------------------------------------------------------------------------
 	// Step 1: Semantic cache lookup
 	// -------------------------------------------------------------------------
-	if h.semanticCache != nil {
+	if h.semanticCache != nil && !wantsCandidates(req) {
 		metrics.CacheLookupsTotal.Inc()
-		cacheResult, err := h.semanticCache.Lookup(ctx, req.Prompt)
+		cacheResult, err := h.semanticCache.Lookup(ctx, prompt, scope)
 		if err != nil {
 			log.Printf("[proxy] cache lookup error: %v", err)
 		}
 
-		if cacheResult.Hit {
+		if cacheResult.Hit && format.accepts(cacheResult.Response.Text) {
+			if v := h.output.Check(cacheResult.Response.Text); v != nil {
+				return prompt, reply, nil, outputViolation(v)
+			}
 			metrics.RecordCacheLookup(true)
 			metrics.RequestsTotal.WithLabelValues("cache_hit").Inc()
 
 			latency := time.Since(start)
 			metrics.RequestLatency.WithLabelValues(providerName, req.Model, "hit").Observe(latency.Seconds())
-
-			return &pb.InferenceResponse{
-				Text:         cacheResult.Response.Text,
+			h.recordSavedCost(ctx, providerName, req.Model, cacheResult.Response)
+			rec.CacheStatus = "hit"
+			reply = cacheResult.Response.Text
+			rec.PromptTokens = cacheResult.Response.PromptTokens
+			rec.OutputTokens = cacheResult.Response.OutputTokens
+
+			return prompt, reply, &pb.InferenceResponse{
+				Text:         redaction.Restore(cacheResult.Response.Text),
 				PromptTokens: cacheResult.Response.PromptTokens,
 				OutputTokens: cacheResult.Response.OutputTokens,
 				CacheHit:     true,
 				LatencyMs:    float64(latency.Milliseconds()),
+				Metadata:     responseMetadata(requestID, providerName, req.Model, cacheResult.Response.Metadata),
 			}, nil
 		}
 		metrics.RecordCacheLookup(false)
@@ -96,68 +260,66 @@ func (h *Handler) Infer(ctx context.Context, req *pb.InferenceRequest) (*pb.Infe
 	// -------------------------------------------------------------------------
 	p, ok := h.providers[providerName]
 	if !ok {
-		return nil, fmt.Errorf("unknown provider for model %q", req.Model)
+		return prompt, reply, nil, fmt.Errorf("unknown provider for model %q", req.Model)
 	}
 
 	// -------------------------------------------------------------------------
-	// Step 3: Get API key from pool
+	// Step 3: Check tenant quota, acquire a concurrency slot, then get API
+	// key from pool
 	// -------------------------------------------------------------------------
 	kp, ok := h.keyPools[providerName]
 	if !ok {
-		return nil, fmt.Errorf("no key pool for provider %q", providerName)
+		return prompt, reply, nil, fmt.Errorf("no key pool for provider %q", providerName)
 	}
 
-	apiKey, err := kp.Next()
+	reservation, err := h.reserveQuota(ctx, req, func(md metadata.MD) { grpc.SetTrailer(ctx, md) })
 	if err != nil {
-		return nil, fmt.Errorf("key pool: %w", err)
+		return prompt, reply, nil, err
+	}
+	var usedTokens int32
+	var costUSD float64
+	defer func() { settleQuota(reservation, usedTokens, costUSD) }()
+
+	// The summary call takes a concurrency slot of its own, so it is made
+	// before this request waits for one.
+	if split > 0 {
+		h.summarize(ctx, conv, split)
+		history, prompt, err = h.fitPrompt(providerName, req, historyOf(conv), prompt, provReq.Parts, func(md metadata.MD) { grpc.SetHeader(ctx, md) })
+		if err != nil {
+			return prompt, reply, nil, err
+		}
+	}
+
+	release, err := h.acquireSlot(ctx, providerName, priority)
+	if err != nil {
+		return prompt, reply, nil, err
+	}
+	defer release(0, resilience.OutcomeIgnore)
+
+	apiKey, err := h.nextKey(providerName, kp)
+	if err != nil {
+		return prompt, reply, nil, fmt.Errorf("key pool: %w", err)
 	}
 
 	// -------------------------------------------------------------------------
-	// Step 4: Execute with circuit breaker + retry
+	// Step 4: Execute with circuit breaker + retry (hedged if enabled)
 	// -------------------------------------------------------------------------
-	provReq := provider.Request{
-		Model:       req.Model,
-		Prompt:      req.Prompt,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		APIKey:      apiKey,
-	}
-
-	var resp provider.Response
-
-	cb := h.circuitBreakers[providerName]
-	if cb == nil {
-		// No circuit breaker — execute directly with retry
-		err = resilience.Retry(ctx, h.retryCfg, func(ctx context.Context) error {
-			var retryErr error
-			resp, retryErr = p.Infer(ctx, provReq)
-			return retryErr
-		})
-	} else {
-		// Circuit breaker wrapping retry
-		err = cb.Execute(func() error {
-			return resilience.Retry(ctx, h.retryCfg, func(ctx context.Context) error {
-				var retryErr error
-				resp, retryErr = p.Infer(ctx, provReq)
-				return retryErr
-			})
-		})
+	provReq.History = history
+	provReq.Prompt = prompt
+	provReq.APIKey = apiKey
 
-		// Update circuit breaker metric
-		metrics.CircuitBreakerState.WithLabelValues(providerName).Set(float64(cb.State()))
-	}
+	callStart := time.Now()
+	result, formatErrs := h.inferValidated(ctx, providerName, p, kp, provReq, format)
+	release(time.Since(callStart), limitOutcome(result.err))
 
+	rec.KeyFingerprint = resilience.Fingerprint(result.apiKey)
+	resp, err := result.resp, result.err
 	if err != nil {
-		// Mark key rate-limited if it's a 429
-		if resilience.IsServerError(err) {
-			kp.MarkRateLimited(apiKey, time.Now().Add(60*time.Second))
-		}
-
 		metrics.RequestsTotal.WithLabelValues("error").Inc()
 		latency := time.Since(start)
 		metrics.RequestLatency.WithLabelValues(providerName, req.Model, "error").Observe(latency.Seconds())
 
-		return nil, fmt.Errorf("inference failed: %w", err)
+		return prompt, reply, nil, fmt.Errorf("inference failed: %w", err)
 	}
 
 	// -------------------------------------------------------------------------
@@ -167,55 +329,166 @@ func (h *Handler) Infer(ctx context.Context, req *pb.InferenceRequest) (*pb.Infe
 	metrics.RequestLatency.WithLabelValues(providerName, req.Model, "miss").Observe(latency.Seconds())
 	metrics.TokenUsageTotal.WithLabelValues(providerName, req.Model, "input").Add(float64(resp.PromptTokens))
 	metrics.TokenUsageTotal.WithLabelValues(providerName, req.Model, "output").Add(float64(resp.OutputTokens))
+	recordFinishReason(providerName, resp.Metadata)
+	usedTokens = resp.PromptTokens + resp.OutputTokens
+	costUSD = h.priceUsage(req.Model, usageOf(resp))
+	recordCost(ctx, providerName, req.Model, costUSD)
+	h.recordKeyUsage(providerName, kp, result.apiKey, usedTokens, costUSD)
+	rec.PromptTokens, rec.CachedTokens, rec.OutputTokens = resp.PromptTokens, resp.CachedTokens, resp.OutputTokens
+	rec.CostUSD = costUSD
+	reply = resp.Text
+
+	// The response was paid for either way; an invalid or violating one is
+	// not returned or cached.
+	if formatErrs != nil {
+		return prompt, reply, nil, responseFormatError(formatErrs)
+	}
+	if v := h.checkOutput(resp); v != nil {
+		return prompt, reply, nil, outputViolation(v)
+	}
 	metrics.RequestsTotal.WithLabelValues("success").Inc()
 
 	// -------------------------------------------------------------------------
 	// Step 6: Store in semantic cache (async, non-blocking)
 	// -------------------------------------------------------------------------
-	if h.semanticCache != nil {
-		go h.semanticCache.Store(context.Background(), req.Prompt, resp)
+	if h.semanticCache != nil && !wantsCandidates(req) {
+		go h.semanticCache.Store(context.Background(), prompt, scope, resp)
 	}
 
-	return &pb.InferenceResponse{
-		Text:         resp.Text,
+	out := &pb.InferenceResponse{
+		Text:         redaction.Restore(resp.Text),
 		PromptTokens: resp.PromptTokens,
 		OutputTokens: resp.OutputTokens,
 		CacheHit:     false,
 		LatencyMs:    float64(latency.Milliseconds()),
-	}, nil
+		CostUsd:      costUSD,
+		Metadata:     responseMetadata(requestID, providerName, req.Model, resp.Metadata),
+	}
+	if wantsCandidates(req) {
+		out.Candidates = candidatesOf(resp, redaction)
+	}
+	return prompt, reply, out, nil
 }
 
-// InferStream handles a server-side streaming inference request.
+// InferStream handles a server-side streaming inference request. A request
+// in a conversation is answered after its earlier turns and saved with the
+// reply once the stream completes.
 func (h *Handler) InferStream(req *pb.InferenceRequest, stream pb.InferenceService_InferStreamServer) error {
+	ctx := stream.Context()
+	if req.GetConversationId() == "" {
+		_, _, err := h.streamReply(ctx, "InferStream", req, nil, stream)
+		return err
+	}
+
+	conv, err := h.openConversation(ctx, req)
+	if err != nil {
+		return err
+	}
+	prompt, reply, err := h.streamReply(ctx, "InferStream", req, conv, stream)
+	if err != nil {
+		return err
+	}
+	return h.saveTurn(ctx, conv, req.Model, prompt, reply)
+}
+
+// replyStream is where streamReply sends a reply: an InferStream stream,
+// or one turn of a Chat stream.
+type replyStream interface {
+	Send(*pb.StreamChunk) error
+	SetHeader(metadata.MD) error
+	SetTrailer(metadata.MD)
+}
+
+// streamReply answers req, after the earlier turns of conv if it is in a
+// conversation or chat session, with a stream of chunks. It returns the prompt as sent to the provider, after
+// PII redaction and context fitting, and the first candidate's text as the
+// provider returned it; on error the text is what was received before it.
+func (h *Handler) streamReply(ctx context.Context, method string, req *pb.InferenceRequest, conv *session.Session, stream replyStream) (prompt, reply string, err error) {
 	start := time.Now()
 	metrics.ActiveRequests.Inc()
 	defer metrics.ActiveRequests.Dec()
 
-	ctx := stream.Context()
+	// Aliases are resolved first so that limits, cache, metrics and usage
+	// records all see the model's own name.
+	req.Model = h.models.Resolve(req.Model)
+	rec := newRecord(ctx, req.Model, start)
+	prompt = req.Prompt
+	requestID := newRequestID()
+	stream.SetHeader(metadata.Pairs(requestIDHeader, requestID))
+	defer func() { h.finishRequest(ctx, method, requestID, prompt, reply, &rec, start, err) }()
+	priority := requestPriority(req.GetPriority())
+	releaseGlobal, err := h.acquireGlobal(ctx, priority)
+	if err != nil {
+		return prompt, reply, err
+	}
+	defer releaseGlobal(0, resilience.OutcomeIgnore)
+
 	ctx, cancel := context.WithTimeout(ctx, h.requestTimeout)
 	defer cancel()
 
-	providerName := resolveProvider(req.Model)
+	providerName := h.providerFor(req.Model)
+	rec.Provider = providerName
+	if err := authorize(ctx, providerName, req.Model); err != nil {
+		return prompt, reply, err
+	}
+	format, err := parseResponseFormat(req.ResponseFormat)
+	if err != nil {
+		return prompt, reply, err
+	}
+	provReq, err := requestParams(req, format)
+	if err != nil {
+		return prompt, reply, err
+	}
+	if err := h.validateParams(providerName, provReq); err != nil {
+		return prompt, reply, err
+	}
+	var redaction *guardrail.Redaction
+	prompt, redaction, err = h.redactPrompt(prompt, conv)
+	if err != nil {
+		return prompt, reply, err
+	}
+	if err := h.screenInjection(ctx, prompt, func(md metadata.MD) { stream.SetHeader(md) }); err != nil {
+		return prompt, reply, err
+	}
+	// A conversation due for a summary is only summarized once the request
+	// is admitted, and fitted to the context window after that.
+	history, split := historyOf(conv), h.summarySplit(conv, req)
+	if split == 0 {
+		history, prompt, err = h.fitPrompt(providerName, req, history, prompt, provReq.Parts, func(md metadata.MD) { stream.SetHeader(md) })
+		if err != nil {
+			return prompt, reply, err
+		}
+	}
+	scope := cacheScope(rec.Tenant, provReq, history)
 
 	// -------------------------------------------------------------------------
 	// Step 1: Check cache (streaming requests can still return cached results)
 	// -------------------------------------------------------------------------
-	if h.semanticCache != nil {
+	if h.semanticCache != nil && !wantsCandidates(req) {
 		metrics.CacheLookupsTotal.Inc()
-		cacheResult, _ := h.semanticCache.Lookup(ctx, req.Prompt)
-		if cacheResult.Hit {
+		cacheResult, _ := h.semanticCache.Lookup(ctx, prompt, scope)
+		if cacheResult.Hit && format.accepts(cacheResult.Response.Text) {
+			if v := h.output.Check(cacheResult.Response.Text); v != nil {
+				return prompt, reply, outputViolation(v)
+			}
 			metrics.RecordCacheLookup(true)
 			metrics.RequestsTotal.WithLabelValues("cache_hit").Inc()
 
 			latency := time.Since(start)
 			metrics.RequestLatency.WithLabelValues(providerName, req.Model, "hit").Observe(latency.Seconds())
+			h.recordSavedCost(ctx, providerName, req.Model, cacheResult.Response)
+			rec.CacheStatus = "hit"
+			reply = cacheResult.Response.Text
+			rec.PromptTokens = cacheResult.Response.PromptTokens
+			rec.OutputTokens = cacheResult.Response.OutputTokens
 
 			// Send the full cached response as a single chunk
-			return stream.Send(&pb.StreamChunk{
-				Text:         cacheResult.Response.Text,
+			return prompt, reply, stream.Send(&pb.StreamChunk{
+				Text:         redaction.Restore(cacheResult.Response.Text),
 				Done:         true,
 				PromptTokens: cacheResult.Response.PromptTokens,
 				OutputTokens: cacheResult.Response.OutputTokens,
+				Metadata:     responseMetadata(requestID, providerName, req.Model, cacheResult.Response.Metadata),
 			})
 		}
 		metrics.RecordCacheLookup(false)
@@ -226,60 +499,205 @@ func (h *Handler) InferStream(req *pb.InferenceRequest, stream pb.InferenceServi
 	// -------------------------------------------------------------------------
 	p, ok := h.providers[providerName]
 	if !ok {
-		return fmt.Errorf("unknown provider for model %q", req.Model)
+		return prompt, reply, fmt.Errorf("unknown provider for model %q", req.Model)
 	}
 
 	kp, ok := h.keyPools[providerName]
 	if !ok {
-		return fmt.Errorf("no key pool for provider %q", providerName)
+		return prompt, reply, fmt.Errorf("no key pool for provider %q", providerName)
 	}
 
-	apiKey, err := kp.Next()
+	reservation, err := h.reserveQuota(ctx, req, stream.SetTrailer)
 	if err != nil {
-		return fmt.Errorf("key pool: %w", err)
+		return prompt, reply, err
+	}
+	var usedTokens int32
+	var costUSD float64
+	defer func() { settleQuota(reservation, usedTokens, costUSD) }()
+
+	// The summary call takes a concurrency slot of its own, so it is made
+	// before this request waits for one.
+	if split > 0 {
+		h.summarize(ctx, conv, split)
+		history, prompt, err = h.fitPrompt(providerName, req, historyOf(conv), prompt, provReq.Parts, func(md metadata.MD) { stream.SetHeader(md) })
+		if err != nil {
+			return prompt, reply, err
+		}
 	}
 
-	provReq := provider.Request{
-		Model:       req.Model,
-		Prompt:      req.Prompt,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		APIKey:      apiKey,
+	release, err := h.acquireSlot(ctx, providerName, priority)
+	if err != nil {
+		return prompt, reply, err
 	}
+	defer release(0, resilience.OutcomeIgnore)
 
-	// -------------------------------------------------------------------------
-	// Step 3: Stream from provider
-	// -------------------------------------------------------------------------
-	chunks, err := p.InferStream(ctx, provReq)
+	apiKey, err := h.nextKey(providerName, kp)
 	if err != nil {
-		metrics.RequestsTotal.WithLabelValues("error").Inc()
-		return fmt.Errorf("stream inference failed: %w", err)
+		return prompt, reply, fmt.Errorf("key pool: %w", err)
 	}
 
-	var fullText string
-	var promptTokens, outputTokens int32
+	provReq.History = history
+	provReq.Prompt = prompt
+	provReq.APIKey = apiKey
 
-	for chunk := range chunks {
+	// -------------------------------------------------------------------------
+	// Step 3: Stream from provider (hedged on first chunk if enabled)
+	// -------------------------------------------------------------------------
+	callStart := time.Now()
+	sr := h.streamHedged(ctx, providerName, p, kp, provReq)
+
+	// The slot is held for the whole stream; time to first chunk is the
+	// latency sample, provider errors drive the outcome.
+	firstChunkLatency := time.Since(callStart)
+	var streamErr error
+	defer func() { release(firstChunkLatency, limitOutcome(streamErr)) }()
+
+	rec.KeyFingerprint = resilience.Fingerprint(sr.apiKey)
+	if sr.err != nil {
+		streamErr = sr.err
+		metrics.RequestsTotal.WithLabelValues("error").Inc()
+		return prompt, reply, fmt.Errorf("stream inference failed: %w", sr.err)
+	}
+	defer sr.release()
+
+	var promptTokens, cachedTokens, outputTokens int32
+	var meta provider.Metadata
+
+	// Placeholders may be split across chunks; the restorer holds back a
+	// partial one until it is complete. Each candidate has its own guard
+	// and restorer; reply is the first candidate's.
+	candidates := map[int]*candidateStream{}
+	candidate := func(i int) *candidateStream {
+		cs, ok := candidates[i]
+		if !ok {
+			cs = &candidateStream{restorer: redaction.NewRestorer(), guard: h.output.NewStream()}
+			candidates[i] = cs
+		}
+		return cs
+	}
+	candidate(0)
+
+	// Providers report usage at the end of the stream. A stream cut short
+	// by a chunk error, the client or an output violation reports none and
+	// is charged an estimate of the prompt and the text streamed so far.
+	usage := func() pricing.Usage {
+		if promptTokens > 0 || outputTokens > 0 {
+			return pricing.Usage{PromptTokens: promptTokens, CachedTokens: cachedTokens, OutputTokens: outputTokens}
+		}
+		var streamed strings.Builder
+		for _, cs := range candidates {
+			streamed.WriteString(cs.text)
+		}
+		return h.estimateUsage(provReq, streamed.String())
+	}
+	defer func() {
+		u := usage()
+		usedTokens = u.PromptTokens + u.OutputTokens
+		costUSD = h.priceUsage(req.Model, u)
+		metrics.TokenUsageTotal.WithLabelValues(providerName, req.Model, "input").Add(float64(u.PromptTokens))
+		metrics.TokenUsageTotal.WithLabelValues(providerName, req.Model, "output").Add(float64(u.OutputTokens))
+		recordCost(ctx, providerName, req.Model, costUSD)
+		h.recordKeyUsage(providerName, kp, sr.apiKey, usedTokens, costUSD)
+		rec.PromptTokens, rec.CachedTokens, rec.OutputTokens = u.PromptTokens, u.CachedTokens, u.OutputTokens
+		rec.CostUSD = costUSD
+	}()
+
+	forward := func(chunk provider.StreamChunk) error {
 		if chunk.Err != nil {
+			streamErr = chunk.Err
 			return fmt.Errorf("stream chunk error: %w", chunk.Err)
 		}
 
-		fullText += chunk.Text
+		if chunk.Index == 0 {
+			reply += chunk.Text
+		}
 		if chunk.PromptTokens > 0 {
 			promptTokens = chunk.PromptTokens
 		}
+		if chunk.CachedTokens > 0 {
+			cachedTokens = chunk.CachedTokens
+		}
 		if chunk.OutputTokens > 0 {
 			outputTokens = chunk.OutputTokens
 		}
 
-		if err := stream.Send(&pb.StreamChunk{
-			Text:         chunk.Text,
-			Done:         chunk.Done,
-			PromptTokens: chunk.PromptTokens,
-			OutputTokens: chunk.OutputTokens,
-		}); err != nil {
+		// Output rules see the provider's text; placeholders are restored
+		// only in what passed.
+		cs := candidate(chunk.Index)
+		text, v := cs.write(chunk.Text)
+		if v != nil {
+			return outputViolation(v)
+		}
+		if chunk.Done {
+			// Release the other candidates' held-back text before the
+			// final chunk.
+			for i, other := range candidates {
+				if i == chunk.Index {
+					continue
+				}
+				tail, v := other.flush()
+				if v != nil {
+					return outputViolation(v)
+				}
+				if tail != "" {
+					if err := stream.Send(&pb.StreamChunk{Text: tail, CandidateIndex: int32(i)}); err != nil {
+						return fmt.Errorf("stream send: %w", err)
+					}
+				}
+			}
+			tail, v := cs.flush()
+			if v != nil {
+				return outputViolation(v)
+			}
+			text += tail
+		}
+
+		out := &pb.StreamChunk{
+			Text:           text,
+			Done:           chunk.Done,
+			PromptTokens:   chunk.PromptTokens,
+			OutputTokens:   chunk.OutputTokens,
+			CandidateIndex: int32(chunk.Index),
+			Logprobs:       logprobsOf(chunk.Logprobs),
+		}
+		if !chunk.Done {
+			out.FinishReason = finishReasons[chunk.FinishReason]
+		} else {
+			out.CostUsd = h.priceUsage(req.Model, usage())
+			meta = chunk.Metadata
+			out.Metadata = responseMetadata(requestID, providerName, req.Model, meta)
+		}
+		if err := stream.Send(out); err != nil {
 			return fmt.Errorf("stream send: %w", err)
 		}
+		return nil
+	}
+
+	if sr.first != nil {
+		if err := forward(*sr.first); err != nil {
+			return prompt, reply, err
+		}
+	}
+	for chunk := range sr.chunks {
+		if err := forward(chunk); err != nil {
+			return prompt, reply, err
+		}
+	}
+	// A stream cut short by cancellation is not a complete reply, even if
+	// the provider closed it without an error.
+	if err := ctx.Err(); err != nil {
+		return prompt, reply, err
+	}
+	for i, cs := range candidates {
+		tail, v := cs.flush()
+		if v != nil {
+			return prompt, reply, outputViolation(v)
+		}
+		if tail != "" {
+			if err := stream.Send(&pb.StreamChunk{Text: tail, CandidateIndex: int32(i)}); err != nil {
+				return prompt, reply, fmt.Errorf("stream send: %w", err)
+			}
+		}
 	}
 
 	// -------------------------------------------------------------------------
@@ -287,23 +705,35 @@ func (h *Handler) InferStream(req *pb.InferenceRequest, stream pb.InferenceServi
 	// -------------------------------------------------------------------------
 	latency := time.Since(start)
 	metrics.RequestLatency.WithLabelValues(providerName, req.Model, "miss").Observe(latency.Seconds())
-	metrics.TokenUsageTotal.WithLabelValues(providerName, req.Model, "input").Add(float64(promptTokens))
-	metrics.TokenUsageTotal.WithLabelValues(providerName, req.Model, "output").Add(float64(outputTokens))
 	metrics.RequestsTotal.WithLabelValues("success").Inc()
+	recordFinishReason(providerName, meta)
+
+	// Streams cannot be repaired once sent; an invalid response ends with
+	// the same error as a unary call and is not cached.
+	if errs := format.checkStream(candidates); errs != nil {
+		metrics.ResponseFormatTotal.WithLabelValues("invalid").Inc()
+		return prompt, reply, responseFormatError(errs)
+	}
+	if format != nil {
+		metrics.ResponseFormatTotal.WithLabelValues("valid").Inc()
+	}
 
 	// Cache the full assembled response
-	if h.semanticCache != nil && fullText != "" {
-		go h.semanticCache.Store(context.Background(), req.Prompt, provider.Response{
-			Text:         fullText,
+	if h.semanticCache != nil && reply != "" && !wantsCandidates(req) {
+		go h.semanticCache.Store(context.Background(), prompt, scope, provider.Response{
+			Text:         reply,
 			PromptTokens: promptTokens,
+			CachedTokens: cachedTokens,
 			OutputTokens: outputTokens,
+			Metadata:     meta,
 		})
 	}
 
-	return nil
+	return prompt, reply, nil
 }
 
-// resolveProvider maps a model name to a provider name.
+// resolveProvider guesses a model's provider from its name, for models
+// missing from the registry.
 func resolveProvider(model string) string {
 	// Simple prefix-based routing
 	switch {
@@ -311,6 +741,8 @@ func resolveProvider(model string) string {
 		return "openai"
 	case len(model) >= 6 && model[:6] == "gemini":
 		return "gemini"
+	case model == "text-embedding-004" || (len(model) >= 10 && model[:10] == "embedding-"):
+		return "gemini" // Gemini embedding models
 	case len(model) >= 7 && model[:7] == "claude-":
 		return "anthropic"
 	default:
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/ledger"
+	"github.com/abdhe/llm-inference-proxy/pkg/pricing"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	"github.com/abdhe/llm-inference-proxy/pkg/tokenizer"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// recordingSink keeps the ledger records written to it.
+type recordingSink struct {
+	mu      sync.Mutex
+	records []ledger.Record
+}
+
+func (s *recordingSink) Name() string { return "test" }
+func (s *recordingSink) Close() error { return nil }
+
+func (s *recordingSink) Write(_ context.Context, records []ledger.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// newLedgerHandler returns a handler like newTestHandler's, with default
+// prices, that writes its usage records to the returned sink; close
+// flushes them.
+func newLedgerHandler(t *testing.T, p provider.Provider) (h *Handler, sink *recordingSink, close func()) {
+	t.Helper()
+	sink = &recordingSink{}
+	l, err := ledger.New(t.TempDir(), sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = NewHandler(Config{
+		Providers: map[string]provider.Provider{"openai": p},
+		KeyPools:  map[string]*resilience.KeyPool{"openai": resilience.NewKeyPool([]string{"sk-test"})},
+		Prices:    pricing.DefaultTable(),
+		Ledger:    l,
+	})
+	return h, sink, func() { l.Close() }
+}
+
+// fakeReplyStream collects the chunks sent to the client. Sends after the
+// first failAfter fail, as if the client went away.
+type fakeReplyStream struct {
+	sent      []*pb.StreamChunk
+	failAfter int // 0 = never fail
+}
+
+func (s *fakeReplyStream) Send(c *pb.StreamChunk) error {
+	if s.failAfter > 0 && len(s.sent) >= s.failAfter {
+		return context.Canceled
+	}
+	s.sent = append(s.sent, c)
+	return nil
+}
+
+func (s *fakeReplyStream) SetHeader(metadata.MD) error { return nil }
+func (s *fakeReplyStream) SetTrailer(metadata.MD)      {}
+
+func TestStreamReplyUsage(t *testing.T) {
+	var e tokenizer.Estimator
+	const prompt = "count to three please"
+	text := func(s ...string) []provider.StreamChunk {
+		chunks := make([]provider.StreamChunk, len(s))
+		for i, t := range s {
+			chunks[i] = provider.StreamChunk{Text: t}
+		}
+		return chunks
+	}
+	done := provider.StreamChunk{Done: true}
+
+	tests := []struct {
+		name       string
+		chunks     []provider.StreamChunk
+		failAfter  int
+		wantErr    bool
+		wantPrompt int32
+		wantOutput int32
+	}{
+		{
+			name:       "reported usage",
+			chunks:     append(text("one", " two"), provider.StreamChunk{Done: true, PromptTokens: 40, OutputTokens: 30}),
+			wantPrompt: 40, wantOutput: 30,
+		},
+		{
+			name:       "no usage reported",
+			chunks:     append(text("one", " two", " three"), done),
+			wantPrompt: int32(e.Count(prompt)), wantOutput: int32(e.Count("one two three")),
+		},
+		{
+			name:       "client cancelled",
+			chunks:     append(text("one", " two", " three"), done),
+			failAfter:  1,
+			wantErr:    true,
+			wantPrompt: int32(e.Count(prompt)), wantOutput: int32(e.Count("one two")),
+		},
+		{
+			name:       "chunk error",
+			chunks:     append(text("one", " two"), provider.StreamChunk{Err: errors.New("reset")}),
+			wantErr:    true,
+			wantPrompt: int32(e.Count(prompt)), wantOutput: int32(e.Count("one two")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, sink, flush := newLedgerHandler(t, &fakeProvider{chunks: tt.chunks})
+			req := &pb.InferenceRequest{Model: "gpt-4o", Prompt: prompt}
+			_, _, err := h.streamReply(context.Background(), "InferStream", req, nil, &fakeReplyStream{failAfter: tt.failAfter})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("streamReply error = %v, want error %v", err, tt.wantErr)
+			}
+			flush()
+
+			if len(sink.records) != 1 {
+				t.Fatalf("%d usage records, want 1", len(sink.records))
+			}
+			rec := sink.records[0]
+			if rec.PromptTokens != tt.wantPrompt || rec.OutputTokens != tt.wantOutput {
+				t.Errorf("recorded %d prompt and %d output tokens, want %d and %d", rec.PromptTokens, rec.OutputTokens, tt.wantPrompt, tt.wantOutput)
+			}
+			if rec.CostUSD <= 0 {
+				t.Errorf("recorded cost %v, want the usage priced", rec.CostUSD)
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/pricing"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+)
+
+// callResult is the outcome of a single unary provider call.
+type callResult struct {
+	resp      provider.Response
+	apiKey    string
+	err       error
+	hedged    bool
+	cancelled bool // The call was cancelled before it completed
+}
+
+// streamResult is an opened provider stream along with its first chunk.
+type streamResult struct {
+	chunks <-chan provider.StreamChunk
+	first  *provider.StreamChunk // nil if the stream closed without any chunk
+	cancel context.CancelFunc    // cancels this stream only; may be nil
+	done   func()                // ends the stream's count on its key; may be nil
+	apiKey string
+	err    error
+	hedged bool
+
+	cancelled bool          // The stream was cancelled before its first chunk
+	latency   time.Duration // Time to open the stream and receive its first chunk
+}
+
+// ok reports whether the stream opened and produced a usable first chunk.
+func (r streamResult) ok() bool {
+	return r.err == nil && (r.first == nil || r.first.Err == nil)
+}
+
+// firstErr returns the error that opening the stream failed with, if any.
+func (r streamResult) firstErr() error {
+	if r.err == nil && r.first != nil {
+		return r.first.Err
+	}
+	return r.err
+}
+
+// discard cancels a losing stream and drains it, returning the text it
+// produced and the usage it reported, if any.
+func (r streamResult) discard() (string, pricing.Usage) {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	var text strings.Builder
+	var usage pricing.Usage
+	collect := func(c provider.StreamChunk) {
+		text.WriteString(c.Text)
+		if c.Done {
+			usage = pricing.Usage{PromptTokens: c.PromptTokens, CachedTokens: c.CachedTokens, OutputTokens: c.OutputTokens}
+		}
+	}
+	if r.first != nil {
+		collect(*r.first)
+	}
+	if r.chunks != nil {
+		for c := range r.chunks {
+			collect(c)
+		}
+	}
+	r.finish()
+	return text.String(), usage
+}
+
+// release cancels the stream and drains it so the provider goroutine exits.
+func (r streamResult) release() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	if r.chunks != nil {
+		go func() {
+			for range r.chunks {
+			}
+			r.finish()
+		}()
+		return
+	}
+	r.finish()
+}
+
+// finish takes the drained stream off its key's in-flight count.
+func (r streamResult) finish() {
+	if r.done != nil {
+		r.done()
+	}
+}
+
+// invoke executes a provider call through the circuit breaker and retry
+// policy, marking the key rate-limited on server errors. If the provider
+// rejects the key as invalid, the key is quarantined and the call is
+// immediately retried with the next key. Returns the key that was used last.
+func (h *Handler) invoke(ctx context.Context, providerName string, p provider.Provider, kp *resilience.KeyPool, req provider.Request) (provider.Response, string, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := h.invokeOnce(ctx, providerName, p, kp, req)
+		if !h.failoverKey(providerName, kp, &req.APIKey, err, attempt) {
+			return resp, req.APIKey, err
+		}
+	}
+}
+
+// invokeOnce executes a single provider call with one key.
+func (h *Handler) invokeOnce(ctx context.Context, providerName string, p provider.Provider, kp *resilience.KeyPool, req provider.Request) (provider.Response, error) {
+	var resp provider.Response
+	err := h.execute(ctx, providerName, kp, req.APIKey, func(ctx context.Context) error {
+		var callErr error
+		resp, callErr = p.Infer(ctx, req)
+		return callErr
+	})
+	return resp, err
+}
+
+// execute runs call, made with apiKey, through the provider's circuit
+// breaker and the retry policy, counting it in flight on the key and
+// marking the key rate-limited on server errors.
+func (h *Handler) execute(ctx context.Context, providerName string, kp *resilience.KeyPool, apiKey string, call func(ctx context.Context) error) error {
+	var err error
+	defer kp.Begin(apiKey)()
+
+	cb := h.circuitBreakers[providerName]
+	if cb == nil {
+		// No circuit breaker — execute directly with retry
+		err = resilience.Retry(ctx, h.retryCfg, call)
+	} else {
+		// Circuit breaker wrapping retry
+		err = cb.Execute(func() error {
+			return resilience.Retry(ctx, h.retryCfg, call)
+		})
+
+		// Update circuit breaker metric
+		metrics.CircuitBreakerState.WithLabelValues(providerName).Set(float64(cb.State()))
+	}
+
+	// Mark key rate-limited if it's a 429
+	if err != nil && !errors.Is(err, context.Canceled) && resilience.IsServerError(err) {
+		kp.MarkRateLimited(apiKey, time.Now().Add(60*time.Second))
+	}
+
+	return err
+}
+
+// inferHedged performs the unary call. If hedging is enabled and the call
+// has not completed after the hedge delay, a second call is sent with the
+// next key from the pool and whichever succeeds first wins.
+func (h *Handler) inferHedged(ctx context.Context, providerName string, p provider.Provider, kp *resilience.KeyPool, req provider.Request) callResult {
+	if !h.hedgeCfg.Enabled {
+		resp, apiKey, err := h.invoke(ctx, providerName, p, kp, req)
+		return callResult{resp: resp, apiKey: apiKey, err: err}
+	}
+	h.hedgeBudget.RecordRequest()
+
+	results := make(chan callResult, 2)
+	launch := func(r provider.Request, hedged bool, release resilience.ReleaseFunc) context.CancelFunc {
+		callCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			callStart := time.Now()
+			resp, apiKey, err := h.invoke(callCtx, providerName, p, kp, r)
+			release(time.Since(callStart), limitOutcome(err))
+			cancelled := err != nil && callCtx.Err() != nil
+			results <- callResult{resp: resp, apiKey: apiKey, err: err, hedged: hedged, cancelled: cancelled}
+		}()
+		return cancel
+	}
+
+	// The primary runs on the caller's limiter slot.
+	start := time.Now()
+	cancelPrimary := launch(req, false, noopRelease)
+	defer cancelPrimary()
+
+	timer := time.NewTimer(h.hedgeDelay(providerName, req.Model))
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r
+	case <-timer.C:
+	}
+
+	hedgeReq, releaseHedge, ok := h.prepareHedge(providerName, kp, req)
+	if !ok {
+		return <-results
+	}
+	cancelHedge := launch(hedgeReq, true, releaseHedge)
+	defer cancelHedge()
+
+	// The first success wins; the deferred cancels stop the loser, which
+	// is charged for once it returns.
+	winner := <-results
+	if winner.err != nil {
+		if second := <-results; second.err == nil {
+			winner = second
+		}
+	} else {
+		go func() {
+			loser := <-results
+			if loser.err != nil && !loser.cancelled {
+				return
+			}
+			usage := usageOf(loser.resp)
+			if usage.PromptTokens == 0 && usage.OutputTokens == 0 {
+				usage = h.estimateUsage(req, loser.resp.Text)
+			}
+			h.chargeHedgeLoser(ctx, providerName, kp, req.Model, loser.apiKey, usage, start)
+		}()
+	}
+	recordHedgeOutcome(providerName, winner.hedged, winner.err == nil)
+	return winner
+}
+
+// streamHedged opens the provider stream. If hedging is enabled and no first
+// chunk has arrived after the hedge delay, a second stream is opened with the
+// next key and whichever delivers its first chunk first is kept.
+func (h *Handler) streamHedged(ctx context.Context, providerName string, p provider.Provider, kp *resilience.KeyPool, req provider.Request) streamResult {
+	if !h.hedgeCfg.Enabled {
+		return h.openStream(ctx, providerName, p, kp, req)
+	}
+	h.hedgeBudget.RecordRequest()
+
+	results := make(chan streamResult, 2)
+	launch := func(r provider.Request, hedged bool) context.CancelFunc {
+		streamCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			openStart := time.Now()
+			sr := h.openStream(streamCtx, providerName, p, kp, r)
+			sr.latency = time.Since(openStart)
+			sr.cancelled = !sr.ok() && streamCtx.Err() != nil
+			sr.cancel = cancel
+			sr.hedged = hedged
+			results <- sr
+		}()
+		return cancel
+	}
+
+	start := time.Now()
+	cancelPrimary := launch(req, false)
+
+	timer := time.NewTimer(h.streamHedgeDelay(providerName, req.Model))
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r
+	case <-timer.C:
+	}
+
+	hedgeReq, releaseHedge, ok := h.prepareHedge(providerName, kp, req)
+	if !ok {
+		return <-results
+	}
+	cancelHedge := launch(hedgeReq, true)
+
+	// The hedge's limiter slot is held while both streams run; a winning
+	// hedge then continues on the caller's slot.
+	releaseSlot := func(primary, hedge streamResult) {
+		if primary.hedged {
+			hedge = primary
+		}
+		releaseHedge(hedge.latency, limitOutcome(hedge.firstErr()))
+	}
+
+	winner := <-results
+	if !winner.ok() {
+		second := <-results
+		releaseSlot(winner, second)
+		if second.ok() {
+			winner.release()
+			winner = second
+		} else {
+			second.release()
+		}
+		recordHedgeOutcome(providerName, winner.hedged, winner.ok())
+		return winner
+	}
+
+	// Cancel the slower stream, drain it in the background and charge for
+	// what it used.
+	if winner.hedged {
+		cancelPrimary()
+	} else {
+		cancelHedge()
+	}
+	go func() {
+		loser := <-results
+		output, usage := loser.discard()
+		releaseSlot(winner, loser)
+		if !loser.ok() && !loser.cancelled {
+			return
+		}
+		if usage.PromptTokens == 0 && usage.OutputTokens == 0 {
+			usage = h.estimateUsage(req, output)
+		}
+		h.chargeHedgeLoser(ctx, providerName, kp, req.Model, loser.apiKey, usage, start)
+	}()
+
+	recordHedgeOutcome(providerName, winner.hedged, true)
+	return winner
+}
+
+// openStream starts a provider stream and waits for its first chunk,
+// failing over to the next key if the provider rejects the key as invalid.
+func (h *Handler) openStream(ctx context.Context, providerName string, p provider.Provider, kp *resilience.KeyPool, req provider.Request) streamResult {
+	start := time.Now()
+	var chunks <-chan provider.StreamChunk
+	var err error
+	for attempt := 0; ; attempt++ {
+		chunks, err = p.InferStream(ctx, req)
+		if !h.failoverKey(providerName, kp, &req.APIKey, err, attempt) {
+			break
+		}
+	}
+	if err != nil {
+		return streamResult{apiKey: req.APIKey, err: err}
+	}
+
+	sr := streamResult{chunks: chunks, apiKey: req.APIKey, done: kp.Begin(req.APIKey)}
+	if first, ok := <-chunks; ok {
+		sr.first = &first
+		if first.Err == nil {
+			metrics.TimeToFirstChunk.WithLabelValues(providerName, req.Model).Observe(time.Since(start).Seconds())
+		}
+	}
+	return sr
+}
+
+// prepareHedge takes a provider limiter slot for the hedge request, if one
+// is free, checks the hedge budget and picks a key. The returned release
+// frees the slot.
+func (h *Handler) prepareHedge(providerName string, kp *resilience.KeyPool, req provider.Request) (provider.Request, resilience.ReleaseFunc, bool) {
+	release, ok := h.trySlot(providerName)
+	if !ok {
+		metrics.HedgeRequestsTotal.WithLabelValues(providerName, "at_limit").Inc()
+		return req, nil, false
+	}
+	if !h.hedgeBudget.Allow() {
+		release(0, resilience.OutcomeIgnore)
+		metrics.HedgeRequestsTotal.WithLabelValues(providerName, "budget_exhausted").Inc()
+		return req, nil, false
+	}
+
+	apiKey, err := h.nextKey(providerName, kp)
+	if err != nil {
+		release(0, resilience.OutcomeIgnore)
+		return req, nil, false
+	}
+
+	metrics.HedgeRequestsTotal.WithLabelValues(providerName, "sent").Inc()
+	req.APIKey = apiKey
+	return req, release, true
+}
+
+// chargeHedgeLoser records the usage of a hedged race's losing call, which
+// the provider bills although its reply is discarded, against its key, the
+// caller's quota and the usage ledger, with the outcome hedge_lost.
+func (h *Handler) chargeHedgeLoser(ctx context.Context, providerName string, kp *resilience.KeyPool, model, apiKey string, usage pricing.Usage, start time.Time) {
+	tokens := usage.PromptTokens + usage.OutputTokens
+	cost := h.priceUsage(model, usage)
+	if h.ledger != nil {
+		rec := newRecord(ctx, model, start)
+		rec.Method = "Hedge"
+		rec.Provider = providerName
+		rec.KeyFingerprint = resilience.Fingerprint(apiKey)
+		rec.PromptTokens, rec.CachedTokens, rec.OutputTokens = usage.PromptTokens, usage.CachedTokens, usage.OutputTokens
+		rec.CostUSD = cost
+		rec.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+		rec.Outcome = "hedge_lost"
+		h.ledger.Append(rec)
+	}
+
+	metrics.TokenUsageTotal.WithLabelValues(providerName, model, "input").Add(float64(usage.PromptTokens))
+	metrics.TokenUsageTotal.WithLabelValues(providerName, model, "output").Add(float64(usage.OutputTokens))
+	recordCost(ctx, providerName, model, cost)
+	h.recordKeyUsage(providerName, kp, apiKey, tokens, cost)
+	h.chargeQuota(ctx, tokens, cost)
+}
+
+// hedgeDelay returns how long to wait before hedging a unary call. It uses
+// the configured latency percentile when the histogram has enough samples.
+func (h *Handler) hedgeDelay(providerName, model string) time.Duration {
+	if h.hedgeCfg.Percentile > 0 {
+		if d, ok := metrics.LatencyQuantile(providerName, model, "miss", h.hedgeCfg.Percentile); ok {
+			return max(d, h.hedgeCfg.MinDelay)
+		}
+	}
+	return h.hedgeCfg.Delay
+}
+
+// streamHedgeDelay returns how long to wait for a stream's first chunk
+// before hedging, from the same percentile of the time-to-first-chunk
+// histogram.
+func (h *Handler) streamHedgeDelay(providerName, model string) time.Duration {
+	if h.hedgeCfg.Percentile > 0 {
+		if d, ok := metrics.FirstChunkQuantile(providerName, model, h.hedgeCfg.Percentile); ok {
+			return max(d, h.hedgeCfg.MinDelay)
+		}
+	}
+	return h.hedgeCfg.Delay
+}
+
+// recordHedgeOutcome records which request won a hedged race.
+func recordHedgeOutcome(providerName string, hedged, success bool) {
+	if !success {
+		return
+	}
+	if hedged {
+		metrics.HedgeRequestsTotal.WithLabelValues(providerName, "hedge_won").Inc()
+	} else {
+		metrics.HedgeRequestsTotal.WithLabelValues(providerName, "primary_won").Inc()
+	}
+}
@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/ledger"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/pricing"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	"github.com/abdhe/llm-inference-proxy/pkg/tokenizer"
+)
+
+// raceProvider holds each call until the test lets it answer. Call i
+// blocks until gates[i] is closed, then replies, or until its context is
+// done. The key of each call is sent on started as it begins.
+type raceProvider struct {
+	mu      sync.Mutex
+	calls   int
+	started chan string
+	gates   []chan struct{}
+}
+
+func newRaceProvider(calls int) *raceProvider {
+	p := &raceProvider{started: make(chan string, calls)}
+	for i := 0; i < calls; i++ {
+		p.gates = append(p.gates, make(chan struct{}))
+	}
+	return p
+}
+
+func (p *raceProvider) Name() string                    { return "race" }
+func (p *raceProvider) Validate(provider.Request) error { return nil }
+
+// begin counts a call and returns its gate.
+func (p *raceProvider) begin(req provider.Request) chan struct{} {
+	p.mu.Lock()
+	gate := p.gates[p.calls]
+	p.calls++
+	p.mu.Unlock()
+	p.started <- req.APIKey
+	return gate
+}
+
+func (p *raceProvider) Infer(ctx context.Context, req provider.Request) (provider.Response, error) {
+	select {
+	case <-p.begin(req):
+		return provider.Response{Text: "won", PromptTokens: 10, OutputTokens: 5}, nil
+	case <-ctx.Done():
+		return provider.Response{}, ctx.Err()
+	}
+}
+
+func (p *raceProvider) InferStream(ctx context.Context, req provider.Request) (<-chan provider.StreamChunk, error) {
+	gate := p.begin(req)
+	ch := make(chan provider.StreamChunk)
+	go func() {
+		defer close(ch)
+		chunks := []provider.StreamChunk{{Text: "won"}, {Done: true, PromptTokens: 10, OutputTokens: 5}}
+		select {
+		case <-gate:
+		case <-ctx.Done():
+			return
+		}
+		for _, c := range chunks {
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// newHedgeHandler returns a handler that hedges every call to p after
+// 5ms, on the other of two keys, under limiter lim.
+func newHedgeHandler(t *testing.T, p provider.Provider, lim *resilience.ConcurrencyLimiter) (h *Handler, sink *recordingSink, close func()) {
+	t.Helper()
+	sink = &recordingSink{}
+	l, err := ledger.New(t.TempDir(), sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = NewHandler(Config{
+		Providers:   map[string]provider.Provider{"openai": p},
+		KeyPools:    map[string]*resilience.KeyPool{"openai": resilience.NewKeyPool([]string{"sk-primary", "sk-hedge"})},
+		Prices:      pricing.DefaultTable(),
+		Ledger:      l,
+		HedgeConfig: resilience.HedgeConfig{Enabled: true, Delay: 5 * time.Millisecond, MaxPercent: 100},
+		Limiters:    map[string]*resilience.ConcurrencyLimiter{"openai": lim},
+	})
+	return h, sink, func() { l.Close() }
+}
+
+// counterValue returns the current value of c.
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	c.Write(&m)
+	return m.GetCounter().GetValue()
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestHedgeChargesLoser(t *testing.T) {
+	var e tokenizer.Estimator
+	const prompt = "tell me a story"
+
+	for _, stream := range []bool{false, true} {
+		name := "unary"
+		if stream {
+			name = "stream"
+		}
+		t.Run(name, func(t *testing.T) {
+			p := newRaceProvider(2)
+			lim := resilience.NewFixedLimiter(4)
+			h, sink, flush := newHedgeHandler(t, p, lim)
+			kp := h.keyPools["openai"]
+			apiKey, _ := kp.Next() // As the caller picks the primary's key
+			req := provider.Request{Model: "gpt-4o", Prompt: prompt, APIKey: apiKey}
+			charged := metrics.TokenUsageTotal.WithLabelValues("openai", "gpt-4o", "input")
+			before := counterValue(charged)
+
+			hedged := make(chan bool, 1)
+			go func() {
+				if stream {
+					sr := h.streamHedged(context.Background(), "openai", p, kp, req)
+					sr.release()
+					hedged <- sr.ok() && sr.hedged
+					return
+				}
+				r := h.inferHedged(context.Background(), "openai", p, kp, req)
+				hedged <- r.err == nil && r.hedged
+			}()
+
+			if key := <-p.started; key != "sk-primary" {
+				t.Fatalf("primary used %s", key)
+			}
+			if key := <-p.started; key != "sk-hedge" {
+				t.Fatalf("hedge used %s, want the next key", key)
+			}
+			// The primary runs on the caller's slot, which this test
+			// did not take; the hedge holds a slot of its own.
+			if got := lim.Stats().InFlight; got != 1 {
+				t.Errorf("in flight during the race = %d, want 1", got)
+			}
+
+			close(p.gates[1])
+			if !<-hedged {
+				t.Fatal("the hedge did not win")
+			}
+			waitFor(t, "the loser to be charged", func() bool {
+				return counterValue(charged) > before && lim.Stats().InFlight == 0
+			})
+			flush()
+
+			if len(sink.records) != 1 {
+				t.Fatalf("%d usage records, want 1", len(sink.records))
+			}
+			rec := sink.records[0]
+			if rec.Method != "Hedge" || rec.Outcome != "hedge_lost" || rec.KeyFingerprint != resilience.Fingerprint("sk-primary") {
+				t.Errorf("loser recorded as %s %s on key %s", rec.Method, rec.Outcome, rec.KeyFingerprint)
+			}
+			if want := int32(e.Count(prompt)); rec.PromptTokens != want || rec.CostUSD <= 0 {
+				t.Errorf("loser charged %d prompt tokens costing %v, want %d and a cost", rec.PromptTokens, rec.CostUSD, want)
+			}
+		})
+	}
+}
+
+func TestHedgeAtLimit(t *testing.T) {
+	p := newRaceProvider(2)
+	lim := resilience.NewFixedLimiter(1)
+	h, _, flush := newHedgeHandler(t, p, lim)
+	defer flush()
+
+	// The caller's own slot fills the limiter.
+	release, err := lim.Acquire(context.Background(), resilience.PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release(0, resilience.OutcomeIgnore)
+
+	atLimit := metrics.HedgeRequestsTotal.WithLabelValues("openai", "at_limit")
+	before := counterValue(atLimit)
+	done := make(chan callResult, 1)
+	kp := h.keyPools["openai"]
+	apiKey, _ := kp.Next()
+	req := provider.Request{Model: "gpt-4o", Prompt: "hi", APIKey: apiKey}
+	go func() { done <- h.inferHedged(context.Background(), "openai", p, kp, req) }()
+
+	<-p.started
+	waitFor(t, "the hedge to be skipped", func() bool { return counterValue(atLimit) > before })
+	close(p.gates[0])
+	if r := <-done; r.err != nil || r.hedged {
+		t.Errorf("inferHedged = hedged %v, %v, want the primary's reply", r.hedged, r.err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.calls != 1 {
+		t.Errorf("provider called %d times, want 1", p.calls)
+	}
+}
+
+func TestHedgeNotSent(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		budget  float64 // Max percent of traffic hedged
+		skipped string  // Hedge outcome counted for the skipped hedge, if any
+	}{
+		{"primary answers within the delay", time.Hour, 100, ""},
+		{"budget exhausted", 5 * time.Millisecond, 0, "budget_exhausted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newRaceProvider(2)
+			h, _, flush := newHedgeHandler(t, p, resilience.NewFixedLimiter(4))
+			defer flush()
+			h.hedgeCfg.Delay = tt.delay
+			h.hedgeBudget = resilience.NewHedgeBudget(tt.budget)
+			sent := metrics.HedgeRequestsTotal.WithLabelValues("openai", "sent")
+			sentBefore := counterValue(sent)
+			skipped := metrics.HedgeRequestsTotal.WithLabelValues("openai", tt.skipped)
+			skippedBefore := counterValue(skipped)
+
+			kp := h.keyPools["openai"]
+			apiKey, _ := kp.Next()
+			req := provider.Request{Model: "gpt-4o", Prompt: "hi", APIKey: apiKey}
+			done := make(chan callResult, 1)
+			go func() { done <- h.inferHedged(context.Background(), "openai", p, kp, req) }()
+
+			<-p.started
+			if tt.skipped != "" {
+				waitFor(t, "the hedge to be skipped", func() bool { return counterValue(skipped) > skippedBefore })
+			}
+			close(p.gates[0])
+			if r := <-done; r.err != nil || r.hedged {
+				t.Errorf("inferHedged = hedged %v, %v, want the primary's reply", r.hedged, r.err)
+			}
+			if got := counterValue(sent) - sentBefore; got != 0 {
+				t.Errorf("%v hedges sent, want none", got)
+			}
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.calls != 1 {
+				t.Errorf("provider called %d times, want 1", p.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/guardrail"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+)
+
+// InjectionReason is the ErrorInfo reason attached to prompts blocked as
+// likely injection attempts.
+const InjectionReason = "PROMPT_INJECTION"
+
+// screenInjection scores the prompt for injection attempts and applies the
+// tenant's thresholds: blocked prompts fail with InvalidArgument, flagged
+// ones get x-guardrail-injection-* response headers through setHeader.
+func (h *Handler) screenInjection(ctx context.Context, prompt string, setHeader func(metadata.MD)) error {
+	if h.injection == nil {
+		return nil
+	}
+
+	tenant := tenantOf(ctx)
+	v := h.injection.Evaluate(ctx, tenant, prompt)
+	signals := v.SignalList()
+	for _, s := range signals {
+		metrics.PromptInjectionSignalsTotal.WithLabelValues(s).Inc()
+	}
+	if v.Action == "" {
+		return nil
+	}
+	metrics.PromptInjectionTotal.WithLabelValues(v.Action).Inc()
+
+	score := strconv.FormatFloat(v.Score, 'f', 2, 64)
+	switch v.Action {
+	case guardrail.ActionBlock:
+		metrics.RequestsTotal.WithLabelValues("injection_blocked").Inc()
+		return errorInfoStatus(codes.InvalidArgument,
+			fmt.Sprintf("proxy: prompt rejected as a likely injection attempt (score %s)", score),
+			InjectionReason, map[string]string{"score": score, "signals": strings.Join(signals, ",")})
+	case guardrail.ActionFlag:
+		setHeader(metadata.Pairs(
+			"x-guardrail-injection-score", score,
+			"x-guardrail-injection-signals", strings.Join(signals, ","),
+		))
+	case guardrail.ActionLog:
+		log.Printf("[proxy] possible prompt injection from tenant %q (score %s, signals %v)", tenant, score, signals)
+	}
+	return nil
+}
+
+// Judge sends a classification prompt to model through the provider's key
+// pool, circuit breaker and retry policy. It is charged to the caller's
+// tenant like a request: see complete. It is meant to back a
+// guardrail.JudgeClassifier.
+func (h *Handler) Judge(model string) guardrail.JudgeFunc {
+	return func(ctx context.Context, prompt string) (string, error) {
+		return h.complete(ctx, "Judge", model, prompt, 8, true)
+	}
+}
+
+// complete sends an internal prompt to model through the provider's key
+// pool, circuit breaker and retry policy, and returns the response text.
+// The call is admitted against the provider's concurrency limit at normal
+// priority and, if admit is set, against the caller's tenant quotas;
+// otherwise it is part of a request already admitted and its usage is just
+// charged to them. It is recorded in the usage ledger and audit log under
+// method.
+func (h *Handler) complete(ctx context.Context, method, model, prompt string, maxTokens int32, admit bool) (reply string, err error) {
+	start := time.Now()
+	model = h.models.Resolve(model)
+	rec := newRecord(ctx, model, start)
+	defer func() { h.finishRequest(ctx, method, newRequestID(), prompt, reply, &rec, start, err) }()
+
+	providerName := h.providerFor(model)
+	rec.Provider = providerName
+	p, ok := h.providers[providerName]
+	if !ok {
+		return "", fmt.Errorf("unknown provider for model %q", model)
+	}
+	kp, ok := h.keyPools[providerName]
+	if !ok {
+		return "", fmt.Errorf("no key pool for provider %q", providerName)
+	}
+
+	var usedTokens int32
+	var costUSD float64
+	if admit {
+		reservation, err := h.reserveTokens(ctx, int64(maxTokens), func(metadata.MD) {})
+		if err != nil {
+			return "", err
+		}
+		defer func() { settleQuota(reservation, usedTokens, costUSD) }()
+	} else {
+		defer func() { h.chargeQuota(ctx, usedTokens, costUSD) }()
+	}
+
+	release, err := h.acquireSlot(ctx, providerName, resilience.PriorityNormal)
+	if err != nil {
+		return "", err
+	}
+	defer release(0, resilience.OutcomeIgnore)
+
+	apiKey, err := h.nextKey(providerName, kp)
+	if err != nil {
+		return "", fmt.Errorf("key pool: %w", err)
+	}
+	callStart := time.Now()
+	resp, apiKey, err := h.invoke(ctx, providerName, p, kp, provider.Request{
+		Model:     model,
+		Prompt:    prompt,
+		MaxTokens: maxTokens,
+		APIKey:    apiKey,
+	})
+	release(time.Since(callStart), limitOutcome(err))
+	rec.KeyFingerprint = resilience.Fingerprint(apiKey)
+	if err != nil {
+		return "", err
+	}
+
+	usedTokens = resp.PromptTokens + resp.OutputTokens
+	costUSD = h.priceUsage(model, usageOf(resp))
+	recordCost(ctx, providerName, model, costUSD)
+	h.recordKeyUsage(providerName, kp, apiKey, usedTokens, costUSD)
+	rec.PromptTokens, rec.CachedTokens, rec.OutputTokens = resp.PromptTokens, resp.CachedTokens, resp.OutputTokens
+	rec.CostUSD = costUSD
+	return resp.Text, nil
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+)
+
+// nextKey selects a key from the pool and counts the request against it.
+func (h *Handler) nextKey(providerName string, kp *resilience.KeyPool) (string, error) {
+	apiKey, err := kp.Next()
+	if err != nil {
+		return "", err
+	}
+
+	labels, _ := kp.Labels(apiKey)
+	metrics.KeyRequestsTotal.WithLabelValues(keyLabelValues(providerName, labels)...).Inc()
+	return apiKey, nil
+}
+
+// failoverKey handles an invalid-key failure: it quarantines the key and
+// swaps the next key into apiKey. Returns true if the call should be
+// retried. At most one failover per key in the pool is attempted.
+func (h *Handler) failoverKey(providerName string, kp *resilience.KeyPool, apiKey *string, err error, attempt int) bool {
+	code := resilience.AuthFailure(err)
+	if code == 0 {
+		return false
+	}
+
+	reason := fmt.Sprintf("%d %s", code, http.StatusText(code))
+	kp.Quarantine(*apiKey, reason)
+
+	labels, _ := kp.Labels(*apiKey)
+	metrics.KeyQuarantinesTotal.WithLabelValues(providerName, resilience.Fingerprint(*apiKey), labels.Label, reason).Inc()
+	log.Printf("[proxy] quarantined %s key %s (%s): %s", providerName, resilience.Fingerprint(*apiKey), labels.Label, reason)
+
+	if attempt+1 >= kp.Size() {
+		return false
+	}
+	next, nextErr := h.nextKey(providerName, kp)
+	if nextErr != nil {
+		return false
+	}
+	*apiKey = next
+	return true
+}
+
+// recordKeyUsage charges a completed call's tokens and cost to its key.
+func (h *Handler) recordKeyUsage(providerName string, kp *resilience.KeyPool, apiKey string, tokens int32, costUSD float64) {
+	kp.RecordUsage(apiKey, int(tokens), costUSD)
+
+	labels, _ := kp.Labels(apiKey)
+	metrics.KeyTokensTotal.WithLabelValues(keyLabelValues(providerName, labels)...).Add(float64(tokens))
+}
+
+// keyLabelValues returns metric label values for a key — never the raw key.
+func keyLabelValues(providerName string, labels resilience.KeyLabels) []string {
+	return []string{providerName, labels.Label, labels.Org, labels.Project, labels.Tier}
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+)
+
+func TestKeyInFlight(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		name := "unary"
+		if stream {
+			name = "stream"
+		}
+		t.Run(name, func(t *testing.T) {
+			p := newRaceProvider(1)
+			kp := resilience.NewKeyPool([]string{"sk-test"})
+			h := NewHandler(Config{
+				Providers: map[string]provider.Provider{"openai": p},
+				KeyPools:  map[string]*resilience.KeyPool{"openai": kp},
+			})
+			inFlight := func() int { return kp.Health()[0].InFlight }
+			req := provider.Request{Model: "gpt-4o", Prompt: "hi", APIKey: "sk-test"}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if stream {
+					sr := h.streamHedged(context.Background(), "openai", p, kp, req)
+					sr.release()
+					return
+				}
+				h.inferHedged(context.Background(), "openai", p, kp, req)
+			}()
+
+			<-p.started
+			waitFor(t, "the call to be counted", func() bool { return inFlight() == 1 })
+			close(p.gates[0])
+			<-done
+			waitFor(t, "the call to end", func() bool { return inFlight() == 0 })
+		})
+	}
+}
+
+// keyRejectingProvider answers like fakeProvider but fails calls made with
+// a rejected key as the providers do for an invalid key.
+type keyRejectingProvider struct {
+	fakeProvider
+	rejected map[string]int // Key → HTTP status
+}
+
+func (p *keyRejectingProvider) Infer(ctx context.Context, req provider.Request) (provider.Response, error) {
+	if code, ok := p.rejected[req.APIKey]; ok {
+		p.mu.Lock()
+		p.reqs = append(p.reqs, req)
+		p.mu.Unlock()
+		return provider.Response{}, fmt.Errorf("openai: API error %d: invalid api key", code)
+	}
+	return p.fakeProvider.Infer(ctx, req)
+}
+
+func TestKeyFailover(t *testing.T) {
+	tests := []struct {
+		name            string
+		rejected        map[string]int
+		wantErr         bool
+		wantKey         string
+		wantQuarantined map[string]string // Key → reason
+	}{
+		{"valid key", nil, false, "sk-a", map[string]string{}},
+		{"fails over to the next key", map[string]int{"sk-a": 401}, false, "sk-b", map[string]string{"sk-a": "401 Unauthorized"}},
+		{"every key rejected", map[string]int{"sk-a": 401, "sk-b": 403}, true, "sk-b", map[string]string{"sk-a": "401 Unauthorized", "sk-b": "403 Forbidden"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &keyRejectingProvider{fakeProvider: fakeProvider{replies: []string{"ok"}}, rejected: tt.rejected}
+			kp := resilience.NewKeyPool([]string{"sk-a", "sk-b"})
+			h := NewHandler(Config{
+				Providers: map[string]provider.Provider{"openai": p},
+				KeyPools:  map[string]*resilience.KeyPool{"openai": kp},
+			})
+			apiKey, err := h.nextKey("openai", kp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, used, err := h.invoke(context.Background(), "openai", p, kp, provider.Request{Model: "gpt-4o", Prompt: "hi", APIKey: apiKey})
+			if (err != nil) != tt.wantErr || used != tt.wantKey {
+				t.Errorf("invoke = key %s, error %v, want key %s, error %v", used, err, tt.wantKey, tt.wantErr)
+			}
+			quarantined := map[string]string{}
+			for _, key := range []string{"sk-a", "sk-b"} {
+				for _, kh := range kp.Health() {
+					if kh.Fingerprint == resilience.Fingerprint(key) && kh.Status == resilience.KeyQuarantined {
+						quarantined[key] = kh.Reason
+					}
+				}
+			}
+			if !reflect.DeepEqual(quarantined, tt.wantQuarantined) {
+				t.Errorf("quarantined = %v, want %v", quarantined, tt.wantQuarantined)
+			}
+		})
+	}
+}
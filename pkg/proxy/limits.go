@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// noopRelease is returned when no limiter applies.
+func noopRelease(time.Duration, resilience.LimitOutcome) {}
+
+// requestPriority maps a request's priority to a queueing class.
+func requestPriority(p pb.Priority) resilience.Priority {
+	switch p {
+	case pb.Priority_PRIORITY_HIGH:
+		return resilience.PriorityHigh
+	case pb.Priority_PRIORITY_LOW:
+		return resilience.PriorityLow
+	default:
+		return resilience.PriorityNormal
+	}
+}
+
+// acquireGlobal obtains a slot under the global in-flight cap. Returns a
+// ResourceExhausted status if the request is shed.
+func (h *Handler) acquireGlobal(ctx context.Context, priority resilience.Priority) (resilience.ReleaseFunc, error) {
+	if h.globalLimiter == nil {
+		return noopRelease, nil
+	}
+
+	release, err := h.globalLimiter.Acquire(ctx, priority)
+	if err == nil {
+		return release, nil
+	}
+	if !errors.Is(err, resilience.ErrQueueFull) && !errors.Is(err, resilience.ErrQueueTimeout) {
+		// Caller went away while queued
+		return nil, status.FromContextError(err).Err()
+	}
+	metrics.LoadShedTotal.WithLabelValues("global", "global_cap").Inc()
+	metrics.RequestsTotal.WithLabelValues("shed").Inc()
+	return nil, status.Error(codes.ResourceExhausted, "proxy: too many in-flight requests")
+}
+
+// acquireSlot obtains a concurrency slot for the provider, waiting in the
+// queue for the request's priority class if necessary. Returns a
+// ResourceExhausted status if the request is shed.
+func (h *Handler) acquireSlot(ctx context.Context, providerName string, priority resilience.Priority) (resilience.ReleaseFunc, error) {
+	lim := h.limiters[providerName]
+	if lim == nil {
+		return noopRelease, nil
+	}
+
+	waitStart := time.Now()
+	release, err := lim.Acquire(ctx, priority)
+	h.recordLimiter(providerName)
+
+	outcome := "admitted"
+	if err != nil {
+		outcome = "shed"
+	}
+	metrics.QueueWaitSeconds.WithLabelValues(providerName, priority.String(), outcome).Observe(time.Since(waitStart).Seconds())
+
+	if err == nil {
+		return func(latency time.Duration, outcome resilience.LimitOutcome) {
+			release(latency, outcome)
+			h.recordLimiter(providerName)
+		}, nil
+	}
+
+	switch {
+	case errors.Is(err, resilience.ErrQueueFull):
+		metrics.LoadShedTotal.WithLabelValues(providerName, "queue_full").Inc()
+	case errors.Is(err, resilience.ErrQueueTimeout):
+		metrics.LoadShedTotal.WithLabelValues(providerName, "queue_timeout").Inc()
+	default:
+		// Caller went away while queued
+		return nil, status.FromContextError(err).Err()
+	}
+	metrics.RequestsTotal.WithLabelValues("shed").Inc()
+	return nil, status.Errorf(codes.ResourceExhausted, "proxy: provider %q is at its concurrency limit (priority %s)", providerName, priority)
+}
+
+// trySlot obtains a provider concurrency slot for optional work, such as a
+// hedged request, only if one is free without queueing.
+func (h *Handler) trySlot(providerName string) (resilience.ReleaseFunc, bool) {
+	lim := h.limiters[providerName]
+	if lim == nil {
+		return noopRelease, true
+	}
+
+	release, ok := lim.TryAcquire()
+	if !ok {
+		return nil, false
+	}
+	h.recordLimiter(providerName)
+	return func(latency time.Duration, outcome resilience.LimitOutcome) {
+		release(latency, outcome)
+		h.recordLimiter(providerName)
+	}, true
+}
+
+// recordLimiter exports the provider limiter state as metrics.
+func (h *Handler) recordLimiter(providerName string) {
+	lim := h.limiters[providerName]
+	if lim == nil {
+		return
+	}
+
+	stats := lim.Stats()
+	metrics.ConcurrencyLimit.WithLabelValues(providerName).Set(float64(stats.Limit))
+	metrics.ConcurrencyInFlight.WithLabelValues(providerName).Set(float64(stats.InFlight))
+	for priority, n := range stats.Queued {
+		metrics.ConcurrencyQueued.WithLabelValues(providerName, priority.String()).Set(float64(n))
+	}
+}
+
+// limitOutcome classifies a provider error for the adaptive limiter.
+func limitOutcome(err error) resilience.LimitOutcome {
+	switch {
+	case err == nil:
+		return resilience.OutcomeSuccess
+	case resilience.IsRateLimited(err), errors.Is(err, context.DeadlineExceeded):
+		return resilience.OutcomeDropped
+	default:
+		return resilience.OutcomeIgnore
+	}
+}
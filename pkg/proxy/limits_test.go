@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+)
+
+func TestAcquireGlobal(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expiring, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		priority resilience.Priority
+		want     codes.Code
+		wantShed bool
+	}{
+		{"cancelled while queued", cancelled, resilience.PriorityNormal, codes.Canceled, false},
+		{"deadline while queued", expiring, resilience.PriorityNormal, codes.DeadlineExceeded, false},
+		{"queue timeout", context.Background(), resilience.PriorityHigh, codes.ResourceExhausted, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lim := resilience.NewConcurrencyLimiter(resilience.LimiterConfig{
+				InitialLimit: 1,
+				MaxQueue:     1,
+				Classes: map[resilience.Priority]resilience.QueueClass{
+					resilience.PriorityHigh:   {Weight: 1, Timeout: 10 * time.Millisecond},
+					resilience.PriorityNormal: {Weight: 1, Timeout: time.Minute},
+					resilience.PriorityLow:    {Weight: 1, Timeout: time.Minute},
+				},
+			})
+			h := NewHandler(Config{GlobalLimiter: lim})
+			held, err := h.acquireGlobal(context.Background(), resilience.PriorityNormal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer held(0, resilience.OutcomeIgnore)
+
+			shed := metrics.LoadShedTotal.WithLabelValues("global", "global_cap")
+			before := counterValue(shed)
+			_, err = h.acquireGlobal(tt.ctx, tt.priority)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("acquireGlobal error = %v, want %s", err, tt.want)
+			}
+			if got := counterValue(shed) > before; got != tt.wantShed {
+				t.Errorf("counted as shed = %v, want %v", got, tt.wantShed)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/models"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// ListModels returns the registered models the caller may use, on the
+// providers this proxy has configured, with their current price and the
+// health of their provider.
+func (h *Handler) ListModels(ctx context.Context, req *pb.ListModelsRequest) (*pb.ListModelsResponse, error) {
+	now := time.Now()
+	health := make(map[string]*pb.ModelHealth)
+	out := &pb.ListModelsResponse{}
+	for _, m := range h.models.List() {
+		if req.Provider != "" && m.Provider != req.Provider {
+			continue
+		}
+		if _, ok := h.providers[m.Provider]; !ok {
+			continue
+		}
+		if !allows(ctx, m.Provider, m.Name) {
+			continue
+		}
+
+		ph, ok := health[m.Provider]
+		if !ok {
+			ph = h.providerHealth(m.Provider)
+			health[m.Provider] = ph
+		}
+		info := &pb.ModelInfo{
+			Name:            m.Name,
+			Provider:        m.Provider,
+			Aliases:         m.Aliases,
+			ContextWindow:   int32(m.ContextWindow),
+			MaxOutputTokens: int32(m.MaxOutputTokens),
+			Features: &pb.ModelFeatures{
+				Streaming:  m.Features.Streaming,
+				Tools:      m.Features.Tools,
+				Vision:     m.Features.Vision,
+				JsonMode:   m.Features.JSONMode,
+				Embeddings: m.Features.Embeddings,
+			},
+			Health: ph,
+			Source: m.Source,
+		}
+		if h.prices != nil {
+			if p, ok := h.prices.Lookup(m.Name, now); ok {
+				info.Price = &pb.ModelPrice{
+					InputPerMillion:       p.InputPerMillion,
+					OutputPerMillion:      p.OutputPerMillion,
+					CachedInputPerMillion: p.CachedInputPerMillion,
+				}
+			}
+		}
+		out.Models = append(out.Models, info)
+	}
+	return out, nil
+}
+
+// providerFor returns the provider serving model: the registry's, or one
+// guessed from the name for models the registry does not list.
+func (h *Handler) providerFor(model string) string {
+	if m, ok := h.models.Lookup(model); ok && m.Provider != "" {
+		return m.Provider
+	}
+	return resolveProvider(model)
+}
+
+// providerHealth reports the circuit breaker state and key availability
+// of a provider.
+func (h *Handler) providerHealth(providerName string) *pb.ModelHealth {
+	state := resilience.StateClosed
+	if cb, ok := h.circuitBreakers[providerName]; ok {
+		state = cb.State()
+	}
+
+	out := &pb.ModelHealth{CircuitState: state.String()}
+	if kp, ok := h.keyPools[providerName]; ok {
+		for _, kh := range kp.Health() {
+			out.TotalKeys++
+			if kh.Status == resilience.KeyActive {
+				out.AvailableKeys++
+			}
+		}
+	}
+	out.Healthy = state != resilience.StateOpen && out.AvailableKeys > 0
+	return out
+}
+
+// RunModelRefresh refreshes the registry from the providers' model lists
+// now and then every interval, until ctx is cancelled.
+func (h *Handler) RunModelRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshCtx, cancel := context.WithTimeout(ctx, h.requestTimeout)
+		h.RefreshModels(refreshCtx)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshModels merges the models each provider lists into the registry,
+// calling the provider through its key pool, circuit breaker and retry
+// policy. A provider whose list fails keeps its previous entries.
+func (h *Handler) RefreshModels(ctx context.Context) {
+	for name, p := range h.providers {
+		lister, ok := p.(provider.ModelLister)
+		if !ok {
+			continue
+		}
+		kp, ok := h.keyPools[name]
+		if !ok {
+			continue
+		}
+
+		found, err := h.listUpstream(ctx, name, lister, kp)
+		if err != nil {
+			metrics.ModelRefreshesTotal.WithLabelValues(name, "error").Inc()
+			log.Printf("[proxy] %s model list refresh failed: %v", name, err)
+			continue
+		}
+		metrics.ModelRefreshesTotal.WithLabelValues(name, "success").Inc()
+
+		list := make([]models.Model, len(found))
+		for i, f := range found {
+			list[i] = models.Model{
+				Name:            f.ID,
+				ContextWindow:   f.ContextWindow,
+				MaxOutputTokens: f.MaxOutputTokens,
+				Features:        models.Features{Embeddings: f.Embeddings},
+			}
+		}
+		if added := h.models.Merge(name, list); added > 0 {
+			log.Printf("[proxy] %s lists %d models, %d new to the registry", name, len(found), added)
+		}
+	}
+}
+
+// listUpstream asks the provider for its models with the next key from the
+// pool, failing over to another key if the provider rejects it as invalid.
+func (h *Handler) listUpstream(ctx context.Context, providerName string, lister provider.ModelLister, kp *resilience.KeyPool) ([]provider.ModelInfo, error) {
+	apiKey, err := h.nextKey(providerName, kp)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []provider.ModelInfo
+	for attempt := 0; ; attempt++ {
+		err = h.execute(ctx, providerName, kp, apiKey, func(ctx context.Context) error {
+			var callErr error
+			found, callErr = lister.ListModels(ctx, apiKey)
+			return callErr
+		})
+		if !h.failoverKey(providerName, kp, &apiKey, err, attempt) {
+			break
+		}
+	}
+	return found, err
+}
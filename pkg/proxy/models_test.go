@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/models"
+	"github.com/abdhe/llm-inference-proxy/pkg/pricing"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+func TestListModels(t *testing.T) {
+	kp := resilience.NewKeyPool([]string{"sk-a", "sk-b"})
+	kp.Quarantine("sk-b", "401 Unauthorized")
+	h := NewHandler(Config{
+		Providers: map[string]provider.Provider{"openai": &fakeProvider{}},
+		KeyPools:  map[string]*resilience.KeyPool{"openai": kp},
+		Prices:    pricing.DefaultTable(),
+		Models: models.NewRegistry(map[string]models.Model{
+			"gpt-4o":         {Provider: "openai", Aliases: []string{"default"}, ContextWindow: 128000},
+			"gpt-4o-mini":    {Provider: "openai"},
+			"gemini-1.5-pro": {Provider: "gemini"}, // No gemini provider configured
+		}),
+	})
+	scoped := auth.NewContext(context.Background(), &auth.Identity{KeyID: "vk-1", AllowedModels: []string{"gpt-4o-mini"}})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  *pb.ListModelsRequest
+		want []string
+	}{
+		{"all", context.Background(), &pb.ListModelsRequest{}, []string{"gpt-4o", "gpt-4o-mini"}},
+		{"by provider", context.Background(), &pb.ListModelsRequest{Provider: "openai"}, []string{"gpt-4o", "gpt-4o-mini"}},
+		{"unconfigured provider", context.Background(), &pb.ListModelsRequest{Provider: "gemini"}, nil},
+		{"virtual key's models only", scoped, &pb.ListModelsRequest{}, []string{"gpt-4o-mini"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := h.ListModels(tt.ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range resp.Models {
+				got = append(got, m.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListModels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	resp, err := h.ListModels(context.Background(), &pb.ListModelsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := resp.Models[0]
+	if m.ContextWindow != 128000 || !reflect.DeepEqual(m.Aliases, []string{"default"}) || m.Source != models.SourceConfig {
+		t.Errorf("gpt-4o = %+v, want its registry entry", m)
+	}
+	if m.Price == nil || m.Price.InputPerMillion <= 0 {
+		t.Errorf("gpt-4o price = %+v, want the price table's", m.Price)
+	}
+	if hl := m.Health; !hl.Healthy || hl.TotalKeys != 2 || hl.AvailableKeys != 1 || hl.CircuitState != resilience.StateClosed.String() {
+		t.Errorf("gpt-4o health = %+v, want healthy with 1 of 2 keys available", hl)
+	}
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/guardrail"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+)
+
+// OutputViolationReason is the ErrorInfo reason attached to responses
+// blocked by the output policy, so clients can tell them from other
+// FailedPrecondition errors.
+const OutputViolationReason = "OUTPUT_POLICY_VIOLATION"
+
+// errorDomain identifies the proxy in ErrorInfo details.
+const errorDomain = "llm-inference-proxy"
+
+// outputViolation counts a violation and converts it to a FailedPrecondition
+// status whose ErrorInfo names the rule.
+func outputViolation(v *guardrail.Violation) error {
+	metrics.OutputViolationsTotal.WithLabelValues(v.Rule).Inc()
+	metrics.RequestsTotal.WithLabelValues("output_blocked").Inc()
+	return errorInfoStatus(codes.FailedPrecondition, v.Error(), OutputViolationReason, map[string]string{"rule": v.Rule})
+}
+
+// errorInfoStatus builds a status carrying a google.rpc.ErrorInfo detail.
+func errorInfoStatus(code codes.Code, msg, reason string, meta map[string]string) error {
+	st := status.New(code, msg)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   errorDomain,
+		Metadata: meta,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// statusOf converts err to a status, mapping context errors to Canceled and
+// DeadlineExceeded.
+func statusOf(err error) *status.Status {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err)
+	}
+	return status.Convert(err)
+}
+
+// errorReason returns the reason of the ErrorInfo detail of st, if any.
+func errorReason(st *status.Status) string {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
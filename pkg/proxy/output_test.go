@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/guardrail"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+func TestOutputPolicy(t *testing.T) {
+	policy, err := guardrail.NewOutputPolicy(guardrail.OutputConfig{
+		Rules:          []guardrail.RegexRule{{Name: "key", Pattern: `sk-[a-z]{10,}`}},
+		LookaheadBytes: 16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := func(s ...string) []provider.StreamChunk {
+		chunks := make([]provider.StreamChunk, len(s))
+		for i, t := range s {
+			chunks[i] = provider.StreamChunk{Text: t}
+		}
+		return chunks
+	}
+	done := provider.StreamChunk{Done: true, PromptTokens: 10, OutputTokens: 5}
+
+	tests := []struct {
+		name    string
+		reply   string
+		chunks  []provider.StreamChunk
+		wantErr bool
+	}{
+		{"clean", "all good here", append(text("all", " good", " here"), done), false},
+		{"violation", "the key is sk-abcdefghijkl", append(text("the key is", " sk-abc", "defghijkl", " ok"), done), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakeProvider{replies: []string{tt.reply}, chunks: tt.chunks}
+			h := NewHandler(Config{
+				Providers: map[string]provider.Provider{"openai": p},
+				KeyPools:  map[string]*resilience.KeyPool{"openai": resilience.NewKeyPool([]string{"sk-test"})},
+				Output:    policy,
+			})
+			req := &pb.InferenceRequest{Model: "gpt-4o", Prompt: "hi"}
+			wantCode := codes.OK
+			if tt.wantErr {
+				wantCode = codes.FailedPrecondition
+			}
+
+			_, _, out, err := h.inferReply(context.Background(), "Infer", req, nil)
+			if got := status.Code(err); got != wantCode {
+				t.Fatalf("inferReply error = %v, want %s", err, wantCode)
+			}
+			if tt.wantErr && out != nil {
+				t.Errorf("inferReply returned %q with the violation", out.GetText())
+			}
+
+			stream := &fakeReplyStream{}
+			_, _, err = h.streamReply(context.Background(), "InferStream", req, nil, stream)
+			if got := status.Code(err); got != wantCode {
+				t.Fatalf("streamReply error = %v, want %s", err, wantCode)
+			}
+			var sent strings.Builder
+			for _, c := range stream.sent {
+				sent.WriteString(c.Text)
+			}
+			if strings.Contains(sent.String(), "sk-") {
+				t.Errorf("client received %q, want the match held back", sent.String())
+			}
+			if !tt.wantErr && sent.String() != tt.reply {
+				t.Errorf("client received %q, want %q", sent.String(), tt.reply)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// UnsupportedParamReason is the ErrorInfo reason for requests using a
+// parameter the target provider does not support or accept at that value.
+const UnsupportedParamReason = "UNSUPPORTED_PARAMETER"
+
+// requestParams copies the generation parameters and content parts of req
+// into a provider request. The prompt and API key are filled in once known.
+func requestParams(req *pb.InferenceRequest, format *responseFormat) (provider.Request, error) {
+	parts, err := partsOf(req.Parts)
+	if err != nil {
+		return provider.Request{}, err
+	}
+	return provider.Request{
+		Model:            req.Model,
+		Temperature:      req.Temperature,
+		MaxTokens:        req.MaxTokens,
+		TopP:             req.TopP,
+		TopK:             req.TopK,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		Seed:             req.Seed,
+		N:                req.N,
+		LogitBias:        req.LogitBias,
+		Logprobs:         req.Logprobs,
+		TopLogprobs:      req.TopLogprobs,
+		Parts:            parts,
+		Format:           format.providerFormat(),
+	}, nil
+}
+
+// validateParams checks provReq against the provider serving it, returning
+// InvalidArgument with the offending parameter in ErrorInfo. Requests for
+// unknown providers are left to fail later, as before.
+func (h *Handler) validateParams(providerName string, provReq provider.Request) error {
+	p, ok := h.providers[providerName]
+	if !ok {
+		return nil
+	}
+	return paramStatus(providerName, p.Validate(provReq))
+}
+
+// paramStatus converts a provider validation error to InvalidArgument with
+// the offending parameter in ErrorInfo; nil stays nil.
+func paramStatus(providerName string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	metrics.RequestsTotal.WithLabelValues("invalid_params").Inc()
+	meta := map[string]string{"provider": providerName}
+	var pe *provider.ParamError
+	if errors.As(err, &pe) {
+		meta["param"] = pe.Param
+	}
+	return errorInfoStatus(codes.InvalidArgument, "proxy: "+err.Error(), UnsupportedParamReason, meta)
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// partURISchemes are the URI schemes accepted for remote parts; whether the
+// provider can fetch a given URI is left to its own validation.
+var partURISchemes = map[string]bool{"https": true, "http": true, "gs": true}
+
+// partsOf validates the request's content parts and converts them for the
+// provider. Each needs a MIME type and exactly one of data or uri.
+func partsOf(parts []*pb.ContentPart) ([]provider.Part, error) {
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	out := make([]provider.Part, 0, len(parts))
+	for i, p := range parts {
+		switch {
+		case p.GetMimeType() == "":
+			return nil, status.Errorf(codes.InvalidArgument, "proxy: parts[%d]: mime_type is required", i)
+		case len(p.GetData()) > 0 && p.GetUri() != "":
+			return nil, status.Errorf(codes.InvalidArgument, "proxy: parts[%d]: set only one of data and uri", i)
+		case len(p.GetData()) == 0 && p.GetUri() == "":
+			return nil, status.Errorf(codes.InvalidArgument, "proxy: parts[%d]: data or uri is required", i)
+		case p.GetUri() != "":
+			u, err := url.Parse(p.GetUri())
+			if err != nil || !partURISchemes[u.Scheme] {
+				return nil, status.Errorf(codes.InvalidArgument, "proxy: parts[%d]: uri must be an https, http or gs URL", i)
+			}
+		}
+		out = append(out, provider.Part{MIMEType: p.GetMimeType(), Data: p.GetData(), URI: p.GetUri()})
+	}
+	return out, nil
+}
+
+// cacheScope partitions the semantic cache so a prompt only matches
+// entries stored for the same tenant, model, parameters, conversation and
+// attachments. It hashes the tenant, req's sampling parameters and response
+// format (with the resolved model), each earlier turn's role and text and
+// each part's MIME type and content or URI. The prompt embedding alone
+// cannot tell any of these apart, and a reply cached for one tenant or
+// model must not be served to a caller of another.
+func cacheScope(tenant string, req provider.Request, history []provider.Message) string {
+	// The prompt is matched by embedding; the key and max_tokens do not
+	// change what a reply says.
+	params := req
+	params.Prompt, params.APIKey, params.MaxTokens = "", "", 0
+	params.History, params.Parts = nil, nil
+	encoded, _ := json.Marshal(params)
+
+	h := sha256.New()
+	h.Write([]byte(tenant))
+	h.Write([]byte{0})
+	h.Write(encoded)
+	h.Write([]byte{0})
+	for _, m := range history {
+		h.Write([]byte(m.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(m.Text))
+		h.Write([]byte{0})
+	}
+	for _, p := range req.Parts {
+		h.Write([]byte(p.MIMEType))
+		h.Write([]byte{0})
+		if len(p.Data) > 0 {
+			h.Write(p.Data)
+		} else {
+			h.Write([]byte(p.URI))
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+)
+
+func TestCacheScope(t *testing.T) {
+	temp := func(v float32) *float32 { return &v }
+	base := provider.Request{Model: "gpt-4o", Prompt: "hi", MaxTokens: 100, APIKey: "sk-a"}
+	history := []provider.Message{{Role: provider.RoleUser, Text: "earlier"}}
+	with := func(f func(*provider.Request)) provider.Request {
+		req := base
+		f(&req)
+		return req
+	}
+
+	tests := []struct {
+		name      string
+		tenant    string
+		req       provider.Request
+		history   []provider.Message
+		wantShare bool // Whether it shares base's scope for tenant "a"
+	}{
+		{"same request", "a", base, nil, true},
+		{"other prompt", "a", with(func(r *provider.Request) { r.Prompt = "hello" }), nil, true},
+		{"other key and max_tokens", "a", with(func(r *provider.Request) { r.APIKey, r.MaxTokens = "sk-b", 10 }), nil, true},
+		{"other tenant", "b", base, nil, false},
+		{"other model", "a", with(func(r *provider.Request) { r.Model = "gpt-4o-mini" }), nil, false},
+		{"temperature", "a", with(func(r *provider.Request) { r.Temperature = temp(0.2) }), nil, false},
+		{"stop sequences", "a", with(func(r *provider.Request) { r.Stop = []string{"\n"} }), nil, false},
+		{"response format", "a", with(func(r *provider.Request) { r.Format = &provider.ResponseFormat{Type: provider.FormatJSONObject} }), nil, false},
+		{"history", "a", base, history, false},
+		{"parts", "a", with(func(r *provider.Request) { r.Parts = []provider.Part{{MIMEType: "image/png", URI: "https://x/a.png"}} }), nil, false},
+	}
+	want := cacheScope("a", base, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cacheScope(tt.tenant, tt.req, tt.history)
+			if (got == want) != tt.wantShare {
+				t.Errorf("cacheScope shares the base scope = %v, want %v", got == want, tt.wantShare)
+			}
+		})
+	}
+
+	if cacheScope("a", with(func(r *provider.Request) { r.Temperature = temp(0.2) }), nil) !=
+		cacheScope("a", with(func(r *provider.Request) { r.Temperature = temp(0.2) }), nil) {
+		t.Error("cacheScope is not deterministic for equal parameters")
+	}
+}
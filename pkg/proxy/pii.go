@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/guardrail"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/session"
+)
+
+// redactPrompt applies the PII policy to a prompt before it reaches the
+// cache or a provider. It returns the redacted prompt and the redaction used
+// to restore placeholders in the response; the redaction is nil when PII
+// scanning is disabled. A prompt matching a blocking detector is rejected
+// with InvalidArgument.
+//
+// In a conversation or chat session conv, the redaction continues conv's
+// placeholders, so earlier turns in the history keep meaning the same
+// values, and the placeholders issued are saved with conv. If the session
+// store cannot keep them, a prompt needing placeholders is rejected with
+// FailedPrecondition.
+func (h *Handler) redactPrompt(prompt string, conv *session.Session) (string, *guardrail.Redaction, error) {
+	if h.pii == nil {
+		return prompt, nil, nil
+	}
+
+	red := h.pii.NewRedaction()
+	if conv != nil {
+		red = h.pii.ResumeRedaction(conv.Placeholders)
+	}
+	redacted := red.Redact(prompt)
+	for name, action := range red.Actions() {
+		metrics.PIIDetectionsTotal.WithLabelValues(name, action).Add(float64(red.Findings[name]))
+	}
+
+	if err := red.Err(); err != nil {
+		metrics.RequestsTotal.WithLabelValues("pii_blocked").Inc()
+		return "", nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if conv != nil && red.Reversible() {
+		if !session.KeepsPlaceholders(h.sessions) {
+			metrics.RequestsTotal.WithLabelValues("pii_blocked").Inc()
+			return "", nil, status.Error(codes.FailedPrecondition,
+				"proxy: prompt needs PII placeholders, which cannot be kept with the conversation; set CHAT_SESSION_KEY or send it outside a conversation")
+		}
+		conv.Placeholders = red.Placeholders()
+	}
+	return redacted, red, nil
+}
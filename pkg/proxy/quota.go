@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/quota"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// reserveQuota admits the request against the caller's tenant quotas,
+// reserving max_tokens until the actual usage is known. On rejection the
+// reset time is sent through setTrailer and a ResourceExhausted status is
+// returned. If the quota store is unavailable the request is admitted.
+func (h *Handler) reserveQuota(ctx context.Context, req *pb.InferenceRequest, setTrailer func(metadata.MD)) (*quota.Reservation, error) {
+	tokens := int64(req.MaxTokens)
+	if tokens <= 0 {
+		tokens = quota.DefaultReserveTokens
+	}
+	// Each candidate may use up to max_tokens.
+	if n := req.GetN(); n > 1 {
+		tokens *= int64(n)
+	}
+	return h.reserveTokens(ctx, tokens, setTrailer)
+}
+
+// reserveTokens admits a request expected to use tokens against the
+// caller's tenant quotas; see reserveQuota.
+func (h *Handler) reserveTokens(ctx context.Context, tokens int64, setTrailer func(metadata.MD)) (*quota.Reservation, error) {
+	if h.quota == nil {
+		return nil, nil
+	}
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	res, err := h.quota.Reserve(ctx, id.Tenant, tokens)
+	var exceeded *quota.ExceededError
+	switch {
+	case errors.As(err, &exceeded):
+		metrics.QuotaRejectionsTotal.WithLabelValues(id.Tenant, exceeded.Limit).Inc()
+		metrics.RequestsTotal.WithLabelValues("quota_exceeded").Inc()
+
+		retryAfter := int(math.Ceil(time.Until(exceeded.ResetAt).Seconds()))
+		setTrailer(metadata.Pairs(
+			"x-ratelimit-limit", exceeded.Limit,
+			"x-ratelimit-reset", exceeded.ResetAt.Format(time.RFC3339),
+			"retry-after", strconv.Itoa(retryAfter),
+		))
+		return nil, status.Error(codes.ResourceExhausted, exceeded.Error())
+	case err != nil:
+		metrics.QuotaErrorsTotal.Inc()
+		log.Printf("[proxy] quota check failed, admitting request: %v", err)
+		return nil, nil
+	}
+	return res, nil
+}
+
+// settleQuota reconciles a reservation with the actual tokens and spend.
+// It runs detached from the request context, which may already be done.
+func settleQuota(res *quota.Reservation, tokens int32, costUSD float64) {
+	if res == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := res.Commit(ctx, int64(tokens), costUSD); err != nil {
+		metrics.QuotaErrorsTotal.Inc()
+		log.Printf("[proxy] quota reconcile failed: %v", err)
+	}
+}
+
+// chargeQuota charges usage that had no reservation, such as a hedged
+// request's losing call, to the caller's tenant quotas. Like settleQuota it
+// runs detached from the request context.
+func (h *Handler) chargeQuota(ctx context.Context, tokens int32, costUSD float64) {
+	if h.quota == nil {
+		return
+	}
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return
+	}
+
+	chargeCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.quota.Charge(chargeCtx, id.Tenant, int64(tokens), costUSD); err != nil {
+		metrics.QuotaErrorsTotal.Inc()
+		log.Printf("[proxy] quota charge failed: %v", err)
+	}
+}
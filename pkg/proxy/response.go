@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// requestIDHeader carries the proxy-assigned request ID in response headers,
+// so clients can quote it even when the call fails.
+const requestIDHeader = "x-request-id"
+
+// finishReasons maps normalized provider finish reasons to the proto enum.
+var finishReasons = map[string]pb.FinishReason{
+	provider.FinishStop:          pb.FinishReason_FINISH_REASON_STOP,
+	provider.FinishLength:        pb.FinishReason_FINISH_REASON_LENGTH,
+	provider.FinishContentFilter: pb.FinishReason_FINISH_REASON_CONTENT_FILTER,
+	provider.FinishToolCalls:     pb.FinishReason_FINISH_REASON_TOOL_CALLS,
+	provider.FinishOther:         pb.FinishReason_FINISH_REASON_OTHER,
+}
+
+// newRequestID assigns an ID to an incoming request.
+func newRequestID() string {
+	return uuid.New().String()
+}
+
+// responseMetadata converts provider metadata for the client. The model
+// falls back to the requested one when the provider does not report it.
+func responseMetadata(requestID, providerName, model string, m provider.Metadata) *pb.ResponseMetadata {
+	if m.Model != "" {
+		model = m.Model
+	}
+	out := &pb.ResponseMetadata{
+		RequestId:          requestID,
+		Provider:           providerName,
+		Model:              model,
+		UpstreamId:         m.ID,
+		FinishReason:       finishReasons[m.FinishReason],
+		NativeFinishReason: m.NativeFinishReason,
+		BlockReason:        m.BlockReason,
+	}
+	for _, sr := range m.SafetyRatings {
+		out.SafetyRatings = append(out.SafetyRatings, &pb.SafetyRating{
+			Category:    sr.Category,
+			Probability: sr.Probability,
+			Blocked:     sr.Blocked,
+		})
+	}
+	return out
+}
+
+// recordFinishReason counts why a provider stopped generating.
+func recordFinishReason(providerName string, m provider.Metadata) {
+	reason := m.FinishReason
+	if reason == "" {
+		reason = "unspecified"
+	}
+	metrics.FinishReasonsTotal.WithLabelValues(providerName, reason).Inc()
+}
@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	"github.com/abdhe/llm-inference-proxy/pkg/schema"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// ResponseFormatReason is the ErrorInfo reason attached to responses that
+// still fail response_format validation after all repair attempts.
+const ResponseFormatReason = "RESPONSE_FORMAT_INVALID"
+
+// maxRepairs caps the repair attempts a request may ask for.
+const maxRepairs = 3
+
+// responseFormat is a request's compiled response_format.
+type responseFormat struct {
+	provider   *provider.ResponseFormat
+	schema     *schema.Schema // nil in JSON object mode
+	maxRepairs int
+}
+
+// parseResponseFormat compiles rf, returning nil for free-text requests.
+// An invalid schema is rejected with InvalidArgument.
+func parseResponseFormat(rf *pb.ResponseFormat) (*responseFormat, error) {
+	f := &responseFormat{maxRepairs: int(rf.GetMaxRepairs())}
+	if f.maxRepairs > maxRepairs {
+		f.maxRepairs = maxRepairs
+	}
+
+	switch rf.GetType() {
+	case pb.ResponseFormat_TYPE_UNSPECIFIED:
+		return nil, nil
+	case pb.ResponseFormat_TYPE_JSON_OBJECT:
+		f.provider = &provider.ResponseFormat{Type: provider.FormatJSONObject}
+	case pb.ResponseFormat_TYPE_JSON_SCHEMA:
+		s, err := schema.Compile([]byte(rf.GetJsonSchema()))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "proxy: response_format: %v", err)
+		}
+		name := rf.GetSchemaName()
+		if name == "" {
+			name = "response"
+		}
+		f.schema = s
+		f.provider = &provider.ResponseFormat{
+			Type:   provider.FormatJSONSchema,
+			Name:   name,
+			Schema: []byte(rf.GetJsonSchema()),
+			Strict: rf.GetStrict(),
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "proxy: unknown response_format type %v", rf.GetType())
+	}
+	return f, nil
+}
+
+// providerFormat returns the format to send upstream; nil-safe.
+func (f *responseFormat) providerFormat() *provider.ResponseFormat {
+	if f == nil {
+		return nil
+	}
+	return f.provider
+}
+
+// check validates text, returning the extracted JSON document and any
+// validation errors. Free-text requests always pass.
+func (f *responseFormat) check(text string) (string, []string) {
+	if f == nil {
+		return text, nil
+	}
+	return schema.ValidateText(f.schema, text)
+}
+
+// checkCandidate validates one candidate's text. Errors of candidates other
+// than the first are prefixed with its index.
+func (f *responseFormat) checkCandidate(index int, text string) (string, []string) {
+	doc, errs := f.check(text)
+	if index > 0 {
+		for i, e := range errs {
+			errs[i] = fmt.Sprintf("candidate %d: %s", index, e)
+		}
+	}
+	return doc, errs
+}
+
+// checkResponse validates every candidate of resp, replacing each text with
+// its extracted document.
+func (f *responseFormat) checkResponse(resp *provider.Response) []string {
+	if f == nil {
+		return nil
+	}
+	var errs []string
+	resp.Text, errs = f.check(resp.Text)
+	for i := range resp.Candidates {
+		c := &resp.Candidates[i]
+		if i == 0 {
+			c.Text = resp.Text
+			continue
+		}
+		var cerrs []string
+		c.Text, cerrs = f.checkCandidate(i, c.Text)
+		errs = append(errs, cerrs...)
+	}
+	return errs
+}
+
+// checkStream validates the assembled text of every streamed candidate.
+func (f *responseFormat) checkStream(candidates map[int]*candidateStream) []string {
+	if f == nil {
+		return nil
+	}
+	indexes := make([]int, 0, len(candidates))
+	for i := range candidates {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var errs []string
+	for _, i := range indexes {
+		_, cerrs := f.checkCandidate(i, candidates[i].text)
+		errs = append(errs, cerrs...)
+	}
+	return errs
+}
+
+// accepts reports whether a cached response satisfies the format.
+func (f *responseFormat) accepts(text string) bool {
+	_, errs := f.check(text)
+	return len(errs) == 0
+}
+
+// inferValidated performs the unary call and validates the response against
+// the request's format. On failure it re-asks the model with the validation
+// errors, on the key that answered, up to the requested number of repairs;
+// responses with several candidates are not repaired. Token usage of every
+// attempt is summed into the result. The final validation errors are
+// returned alongside a successful call.
+func (h *Handler) inferValidated(ctx context.Context, providerName string, p provider.Provider, kp *resilience.KeyPool, req provider.Request, f *responseFormat) (callResult, []string) {
+	result := h.inferHedged(ctx, providerName, p, kp, req)
+	if f == nil || result.err != nil {
+		return result, nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		errs := f.checkResponse(&result.resp)
+		if len(errs) == 0 {
+			if attempt == 0 {
+				metrics.ResponseFormatTotal.WithLabelValues("valid").Inc()
+			} else {
+				metrics.ResponseFormatTotal.WithLabelValues("repaired").Inc()
+			}
+			return result, nil
+		}
+		if attempt >= f.maxRepairs || len(result.resp.Candidates) > 1 {
+			metrics.ResponseFormatTotal.WithLabelValues("invalid").Inc()
+			return result, errs
+		}
+
+		repair := req
+		repair.APIKey = result.apiKey
+		repair.Prompt = repairPrompt(req.Prompt, result.resp.Text, errs)
+		resp, apiKey, err := h.invoke(ctx, providerName, p, kp, repair)
+		if err != nil {
+			metrics.ResponseFormatTotal.WithLabelValues("invalid").Inc()
+			return result, errs
+		}
+		metrics.ResponseFormatRepairsTotal.Inc()
+
+		result.apiKey = apiKey
+		result.resp.Text = resp.Text
+		result.resp.Candidates = resp.Candidates
+		result.resp.Metadata = resp.Metadata
+		result.resp.PromptTokens += resp.PromptTokens
+		result.resp.CachedTokens += resp.CachedTokens
+		result.resp.OutputTokens += resp.OutputTokens
+	}
+}
+
+// repairPrompt asks the model to correct its previous reply.
+func repairPrompt(prompt, reply string, errs []string) string {
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nYour previous reply was:\n")
+	b.WriteString(reply)
+	b.WriteString("\n\nIt does not satisfy the required JSON format:\n")
+	for _, e := range errs {
+		fmt.Fprintf(&b, "- %s\n", e)
+	}
+	b.WriteString("\nReply again with only the corrected JSON, without any other text.")
+	return b.String()
+}
+
+// responseFormatError is the FailedPrecondition status returned when the
+// response does not satisfy response_format.
+func responseFormatError(errs []string) error {
+	metrics.RequestsTotal.WithLabelValues("invalid_format").Inc()
+	return errorInfoStatus(codes.FailedPrecondition,
+		"proxy: response does not satisfy response_format: "+strings.Join(errs, "; "),
+		ResponseFormatReason, map[string]string{"errors": strings.Join(errs, "\n")})
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/resilience"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// fakeProvider answers unary calls with scripted replies, in order, and
+// streams with the scripted chunks, and records the requests it was sent.
+type fakeProvider struct {
+	mu      sync.Mutex
+	replies []string
+	chunks  []provider.StreamChunk
+	err     error
+	reqs    []provider.Request
+}
+
+func (f *fakeProvider) Name() string                    { return "fake" }
+func (f *fakeProvider) Validate(provider.Request) error { return nil }
+
+func (f *fakeProvider) Infer(_ context.Context, req provider.Request) (provider.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reqs = append(f.reqs, req)
+	if f.err != nil {
+		return provider.Response{}, f.err
+	}
+	if len(f.replies) == 0 {
+		return provider.Response{}, errors.New("fake: no reply scripted")
+	}
+	text := f.replies[0]
+	f.replies = f.replies[1:]
+	return provider.Response{Text: text, PromptTokens: 10, OutputTokens: 5}, nil
+}
+
+func (f *fakeProvider) InferStream(ctx context.Context, req provider.Request) (<-chan provider.StreamChunk, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reqs = append(f.reqs, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.chunks == nil {
+		return nil, errors.New("fake: no stream scripted")
+	}
+	ch := make(chan provider.StreamChunk)
+	go func(chunks []provider.StreamChunk) {
+		defer close(ch)
+		for _, c := range chunks {
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(f.chunks)
+	return ch, nil
+}
+
+// newTestHandler returns a handler whose only provider, "openai", is p,
+// with a single key and no retries.
+func newTestHandler(p provider.Provider) *Handler {
+	return NewHandler(Config{
+		Providers: map[string]provider.Provider{"openai": p},
+		KeyPools:  map[string]*resilience.KeyPool{"openai": resilience.NewKeyPool([]string{"sk-test"})},
+	})
+}
+
+func TestInferValidatedRepairs(t *testing.T) {
+	const schemaDoc = `{"type":"object","required":["n"],"properties":{"n":{"type":"integer"}}}`
+
+	tests := []struct {
+		name       string
+		maxRepairs int32
+		replies    []string
+		wantText   string
+		wantErrs   bool
+		wantCalls  int
+	}{
+		{"valid first time", 2, []string{`{"n":1}`}, `{"n":1}`, false, 1},
+		{"fenced reply is extracted", 0, []string{"```json\n{\"n\":1}\n```"}, `{"n":1}`, false, 1},
+		{"repaired", 2, []string{`{"n":"one"}`, `{"n":1}`}, `{"n":1}`, false, 2},
+		{"repaired on the last attempt", 2, []string{`nope`, `{}`, `{"n":1}`}, `{"n":1}`, false, 3},
+		{"out of repairs", 1, []string{`nope`, `{}`}, `{}`, true, 2},
+		{"no repairs asked for", 0, []string{`{}`}, `{}`, true, 1},
+		{"repairs are capped", 10, []string{`{}`, `{}`, `{}`, `{}`, `{}`}, `{}`, true, maxRepairs + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseResponseFormat(&pb.ResponseFormat{
+				Type:       pb.ResponseFormat_TYPE_JSON_SCHEMA,
+				JsonSchema: schemaDoc,
+				MaxRepairs: tt.maxRepairs,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &fakeProvider{replies: tt.replies}
+			h := newTestHandler(p)
+
+			req := provider.Request{Model: "gpt-4o", Prompt: "count", APIKey: "sk-test"}
+			result, errs := h.inferValidated(context.Background(), "openai", p, h.keyPools["openai"], req, f)
+			if result.err != nil {
+				t.Fatalf("inferValidated: %v", result.err)
+			}
+			if result.resp.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", result.resp.Text, tt.wantText)
+			}
+			if (len(errs) > 0) != tt.wantErrs {
+				t.Errorf("errors = %q, want errors %v", errs, tt.wantErrs)
+			}
+			if len(p.reqs) != tt.wantCalls {
+				t.Fatalf("provider called %d times, want %d", len(p.reqs), tt.wantCalls)
+			}
+			if got, want := result.resp.OutputTokens, int32(5*tt.wantCalls); got != want {
+				t.Errorf("output tokens = %d, want %d summed over every attempt", got, want)
+			}
+			for _, r := range p.reqs[1:] {
+				if !strings.HasPrefix(r.Prompt, "count\n\nYour previous reply was:\n") || !strings.Contains(r.Prompt, "does not satisfy the required JSON format") {
+					t.Errorf("repair prompt = %q", r.Prompt)
+				}
+			}
+		})
+	}
+}
+
+func TestParseResponseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		rf      *pb.ResponseFormat
+		wantNil bool
+		wantErr bool
+	}{
+		{"free text", nil, true, false},
+		{"json object", &pb.ResponseFormat{Type: pb.ResponseFormat_TYPE_JSON_OBJECT}, false, false},
+		{"json schema", &pb.ResponseFormat{Type: pb.ResponseFormat_TYPE_JSON_SCHEMA, JsonSchema: `{"type":"object"}`}, false, false},
+		{"invalid schema", &pb.ResponseFormat{Type: pb.ResponseFormat_TYPE_JSON_SCHEMA, JsonSchema: `{`}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseResponseFormat(tt.rf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseResponseFormat error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (f == nil) != tt.wantNil {
+				t.Errorf("parseResponseFormat = %v, want nil %v", f, tt.wantNil)
+			}
+		})
+	}
+}
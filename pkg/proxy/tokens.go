@@ -0,0 +1,242 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/metrics"
+	"github.com/abdhe/llm-inference-proxy/pkg/models"
+	"github.com/abdhe/llm-inference-proxy/pkg/pricing"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	"github.com/abdhe/llm-inference-proxy/pkg/tokenizer"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// ContextWindowReason is the ErrorInfo reason for requests whose prompt and
+// max_tokens do not fit the model's context window.
+const ContextWindowReason = "CONTEXT_WINDOW_EXCEEDED"
+
+// Context overflow policies, for CONTEXT_OVERFLOW.
+const (
+	ContextReject   = "reject"
+	ContextTruncate = "truncate"
+)
+
+// contextTruncatedHeader reports how many prompt tokens were dropped to fit
+// the context window.
+const contextTruncatedHeader = "x-context-truncated-tokens"
+
+// partTokens is the estimated size of one image or document part. Both
+// providers bill a typical image at a few hundred tokens.
+const partTokens = 258
+
+// CountTokens counts the prompt's tokens and checks them against the
+// model's limits. Providers with a counting endpoint are asked for an exact
+// count; otherwise the local tokenizer is used.
+func (h *Handler) CountTokens(ctx context.Context, req *pb.CountTokensRequest) (*pb.CountTokensResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.requestTimeout)
+	defer cancel()
+
+	req.Model = h.models.Resolve(req.Model)
+	providerName := h.providerFor(req.Model)
+	if err := authorize(ctx, providerName, req.Model); err != nil {
+		return nil, err
+	}
+	parts, err := partsOf(req.Parts)
+	if err != nil {
+		return nil, err
+	}
+
+	tok, m, _ := h.tokenizerFor(req.Model)
+	tokens, method := countPrompt(tok, req.Prompt, parts), tok.Name()
+	provReq := provider.Request{Model: req.Model, Prompt: req.Prompt, Parts: parts}
+	if n, ok := h.countUpstream(ctx, providerName, provReq); ok {
+		tokens, method = int(n), "provider"
+	}
+
+	out := &pb.CountTokensResponse{
+		PromptTokens:    int32(tokens),
+		ContextWindow:   int32(m.ContextWindow),
+		MaxOutputTokens: int32(m.MaxOutputTokens),
+		Fits:            true,
+		Method:          method,
+	}
+	if m.ContextWindow > 0 {
+		out.RemainingTokens = int32(m.ContextWindow - tokens - int(req.MaxTokens))
+		out.Fits = out.RemainingTokens >= 0
+	}
+	if m.MaxOutputTokens > 0 && int(req.MaxTokens) > m.MaxOutputTokens {
+		out.Fits = false
+	}
+	return out, nil
+}
+
+// countUpstream asks the provider to count req's prompt tokens, through
+// the key pool, circuit breaker and retry policy. It reports false if the
+// provider cannot count tokens or the call fails.
+func (h *Handler) countUpstream(ctx context.Context, providerName string, req provider.Request) (int32, bool) {
+	counter, ok := h.providers[providerName].(provider.TokenCounter)
+	if !ok {
+		return 0, false
+	}
+	kp, ok := h.keyPools[providerName]
+	if !ok {
+		return 0, false
+	}
+
+	apiKey, err := h.nextKey(providerName, kp)
+	if err != nil {
+		return 0, false
+	}
+	req.APIKey = apiKey
+
+	var n int32
+	for attempt := 0; ; attempt++ {
+		err = h.execute(ctx, providerName, kp, req.APIKey, func(ctx context.Context) error {
+			var callErr error
+			n, callErr = counter.CountTokens(ctx, req)
+			return callErr
+		})
+		if !h.failoverKey(providerName, kp, &req.APIKey, err, attempt) {
+			break
+		}
+	}
+	if err != nil {
+		log.Printf("[proxy] %s token count failed, using the local tokenizer: %v", providerName, err)
+		return 0, false
+	}
+	return n, true
+}
+
+// tokenizerFor returns the tokenizer and registry entry of model. Models
+// missing from the registry get the estimator and zero limits.
+func (h *Handler) tokenizerFor(model string) (tokenizer.Tokenizer, models.Model, bool) {
+	m, ok := h.models.Lookup(model)
+	return h.tokenizers.For(m.Encoding), m, ok
+}
+
+// countPrompt returns the tokens of prompt plus its parts.
+func countPrompt(tok tokenizer.Tokenizer, prompt string, parts []provider.Part) int {
+	return tok.Count(prompt) + len(parts)*partTokens
+}
+
+// estimateUsage estimates the usage of a call the provider reported none
+// for, from the tokens of req's history, prompt and parts and of the output
+// received.
+func (h *Handler) estimateUsage(req provider.Request, output string) pricing.Usage {
+	tok, _, _ := h.tokenizerFor(req.Model)
+	in := countPrompt(tok, req.Prompt, req.Parts)
+	for _, m := range req.History {
+		in += tok.Count(m.Text)
+	}
+	return pricing.Usage{PromptTokens: int32(in), OutputTokens: int32(tok.Count(output))}
+}
+
+// fitPrompt checks the request, after the earlier turns in history,
+// against the model's context window before it is sent; see fitContext.
+// The history and prompt are returned with any truncation applied, and the
+// dropped token count sent through setHeader.
+func (h *Handler) fitPrompt(providerName string, req *pb.InferenceRequest, history []provider.Message, prompt string, parts []provider.Part, setHeader func(metadata.MD)) ([]provider.Message, string, error) {
+	turns := make([]string, 0, len(history)+1)
+	for _, m := range history {
+		turns = append(turns, m.Text)
+	}
+	turns = append(turns, prompt)
+
+	turns, removed, err := h.fitContext(providerName, req.Model, req.GetContextPolicy(), req.MaxTokens, turns, parts)
+	if err != nil {
+		return nil, "", err
+	}
+	if removed > 0 {
+		setHeader(metadata.Pairs(contextTruncatedHeader, strconv.Itoa(removed)))
+	}
+	// Truncation keeps a suffix: the last len(turns)-1 history turns, then
+	// the prompt, cut at the start if nothing else remains.
+	return history[len(history)-(len(turns)-1):], turns[len(turns)-1], nil
+}
+
+// fitContext checks that turns, oldest first, plus parts and maxTokens fit
+// model's context window, and that maxTokens is within its output limit.
+// Under the truncate policy the oldest turns are dropped, then the start of
+// the last one, until the request fits; the number of tokens removed is
+// returned. Models missing from the registry are not checked.
+func (h *Handler) fitContext(providerName, model string, policy pb.ContextPolicy, maxTokens int32, turns []string, parts []provider.Part) ([]string, int, error) {
+	tok, m, ok := h.tokenizerFor(model)
+	if !ok {
+		return turns, 0, nil
+	}
+	if m.MaxOutputTokens > 0 && int(maxTokens) > m.MaxOutputTokens {
+		return nil, 0, paramStatus(providerName, &provider.ParamError{
+			Provider: providerName,
+			Param:    "max_tokens",
+			Reason:   fmt.Sprintf("must be at most %d for %s", m.MaxOutputTokens, m.Name),
+		})
+	}
+	if m.ContextWindow == 0 {
+		return turns, 0, nil
+	}
+
+	budget := m.ContextWindow - int(maxTokens) - len(parts)*partTokens
+	counts := make([]int, len(turns))
+	total := 0
+	for i, t := range turns {
+		counts[i] = tok.Count(t)
+		total += counts[i]
+	}
+	if total <= budget {
+		return turns, 0, nil
+	}
+	promptTokens := total + len(parts)*partTokens
+
+	truncate := policy == pb.ContextPolicy_CONTEXT_POLICY_TRUNCATE ||
+		(policy == pb.ContextPolicy_CONTEXT_POLICY_UNSPECIFIED && h.contextPolicy == ContextTruncate)
+	if truncate && budget > 0 {
+		removed := 0
+		for len(turns) > 1 && total > budget {
+			removed += counts[0]
+			total -= counts[0]
+			turns, counts = turns[1:], counts[1:]
+		}
+		if total > budget {
+			last, kept := keepLast(tok, turns[0], budget)
+			removed += counts[0] - kept
+			turns = []string{last}
+			total = kept
+		}
+		if total > 0 {
+			metrics.ContextOverflowTotal.WithLabelValues(model, "truncated").Inc()
+			return turns, removed, nil
+		}
+	}
+
+	metrics.ContextOverflowTotal.WithLabelValues(model, "rejected").Inc()
+	metrics.RequestsTotal.WithLabelValues("context_exceeded").Inc()
+	return nil, 0, errorInfoStatus(codes.InvalidArgument,
+		fmt.Sprintf("proxy: prompt (%d tokens) plus max_tokens (%d) exceeds the %d-token context window of %s",
+			promptTokens, maxTokens, m.ContextWindow, m.Name),
+		ContextWindowReason, map[string]string{
+			"prompt_tokens":  strconv.Itoa(promptTokens),
+			"max_tokens":     strconv.Itoa(int(maxTokens)),
+			"context_window": strconv.Itoa(m.ContextWindow),
+		})
+}
+
+// keepLast returns the longest suffix of text that counts at most budget
+// tokens, and its count. Re-tokenizing a cut can merge differently, so the
+// cut is tightened until the count fits.
+func keepLast(tok tokenizer.Tokenizer, text string, budget int) (string, int) {
+	n := budget
+	last := tok.KeepLast(text, n)
+	kept := tok.Count(last)
+	for kept > budget && n > 0 {
+		n -= kept - budget
+		last = tok.KeepLast(text, n)
+		kept = tok.Count(last)
+	}
+	return last, kept
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/models"
+	"github.com/abdhe/llm-inference-proxy/pkg/provider"
+	pb "github.com/abdhe/llm-inference-proxy/proto"
+)
+
+// words returns text the token estimator counts as n tokens.
+func words(n int) string {
+	return strings.Repeat(" w", n)
+}
+
+// newContextHandler returns a handler whose "tiny" model has a 20-token
+// context window and at most 10 output tokens, counted by the estimator.
+func newContextHandler(policy string) *Handler {
+	return NewHandler(Config{
+		Models: models.NewRegistry(map[string]models.Model{
+			"tiny": {Provider: "openai", ContextWindow: 20, MaxOutputTokens: 10},
+		}),
+		ContextOverflow: policy,
+	})
+}
+
+func TestFitContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string // Handler default
+		reqPolicy   pb.ContextPolicy
+		model       string
+		maxTokens   int32
+		turns       []int // Tokens per turn, oldest first
+		parts       int
+		wantTurns   []int
+		wantRemoved int
+		wantCode    codes.Code
+	}{
+		{name: "fits", policy: ContextTruncate, model: "tiny", maxTokens: 10, turns: []int{5, 5}, wantTurns: []int{5, 5}},
+		{name: "drops oldest turns", policy: ContextTruncate, model: "tiny", maxTokens: 10, turns: []int{6, 6, 3}, wantTurns: []int{6, 3}, wantRemoved: 6},
+		{name: "cuts the start of the last turn", policy: ContextTruncate, model: "tiny", maxTokens: 10, turns: []int{4, 15}, wantTurns: []int{10}, wantRemoved: 9},
+		{name: "rejects by default", policy: ContextReject, model: "tiny", maxTokens: 10, turns: []int{6, 6, 3}, wantCode: codes.InvalidArgument},
+		{name: "request asks to truncate", policy: ContextReject, reqPolicy: pb.ContextPolicy_CONTEXT_POLICY_TRUNCATE, model: "tiny", maxTokens: 10, turns: []int{6, 6, 3}, wantTurns: []int{6, 3}, wantRemoved: 6},
+		{name: "request asks to reject", policy: ContextTruncate, reqPolicy: pb.ContextPolicy_CONTEXT_POLICY_REJECT, model: "tiny", maxTokens: 10, turns: []int{6, 6, 3}, wantCode: codes.InvalidArgument},
+		{name: "max_tokens above the output limit", policy: ContextTruncate, model: "tiny", maxTokens: 11, turns: []int{1}, wantCode: codes.InvalidArgument},
+		{name: "parts leave no room", policy: ContextTruncate, model: "tiny", maxTokens: 10, turns: []int{1}, parts: 1, wantCode: codes.InvalidArgument},
+		{name: "unknown model is not checked", policy: ContextReject, model: "unknown", maxTokens: 1000, turns: []int{500}, wantTurns: []int{500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newContextHandler(tt.policy)
+			turns := make([]string, len(tt.turns))
+			for i, n := range tt.turns {
+				turns[i] = words(n)
+			}
+			parts := make([]provider.Part, tt.parts)
+
+			got, removed, err := h.fitContext("openai", tt.model, tt.reqPolicy, tt.maxTokens, turns, parts)
+			if tt.wantCode != codes.OK {
+				if status.Code(err) != tt.wantCode {
+					t.Fatalf("fitContext error = %v, want code %v", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fitContext: %v", err)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("removed = %d, want %d", removed, tt.wantRemoved)
+			}
+			if len(got) != len(tt.wantTurns) {
+				t.Fatalf("kept %d turns, want %d", len(got), len(tt.wantTurns))
+			}
+			for i, want := range tt.wantTurns {
+				if got[i] != words(want) {
+					t.Errorf("turn %d = %q, want %d tokens", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFitPrompt(t *testing.T) {
+	h := newContextHandler(ContextTruncate)
+	history := []provider.Message{
+		{Role: provider.RoleUser, Text: words(6)},
+		{Role: provider.RoleAssistant, Text: words(2)},
+	}
+
+	tests := []struct {
+		name        string
+		history     []provider.Message
+		prompt      string
+		wantHistory int
+		wantPrompt  string
+		wantHeader  string
+	}{
+		{"fits", history, words(2), 2, words(2), ""},
+		{"drops a history turn", history, words(5), 1, words(5), "6"},
+		{"drops the history and cuts the prompt", history, words(12), 0, words(10), "10"},
+		{"no history", nil, words(12), 0, words(10), "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header metadata.MD
+			req := &pb.InferenceRequest{Model: "tiny", MaxTokens: 10}
+			gotHistory, gotPrompt, err := h.fitPrompt("openai", req, tt.history, tt.prompt, nil, func(md metadata.MD) { header = md })
+			if err != nil {
+				t.Fatalf("fitPrompt: %v", err)
+			}
+			if len(gotHistory) != tt.wantHistory {
+				t.Errorf("kept %d history turns, want %d", len(gotHistory), tt.wantHistory)
+			}
+			if len(gotHistory) > 0 && gotHistory[len(gotHistory)-1] != tt.history[len(tt.history)-1] {
+				t.Errorf("kept history %v, want the latest turns", gotHistory)
+			}
+			if gotPrompt != tt.wantPrompt {
+				t.Errorf("prompt = %q, want %q", gotPrompt, tt.wantPrompt)
+			}
+			if got := strings.Join(header.Get(contextTruncatedHeader), ","); got != tt.wantHeader {
+				t.Errorf("%s = %q, want %q", contextTruncatedHeader, got, tt.wantHeader)
+			}
+		})
+	}
+}
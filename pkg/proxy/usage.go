@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/abdhe/llm-inference-proxy/pkg/audit"
+	"github.com/abdhe/llm-inference-proxy/pkg/auth"
+	"github.com/abdhe/llm-inference-proxy/pkg/ledger"
+)
+
+// newRecord starts the usage ledger record for a request.
+func newRecord(ctx context.Context, model string, start time.Time) ledger.Record {
+	rec := ledger.Record{
+		Time:        start.UTC(),
+		Tenant:      anonymousTenant,
+		Model:       model,
+		CacheStatus: "miss",
+	}
+	if id, ok := auth.FromContext(ctx); ok {
+		rec.Tenant = id.Tenant
+		rec.Team = id.Team
+	}
+	return rec
+}
+
+// finishRequest completes a record with the request's latency and outcome,
+// appends it to the usage ledger and, if sampled, writes an audit event.
+func (h *Handler) finishRequest(ctx context.Context, method, requestID, prompt, response string, rec *ledger.Record, start time.Time, err error) {
+	if h.ledger == nil && h.audit == nil {
+		return
+	}
+
+	rec.Method = method
+	rec.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	switch {
+	case err == nil && rec.CacheStatus == "hit":
+		rec.Outcome = "cache_hit"
+	case err == nil:
+		rec.Outcome = "success"
+	default:
+		rec.Outcome = outcomeOf(err)
+	}
+
+	if h.ledger != nil {
+		h.ledger.Append(*rec)
+	}
+	if h.audit != nil && h.audit.Sampled() {
+		h.audit.Log(auditEvent(ctx, method, requestID, prompt, response, rec, err))
+	}
+}
+
+// auditEvent builds the audit event for a finished request. The event ID is
+// the request ID returned to the client.
+func auditEvent(ctx context.Context, method, requestID, prompt, response string, rec *ledger.Record, err error) audit.Event {
+	e := audit.Event{
+		ID:           requestID,
+		Time:         rec.Time,
+		Method:       method,
+		Tenant:       rec.Tenant,
+		Team:         rec.Team,
+		Model:        rec.Model,
+		Provider:     rec.Provider,
+		Prompt:       prompt,
+		Response:     response,
+		PromptTokens: rec.PromptTokens,
+		OutputTokens: rec.OutputTokens,
+		CostUSD:      rec.CostUSD,
+		CacheHit:     rec.CacheStatus == "hit",
+		LatencyMS:    rec.LatencyMS,
+		Outcome:      rec.Outcome,
+	}
+	if id, ok := auth.FromContext(ctx); ok {
+		e.KeyID = id.KeyID
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// outcomeOf names a failed request's outcome after its gRPC status code,
+// e.g. "resource_exhausted".
+func outcomeOf(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return "error"
+	}
+
+	var b strings.Builder
+	for i, r := range st.Code().String() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
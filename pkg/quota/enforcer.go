@@ -0,0 +1,212 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// reserveScript atomically checks every counter against its limit and, only
+// if all pass, applies the deltas. Returns 0 on success or the 1-based index
+// of the first counter that would be exceeded.
+//
+// KEYS: counter keys. ARGV: delta, limit, ttl seconds for each key.
+var reserveScript = redis.NewScript(`
+for i = 1, #KEYS do
+  local delta = tonumber(ARGV[(i-1)*3+1])
+  local limit = tonumber(ARGV[(i-1)*3+2])
+  local cur = tonumber(redis.call('GET', KEYS[i]) or '0')
+  if cur >= limit or cur + delta > limit then
+    return i
+  end
+end
+for i = 1, #KEYS do
+  redis.call('INCRBYFLOAT', KEYS[i], ARGV[(i-1)*3+1])
+  redis.call('EXPIRE', KEYS[i], ARGV[(i-1)*3+3])
+end
+return 0
+`)
+
+// Enforcer checks and records tenant usage in Redis.
+type Enforcer struct {
+	client *redis.Client
+	cfg    Config
+}
+
+// NewEnforcer creates a Redis-backed quota enforcer.
+func NewEnforcer(addr, password string, db int, cfg Config) *Enforcer {
+	return &Enforcer{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+		cfg: cfg,
+	}
+}
+
+// Ping checks Redis connectivity.
+func (e *Enforcer) Ping(ctx context.Context) error {
+	return e.client.Ping(ctx).Err()
+}
+
+// Close shuts down the Redis connection.
+func (e *Enforcer) Close() error {
+	return e.client.Close()
+}
+
+// Reserve admits one request for tenant, reserving tokens against the token
+// limits. It returns an *ExceededError if any limit would be exceeded. The
+// reservation must be settled with Commit once the actual usage is known.
+func (e *Enforcer) Reserve(ctx context.Context, tenant string, tokens int64) (*Reservation, error) {
+	dims := e.cfg.For(tenant).dimensions()
+	if len(dims) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	r := &Reservation{enforcer: e, tokens: tokens}
+	keys := make([]string, len(dims))
+	args := make([]interface{}, 0, len(dims)*3)
+	ends := make([]time.Time, len(dims))
+
+	for i, d := range dims {
+		id, end, ttl := d.window.bounds(now)
+		keys[i] = counterKey(tenant, d.name, id)
+		ends[i] = end
+
+		var delta float64
+		switch d.kind {
+		case kindRequests:
+			delta = 1
+		case kindTokens:
+			delta = float64(tokens)
+			r.tokenKeys = append(r.tokenKeys, counter{Key: keys[i], TTL: ttl})
+		case kindSpend:
+			// Spend is only known afterward; admission just checks the
+			// budget is not already used up.
+			r.spendKeys = append(r.spendKeys, counter{Key: keys[i], TTL: ttl})
+		}
+		args = append(args, strconv.FormatFloat(delta, 'f', -1, 64), strconv.FormatFloat(d.limit, 'f', -1, 64), int(ttl.Seconds()))
+	}
+
+	idx, err := reserveScript.Run(ctx, e.client, keys, args...).Int()
+	if err != nil {
+		return nil, fmt.Errorf("quota: reserve: %w", err)
+	}
+	if idx > 0 {
+		return nil, &ExceededError{Tenant: tenant, Limit: dims[idx-1].name, ResetAt: ends[idx-1]}
+	}
+	return r, nil
+}
+
+// Charge records usage that was not reserved, such as a hedged request's
+// losing call, against tenant's token and spend counters. It is not checked
+// against the limits: the provider has already billed it.
+func (e *Enforcer) Charge(ctx context.Context, tenant string, tokens int64, costUSD float64) error {
+	now := time.Now()
+	pipe := e.client.Pipeline()
+	for _, d := range e.cfg.For(tenant).dimensions() {
+		var delta float64
+		switch d.kind {
+		case kindTokens:
+			delta = float64(tokens)
+		case kindSpend:
+			delta = costUSD
+		}
+		if delta == 0 {
+			continue
+		}
+		id, _, ttl := d.window.bounds(now)
+		key := counterKey(tenant, d.name, id)
+		pipe.IncrByFloat(ctx, key, delta)
+		pipe.Expire(ctx, key, ttl)
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("quota: charge: %w", err)
+	}
+	return nil
+}
+
+// counterKey names the Redis counter of a tenant's limit for one window.
+func counterKey(tenant, limit, window string) string {
+	return fmt.Sprintf("quota:%s:%s:%s", tenant, limit, window)
+}
+
+// Reservation is an admitted request's provisional usage.
+type Reservation struct {
+	enforcer  *Enforcer
+	tokens    int64
+	tokenKeys []counter
+	spendKeys []counter
+}
+
+// counter is a Redis counter key and how long it must be kept.
+type counter struct {
+	Key string        `json:"key"`
+	TTL time.Duration `json:"ttl"`
+}
+
+// Ticket is a Reservation in a form that can be stored, so that one that
+// stays open for hours — e.g. for a provider-side batch — can be settled
+// after a restart or by another replica.
+type Ticket struct {
+	Tokens    int64     `json:"tokens"`
+	TokenKeys []counter `json:"token_keys,omitempty"`
+	SpendKeys []counter `json:"spend_keys,omitempty"`
+}
+
+// Ticket returns the reservation in storable form, or nil for a nil
+// reservation.
+func (r *Reservation) Ticket() *Ticket {
+	if r == nil {
+		return nil
+	}
+	return &Ticket{Tokens: r.tokens, TokenKeys: r.tokenKeys, SpendKeys: r.spendKeys}
+}
+
+// Resume returns the reservation t was made from, to be settled with
+// Commit. It returns nil for a nil ticket.
+func (e *Enforcer) Resume(t *Ticket) *Reservation {
+	if t == nil {
+		return nil
+	}
+	return &Reservation{enforcer: e, tokens: t.Tokens, tokenKeys: t.TokenKeys, spendKeys: t.SpendKeys}
+}
+
+// Commit replaces the reserved tokens with the actual count and charges the
+// actual spend. Use tokens = 0 to refund a failed request. Safe on a nil
+// reservation (no limits applied).
+func (r *Reservation) Commit(ctx context.Context, tokens int64, costUSD float64) error {
+	if r == nil {
+		return nil
+	}
+
+	delta := float64(tokens - r.tokens)
+	pipe := r.enforcer.client.Pipeline()
+	if delta != 0 {
+		for _, c := range r.tokenKeys {
+			pipe.IncrByFloat(ctx, c.Key, delta)
+			pipe.Expire(ctx, c.Key, c.TTL)
+		}
+	}
+	if costUSD != 0 {
+		for _, c := range r.spendKeys {
+			pipe.IncrByFloat(ctx, c.Key, costUSD)
+			pipe.Expire(ctx, c.Key, c.TTL)
+		}
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("quota: commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Package quota enforces per-tenant request rates, token rates and
+// token / spend budgets, shared across replicas through Redis.
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultReserveTokens is reserved for requests that do not set max_tokens.
+const DefaultReserveTokens = 1024
+
+// Limits are the quotas for one tenant. Zero means unlimited.
+type Limits struct {
+	RPM              int64   `json:"rpm"`
+	TPM              int64   `json:"tpm"`
+	DailyTokens      int64   `json:"daily_tokens"`
+	MonthlyTokens    int64   `json:"monthly_tokens"`
+	DailyBudgetUSD   float64 `json:"daily_budget_usd"`
+	MonthlyBudgetUSD float64 `json:"monthly_budget_usd"`
+}
+
+// Config maps tenants to their limits. Tenants without an entry get Default.
+type Config struct {
+	Default Limits            `json:"default"`
+	Tenants map[string]Limits `json:"tenants"`
+}
+
+// LoadConfig reads a tenant limits JSON file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("quota: read %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("quota: parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// For returns the limits that apply to tenant.
+func (c Config) For(tenant string) Limits {
+	if l, ok := c.Tenants[tenant]; ok {
+		return l
+	}
+	return c.Default
+}
+
+// ExceededError is returned when a request would exceed a tenant quota.
+type ExceededError struct {
+	Tenant  string
+	Limit   string    // Which limit was hit, e.g. "tpm" or "daily_budget_usd"
+	ResetAt time.Time // When the limit's window rolls over
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota: tenant %q exceeded %s limit, resets at %s",
+		e.Tenant, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// window is a fixed accounting period.
+type window int
+
+const (
+	windowMinute window = iota
+	windowDay
+	windowMonth
+)
+
+// bounds returns an identifier for the window containing now, when it ends,
+// and how long a counter for it must be kept.
+func (w window) bounds(now time.Time) (id string, end time.Time, ttl time.Duration) {
+	now = now.UTC()
+	switch w {
+	case windowMinute:
+		start := now.Truncate(time.Minute)
+		return start.Format("200601021504"), start.Add(time.Minute), 2 * time.Minute
+	case windowDay:
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format("20060102"), start.AddDate(0, 0, 1), 48 * time.Hour
+	default:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("200601"), start.AddDate(0, 1, 0), 32 * 24 * time.Hour
+	}
+}
+
+// kind is what a counter measures.
+type kind int
+
+const (
+	kindRequests kind = iota
+	kindTokens
+	kindSpend
+)
+
+// dimension is one limited counter.
+type dimension struct {
+	name   string
+	kind   kind
+	window window
+	limit  float64
+}
+
+// dimensions returns the counters that have a limit set.
+func (l Limits) dimensions() []dimension {
+	all := []dimension{
+		{"rpm", kindRequests, windowMinute, float64(l.RPM)},
+		{"tpm", kindTokens, windowMinute, float64(l.TPM)},
+		{"daily_tokens", kindTokens, windowDay, float64(l.DailyTokens)},
+		{"monthly_tokens", kindTokens, windowMonth, float64(l.MonthlyTokens)},
+		{"daily_budget_usd", kindSpend, windowDay, l.DailyBudgetUSD},
+		{"monthly_budget_usd", kindSpend, windowMonth, l.MonthlyBudgetUSD},
+	}
+
+	dims := all[:0]
+	for _, d := range all {
+		if d.limit > 0 {
+			dims = append(dims, d)
+		}
+	}
+	return dims
+}
@@ -0,0 +1,213 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWindowBounds(t *testing.T) {
+	at := time.Date(2024, 1, 31, 23, 59, 30, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name    string
+		window  window
+		wantID  string
+		wantEnd time.Time
+		wantTTL time.Duration
+	}{
+		{"minute", windowMinute, "202401312259", time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC), 2 * time.Minute},
+		{"day", windowDay, "20240131", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 48 * time.Hour},
+		{"month", windowMonth, "202401", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 32 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, end, ttl := tt.window.bounds(at)
+			if id != tt.wantID || !end.Equal(tt.wantEnd) || ttl != tt.wantTTL {
+				t.Errorf("bounds = %s, %s, %s, want %s, %s, %s", id, end, ttl, tt.wantID, tt.wantEnd, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestConfigFor(t *testing.T) {
+	cfg := Config{
+		Default: Limits{RPM: 60},
+		Tenants: map[string]Limits{"acme": {TPM: 1000, DailyBudgetUSD: 5}},
+	}
+
+	tests := []struct {
+		tenant string
+		want   []string
+	}{
+		{"acme", []string{"tpm", "daily_budget_usd"}},
+		{"other", []string{"rpm"}},
+		{"", []string{"rpm"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tenant, func(t *testing.T) {
+			var got []string
+			for _, d := range cfg.For(tt.tenant).dimensions() {
+				got = append(got, d.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("For(%q) limits %v, want %v", tt.tenant, got, tt.want)
+			}
+		})
+	}
+
+	if dims := (Limits{}).dimensions(); len(dims) != 0 {
+		t.Errorf("zero limits have %d dimensions, want none", len(dims))
+	}
+}
+
+func TestTicketRoundTrip(t *testing.T) {
+	e := &Enforcer{}
+	r := &Reservation{
+		enforcer:  e,
+		tokens:    100,
+		tokenKeys: []counter{{Key: "quota:acme:tpm:202401312259", TTL: 2 * time.Minute}},
+		spendKeys: []counter{{Key: "quota:acme:daily_budget_usd:20240131", TTL: 48 * time.Hour}},
+	}
+
+	data, err := json.Marshal(r.Ticket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ticket Ticket
+	if err := json.Unmarshal(data, &ticket); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Resume(&ticket); !reflect.DeepEqual(got, r) {
+		t.Errorf("Resume = %+v, want %+v", got, r)
+	}
+
+	var none *Reservation
+	if none.Ticket() != nil || e.Resume(nil) != nil {
+		t.Error("nil reservation should round-trip to nil")
+	}
+}
+
+// newTestEnforcer returns an enforcer on the Redis at REDIS_ADDR (default
+// localhost:6379), skipping the test if it is unreachable.
+func newTestEnforcer(t *testing.T, cfg Config) *Enforcer {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	e := NewEnforcer(addr, os.Getenv("REDIS_PASSWORD"), 0, cfg)
+	t.Cleanup(func() { e.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := e.Ping(ctx); err != nil {
+		t.Skipf("redis not available at %s: %v", addr, err)
+	}
+	return e
+}
+
+func TestReserve(t *testing.T) {
+	tenant := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	e := newTestEnforcer(t, Config{
+		Tenants: map[string]Limits{tenant: {RPM: 3, TPM: 100, DailyBudgetUSD: 1}},
+	})
+	ctx := context.Background()
+	t.Cleanup(func() {
+		keys, _ := e.client.Keys(ctx, "quota:"+tenant+":*").Result()
+		if len(keys) > 0 {
+			e.client.Del(ctx, keys...)
+		}
+	})
+
+	steps := []struct {
+		name      string
+		reserve   int64
+		commit    int64
+		costUSD   float64
+		wantLimit string // Limit hit by Reserve, or "" if admitted
+	}{
+		{"admitted", 60, 30, 0.5, ""},
+		{"commit settles the actual tokens", 70, 20, 0.6, ""},
+		{"token limit", 60, 0, 0, "tpm"},
+		{"spent budget", 0, 0, 0, "daily_budget_usd"},
+	}
+	for _, st := range steps {
+		r, err := e.Reserve(ctx, tenant, st.reserve)
+		if st.wantLimit != "" {
+			var exceeded *ExceededError
+			if !errors.As(err, &exceeded) || exceeded.Limit != st.wantLimit {
+				t.Fatalf("%s: Reserve error = %v, want %s exceeded", st.name, err, st.wantLimit)
+			}
+			if !exceeded.ResetAt.After(time.Now()) {
+				t.Errorf("%s: resets at %s, want a future time", st.name, exceeded.ResetAt)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Reserve: %v", st.name, err)
+		}
+		if err := r.Commit(ctx, st.commit, st.costUSD); err != nil {
+			t.Fatalf("%s: Commit: %v", st.name, err)
+		}
+	}
+
+	// A rejected reservation must not have counted the request.
+	id, _, _ := windowMinute.bounds(time.Now())
+	if n, err := e.client.Get(ctx, fmt.Sprintf("quota:%s:rpm:%s", tenant, id)).Float64(); err != nil || n != 2 {
+		t.Errorf("rpm counter = %v (%v), want 2", n, err)
+	}
+
+	if r, err := e.Reserve(ctx, "unlimited", 1_000_000); r != nil || err != nil {
+		t.Errorf("Reserve for a tenant with no limits = %v, %v, want nil, nil", r, err)
+	}
+}
+
+func TestCharge(t *testing.T) {
+	tenant := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	e := newTestEnforcer(t, Config{
+		Tenants: map[string]Limits{tenant: {RPM: 1, TPM: 100, DailyBudgetUSD: 1}},
+	})
+	ctx := context.Background()
+	t.Cleanup(func() {
+		keys, _ := e.client.Keys(ctx, "quota:"+tenant+":*").Result()
+		if len(keys) > 0 {
+			e.client.Del(ctx, keys...)
+		}
+	})
+
+	// Charges are not admission checked and do not count as requests.
+	for i := 0; i < 2; i++ {
+		if err := e.Charge(ctx, tenant, 80, 0.25); err != nil {
+			t.Fatalf("Charge: %v", err)
+		}
+	}
+	now := time.Now()
+	minute, _, _ := windowMinute.bounds(now)
+	day, _, _ := windowDay.bounds(now)
+	counters := []struct {
+		limit, window string
+		want          float64
+	}{
+		{"tpm", minute, 160},
+		{"daily_budget_usd", day, 0.5},
+	}
+	for _, c := range counters {
+		if n, err := e.client.Get(ctx, counterKey(tenant, c.limit, c.window)).Float64(); err != nil || n != c.want {
+			t.Errorf("%s counter = %v (%v), want %v", c.limit, n, err, c.want)
+		}
+	}
+	if n, _ := e.client.Exists(ctx, counterKey(tenant, "rpm", minute)).Result(); n != 0 {
+		t.Error("Charge counted a request")
+	}
+
+	var exceeded *ExceededError
+	if _, err := e.Reserve(ctx, tenant, 1); !errors.As(err, &exceeded) || exceeded.Limit != "tpm" {
+		t.Errorf("Reserve after charging past the limit = %v, want tpm exceeded", err)
+	}
+}
@@ -1,7 +1,9 @@
 package resilience
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -15,6 +17,18 @@ const (
 	StateHalfOpen                     // Probing — one request allowed
 )
 
+// String returns "closed", "open" or "half_open".
+func (s CircuitState) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
 // ErrCircuitOpen is returned when the circuit breaker is open.
 var ErrCircuitOpen = errors.New("circuit breaker is open")
 
@@ -73,6 +87,13 @@ func (cb *CircuitBreaker) Execute(fn func() error) error {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
+	// A caller cancelling (e.g. a hedged request losing the race) or a
+	// rejected key (quarantined by the key pool instead) says nothing
+	// about provider health.
+	if errors.Is(err, context.Canceled) || AuthFailure(err) != 0 {
+		return err
+	}
+
 	if err != nil {
 		cb.recordFailure()
 		return err
@@ -155,6 +176,22 @@ func IsServerError(err error) bool {
 	return false
 }
 
+// AuthFailure returns the upstream HTTP status (401 or 403) if the error is
+// an authentication/authorization failure — typically a revoked or mistyped
+// key — or 0 otherwise.
+func AuthFailure(err error) int {
+	if err == nil {
+		return 0
+	}
+	errMsg := err.Error()
+	for _, code := range []int{401, 403} {
+		if contains(errMsg, fmt.Sprintf("API error %d", code)) {
+			return code
+		}
+	}
+	return 0
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && searchString(s, substr)
 }
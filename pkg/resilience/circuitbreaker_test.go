@@ -0,0 +1,34 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCircuitBreakerFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want CircuitState
+	}{
+		{"server errors trip the circuit", errors.New("openai: API error 503: overloaded"), StateOpen},
+		{"rejected keys do not", errors.New("openai: API error 401: invalid api key"), StateClosed},
+		{"forbidden keys do not", fmt.Errorf("call: %w", errors.New("gemini: API error 403: denied")), StateClosed},
+		{"cancelled calls do not", fmt.Errorf("call: %w", context.Canceled), StateClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2})
+			for i := 0; i < 3; i++ {
+				if err := cb.Execute(func() error { return tt.err }); err == nil {
+					t.Fatal("Execute swallowed the error")
+				}
+			}
+			if got := cb.State(); got != tt.want {
+				t.Errorf("state after three %q = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
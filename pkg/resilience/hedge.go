@@ -0,0 +1,78 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+// HedgeConfig holds configuration for hedged requests.
+//
+// When enabled, a second request is sent if the first has not produced a
+// response (or a first stream chunk) after the hedge delay. The delay is
+// derived from the observed latency percentile when enough samples exist,
+// otherwise the fixed Delay is used.
+type HedgeConfig struct {
+	Enabled    bool
+	Delay      time.Duration // Fixed delay, also the fallback for Percentile
+	Percentile float64       // Latency quantile to hedge at (e.g. 0.95); 0 disables
+	MinDelay   time.Duration // Lower bound on the derived delay
+	MaxPercent float64       // Max share of traffic (0–100) that may be hedged
+}
+
+// DefaultHedgeConfig returns sensible defaults for hedging (disabled).
+func DefaultHedgeConfig() HedgeConfig {
+	return HedgeConfig{
+		Enabled:    false,
+		Delay:      2 * time.Second,
+		Percentile: 0.95,
+		MinDelay:   50 * time.Millisecond,
+		MaxPercent: 5,
+	}
+}
+
+// HedgeBudget limits hedged requests to a percentage of total traffic.
+// Every request earns a fraction of a token; every hedge spends a whole one.
+type HedgeBudget struct {
+	mu        sync.Mutex
+	ratio     float64 // Tokens earned per request
+	tokens    float64
+	maxTokens float64
+}
+
+// NewHedgeBudget creates a budget that allows at most maxPercent of
+// requests to be hedged.
+func NewHedgeBudget(maxPercent float64) *HedgeBudget {
+	if maxPercent < 0 {
+		maxPercent = 0
+	}
+	if maxPercent > 100 {
+		maxPercent = 100
+	}
+	return &HedgeBudget{
+		ratio:     maxPercent / 100,
+		maxTokens: 10, // Allow a short burst of hedges after a quiet period
+	}
+}
+
+// RecordRequest credits the budget for one request.
+func (b *HedgeBudget) RecordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += b.ratio
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+// Allow reports whether a hedge may be sent, spending a token if so.
+func (b *HedgeBudget) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,33 @@
+package resilience
+
+import "testing"
+
+func TestHedgeBudget(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxPercent float64
+		requests   int
+		want       int // Hedges allowed after the requests
+	}{
+		{"disabled", 0, 100, 0},
+		{"a quarter", 25, 20, 5},
+		{"less than one hedge earned", 25, 3, 0},
+		{"burst is capped", 100, 50, 10},
+		{"above 100 percent is clamped", 500, 5, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewHedgeBudget(tt.maxPercent)
+			for i := 0; i < tt.requests; i++ {
+				b.RecordRequest()
+			}
+			got := 0
+			for b.Allow() {
+				got++
+			}
+			if got != tt.want {
+				t.Errorf("%d hedges allowed after %d requests, want %d", got, tt.requests, tt.want)
+			}
+		})
+	}
+}
@@ -2,17 +2,49 @@
 package resilience
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"sync"
 	"time"
 )
 
-// KeyPool manages a pool of API keys with round-robin rotation
-// and per-key rate-limit awareness.
+// Key selection strategies.
+const (
+	StrategyRoundRobin  = "round_robin"  // Rotate through available keys
+	StrategyWeighted    = "weighted"     // Smooth weighted round-robin by KeyConfig.Weight
+	StrategyLeastLoaded = "least_loaded" // Key with the fewest requests in flight, then the most quota headroom this minute
+)
+
+// KeyLabels are the non-secret attributes of a key, safe to use in metrics.
+type KeyLabels struct {
+	Label   string `json:"label"` // Human-readable name; defaults to the key fingerprint
+	Org     string `json:"org,omitempty"`
+	Project string `json:"project,omitempty"`
+	Tier    string `json:"tier,omitempty"`
+}
+
+// KeyConfig configures a single API key in a pool. Spend against the caps
+// is counted in memory by each replica and starts from zero when the
+// process restarts, so with N replicas a key may spend up to N times its
+// cap; tenant budgets, kept in Redis, are the shared limit.
+type KeyConfig struct {
+	Key string
+	KeyLabels
+
+	Weight           int     // Relative share for the weighted and least_loaded strategies (default 1)
+	RPM              int     // Requests-per-minute ceiling; 0 for unlimited
+	TPM              int     // Tokens-per-minute ceiling; 0 for unlimited
+	DailyBudgetUSD   float64 // Spend cap per UTC day; 0 for unlimited
+	MonthlyBudgetUSD float64 // Spend cap per UTC month; 0 for unlimited
+}
+
+// KeyPool manages a pool of API keys with pluggable selection and
+// per-key rate-limit, quota and budget awareness.
 type KeyPool struct {
-	mu      sync.Mutex
-	keys    []keyEntry
-	current int
+	mu       sync.Mutex
+	keys     []*keyEntry
+	current  int
+	strategy string
 }
 
 type keyEntry struct {
@@ -20,23 +52,85 @@ type keyEntry struct {
 	Remaining int       // Remaining calls before rate limit
 	ResetAt   time.Time // When the rate limit resets
 	Exhausted bool      // Temporarily exhausted
+
+	quarantined      bool // Excluded until re-enabled or reloaded
+	quarantineReason string
+	quarantinedAt    time.Time
+
+	cfg           KeyConfig
+	currentWeight int          // Smooth weighted round-robin state
+	rpm           *TokenBucket // nil if unlimited
+	tpm           *TokenBucket // nil if unlimited
+
+	spendDay     string // UTC day the daily spend applies to (2006-01-02)
+	spendMonth   string // UTC month the monthly spend applies to (2006-01)
+	dailySpend   float64
+	monthlySpend float64
+
+	windowStart    time.Time // Start of the current one-minute load window
+	windowRequests int
+	inFlight       int // Calls running on the key, counted by Begin
 }
 
-// NewKeyPool creates a key pool from a list of API keys.
+// NewKeyPool creates a round-robin key pool from a list of API keys.
 func NewKeyPool(keys []string) *KeyPool {
-	entries := make([]keyEntry, len(keys))
+	cfgs := make([]KeyConfig, len(keys))
 	for i, k := range keys {
-		entries[i] = keyEntry{
-			Key:       k,
-			Remaining: -1, // Unknown initially
-		}
+		cfgs[i] = KeyConfig{Key: k}
+	}
+	return NewKeyPoolWithConfig(StrategyRoundRobin, cfgs)
+}
+
+// NewKeyPoolWithConfig creates a key pool with the given selection strategy
+// and per-key configuration.
+func NewKeyPoolWithConfig(strategy string, keys []KeyConfig) *KeyPool {
+	if strategy == "" {
+		strategy = StrategyRoundRobin
+	}
+	entries := make([]*keyEntry, len(keys))
+	for i, k := range keys {
+		entries[i] = newKeyEntry(k)
+	}
+	return &KeyPool{keys: entries, strategy: strategy}
+}
+
+func newKeyEntry(cfg KeyConfig) *keyEntry {
+	cfg = cfg.withDefaults()
+	e := &keyEntry{
+		Key:       cfg.Key,
+		Remaining: -1, // Unknown initially
+		cfg:       cfg,
 	}
-	return &KeyPool{keys: entries}
+	if cfg.RPM > 0 {
+		e.rpm = NewTokenBucket(float64(cfg.RPM))
+	}
+	if cfg.TPM > 0 {
+		e.tpm = NewTokenBucket(float64(cfg.TPM))
+	}
+	return e
+}
+
+// withDefaults fills in the default weight and label.
+func (c KeyConfig) withDefaults() KeyConfig {
+	if c.Weight <= 0 {
+		c.Weight = 1
+	}
+	if c.Label == "" {
+		c.Label = Fingerprint(c.Key)
+	}
+	return c
+}
+
+// Fingerprint returns a short, non-reversible identifier for a key that is
+// safe to log and use as a metric label.
+func Fingerprint(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("sha256:%x", hash[:6])
 }
 
-// Next returns the next available API key using round-robin selection.
-// It skips keys that are currently exhausted (rate-limited).
-// Returns an error if all keys are exhausted.
+// Next returns the next available API key according to the pool strategy.
+// It skips keys that are rate-limited, over their RPM/TPM ceilings or over
+// their spend caps. Returns an error if no key is available.
 func (kp *KeyPool) Next() (string, error) {
 	kp.mu.Lock()
 	defer kp.mu.Unlock()
@@ -48,32 +142,122 @@ func (kp *KeyPool) Next() (string, error) {
 
 	now := time.Now()
 
-	// Try each key once in round-robin order
+	var entry *keyEntry
+	switch kp.strategy {
+	case StrategyWeighted:
+		entry = kp.pickWeighted(now)
+	case StrategyLeastLoaded:
+		entry = kp.pickLeastLoaded(now)
+	default:
+		entry = kp.pickRoundRobin(now)
+	}
+
+	if entry == nil {
+		reset, ok := kp.earliestReset(now)
+		if !ok {
+			return "", fmt.Errorf("keypool: all keys quarantined")
+		}
+		return "", fmt.Errorf("keypool: all keys exhausted, earliest reset at %s", reset.Format(time.RFC3339))
+	}
+
+	if entry.rpm != nil {
+		entry.rpm.Take(1, now)
+	}
+	entry.windowRequests++
+	return entry.Key, nil
+}
+
+// pickRoundRobin tries each key once in round-robin order. Must be called with mu held.
+func (kp *KeyPool) pickRoundRobin(now time.Time) *keyEntry {
+	n := len(kp.keys)
 	for i := 0; i < n; i++ {
 		idx := (kp.current + i) % n
-		entry := &kp.keys[idx]
+		entry := kp.keys[idx]
 
-		// Reset exhausted keys whose cooldown has passed
-		if entry.Exhausted && now.After(entry.ResetAt) {
-			entry.Exhausted = false
-			entry.Remaining = -1
+		if entry.available(now) {
+			kp.current = (idx + 1) % n
+			return entry
 		}
+	}
+	return nil
+}
 
-		if !entry.Exhausted {
-			kp.current = (idx + 1) % n
-			return entry.Key, nil
+// pickWeighted uses smooth weighted round-robin over available keys.
+// Must be called with mu held.
+func (kp *KeyPool) pickWeighted(now time.Time) *keyEntry {
+	var best *keyEntry
+	total := 0
+	for _, e := range kp.keys {
+		if !e.available(now) {
+			continue
+		}
+		e.currentWeight += e.cfg.Weight
+		total += e.cfg.Weight
+		if best == nil || e.currentWeight > best.currentWeight {
+			best = e
+		}
+	}
+	if best != nil {
+		best.currentWeight -= total
+	}
+	return best
+}
+
+// pickLeastLoaded picks the available key with the fewest calls in flight
+// per unit of weight, breaking ties by the lowest load this minute. Must be
+// called with mu held.
+func (kp *KeyPool) pickLeastLoaded(now time.Time) *keyEntry {
+	var best *keyEntry
+	bestBusy, bestLoad := 0.0, 0.0
+	for _, e := range kp.keys {
+		if !e.available(now) {
+			continue
+		}
+		busy := float64(e.inFlight) / float64(e.cfg.Weight)
+		load := e.load(now)
+		if best == nil || busy < bestBusy || (busy == bestBusy && load < bestLoad) {
+			best, bestBusy, bestLoad = e, busy, load
 		}
 	}
+	return best
+}
 
-	// All keys exhausted — find the earliest reset time
-	earliest := kp.keys[0].ResetAt
-	for _, e := range kp.keys[1:] {
-		if e.ResetAt.Before(earliest) {
-			earliest = e.ResetAt
+// earliestReset returns when the first unavailable key becomes usable again.
+// Quarantined keys never reset on their own; returns false if every key is
+// quarantined. Must be called with mu held.
+func (kp *KeyPool) earliestReset(now time.Time) (time.Time, bool) {
+	var earliest time.Time
+	for _, e := range kp.keys {
+		if e.quarantined {
+			continue
+		}
+		if at := e.availableAt(now); earliest.IsZero() || at.Before(earliest) {
+			earliest = at
 		}
 	}
+	return earliest, !earliest.IsZero()
+}
+
+// Begin counts a call in flight on key until the returned func is called,
+// for the least_loaded strategy. The func may be called more than once.
+func (kp *KeyPool) Begin(key string) (done func()) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	e := kp.find(key)
+	if e == nil {
+		return func() {}
+	}
+	e.inFlight++
 
-	return "", fmt.Errorf("keypool: all keys exhausted, earliest reset at %s", earliest.Format(time.RFC3339))
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			kp.mu.Lock()
+			defer kp.mu.Unlock()
+			e.inFlight--
+		})
+	}
 }
 
 // MarkRateLimited marks a key as rate-limited with the given reset time.
@@ -81,13 +265,10 @@ func (kp *KeyPool) MarkRateLimited(key string, resetAt time.Time) {
 	kp.mu.Lock()
 	defer kp.mu.Unlock()
 
-	for i := range kp.keys {
-		if kp.keys[i].Key == key {
-			kp.keys[i].Exhausted = true
-			kp.keys[i].ResetAt = resetAt
-			kp.keys[i].Remaining = 0
-			return
-		}
+	if e := kp.find(key); e != nil {
+		e.Exhausted = true
+		e.ResetAt = resetAt
+		e.Remaining = 0
 	}
 }
 
@@ -96,21 +277,311 @@ func (kp *KeyPool) UpdateRemaining(key string, remaining int, resetAt time.Time)
 	kp.mu.Lock()
 	defer kp.mu.Unlock()
 
-	for i := range kp.keys {
-		if kp.keys[i].Key == key {
-			kp.keys[i].Remaining = remaining
-			kp.keys[i].ResetAt = resetAt
-			if remaining == 0 {
-				kp.keys[i].Exhausted = true
-			}
-			return
+	if e := kp.find(key); e != nil {
+		e.Remaining = remaining
+		e.ResetAt = resetAt
+		if remaining == 0 {
+			e.Exhausted = true
 		}
 	}
 }
 
+// Quarantine removes a key from rotation indefinitely, e.g. after the
+// provider rejected it as invalid. It stays out until Enable, or until a
+// reload replaces its secret or changes its settings.
+func (kp *KeyPool) Quarantine(key, reason string) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	if e := kp.find(key); e != nil {
+		e.quarantined = true
+		e.quarantineReason = reason
+		e.quarantinedAt = time.Now()
+	}
+}
+
+// Enable returns a quarantined key to rotation, identified by its
+// fingerprint or label. Reports whether a key matched.
+func (kp *KeyPool) Enable(id string) bool {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	matched := false
+	for _, e := range kp.keys {
+		if Fingerprint(e.Key) == id || e.cfg.Label == id {
+			e.quarantined = false
+			e.quarantineReason = ""
+			e.quarantinedAt = time.Time{}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// Key health statuses.
+const (
+	KeyActive      = "active"
+	KeyRateLimited = "rate_limited"
+	KeyQuarantined = "quarantined"
+)
+
+// KeyHealth describes one key's current state without exposing the secret.
+type KeyHealth struct {
+	Fingerprint string     `json:"fingerprint"`
+	KeyLabels              // Label, Org, Project, Tier
+	Status      string     `json:"status"`           // active, rate_limited or quarantined
+	Reason      string     `json:"reason,omitempty"` // Why the key is not active
+	Since       *time.Time `json:"since,omitempty"`  // When quarantined
+	Until       *time.Time `json:"until,omitempty"`  // When a rate-limited key becomes available
+	InFlight    int        `json:"in_flight"`        // Calls running on the key
+}
+
+// Health returns the health of every key in the pool.
+func (kp *KeyPool) Health() []KeyHealth {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	now := time.Now()
+	health := make([]KeyHealth, len(kp.keys))
+	for i, e := range kp.keys {
+		h := KeyHealth{
+			Fingerprint: Fingerprint(e.Key),
+			KeyLabels:   e.cfg.KeyLabels,
+			Status:      KeyActive,
+			InFlight:    e.inFlight,
+		}
+		switch {
+		case e.quarantined:
+			h.Status = KeyQuarantined
+			h.Reason = e.quarantineReason
+			since := e.quarantinedAt
+			h.Since = &since
+		case !e.available(now):
+			h.Status = KeyRateLimited
+			h.Reason = e.unavailableReason(now)
+			until := e.availableAt(now)
+			h.Until = &until
+		}
+		health[i] = h
+	}
+	return health
+}
+
+// RecordUsage charges tokens and spend to a key after a call completes.
+// Tokens count against the TPM bucket; cost counts against the spend caps.
+func (kp *KeyPool) RecordUsage(key string, tokens int, costUSD float64) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	e := kp.find(key)
+	if e == nil {
+		return
+	}
+
+	now := time.Now()
+	if e.tpm != nil {
+		e.tpm.Take(float64(tokens), now)
+	}
+	e.rollWindows(now)
+	e.dailySpend += costUSD
+	e.monthlySpend += costUSD
+}
+
+// Labels returns the non-secret labels for a key in the pool.
+func (kp *KeyPool) Labels(key string) (KeyLabels, bool) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	if e := kp.find(key); e != nil {
+		return e.cfg.KeyLabels, true
+	}
+	return KeyLabels{}, false
+}
+
+// ByFingerprint returns the key in the pool with the given fingerprint, for
+// work that outlives the process and stores only the fingerprint.
+func (kp *KeyPool) ByFingerprint(fingerprint string) (string, bool) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	for _, e := range kp.keys {
+		if Fingerprint(e.Key) == fingerprint {
+			return e.Key, true
+		}
+	}
+	return "", false
+}
+
+// Replace atomically swaps the pool's keys. Keys present before and after
+// keep their runtime state (exhaustion, buckets, spend, quarantine); their
+// settings are updated, and a quarantine is lifted only if they changed. A
+// rotated secret is a new key. Keys no longer present are dropped.
+func (kp *KeyPool) Replace(keys []KeyConfig) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	existing := make(map[string]*keyEntry, len(kp.keys))
+	for _, e := range kp.keys {
+		existing[e.Key] = e
+	}
+
+	entries := make([]*keyEntry, 0, len(keys))
+	for _, k := range keys {
+		fresh := newKeyEntry(k)
+		if old, ok := existing[k.Key]; ok {
+			old.reconfigure(fresh)
+			fresh = old
+		}
+		entries = append(entries, fresh)
+	}
+
+	kp.keys = entries
+	if len(entries) > 0 {
+		kp.current %= len(entries)
+	} else {
+		kp.current = 0
+	}
+}
+
 // Size returns the number of keys in the pool.
 func (kp *KeyPool) Size() int {
 	kp.mu.Lock()
 	defer kp.mu.Unlock()
 	return len(kp.keys)
 }
+
+// find returns the entry for a key, or nil. Must be called with mu held.
+func (kp *KeyPool) find(key string) *keyEntry {
+	for _, e := range kp.keys {
+		if e.Key == key {
+			return e
+		}
+	}
+	return nil
+}
+
+// reconfigure applies new settings from fresh while keeping runtime state.
+// Buckets are only replaced if their ceiling changed, and a quarantine is
+// only lifted if the key's settings changed.
+func (e *keyEntry) reconfigure(fresh *keyEntry) {
+	if fresh.cfg == e.cfg {
+		return
+	}
+	if fresh.cfg.RPM != e.cfg.RPM {
+		e.rpm = fresh.rpm
+	}
+	if fresh.cfg.TPM != e.cfg.TPM {
+		e.tpm = fresh.tpm
+	}
+	e.cfg = fresh.cfg
+	e.quarantined = false
+	e.quarantineReason = ""
+	e.quarantinedAt = time.Time{}
+}
+
+// available reports whether the key can take a request now.
+func (e *keyEntry) available(now time.Time) bool {
+	// Reset exhausted keys whose cooldown has passed
+	if e.Exhausted && now.After(e.ResetAt) {
+		e.Exhausted = false
+		e.Remaining = -1
+	}
+	e.rollWindows(now)
+
+	switch {
+	case e.quarantined, e.Exhausted:
+		return false
+	case e.rpm != nil && e.rpm.Available(now) < 1:
+		return false
+	case e.tpm != nil && e.tpm.Available(now) <= 0:
+		return false
+	case e.cfg.DailyBudgetUSD > 0 && e.dailySpend >= e.cfg.DailyBudgetUSD:
+		return false
+	case e.cfg.MonthlyBudgetUSD > 0 && e.monthlySpend >= e.cfg.MonthlyBudgetUSD:
+		return false
+	}
+	return true
+}
+
+// unavailableReason explains why available returned false.
+func (e *keyEntry) unavailableReason(now time.Time) string {
+	switch {
+	case e.Exhausted:
+		return "upstream rate limit"
+	case e.rpm != nil && e.rpm.Available(now) < 1:
+		return "rpm ceiling"
+	case e.tpm != nil && e.tpm.Available(now) <= 0:
+		return "tpm ceiling"
+	case e.cfg.DailyBudgetUSD > 0 && e.dailySpend >= e.cfg.DailyBudgetUSD:
+		return "daily budget"
+	case e.cfg.MonthlyBudgetUSD > 0 && e.monthlySpend >= e.cfg.MonthlyBudgetUSD:
+		return "monthly budget"
+	}
+	return ""
+}
+
+// availableAt estimates when the key will next be available.
+func (e *keyEntry) availableAt(now time.Time) time.Time {
+	at := now
+	later := func(t time.Time) {
+		if t.After(at) {
+			at = t
+		}
+	}
+
+	if e.Exhausted {
+		later(e.ResetAt)
+	}
+	if e.rpm != nil {
+		later(now.Add(e.rpm.TimeUntil(1, now)))
+	}
+	if e.tpm != nil {
+		later(now.Add(e.tpm.TimeUntil(1, now)))
+	}
+	utc := now.UTC()
+	if e.cfg.DailyBudgetUSD > 0 && e.dailySpend >= e.cfg.DailyBudgetUSD {
+		later(time.Date(utc.Year(), utc.Month(), utc.Day()+1, 0, 0, 0, 0, time.UTC))
+	}
+	if e.cfg.MonthlyBudgetUSD > 0 && e.monthlySpend >= e.cfg.MonthlyBudgetUSD {
+		later(time.Date(utc.Year(), utc.Month()+1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	return at
+}
+
+// load returns the key's utilization this minute: the highest fraction of
+// its RPM/TPM ceilings used, or weighted request count if unlimited.
+func (e *keyEntry) load(now time.Time) float64 {
+	if e.rpm == nil && e.tpm == nil {
+		return float64(e.windowRequests) / float64(e.cfg.Weight)
+	}
+
+	load := 0.0
+	if e.rpm != nil {
+		load = e.rpm.Used(now)
+	}
+	if e.tpm != nil {
+		if used := e.tpm.Used(now); used > load {
+			load = used
+		}
+	}
+	return load
+}
+
+// rollWindows resets per-minute counters and per-period spend when their
+// window has passed.
+func (e *keyEntry) rollWindows(now time.Time) {
+	if now.Sub(e.windowStart) >= time.Minute {
+		e.windowStart = now
+		e.windowRequests = 0
+	}
+
+	utc := now.UTC()
+	if day := utc.Format("2006-01-02"); day != e.spendDay {
+		e.spendDay = day
+		e.dailySpend = 0
+	}
+	if month := utc.Format("2006-01"); month != e.spendMonth {
+		e.spendMonth = month
+		e.monthlySpend = 0
+	}
+}
@@ -0,0 +1,118 @@
+package resilience
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ProviderKeysConfig is the per-provider section of a key pool config file.
+type ProviderKeysConfig struct {
+	Strategy string         `json:"strategy"` // round_robin, weighted or least_loaded
+	Keys     []KeyFileEntry `json:"keys"`
+}
+
+// KeyFileEntry is a single key in a key pool config file. The secret is
+// given either inline (Key) or by environment variable name (KeyEnv).
+// Entries with neither carry settings for a file-loaded key of the same
+// Label (see ApplySettings).
+type KeyFileEntry struct {
+	Key              string  `json:"key,omitempty"`
+	KeyEnv           string  `json:"key_env,omitempty"`
+	Label            string  `json:"label,omitempty"`
+	Org              string  `json:"org,omitempty"`
+	Project          string  `json:"project,omitempty"`
+	Tier             string  `json:"tier,omitempty"`
+	Weight           int     `json:"weight,omitempty"`
+	RPM              int     `json:"rpm,omitempty"`
+	TPM              int     `json:"tpm,omitempty"`
+	DailyBudgetUSD   float64 `json:"daily_budget_usd,omitempty"`
+	MonthlyBudgetUSD float64 `json:"monthly_budget_usd,omitempty"`
+}
+
+// LoadKeyPoolConfig reads a JSON key pool config file keyed by provider name:
+//
+//	{
+//	  "openai": {
+//	    "strategy": "weighted",
+//	    "keys": [
+//	      {"label": "prod-a", "key_env": "OPENAI_KEY_A", "org": "acme", "tier": "tier-5",
+//	       "weight": 3, "rpm": 500, "tpm": 200000, "daily_budget_usd": 50}
+//	    ]
+//	  }
+//	}
+func LoadKeyPoolConfig(path string) (map[string]ProviderKeysConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("keypool config: read: %w", err)
+	}
+
+	var cfg map[string]ProviderKeysConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("keypool config: decode: %w", err)
+	}
+
+	for name, p := range cfg {
+		switch p.Strategy {
+		case "", StrategyRoundRobin, StrategyWeighted, StrategyLeastLoaded:
+		default:
+			return nil, fmt.Errorf("keypool config: %s: unknown strategy %q", name, p.Strategy)
+		}
+	}
+	return cfg, nil
+}
+
+// KeyConfigs resolves the entries into KeyConfigs, reading secrets from the
+// environment where KeyEnv is set. Entries without a secret are skipped.
+func (p ProviderKeysConfig) KeyConfigs() []KeyConfig {
+	var keys []KeyConfig
+	for _, e := range p.Keys {
+		key := e.Key
+		if key == "" && e.KeyEnv != "" {
+			key = os.Getenv(e.KeyEnv)
+		}
+		if key == "" {
+			continue
+		}
+		keys = append(keys, e.toKeyConfig(key))
+	}
+	return keys
+}
+
+// ApplySettings copies settings from secret-less entries onto keys loaded
+// from files, matching on label. This lets the config file carry weights,
+// quotas and labels for keys whose secrets live in a mounted directory.
+func (p ProviderKeysConfig) ApplySettings(keys []KeyConfig) []KeyConfig {
+	byLabel := make(map[string]KeyFileEntry)
+	for _, e := range p.Keys {
+		if e.Key == "" && e.KeyEnv == "" && e.Label != "" {
+			byLabel[e.Label] = e
+		}
+	}
+
+	out := make([]KeyConfig, len(keys))
+	for i, k := range keys {
+		if e, ok := byLabel[k.Label]; ok {
+			k = e.toKeyConfig(k.Key)
+		}
+		out[i] = k
+	}
+	return out
+}
+
+func (e KeyFileEntry) toKeyConfig(key string) KeyConfig {
+	return KeyConfig{
+		Key: key,
+		KeyLabels: KeyLabels{
+			Label:   e.Label,
+			Org:     e.Org,
+			Project: e.Project,
+			Tier:    e.Tier,
+		},
+		Weight:           e.Weight,
+		RPM:              e.RPM,
+		TPM:              e.TPM,
+		DailyBudgetUSD:   e.DailyBudgetUSD,
+		MonthlyBudgetUSD: e.MonthlyBudgetUSD,
+	}
+}
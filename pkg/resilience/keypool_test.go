@@ -0,0 +1,140 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyPoolLeastLoaded(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []KeyConfig
+		inFlight map[string]int // Calls begun and not done, per key
+		used     map[string]int // Requests taken this minute, per key
+		want     string
+	}{
+		{"fewest in flight", []KeyConfig{{Key: "a"}, {Key: "b"}}, map[string]int{"a": 2, "b": 1}, nil, "b"},
+		{"in flight outweighs requests this minute", []KeyConfig{{Key: "a"}, {Key: "b"}}, map[string]int{"a": 1}, map[string]int{"b": 5}, "b"},
+		{"in flight per unit of weight", []KeyConfig{{Key: "a", Weight: 3}, {Key: "b"}}, map[string]int{"a": 2, "b": 1}, nil, "a"},
+		{"ties go to the least used this minute", []KeyConfig{{Key: "a"}, {Key: "b"}}, map[string]int{"a": 1, "b": 1}, map[string]int{"a": 3, "b": 1}, "b"},
+		{"ties go to the most RPM headroom", []KeyConfig{{Key: "a", RPM: 10}, {Key: "b", RPM: 10}}, nil, map[string]int{"a": 4, "b": 2}, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kp := NewKeyPoolWithConfig(StrategyLeastLoaded, tt.keys)
+			now := time.Now()
+			for key, n := range tt.used {
+				e := kp.find(key)
+				e.rollWindows(now)
+				e.windowRequests = n
+				if e.rpm != nil {
+					e.rpm.Take(float64(n), now)
+				}
+			}
+			for key, n := range tt.inFlight {
+				for i := 0; i < n; i++ {
+					kp.Begin(key)
+				}
+			}
+
+			got, err := kp.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Next() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyPoolBegin(t *testing.T) {
+	kp := NewKeyPool([]string{"a"})
+	inFlight := func() int { return kp.Health()[0].InFlight }
+
+	first, second := kp.Begin("a"), kp.Begin("a")
+	if got := inFlight(); got != 2 {
+		t.Fatalf("in flight = %d, want 2", got)
+	}
+	first()
+	first() // A second call is a no-op
+	if got := inFlight(); got != 1 {
+		t.Errorf("in flight after one call is done = %d, want 1", got)
+	}
+	second()
+	if got := inFlight(); got != 0 {
+		t.Errorf("in flight after both calls are done = %d, want 0", got)
+	}
+
+	kp.Begin("unknown")() // Keys outside the pool are not counted
+	if got := inFlight(); got != 0 {
+		t.Errorf("in flight after an unknown key = %d, want 0", got)
+	}
+}
+
+func TestKeyPoolSpendCaps(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        KeyConfig
+		spend      float64
+		wantReason string // Empty if the key stays available
+	}{
+		{"under the daily cap", KeyConfig{Key: "a", DailyBudgetUSD: 10}, 9.99, ""},
+		{"at the daily cap", KeyConfig{Key: "a", DailyBudgetUSD: 10}, 10, "daily budget"},
+		{"at the monthly cap", KeyConfig{Key: "a", MonthlyBudgetUSD: 10}, 10, "monthly budget"},
+		{"no caps", KeyConfig{Key: "a"}, 1000, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kp := NewKeyPoolWithConfig(StrategyRoundRobin, []KeyConfig{tt.cfg})
+			kp.RecordUsage("a", 0, tt.spend)
+
+			_, err := kp.Next()
+			if got := err == nil; got != (tt.wantReason == "") {
+				t.Fatalf("Next() error = %v, want available %v", err, tt.wantReason == "")
+			}
+			if h := kp.Health()[0]; h.Reason != tt.wantReason {
+				t.Errorf("health reason = %q, want %q", h.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestKeyPoolQuarantine(t *testing.T) {
+	kp := NewKeyPoolWithConfig(StrategyRoundRobin, []KeyConfig{{Key: "a", KeyLabels: KeyLabels{Label: "prod-a"}}, {Key: "b"}})
+	kp.Quarantine("a", "401 Unauthorized")
+
+	for i := 0; i < 3; i++ {
+		if got, err := kp.Next(); err != nil || got != "b" {
+			t.Fatalf("Next() = %q, %v, want b while a is quarantined", got, err)
+		}
+	}
+	h := kp.Health()[0]
+	if h.Status != KeyQuarantined || h.Reason != "401 Unauthorized" || h.Since == nil {
+		t.Errorf("health = %+v, want quarantined with its reason and time", h)
+	}
+
+	kp.Quarantine("b", "403 Forbidden")
+	if _, err := kp.Next(); err == nil {
+		t.Error("Next() succeeded with every key quarantined, want an error")
+	}
+
+	tests := []struct {
+		id    string
+		match bool
+	}{
+		{"prod-a", true},         // By label
+		{Fingerprint("b"), true}, // By fingerprint
+		{"b", false},             // Never by the secret itself
+	}
+	for _, tt := range tests {
+		if got := kp.Enable(tt.id); got != tt.match {
+			t.Errorf("Enable(%q) = %v, want %v", tt.id, got, tt.match)
+		}
+	}
+	for _, h := range kp.Health() {
+		if h.Status != KeyActive || h.Since != nil {
+			t.Errorf("%s health after Enable = %+v, want active", h.Label, h)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package resilience
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LoadKeysFromPath reads API keys from a file or a directory of files, such
+// as a mounted Kubernetes Secret. Each file holds one or more keys separated
+// by newlines or commas. Keys are labelled with their file name (suffixed
+// with "#n" when a file holds several). Hidden entries (e.g. the "..data"
+// symlinks Kubernetes creates) are ignored.
+func LoadKeysFromPath(path string) ([]KeyConfig, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("keys: stat %s: %w", path, err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("keys: read dir %s: %w", path, err)
+		}
+		files = files[:0]
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			full := filepath.Join(path, e.Name())
+			// Secret mounts expose keys as symlinks — follow them
+			if fi, err := os.Stat(full); err != nil || fi.IsDir() {
+				continue
+			}
+			files = append(files, full)
+		}
+		sort.Strings(files)
+	}
+
+	var keys []KeyConfig
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("keys: read %s: %w", f, err)
+		}
+
+		fileKeys := strings.FieldsFunc(string(data), func(r rune) bool {
+			return r == '\n' || r == '\r' || r == ','
+		})
+		var trimmed []string
+		for _, k := range fileKeys {
+			if k = strings.TrimSpace(k); k != "" {
+				trimmed = append(trimmed, k)
+			}
+		}
+
+		name := filepath.Base(f)
+		for i, k := range trimmed {
+			label := name
+			if len(trimmed) > 1 {
+				label = fmt.Sprintf("%s#%d", name, i+1)
+			}
+			keys = append(keys, KeyConfig{Key: k, KeyLabels: KeyLabels{Label: label}})
+		}
+	}
+	return keys, nil
+}
+
+// KeyReloader periodically rebuilds a pool's key set and swaps it into the
+// live KeyPool when it changes. Polling (rather than inotify) copes with the
+// atomic symlink swaps Kubernetes uses to update Secret volumes.
+type KeyReloader struct {
+	pool     *KeyPool
+	load     func() ([]KeyConfig, error)
+	interval time.Duration
+	onReload func(keys int, err error)
+
+	digest string
+}
+
+// NewKeyReloader creates a reloader. load returns the complete desired key
+// set; onReload (optional) is called after every reload attempt that
+// changed the pool or failed.
+func NewKeyReloader(pool *KeyPool, interval time.Duration, load func() ([]KeyConfig, error), onReload func(keys int, err error)) *KeyReloader {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &KeyReloader{
+		pool:     pool,
+		load:     load,
+		interval: interval,
+		onReload: onReload,
+		digest:   digestKeys(pool.configs()),
+	}
+}
+
+// Run polls for changes until ctx is cancelled.
+func (r *KeyReloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reload()
+		}
+	}
+}
+
+// Reload loads the key set once and applies it if it changed. On error the
+// live pool is left untouched.
+func (r *KeyReloader) Reload() {
+	keys, err := r.load()
+	if err != nil {
+		log.Printf("[keypool] reload failed: %v", err)
+		r.notify(r.pool.Size(), err)
+		return
+	}
+
+	digest := digestKeys(keys)
+	if digest == r.digest {
+		return
+	}
+
+	r.pool.Replace(keys)
+	r.digest = digest
+	log.Printf("[keypool] reloaded %d keys", len(keys))
+	r.notify(len(keys), nil)
+}
+
+func (r *KeyReloader) notify(keys int, err error) {
+	if r.onReload != nil {
+		r.onReload(keys, err)
+	}
+}
+
+// configs returns the current key configs.
+func (kp *KeyPool) configs() []KeyConfig {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	cfgs := make([]KeyConfig, len(kp.keys))
+	for i, e := range kp.keys {
+		cfgs[i] = e.cfg
+	}
+	return cfgs
+}
+
+// digestKeys returns a stable digest of a key set and its settings.
+func digestKeys(keys []KeyConfig) string {
+	lines := make([]string, len(keys))
+	for i, k := range keys {
+		lines[i] = fmt.Sprintf("%+v", k.withDefaults())
+	}
+	sort.Strings(lines)
+
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return fmt.Sprintf("%x", hash)
+}
@@ -0,0 +1,130 @@
+package resilience
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadKeysFromPath(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"prod-a":      "sk-a\n",
+		"prod-b":      "sk-b1, sk-b2\r\n\n",
+		".hidden":     "sk-hidden",
+		"empty":       "  \n",
+		"..data/prod": "sk-nested",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Secret mounts expose each key as a symlink into a hidden directory.
+	if err := os.Symlink(filepath.Join(dir, "..data/prod"), filepath.Join(dir, "prod-c")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want []KeyConfig
+	}{
+		{"directory", dir, []KeyConfig{
+			{Key: "sk-a", KeyLabels: KeyLabels{Label: "prod-a"}},
+			{Key: "sk-b1", KeyLabels: KeyLabels{Label: "prod-b#1"}},
+			{Key: "sk-b2", KeyLabels: KeyLabels{Label: "prod-b#2"}},
+			{Key: "sk-nested", KeyLabels: KeyLabels{Label: "prod-c"}},
+		}},
+		{"file", filepath.Join(dir, "prod-b"), []KeyConfig{
+			{Key: "sk-b1", KeyLabels: KeyLabels{Label: "prod-b#1"}},
+			{Key: "sk-b2", KeyLabels: KeyLabels{Label: "prod-b#2"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadKeysFromPath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadKeysFromPath = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadKeysFromPath(filepath.Join(dir, "missing")); err == nil {
+		t.Error("LoadKeysFromPath(missing) succeeded, want an error")
+	}
+}
+
+func TestKeyReloader(t *testing.T) {
+	pool := NewKeyPoolWithConfig(StrategyRoundRobin, []KeyConfig{{Key: "sk-a"}, {Key: "sk-b"}})
+	pool.MarkRateLimited("sk-a", time.Now().Add(time.Hour))
+	pool.Quarantine("sk-b", "401 Unauthorized")
+
+	var keys []KeyConfig
+	var loadErr error
+	var reloads []int
+	r := NewKeyReloader(pool, time.Minute, func() ([]KeyConfig, error) { return keys, loadErr }, func(n int, err error) {
+		if err != nil {
+			n = -1
+		}
+		reloads = append(reloads, n)
+	})
+	status := func() map[string]string {
+		out := map[string]string{}
+		for _, h := range pool.Health() {
+			out[h.Label] = h.Status
+		}
+		return out
+	}
+
+	// Unchanged keys are not swapped in.
+	keys = []KeyConfig{{Key: "sk-a"}, {Key: "sk-b"}}
+	r.Reload()
+	if len(reloads) != 0 {
+		t.Fatalf("reloads after an unchanged key set = %v, want none", reloads)
+	}
+
+	// A failed load leaves the pool untouched.
+	loadErr = errors.New("disk gone")
+	r.Reload()
+	loadErr = nil
+	if pool.Size() != 2 || !reflect.DeepEqual(reloads, []int{-1}) {
+		t.Fatalf("after a failed reload: %d keys, reloads %v, want 2 keys and one failure", pool.Size(), reloads)
+	}
+
+	// Kept keys keep their state, removed keys are dropped and new keys
+	// added.
+	keys = []KeyConfig{{Key: "sk-a"}, {Key: "sk-b"}, {Key: "sk-c"}}
+	r.Reload()
+	want := map[string]string{
+		Fingerprint("sk-a"): KeyRateLimited,
+		Fingerprint("sk-b"): KeyQuarantined,
+		Fingerprint("sk-c"): KeyActive,
+	}
+	if got := status(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after adding a key: %v, want %v", got, want)
+	}
+
+	keys = []KeyConfig{{Key: "sk-b", RPM: 10}, {Key: "sk-c"}}
+	r.Reload()
+	want = map[string]string{
+		Fingerprint("sk-b"): KeyActive, // New settings lift the quarantine
+		Fingerprint("sk-c"): KeyActive,
+	}
+	if got := status(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after removing a key: %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(reloads, []int{-1, 3, 2}) {
+		t.Errorf("reloads = %v, want [-1 3 2]", reloads)
+	}
+}
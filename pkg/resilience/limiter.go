@@ -0,0 +1,277 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when a request arrives and the wait queue is full.
+var ErrQueueFull = errors.New("limiter: queue full")
+
+// ErrQueueTimeout is returned when a request waited too long for a slot.
+var ErrQueueTimeout = errors.New("limiter: timed out waiting for a slot")
+
+// LimitOutcome classifies a completed request for the adaptive limiter.
+type LimitOutcome int
+
+const (
+	OutcomeSuccess LimitOutcome = iota // Completed — may grow the limit
+	OutcomeDropped                     // Throttled or timed out — shrink the limit
+	OutcomeIgnore                      // Unrelated to load (e.g. bad request)
+)
+
+// ReleaseFunc returns a slot to the limiter. latency is the sample used for
+// adaptation (for streams, the time to first chunk).
+type ReleaseFunc func(latency time.Duration, outcome LimitOutcome)
+
+// LimiterConfig holds configuration for a ConcurrencyLimiter.
+type LimiterConfig struct {
+	InitialLimit  int           // Starting concurrency limit
+	MinLimit      int           // Floor for the adaptive limit
+	MaxLimit      int           // Ceiling for the adaptive limit
+	TargetLatency time.Duration // Latency above this counts as congestion; 0 disables
+	BackoffRatio  float64       // Multiplicative decrease factor (e.g. 0.9)
+	MaxQueue      int           // Max requests waiting for a slot; 0 sheds immediately
+	QueueTimeout  time.Duration // Max wait for classes without their own Timeout
+
+	// Classes configures per-priority weights and queue deadlines.
+	// Nil uses DefaultQueueClasses.
+	Classes map[Priority]QueueClass
+}
+
+// DefaultLimiterConfig returns sensible defaults for a per-provider limiter.
+func DefaultLimiterConfig() LimiterConfig {
+	return LimiterConfig{
+		InitialLimit:  20,
+		MinLimit:      2,
+		MaxLimit:      200,
+		TargetLatency: 10 * time.Second,
+		BackoffRatio:  0.9,
+		MaxQueue:      50,
+		QueueTimeout:  2 * time.Second,
+		Classes:       DefaultQueueClasses(),
+	}
+}
+
+// LimiterStats is a point-in-time snapshot of a limiter.
+type LimiterStats struct {
+	Limit    int
+	InFlight int
+	Queued   map[Priority]int // Waiting requests per class
+}
+
+// ConcurrencyLimiter bounds in-flight requests with an AIMD limit: the limit
+// grows by one per window of successful requests and shrinks multiplicatively
+// on throttling or latency above the target. Excess requests wait in a
+// weighted fair queue, up to their class deadline, before being shed.
+type ConcurrencyLimiter struct {
+	mu sync.Mutex
+
+	cfg      LimiterConfig
+	limit    float64
+	inFlight int
+	waiters  *fairQueue
+}
+
+type waiter struct {
+	priority Priority
+	ready    chan struct{}
+	granted  bool // Set when a slot was handed over
+	evicted  bool // Set when displaced by a higher-priority request
+}
+
+// NewConcurrencyLimiter creates a new adaptive limiter with the given config.
+func NewConcurrencyLimiter(cfg LimiterConfig) *ConcurrencyLimiter {
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit < cfg.MinLimit {
+		cfg.InitialLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit > cfg.MaxLimit {
+		cfg.InitialLimit = cfg.MaxLimit
+	}
+	if cfg.BackoffRatio <= 0 || cfg.BackoffRatio >= 1 {
+		cfg.BackoffRatio = 0.9
+	}
+	if cfg.Classes == nil {
+		cfg.Classes = DefaultQueueClasses()
+	}
+
+	return &ConcurrencyLimiter{
+		cfg:     cfg,
+		limit:   float64(cfg.InitialLimit),
+		waiters: newFairQueue(cfg.Classes),
+	}
+}
+
+// NewFixedLimiter creates a non-adaptive limiter that sheds immediately once
+// max requests are in flight.
+func NewFixedLimiter(max int) *ConcurrencyLimiter {
+	return NewConcurrencyLimiter(LimiterConfig{
+		InitialLimit: max,
+		MinLimit:     max,
+		MaxLimit:     max,
+	})
+}
+
+// Acquire obtains a slot, waiting in the class queue if the limit is reached.
+// When the queue is full, a higher-priority request displaces the newest
+// waiter of a lower class. Returns ErrQueueFull or ErrQueueTimeout if the
+// request is shed.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, priority Priority) (ReleaseFunc, error) {
+	l.mu.Lock()
+
+	if l.inFlight < l.currentLimit() && l.waiters.Len() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.releaseFunc(), nil
+	}
+
+	if l.waiters.Len() >= l.cfg.MaxQueue {
+		victim := l.waiters.EvictBelow(priority)
+		if victim == nil {
+			l.mu.Unlock()
+			return nil, ErrQueueFull
+		}
+		victim.evicted = true
+		close(victim.ready)
+	}
+
+	w := &waiter{priority: priority, ready: make(chan struct{})}
+	l.waiters.Push(w)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if d := l.queueTimeout(priority); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-w.ready:
+		if w.evicted {
+			return nil, ErrQueueFull
+		}
+		return l.releaseFunc(), nil
+	case <-timeout:
+		if l.abandon(w) {
+			return l.releaseFunc(), nil
+		}
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		if l.abandon(w) {
+			return l.releaseFunc(), nil
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// TryAcquire obtains a slot only if one is free and no request is queued,
+// for optional work such as a hedged request that must not wait for, or
+// displace, a queued one.
+func (l *ConcurrencyLimiter) TryAcquire() (ReleaseFunc, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= l.currentLimit() || l.waiters.Len() > 0 {
+		return nil, false
+	}
+	l.inFlight++
+	return l.releaseFunc(), true
+}
+
+// Stats returns a snapshot of the limiter state.
+func (l *ConcurrencyLimiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	queued := make(map[Priority]int, numPriorities)
+	for _, p := range Priorities {
+		queued[p] = l.waiters.LenOf(p)
+	}
+
+	return LimiterStats{
+		Limit:    l.currentLimit(),
+		InFlight: l.inFlight,
+		Queued:   queued,
+	}
+}
+
+// queueTimeout returns the max wait for a class.
+func (l *ConcurrencyLimiter) queueTimeout(p Priority) time.Duration {
+	if c, ok := l.cfg.Classes[p]; ok && c.Timeout > 0 {
+		return c.Timeout
+	}
+	return l.cfg.QueueTimeout
+}
+
+// abandon removes a waiter from the queue. It returns true if the waiter was
+// granted a slot concurrently, in which case the caller owns that slot.
+func (l *ConcurrencyLimiter) abandon(w *waiter) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if w.granted {
+		return true
+	}
+	l.waiters.Remove(w)
+	return false
+}
+
+// releaseFunc returns a ReleaseFunc that may be called at most once.
+func (l *ConcurrencyLimiter) releaseFunc() ReleaseFunc {
+	var once sync.Once
+	return func(latency time.Duration, outcome LimitOutcome) {
+		once.Do(func() { l.release(latency, outcome) })
+	}
+}
+
+// release frees a slot, adapts the limit and hands slots to waiters.
+func (l *ConcurrencyLimiter) release(latency time.Duration, outcome LimitOutcome) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Only grow when the limit is actually being exercised, otherwise an
+	// idle limiter would drift up to MaxLimit.
+	saturated := float64(l.inFlight)*2 >= l.limit
+	l.inFlight--
+
+	congested := l.cfg.TargetLatency > 0 && latency > l.cfg.TargetLatency
+	switch {
+	case outcome == OutcomeDropped || (outcome == OutcomeSuccess && congested):
+		l.limit *= l.cfg.BackoffRatio
+	case outcome == OutcomeSuccess && saturated:
+		l.limit += 1 / l.limit
+	}
+	l.limit = math.Max(float64(l.cfg.MinLimit), math.Min(float64(l.cfg.MaxLimit), l.limit))
+
+	l.grantWaiters()
+}
+
+// grantWaiters hands free slots to queued requests. Must be called with mu held.
+func (l *ConcurrencyLimiter) grantWaiters() {
+	for l.waiters.Len() > 0 && l.inFlight < l.currentLimit() {
+		w := l.waiters.Pop()
+		w.granted = true
+		l.inFlight++
+		close(w.ready)
+	}
+}
+
+// currentLimit returns the integral limit. Must be called with mu held.
+func (l *ConcurrencyLimiter) currentLimit() int {
+	return int(l.limit)
+}
+
+// IsRateLimited returns true if the error represents an upstream 429.
+func IsRateLimited(err error) bool {
+	return err != nil && contains(err.Error(), "429")
+}
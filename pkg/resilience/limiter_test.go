@@ -0,0 +1,92 @@
+package resilience
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLimiterAIMD(t *testing.T) {
+	cfg := LimiterConfig{
+		InitialLimit:  10,
+		MinLimit:      2,
+		MaxLimit:      12,
+		TargetLatency: time.Second,
+		BackoffRatio:  0.5,
+	}
+
+	tests := []struct {
+		name     string
+		limit    float64
+		inFlight int
+		latency  time.Duration
+		outcome  LimitOutcome
+		want     float64
+	}{
+		{"success while saturated grows by 1/limit", 10, 5, 0, OutcomeSuccess, 10.1},
+		{"success while idle keeps the limit", 10, 4, 0, OutcomeSuccess, 10},
+		{"dropped shrinks", 10, 1, 0, OutcomeDropped, 5},
+		{"slow success shrinks", 10, 10, 2 * time.Second, OutcomeSuccess, 5},
+		{"slow ignored call keeps the limit", 10, 10, 2 * time.Second, OutcomeIgnore, 10},
+		{"ignored keeps the limit", 10, 10, 0, OutcomeIgnore, 10},
+		{"shrinking stops at the floor", 3, 1, 0, OutcomeDropped, 2},
+		{"growing stops at the ceiling", 12, 12, 0, OutcomeSuccess, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewConcurrencyLimiter(cfg)
+			l.limit = tt.limit
+			l.inFlight = tt.inFlight
+			l.release(tt.latency, tt.outcome)
+			if math.Abs(l.limit-tt.want) > 1e-9 {
+				t.Errorf("limit = %v, want %v", l.limit, tt.want)
+			}
+			if l.inFlight != tt.inFlight-1 {
+				t.Errorf("in flight = %d, want %d", l.inFlight, tt.inFlight-1)
+			}
+		})
+	}
+}
+
+func TestLimiterAdditiveIncrease(t *testing.T) {
+	l := NewConcurrencyLimiter(LimiterConfig{InitialLimit: 4, MinLimit: 1, MaxLimit: 100})
+
+	// A full window of successes at the limit raises it by about one.
+	for i := 0; i < 4; i++ {
+		l.inFlight = l.currentLimit()
+		l.release(0, OutcomeSuccess)
+	}
+	if l.limit < 4.9 || l.limit >= 5 {
+		t.Fatalf("limit after one window = %v, want just under 5", l.limit)
+	}
+	for i := 0; i < 10; i++ {
+		l.inFlight = l.currentLimit()
+		l.release(0, OutcomeSuccess)
+	}
+	if got := l.currentLimit(); got < 6 {
+		t.Errorf("limit after ten more successes = %d, want at least 6", got)
+	}
+}
+
+func TestLimiterTryAcquire(t *testing.T) {
+	l := NewFixedLimiter(2)
+	release, ok := l.TryAcquire()
+	if !ok {
+		t.Fatal("TryAcquire on an idle limiter failed")
+	}
+	if _, ok := l.TryAcquire(); !ok {
+		t.Fatal("TryAcquire below the limit failed")
+	}
+	if _, ok := l.TryAcquire(); ok {
+		t.Fatal("TryAcquire at the limit succeeded")
+	}
+
+	release(0, OutcomeIgnore)
+	l.waiters.Push(&waiter{priority: PriorityLow, ready: make(chan struct{})})
+	if _, ok := l.TryAcquire(); ok {
+		t.Error("TryAcquire took a free slot ahead of a queued request")
+	}
+	if got := l.Stats().InFlight; got != 1 {
+		t.Errorf("in flight = %d, want 1", got)
+	}
+}
@@ -0,0 +1,153 @@
+package resilience
+
+import (
+	"strings"
+	"time"
+)
+
+// Priority is the scheduling class of a request waiting for capacity.
+type Priority int
+
+const (
+	PriorityHigh   Priority = iota // Interactive user traffic
+	PriorityNormal                 // Default class
+	PriorityLow                    // Batch / back-office jobs
+
+	numPriorities = 3
+)
+
+// Priorities lists every class, highest first.
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// String returns the lowercase class name used in config and metrics.
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePriority parses a class name, defaulting to PriorityNormal.
+func ParsePriority(s string) Priority {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "high":
+		return PriorityHigh
+	case "low":
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// QueueClass configures how one priority class is queued.
+type QueueClass struct {
+	Weight  int           // Share of freed slots under contention
+	Timeout time.Duration // Max time a request of this class may wait
+}
+
+// DefaultQueueClasses returns the default per-class weights and deadlines:
+// interactive traffic gets most of the capacity but fails fast, batch work
+// gets a small share and waits longer.
+func DefaultQueueClasses() map[Priority]QueueClass {
+	return map[Priority]QueueClass{
+		PriorityHigh:   {Weight: 6, Timeout: 1 * time.Second},
+		PriorityNormal: {Weight: 3, Timeout: 2 * time.Second},
+		PriorityLow:    {Weight: 1, Timeout: 10 * time.Second},
+	}
+}
+
+// fairQueue is a weighted fair queue of waiters, one FIFO per class.
+// Classes are served by smooth weighted round-robin so lower classes are
+// never fully starved.
+type fairQueue struct {
+	queues  [numPriorities][]*waiter
+	weights [numPriorities]int
+	current [numPriorities]int // Smooth WRR running weights
+}
+
+func newFairQueue(classes map[Priority]QueueClass) *fairQueue {
+	q := &fairQueue{}
+	for _, p := range Priorities {
+		w := classes[p].Weight
+		if w <= 0 {
+			w = 1
+		}
+		q.weights[p] = w
+	}
+	return q
+}
+
+// Len returns the total number of waiters.
+func (q *fairQueue) Len() int {
+	n := 0
+	for _, queue := range q.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// LenOf returns the number of waiters in one class.
+func (q *fairQueue) LenOf(p Priority) int {
+	return len(q.queues[p])
+}
+
+// Push appends a waiter to its class queue.
+func (q *fairQueue) Push(w *waiter) {
+	q.queues[w.priority] = append(q.queues[w.priority], w)
+}
+
+// Pop removes the next waiter to be served, or nil if empty.
+func (q *fairQueue) Pop() *waiter {
+	total := 0
+	best := -1
+	for p := range q.queues {
+		if len(q.queues[p]) == 0 {
+			continue
+		}
+		q.current[p] += q.weights[p]
+		total += q.weights[p]
+		if best < 0 || q.current[p] > q.current[best] {
+			best = p
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+
+	q.current[best] -= total
+	w := q.queues[best][0]
+	q.queues[best] = q.queues[best][1:]
+	return w
+}
+
+// Remove deletes a specific waiter, reporting whether it was queued.
+func (q *fairQueue) Remove(w *waiter) bool {
+	queue := q.queues[w.priority]
+	for i, other := range queue {
+		if other == w {
+			q.queues[w.priority] = append(queue[:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// EvictBelow removes the most recently queued waiter of the lowest class
+// strictly below p, or returns nil if there is none.
+func (q *fairQueue) EvictBelow(p Priority) *waiter {
+	for c := numPriorities - 1; c > int(p); c-- {
+		queue := q.queues[c]
+		if n := len(queue); n > 0 {
+			w := queue[n-1]
+			q.queues[c] = queue[:n-1]
+			return w
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Priority
+	}{
+		{"high", PriorityHigh},
+		{" HIGH ", PriorityHigh},
+		{"low", PriorityLow},
+		{"normal", PriorityNormal},
+		{"", PriorityNormal},
+		{"urgent", PriorityNormal},
+	}
+	for _, tt := range tests {
+		if got := ParsePriority(tt.in); got != tt.want {
+			t.Errorf("ParsePriority(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFairQueueWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		classes map[Priority]QueueClass
+		queued  [numPriorities]int
+		pops    int
+		want    [numPriorities]int // Pops per class
+	}{
+		{"default weights", DefaultQueueClasses(), [numPriorities]int{100, 100, 100}, 10, [numPriorities]int{6, 3, 1}},
+		{"two classes", DefaultQueueClasses(), [numPriorities]int{100, 0, 100}, 14, [numPriorities]int{12, 0, 2}},
+		{"low class is never starved", DefaultQueueClasses(), [numPriorities]int{100, 100, 100}, 20, [numPriorities]int{12, 6, 2}},
+		{"missing weights count as one", map[Priority]QueueClass{}, [numPriorities]int{100, 100, 100}, 9, [numPriorities]int{3, 3, 3}},
+		{"a drained class gives way", DefaultQueueClasses(), [numPriorities]int{2, 0, 100}, 10, [numPriorities]int{2, 0, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newFairQueue(tt.classes)
+			for _, p := range Priorities {
+				for i := 0; i < tt.queued[p]; i++ {
+					q.Push(&waiter{priority: p})
+				}
+			}
+			var got [numPriorities]int
+			for i := 0; i < tt.pops; i++ {
+				got[q.Pop().priority]++
+			}
+			if got != tt.want {
+				t.Errorf("pops per class = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFairQueueOrder(t *testing.T) {
+	q := newFairQueue(DefaultQueueClasses())
+	if q.Pop() != nil {
+		t.Fatal("Pop on an empty queue returned a waiter")
+	}
+
+	a, b, c := &waiter{priority: PriorityLow}, &waiter{priority: PriorityLow}, &waiter{priority: PriorityLow}
+	high := &waiter{priority: PriorityHigh}
+	for _, w := range []*waiter{a, b, c, high} {
+		q.Push(w)
+	}
+
+	if got := q.EvictBelow(PriorityLow); got != nil {
+		t.Errorf("EvictBelow(low) = %v, want nil: nothing is below low", got)
+	}
+	if got := q.EvictBelow(PriorityHigh); got != c {
+		t.Errorf("EvictBelow(high) evicted %p, want the newest low waiter %p", got, c)
+	}
+	if !q.Remove(high) || q.Remove(high) {
+		t.Error("Remove should find the waiter once")
+	}
+	if got := q.Pop(); got != a {
+		t.Errorf("Pop = %p, want the oldest waiter %p", got, a)
+	}
+	if got := q.Pop(); got != b {
+		t.Errorf("Pop = %p, want %p", got, b)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len = %d, want 0", q.Len())
+	}
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	classes := map[Priority]QueueClass{
+		PriorityHigh:   {Weight: 1, Timeout: 20 * time.Millisecond},
+		PriorityNormal: {Weight: 1},
+		PriorityLow:    {Weight: 1, Timeout: time.Minute},
+	}
+	l := NewConcurrencyLimiter(LimiterConfig{InitialLimit: 1, MinLimit: 1, MaxLimit: 1, MaxQueue: 1, QueueTimeout: 30 * time.Millisecond, Classes: classes})
+	ctx := context.Background()
+
+	release, err := l.Acquire(ctx, PriorityNormal)
+	if err != nil {
+		t.Fatalf("first Acquire: %v", err)
+	}
+
+	// The queue holds a low-priority waiter until a high-priority request
+	// displaces it.
+	lowErr := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, PriorityLow)
+		lowErr <- err
+	}()
+	waitQueued(t, l, PriorityLow, 1)
+
+	if _, err := l.Acquire(ctx, PriorityLow); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Acquire with a full queue = %v, want ErrQueueFull", err)
+	}
+
+	start := time.Now()
+	if _, err := l.Acquire(ctx, PriorityHigh); !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("high-priority Acquire = %v, want ErrQueueTimeout after its class deadline", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("high-priority Acquire waited %s, want its 20ms deadline", waited)
+	}
+	if err := <-lowErr; !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("displaced waiter got %v, want ErrQueueFull", err)
+	}
+
+	// A class without its own deadline uses QueueTimeout.
+	if _, err := l.Acquire(ctx, PriorityNormal); !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("normal-priority Acquire = %v, want ErrQueueTimeout", err)
+	}
+
+	// A released slot goes to the next waiter.
+	granted := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, PriorityLow)
+		granted <- err
+	}()
+	waitQueued(t, l, PriorityLow, 1)
+	release(0, OutcomeIgnore)
+	if err := <-granted; err != nil {
+		t.Fatalf("queued Acquire after release = %v", err)
+	}
+	if s := l.Stats(); s.InFlight != 1 || s.Queued[PriorityLow] != 0 {
+		t.Errorf("stats = %+v, want one in flight and none queued", s)
+	}
+}
+
+func TestLimiterQueueTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		classes map[Priority]QueueClass
+		p       Priority
+		want    time.Duration
+	}{
+		{"class deadline", map[Priority]QueueClass{PriorityHigh: {Weight: 1, Timeout: time.Second}}, PriorityHigh, time.Second},
+		{"class without a deadline", map[Priority]QueueClass{PriorityHigh: {Weight: 1}}, PriorityHigh, 5 * time.Second},
+		{"class not configured", map[Priority]QueueClass{PriorityHigh: {Weight: 1}}, PriorityLow, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewConcurrencyLimiter(LimiterConfig{QueueTimeout: 5 * time.Second, Classes: tt.classes})
+			if got := l.queueTimeout(tt.p); got != tt.want {
+				t.Errorf("queueTimeout(%s) = %s, want %s", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+// waitQueued waits until n requests of class p are queued.
+func waitQueued(t *testing.T, l *ConcurrencyLimiter, p Priority, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for l.Stats().Queued[p] != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d %s requests never queued", n, p)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
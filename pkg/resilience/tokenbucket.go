@@ -0,0 +1,69 @@
+package resilience
+
+import "time"
+
+// TokenBucket is a simple token bucket refilled continuously at a fixed
+// per-minute rate. It is not safe for concurrent use; callers hold their
+// own lock (e.g. KeyPool.mu).
+type TokenBucket struct {
+	capacity float64
+	rate     float64 // Tokens per second
+	tokens   float64
+	last     time.Time
+}
+
+// NewTokenBucket creates a full bucket holding perMinute tokens that refills
+// at perMinute tokens per minute.
+func NewTokenBucket(perMinute float64) *TokenBucket {
+	return &TokenBucket{
+		capacity: perMinute,
+		rate:     perMinute / 60,
+		tokens:   perMinute,
+		last:     time.Now(),
+	}
+}
+
+// Available returns the number of tokens currently in the bucket.
+// It may be negative if more was taken than was available.
+func (b *TokenBucket) Available(now time.Time) float64 {
+	b.refill(now)
+	return b.tokens
+}
+
+// Take removes n tokens. The balance may go negative, which delays
+// availability until the debt is refilled.
+func (b *TokenBucket) Take(n float64, now time.Time) {
+	b.refill(now)
+	b.tokens -= n
+}
+
+// Used returns the fraction (0–1+) of the bucket capacity currently consumed.
+func (b *TokenBucket) Used(now time.Time) float64 {
+	b.refill(now)
+	if b.capacity <= 0 {
+		return 0
+	}
+	return (b.capacity - b.tokens) / b.capacity
+}
+
+// TimeUntil returns how long until at least n tokens are available.
+func (b *TokenBucket) TimeUntil(n float64, now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= n || b.rate <= 0 {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// refill adds tokens accrued since the last update.
+func (b *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
@@ -0,0 +1,512 @@
+// Package schema validates model output against JSON Schema documents.
+//
+// It implements the subset of JSON Schema that structured-output APIs
+// accept: type (including type arrays and nullable), properties, required,
+// additionalProperties, items, prefixItems, enum, const, numeric and length
+// bounds, pattern, uniqueItems, allOf / anyOf / oneOf / not, and local $ref
+// into $defs or definitions. Other keywords, such as format, are ignored.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxErrors caps how many validation errors are reported.
+const maxErrors = 20
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	root     map[string]any
+	patterns map[string]*regexp.Regexp
+}
+
+// Compile parses a JSON Schema document.
+func Compile(doc []byte) (*Schema, error) {
+	var root map[string]any
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, fmt.Errorf("schema: parse: %w", err)
+	}
+	s := &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	if err := s.compilePatterns(root); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Root returns the decoded schema document.
+func (s *Schema) Root() map[string]any {
+	return s.root
+}
+
+// compilePatterns pre-compiles every "pattern" keyword so invalid schemas
+// are rejected up front.
+func (s *Schema) compilePatterns(v any) error {
+	switch n := v.(type) {
+	case map[string]any:
+		if p, ok := n["pattern"].(string); ok {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("schema: pattern %q: %w", p, err)
+			}
+			s.patterns[p] = re
+		}
+		for _, child := range n {
+			if err := s.compilePatterns(child); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, child := range n {
+			if err := s.compilePatterns(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Extract returns the JSON document in a model reply, dropping surrounding
+// whitespace and a Markdown code fence if the model added one.
+func Extract(text string) string {
+	t := strings.TrimSpace(text)
+	if strings.HasPrefix(t, "```") {
+		if nl := strings.IndexByte(t, '\n'); nl >= 0 {
+			t = t[nl+1:]
+		}
+		t = strings.TrimSuffix(strings.TrimSpace(t), "```")
+		t = strings.TrimSpace(t)
+	}
+	return t
+}
+
+// ValidateText parses text as JSON and validates it. A nil Schema only
+// requires a JSON object. It returns the extracted document and the
+// validation errors, if any.
+func ValidateText(s *Schema, text string) (string, []string) {
+	doc := Extract(text)
+
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return doc, []string{"response is not valid JSON: " + err.Error()}
+	}
+	if dec.More() {
+		return doc, []string{"response has trailing data after the JSON value"}
+	}
+
+	if s == nil {
+		if _, ok := v.(map[string]any); !ok {
+			return doc, []string{"$: expected a JSON object"}
+		}
+		return doc, nil
+	}
+	return doc, s.Validate(v)
+}
+
+// Validate checks a decoded value (numbers as json.Number or float64).
+func (s *Schema) Validate(v any) []string {
+	var errs []string
+	s.validate(s.root, v, "$", &errs)
+	if len(errs) > maxErrors {
+		errs = append(errs[:maxErrors], fmt.Sprintf("... and %d more", len(errs)-maxErrors))
+	}
+	return errs
+}
+
+func (s *Schema) validate(node map[string]any, v any, path string, errs *[]string) {
+	if ref, ok := node["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		if err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s: %v", path, err))
+			return
+		}
+		node = target
+	}
+
+	if v == nil {
+		if b, _ := node["nullable"].(bool); b {
+			return
+		}
+	}
+
+	if t, ok := node["type"]; ok && !matchesType(t, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, typeString(t), typeOf(v)))
+		return
+	}
+
+	if enum, ok := node["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*errs = append(*errs, fmt.Sprintf("%s: %s is not one of %s", path, compact(v), compact(enum)))
+		}
+	}
+	if c, ok := node["const"]; ok && !equal(c, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: must equal %s", path, compact(c)))
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		s.validateObject(node, val, path, errs)
+	case []any:
+		s.validateArray(node, val, path, errs)
+	case string:
+		s.validateString(node, val, path, errs)
+	case json.Number, float64:
+		validateNumber(node, toFloat(val), path, errs)
+	}
+
+	s.validateCombinators(node, v, path, errs)
+}
+
+func (s *Schema) validateObject(node map[string]any, obj map[string]any, path string, errs *[]string) {
+	props, _ := node["properties"].(map[string]any)
+
+	if req, ok := node["required"].([]any); ok {
+		for _, r := range req {
+			name, _ := r.(string)
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := path + "." + k
+		if ps, ok := props[k].(map[string]any); ok {
+			s.validate(ps, obj[k], child, errs)
+			continue
+		}
+		switch ap := node["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				*errs = append(*errs, fmt.Sprintf("%s: unexpected property %q", path, k))
+			}
+		case map[string]any:
+			s.validate(ap, obj[k], child, errs)
+		}
+	}
+
+	if n, ok := intKeyword(node, "minProperties"); ok && len(obj) < n {
+		*errs = append(*errs, fmt.Sprintf("%s: expected at least %d properties", path, n))
+	}
+	if n, ok := intKeyword(node, "maxProperties"); ok && len(obj) > n {
+		*errs = append(*errs, fmt.Sprintf("%s: expected at most %d properties", path, n))
+	}
+}
+
+func (s *Schema) validateArray(node map[string]any, arr []any, path string, errs *[]string) {
+	start := 0
+	if prefix, ok := node["prefixItems"].([]any); ok {
+		for i, ps := range prefix {
+			if i >= len(arr) {
+				break
+			}
+			if m, ok := ps.(map[string]any); ok {
+				s.validate(m, arr[i], fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+		start = len(prefix)
+	}
+	if items, ok := node["items"].(map[string]any); ok {
+		for i := start; i < len(arr); i++ {
+			s.validate(items, arr[i], fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+
+	if n, ok := intKeyword(node, "minItems"); ok && len(arr) < n {
+		*errs = append(*errs, fmt.Sprintf("%s: expected at least %d items, got %d", path, n, len(arr)))
+	}
+	if n, ok := intKeyword(node, "maxItems"); ok && len(arr) > n {
+		*errs = append(*errs, fmt.Sprintf("%s: expected at most %d items, got %d", path, n, len(arr)))
+	}
+	if u, _ := node["uniqueItems"].(bool); u {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if equal(arr[i], arr[j]) {
+					*errs = append(*errs, fmt.Sprintf("%s: items %d and %d are equal", path, i, j))
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Schema) validateString(node map[string]any, str string, path string, errs *[]string) {
+	n := utf8.RuneCountInString(str)
+	if min, ok := intKeyword(node, "minLength"); ok && n < min {
+		*errs = append(*errs, fmt.Sprintf("%s: shorter than %d characters", path, min))
+	}
+	if max, ok := intKeyword(node, "maxLength"); ok && n > max {
+		*errs = append(*errs, fmt.Sprintf("%s: longer than %d characters", path, max))
+	}
+	if p, ok := node["pattern"].(string); ok {
+		if re := s.patterns[p]; re != nil && !re.MatchString(str) {
+			*errs = append(*errs, fmt.Sprintf("%s: does not match pattern %q", path, p))
+		}
+	}
+}
+
+func validateNumber(node map[string]any, f float64, path string, errs *[]string) {
+	if min, ok := floatKeyword(node, "minimum"); ok && f < min {
+		*errs = append(*errs, fmt.Sprintf("%s: %v is less than the minimum %v", path, f, min))
+	}
+	if max, ok := floatKeyword(node, "maximum"); ok && f > max {
+		*errs = append(*errs, fmt.Sprintf("%s: %v is greater than the maximum %v", path, f, max))
+	}
+	if min, ok := floatKeyword(node, "exclusiveMinimum"); ok && f <= min {
+		*errs = append(*errs, fmt.Sprintf("%s: %v must be greater than %v", path, f, min))
+	}
+	if max, ok := floatKeyword(node, "exclusiveMaximum"); ok && f >= max {
+		*errs = append(*errs, fmt.Sprintf("%s: %v must be less than %v", path, f, max))
+	}
+	if m, ok := floatKeyword(node, "multipleOf"); ok && m > 0 {
+		if q := f / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is not a multiple of %v", path, f, m))
+		}
+	}
+}
+
+func (s *Schema) validateCombinators(node map[string]any, v any, path string, errs *[]string) {
+	if all, ok := node["allOf"].([]any); ok {
+		for _, sub := range all {
+			if m, ok := sub.(map[string]any); ok {
+				s.validate(m, v, path, errs)
+			}
+		}
+	}
+	if any_, ok := node["anyOf"].([]any); ok && s.countMatches(any_, v, path) == 0 {
+		*errs = append(*errs, fmt.Sprintf("%s: does not match any of the allowed schemas", path))
+	}
+	if one, ok := node["oneOf"].([]any); ok {
+		if n := s.countMatches(one, v, path); n != 1 {
+			*errs = append(*errs, fmt.Sprintf("%s: matches %d schemas, expected exactly one", path, n))
+		}
+	}
+	if not, ok := node["not"].(map[string]any); ok {
+		var sub []string
+		s.validate(not, v, path, &sub)
+		if len(sub) == 0 {
+			*errs = append(*errs, fmt.Sprintf("%s: matches a schema it must not match", path))
+		}
+	}
+}
+
+func (s *Schema) countMatches(schemas []any, v any, path string) int {
+	n := 0
+	for _, sub := range schemas {
+		m, ok := sub.(map[string]any)
+		if !ok {
+			continue
+		}
+		var subErrs []string
+		s.validate(m, v, path, &subErrs)
+		if len(subErrs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// resolve follows a local JSON pointer such as "#/$defs/Item".
+func (s *Schema) resolve(ref string) (map[string]any, error) {
+	if ref == "#" {
+		return s.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q (only local references)", ref)
+	}
+
+	var cur any = s.root
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		cur = m[part]
+	}
+	m, ok := cur.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+	return m, nil
+}
+
+// matchesType checks v against a "type" keyword, a string or a list.
+func matchesType(t any, v any) bool {
+	switch tt := t.(type) {
+	case string:
+		return isType(tt, v)
+	case []any:
+		for _, x := range tt {
+			if name, ok := x.(string); ok && isType(name, v) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func isType(name string, v any) bool {
+	switch name {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		switch v.(type) {
+		case json.Number, float64:
+			return true
+		}
+		return false
+	case "integer":
+		switch v.(type) {
+		case json.Number, float64:
+			f := toFloat(v)
+			return f == math.Trunc(f)
+		}
+		return false
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	default:
+		return true
+	}
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number, float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func typeString(t any) string {
+	if list, ok := t.([]any); ok {
+		names := make([]string, 0, len(list))
+		for _, x := range list {
+			names = append(names, fmt.Sprint(x))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func intKeyword(node map[string]any, key string) (int, bool) {
+	f, ok := floatKeyword(node, key)
+	return int(f), ok
+}
+
+func floatKeyword(node map[string]any, key string) (float64, bool) {
+	switch n := node[key].(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equal compares JSON values structurally, treating numbers by value.
+func equal(a, b any) bool {
+	switch av := a.(type) {
+	case json.Number, float64:
+		switch b.(type) {
+		case json.Number, float64:
+			return toFloat(av) == toFloat(b)
+		}
+		return false
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, x := range av {
+			y, ok := bv[k]
+			if !ok || !equal(x, y) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func compact(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(buf.String())
+}
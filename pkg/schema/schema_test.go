@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bare", `{"a":1}`, `{"a":1}`},
+		{"whitespace", "\n  {\"a\":1}  \n", `{"a":1}`},
+		{"fenced", "```json\n{\"a\":1}\n```", `{"a":1}`},
+		{"fenced without language", "```\n{\"a\":1}\n```\n", `{"a":1}`},
+		{"prose is kept", `Here: {"a":1}`, `Here: {"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Extract(tt.in); got != tt.want {
+				t.Errorf("Extract(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{"valid", `{"type":"object","properties":{"id":{"type":"string","pattern":"^[a-z]+$"}}}`, ""},
+		{"not json", `{"type":`, "schema: parse"},
+		{"bad pattern in a nested schema", `{"items":{"pattern":"("}}`, "schema: pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.doc))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Compile: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Compile error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+const personSchema = `{
+	"type": "object",
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 10},
+		"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+		"email": {"type": ["string", "null"], "pattern": "^[^@]+@[^@]+$"},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+		"point": {"type": "array", "prefixItems": [{"type": "number"}, {"type": "number"}], "minItems": 2},
+		"address": {"$ref": "#/$defs/address"},
+		"id": {"oneOf": [{"type": "integer"}, {"type": "string", "minLength": 3}]},
+		"score": {"type": "number", "multipleOf": 0.5},
+		"nick": {"type": "string", "nullable": true},
+		"kind": {"const": "person"},
+		"extra": {"not": {"type": "string"}}
+	},
+	"$defs": {
+		"address": {"type": "object", "required": ["city"], "properties": {"city": {"type": "string"}}}
+	}
+}`
+
+func TestValidateText(t *testing.T) {
+	s, err := Compile([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		schema *Schema
+		text   string
+		want   []string // Substrings of the expected errors, in order
+	}{
+		{"valid", s, `{"name":"Ann","age":30}`, nil},
+		{"valid with every keyword", s, `{"name":"Ann","age":30,"email":null,"role":"admin","tags":["a","b"],"point":[1,2.5],` +
+			`"address":{"city":"Oslo"},"id":"abc","score":1.5,"nick":null,"kind":"person","extra":1}`, nil},
+		{"fenced reply", s, "```json\n{\"name\":\"Ann\",\"age\":30}\n```", nil},
+		{"not json", s, `{"name":`, []string{"response is not valid JSON"}},
+		{"trailing data", s, `{"name":"Ann","age":30} {}`, []string{"trailing data"}},
+		{"wrong root type", s, `[1]`, []string{"$: expected object, got array"}},
+		{"missing required", s, `{"name":"Ann"}`, []string{`$: missing required property "age"`}},
+		{"unexpected property", s, `{"name":"Ann","age":30,"x":1}`, []string{`$: unexpected property "x"`}},
+		{"integer", s, `{"name":"Ann","age":30.5}`, []string{"$.age: expected integer, got number"}},
+		{"bounds", s, `{"name":"","age":150}`, []string{"$.age: 150 must be less than 150", "$.name: shorter than 1 characters"}},
+		{"length counts runes", s, `{"name":"ÅÅÅÅÅÅÅÅÅÅ","age":1}`, nil},
+		{"pattern", s, `{"name":"Ann","age":1,"email":"nope"}`, []string{`$.email: does not match pattern`}},
+		{"enum", s, `{"name":"Ann","age":1,"role":"root"}`, []string{`$.role: "root" is not one of ["admin","user"]`}},
+		{"array items", s, `{"name":"Ann","age":1,"tags":["a",1,"b"]}`, []string{"$.tags[1]: expected string, got number", "$.tags: expected at most 2 items, got 3"}},
+		{"unique items", s, `{"name":"Ann","age":1,"tags":["a","a"]}`, []string{"$.tags: items 0 and 1 are equal"}},
+		{"prefix items", s, `{"name":"Ann","age":1,"point":["x"]}`, []string{"$.point[0]: expected number, got string", "$.point: expected at least 2 items, got 1"}},
+		{"ref", s, `{"name":"Ann","age":1,"address":{}}`, []string{`$.address: missing required property "city"`}},
+		{"one of none", s, `{"name":"Ann","age":1,"id":"ab"}`, []string{"$.id: matches 0 schemas, expected exactly one"}},
+		{"multiple of", s, `{"name":"Ann","age":1,"score":0.3}`, []string{"$.score: 0.3 is not a multiple of 0.5"}},
+		{"const", s, `{"name":"Ann","age":1,"kind":"robot"}`, []string{`$.kind: must equal "person"`}},
+		{"not", s, `{"name":"Ann","age":1,"extra":"x"}`, []string{"$.extra: matches a schema it must not match"}},
+		{"json mode needs an object", nil, `"text"`, []string{"$: expected a JSON object"}},
+		{"json mode accepts any object", nil, `{"anything":[1,2]}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := ValidateText(tt.schema, tt.text)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("ValidateText(%s) = %q, want %d errors matching %q", tt.text, errs, len(tt.want), tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(errs[i], want) {
+					t.Errorf("error %d = %q, want it to contain %q", i, errs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCapsErrors(t *testing.T) {
+	s, err := Compile([]byte(`{"type":"array","items":{"type":"string"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs := ValidateText(s, "["+strings.Repeat("1,", 30)+"1]")
+	if len(errs) != maxErrors+1 || errs[maxErrors] != "... and 11 more" {
+		t.Errorf("got %d errors ending in %q, want %d and a summary", len(errs), errs[len(errs)-1], maxErrors+1)
+	}
+}
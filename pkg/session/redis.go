@@ -0,0 +1,158 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps sessions in Redis as JSON, so any replica can serve the
+// next turn and sessions survive restarts. PII placeholder values are
+// sealed with AES-GCM and can only be stored when the store has a key.
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+	aead   cipher.AEAD // nil without a key
+}
+
+// redisSession is a session as stored in Redis, with its placeholder values
+// sealed.
+type redisSession struct {
+	Session
+	Placeholders []byte `json:"placeholders,omitempty"` // Nonce followed by the sealed JSON map
+}
+
+// NewRedisStore creates a Redis-backed store whose sessions expire ttl
+// after their last turn. key is the AES key (16, 24 or 32 bytes) that seals
+// placeholder values; without one, sessions with placeholders are refused.
+func NewRedisStore(addr, password string, db int, ttl time.Duration, key []byte) (*RedisStore, error) {
+	r := &RedisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+		ttl: ttl,
+	}
+	if len(key) > 0 {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("session: key: %w", err)
+		}
+		if r.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("session: key: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// KeepsPlaceholders reports whether the store has a key to seal
+// placeholder values with.
+func (r *RedisStore) KeepsPlaceholders() bool {
+	return r.aead != nil
+}
+
+func (r *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	data, err := r.client.Get(ctx, sessionKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session: get: %w", err)
+	}
+
+	var rs redisSession
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("session: decode %s: %w", id, err)
+	}
+	if len(rs.Placeholders) > 0 {
+		if rs.Session.Placeholders, err = r.open(rs.Placeholders); err != nil {
+			return nil, fmt.Errorf("session: decode %s: %w", id, err)
+		}
+	}
+	return &rs.Session, nil
+}
+
+func (r *RedisStore) Put(ctx context.Context, s *Session) error {
+	rs := redisSession{Session: *s}
+	if len(s.Placeholders) > 0 {
+		sealed, err := r.seal(s.Placeholders)
+		if err != nil {
+			return fmt.Errorf("session: encode %s: %w", s.ID, err)
+		}
+		rs.Placeholders = sealed
+	}
+	data, err := json.Marshal(rs)
+	if err != nil {
+		return fmt.Errorf("session: encode %s: %w", s.ID, err)
+	}
+	if err := r.client.Set(ctx, sessionKey(s.ID), data, r.ttl).Err(); err != nil {
+		return fmt.Errorf("session: set: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStore) Delete(ctx context.Context, id string) error {
+	if err := r.client.Del(ctx, sessionKey(id)).Err(); err != nil {
+		return fmt.Errorf("session: delete: %w", err)
+	}
+	return nil
+}
+
+// seal encrypts placeholder values under a random nonce.
+func (r *RedisStore) seal(placeholders map[string]string) ([]byte, error) {
+	if r.aead == nil {
+		return nil, errors.New("placeholders cannot be stored without a key")
+	}
+	plain, err := json.Marshal(placeholders)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, r.aead.NonceSize(), r.aead.NonceSize()+len(plain)+r.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return r.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+// open decrypts placeholder values sealed by seal.
+func (r *RedisStore) open(sealed []byte) (map[string]string, error) {
+	if r.aead == nil {
+		return nil, errors.New("placeholders cannot be read without a key")
+	}
+	n := r.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("placeholders: sealed data too short")
+	}
+	plain, err := r.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("placeholders: %w", err)
+	}
+	var placeholders map[string]string
+	if err := json.Unmarshal(plain, &placeholders); err != nil {
+		return nil, fmt.Errorf("placeholders: %w", err)
+	}
+	return placeholders, nil
+}
+
+// Ping checks the Redis connection.
+func (r *RedisStore) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Close closes the Redis connection.
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
+
+// sessionKey is the Redis key of a session.
+func sessionKey(id string) string {
+	return "llm_session:" + id
+}
@@ -0,0 +1,162 @@
+// Package session stores chat conversations between the turns of a Chat
+// stream, in memory or in Redis, with a TTL refreshed on every turn.
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned for sessions that do not exist or have expired.
+var ErrNotFound = errors.New("session: not found")
+
+// Turn is one message of a conversation.
+type Turn struct {
+	Role string    `json:"role"` // provider.RoleUser, RoleAssistant or RoleSystem
+	Text string    `json:"text"`
+	At   time.Time `json:"at"`
+}
+
+// Session is a stored conversation. Turns hold the text the provider saw:
+// prompts after PII redaction and replies before placeholders are restored.
+// Placeholders maps the PII placeholders issued in any turn to their
+// original values, so that every turn numbers and restores them alike.
+type Session struct {
+	ID           string            `json:"id"`
+	Tenant       string            `json:"tenant,omitempty"` // Owner; other tenants cannot resume it
+	Model        string            `json:"model"`
+	Turns        []Turn            `json:"turns"`
+	Placeholders map[string]string `json:"placeholders,omitempty"`
+	Created      time.Time         `json:"created"`
+	Updated      time.Time         `json:"updated"`
+}
+
+// New creates an empty session with a random ID.
+func New(tenant, model string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:      "sess_" + uuid.New().String(),
+		Tenant:  tenant,
+		Model:   model,
+		Created: now,
+		Updated: now,
+	}
+}
+
+// Append adds a turn, dropping the oldest turns beyond maxTurns (0 for no
+// limit).
+func (s *Session) Append(role, text string, maxTurns int) {
+	now := time.Now()
+	s.Turns = append(s.Turns, Turn{Role: role, Text: text, At: now})
+	if maxTurns > 0 && len(s.Turns) > maxTurns {
+		s.Turns = append([]Turn(nil), s.Turns[len(s.Turns)-maxTurns:]...)
+	}
+	s.Updated = now
+}
+
+// clone returns a copy of s that shares no turns or placeholders with it.
+func (s *Session) clone() *Session {
+	c := *s
+	c.Turns = append([]Turn(nil), s.Turns...)
+	if s.Placeholders != nil {
+		c.Placeholders = make(map[string]string, len(s.Placeholders))
+		for k, v := range s.Placeholders {
+			c.Placeholders[k] = v
+		}
+	}
+	return &c
+}
+
+// Store persists sessions.
+type Store interface {
+	// Get returns the session with id, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Session, error)
+
+	// Put saves s and restarts its TTL.
+	Put(ctx context.Context, s *Session) error
+
+	// Delete removes the session with id. Deleting a missing session is
+	// not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// KeepsPlaceholders reports whether st can store sessions' PII placeholder
+// values: the memory store always can, the Redis store only with an
+// encryption key.
+func KeepsPlaceholders(st Store) bool {
+	k, ok := st.(interface{ KeepsPlaceholders() bool })
+	return ok && k.KeepsPlaceholders()
+}
+
+// MemoryStore keeps sessions in process memory. Sessions are lost on
+// restart and are not shared between replicas.
+type MemoryStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	sessions  map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	session *Session
+	expires time.Time
+}
+
+// sweepInterval bounds how often Put scans for expired sessions.
+const sweepInterval = time.Minute
+
+// NewMemoryStore creates an in-memory store whose sessions expire ttl
+// after their last turn.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:       ttl,
+		sessions:  make(map[string]memoryEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *MemoryStore) Get(_ context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.sessions[id]
+	if !ok || time.Now().After(e.expires) {
+		delete(m.sessions, id)
+		return nil, ErrNotFound
+	}
+	return e.session.clone(), nil
+}
+
+func (m *MemoryStore) Put(_ context.Context, s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sessions[s.ID] = memoryEntry{session: s.clone(), expires: now.Add(m.ttl)}
+
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		for id, e := range m.sessions {
+			if now.After(e.expires) {
+				delete(m.sessions, id)
+			}
+		}
+		m.lastSweep = now
+	}
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+// KeepsPlaceholders is always true: placeholder values stay in process
+// memory.
+func (m *MemoryStore) KeepsPlaceholders() bool { return true }
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAppend(t *testing.T) {
+	s := New("acme", "gpt-4o")
+	for _, text := range []string{"a", "b", "c", "d"} {
+		s.Append("user", text, 3)
+	}
+	if len(s.Turns) != 3 || s.Turns[0].Text != "b" || s.Turns[2].Text != "d" {
+		t.Errorf("turns = %+v, want the latest 3", s.Turns)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore(time.Hour)
+	s := New("acme", "gpt-4o")
+	s.Append("user", "hi", 0)
+	s.Placeholders = map[string]string{"[EMAIL_1]": "a@example.com"}
+	if err := m.Put(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stored sessions do not share turns or placeholders with callers.
+	s.Append("assistant", "hello", 0)
+	s.Placeholders["[EMAIL_2]"] = "b@example.com"
+	got, err := m.Get(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Turns) != 1 || len(got.Placeholders) != 1 {
+		t.Errorf("stored session = %+v, want it as it was put", got)
+	}
+	got.Turns[0].Text = "changed"
+	if again, _ := m.Get(ctx, s.ID); again.Turns[0].Text != "hi" {
+		t.Errorf("stored turn = %q after editing a copy, want hi", again.Turns[0].Text)
+	}
+
+	if err := m.Delete(ctx, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(ctx, s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := m.Delete(ctx, s.ID); err != nil {
+		t.Errorf("Delete of a missing session = %v, want nil", err)
+	}
+
+	expired := NewMemoryStore(-time.Second)
+	expired.Put(ctx, s)
+	if _, err := expired.Get(ctx, s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an expired session = %v, want ErrNotFound", err)
+	}
+	if !KeepsPlaceholders(m) {
+		t.Error("KeepsPlaceholders(memory store) = false, want true")
+	}
+}
@@ -0,0 +1,389 @@
+// Package tokenizer counts prompt tokens before a request reaches the
+// provider: byte-pair encoding for OpenAI model families when the
+// encoding's rank file is available, and an approximation otherwise.
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts and truncates text in a model's tokens.
+type Tokenizer interface {
+	// Name identifies the encoding, e.g. "o200k_base" or "estimate".
+	Name() string
+
+	// Count returns the number of tokens in text.
+	Count(text string) int
+
+	// KeepLast returns the longest suffix of text of at most n tokens.
+	KeepLast(text string, n int) string
+}
+
+// Set holds the BPE encodings loaded from disk and falls back to an
+// Estimator for everything else.
+type Set struct {
+	encodings map[string]*BPE
+	estimator Estimator
+}
+
+// NewSet creates a set with no BPE encodings.
+func NewSet() *Set {
+	return &Set{encodings: make(map[string]*BPE)}
+}
+
+// LoadSet loads every "<encoding>.tiktoken" rank file in dir, e.g.
+// o200k_base.tiktoken as published for tiktoken.
+func LoadSet(dir string) (*Set, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tiktoken"))
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: %w", err)
+	}
+
+	s := NewSet()
+	for _, path := range paths {
+		bpe, err := LoadBPE(path)
+		if err != nil {
+			return nil, err
+		}
+		s.encodings[bpe.name] = bpe
+	}
+	return s, nil
+}
+
+// Encodings returns the names of the loaded BPE encodings.
+func (s *Set) Encodings() []string {
+	names := make([]string, 0, len(s.encodings))
+	for name := range s.encodings {
+		names = append(names, name)
+	}
+	return names
+}
+
+// For returns the tokenizer of encoding, or the estimator if it is empty or
+// not loaded. A nil Set always returns the estimator.
+func (s *Set) For(encoding string) Tokenizer {
+	if s != nil {
+		if bpe, ok := s.encodings[encoding]; ok {
+			return bpe
+		}
+	}
+	return Estimator{}
+}
+
+// ---------------------------------------------------------------------------
+// Estimator
+// ---------------------------------------------------------------------------
+
+// Estimator approximates token counts without a vocabulary: words and
+// punctuation runs count about one token per four bytes, at least one each,
+// and each character of scripts written without spaces (CJK) counts as a
+// token.
+type Estimator struct{}
+
+func (Estimator) Name() string { return "estimate" }
+
+func (Estimator) Count(text string) int {
+	n := 0
+	for _, piece := range split(text) {
+		n += estimatePiece(piece)
+	}
+	return n
+}
+
+func (Estimator) KeepLast(text string, n int) string {
+	pieces := split(text)
+	i := len(pieces)
+	for kept := 0; i > 0; i-- {
+		kept += estimatePiece(pieces[i-1])
+		if kept > n {
+			break
+		}
+	}
+	return strings.Join(pieces[i:], "")
+}
+
+// estimatePiece estimates the tokens of one pre-tokenized piece.
+func estimatePiece(piece string) int {
+	wide := 0
+	for _, r := range piece {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			wide++
+		}
+	}
+	if wide > 0 {
+		return wide + (len(piece)-wide*3+3)/4
+	}
+	return max(1, (len(piece)+1)/4)
+}
+
+// ---------------------------------------------------------------------------
+// BPE
+// ---------------------------------------------------------------------------
+
+// BPE is a byte-pair encoding loaded from a tiktoken rank file.
+type BPE struct {
+	name  string
+	ranks map[string]int
+	// tokens maps ranks back to bytes, for truncation.
+	tokens map[int]string
+}
+
+// LoadBPE reads a tiktoken rank file: one base64 token and its rank per
+// line. The encoding is named after the file.
+func LoadBPE(path string) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	bpe := &BPE{
+		name:   strings.TrimSuffix(filepath.Base(path), ".tiktoken"),
+		ranks:  make(map[string]int),
+		tokens: make(map[int]string),
+	}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("tokenizer: %s:%d: expected token and rank", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer: %s:%d: %w", path, line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer: %s:%d: %w", path, line, err)
+		}
+		bpe.ranks[string(token)] = rank
+		bpe.tokens[rank] = string(token)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("tokenizer: read %s: %w", path, err)
+	}
+	if len(bpe.ranks) == 0 {
+		return nil, fmt.Errorf("tokenizer: %s: no tokens", path)
+	}
+	return bpe, nil
+}
+
+func (b *BPE) Name() string { return b.name }
+
+func (b *BPE) Count(text string) int {
+	n := 0
+	for _, piece := range split(text) {
+		if len(piece) > maxPieceBytes {
+			n += estimatePiece(piece)
+			continue
+		}
+		n += len(b.encodePiece(piece))
+	}
+	return n
+}
+
+func (b *BPE) KeepLast(text string, n int) string {
+	pieces := split(text)
+	kept := 0
+	for i := len(pieces) - 1; i >= 0; i-- {
+		piece := pieces[i]
+		if len(piece) > maxPieceBytes {
+			// Estimated pieces are only kept whole.
+			if kept += estimatePiece(piece); kept > n {
+				return strings.Join(pieces[i+1:], "")
+			}
+			continue
+		}
+
+		tokens := b.encodePiece(piece)
+		if kept+len(tokens) <= n {
+			kept += len(tokens)
+			continue
+		}
+		var out strings.Builder
+		for _, t := range tokens[len(tokens)-(n-kept):] {
+			out.WriteString(b.tokens[t])
+		}
+		// A cut inside a multi-byte character leaves a partial rune; drop it.
+		s := out.String()
+		for len(s) > 0 {
+			r, size := utf8.DecodeRuneInString(s)
+			if r != utf8.RuneError || size > 1 {
+				break
+			}
+			s = s[1:]
+		}
+		return s + strings.Join(pieces[i+1:], "")
+	}
+	return text
+}
+
+// maxPieceBytes bounds the pieces BPE merges. Longer pieces, which ordinary
+// text never has (base64 blobs, runs of one character), are estimated
+// instead, so a huge prompt costs about as much to count as to read.
+const maxPieceBytes = 4096
+
+// encodePiece merges the bytes of piece into tokens, lowest rank first.
+func (b *BPE) encodePiece(piece string) []int {
+	if rank, ok := b.ranks[piece]; ok {
+		return []int{rank}
+	}
+	return b.merge(piece)
+}
+
+// merge runs byte-pair merges over piece in O(n log n): candidate pairs
+// wait in a heap ordered by rank, then position, and pairs whose parts have
+// since been merged with something else are skipped when they come up.
+func (b *BPE) merge(piece string) []int {
+	n := len(piece)
+	// Parts are identified by their first byte. end[i] is where part i
+	// ends, or 0 once it has been merged into the part before it.
+	end := make([]int, n)
+	prev := make([]int, n)
+	for i := range end {
+		end[i] = i + 1
+		prev[i] = i - 1
+	}
+
+	var pairs mergeHeap
+	push := func(left int) {
+		right := end[left]
+		if right >= n {
+			return
+		}
+		if rank, ok := b.ranks[piece[left:end[right]]]; ok {
+			pairs.push(mergePair{rank: rank, left: left, end: end[right]})
+		}
+	}
+	for i := 0; i < n-1; i++ {
+		push(i)
+	}
+
+	for len(pairs) > 0 {
+		p := pairs.pop()
+		right := end[p.left]
+		if right == 0 || right >= n || end[right] != p.end {
+			continue
+		}
+		end[p.left] = p.end
+		end[right] = 0
+		if p.end < n {
+			prev[p.end] = p.left
+		}
+		if prev[p.left] >= 0 {
+			push(prev[p.left])
+		}
+		push(p.left)
+	}
+
+	tokens := make([]int, 0, n)
+	for i := 0; i < n; i = end[i] {
+		// Every byte is in a tiktoken vocabulary; count one if it is not.
+		tokens = append(tokens, b.ranks[piece[i:end[i]]])
+	}
+	return tokens
+}
+
+// mergePair is a candidate merge of the part starting at left with the
+// part after it, which ends at end.
+type mergePair struct {
+	rank, left, end int
+}
+
+// mergeHeap is a binary min-heap of candidate merges by rank, leftmost
+// first on ties. It is typed rather than built on container/heap to keep
+// merges free of allocations.
+type mergeHeap []mergePair
+
+func (h mergeHeap) less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank < h[j].rank
+	}
+	return h[i].left < h[j].left
+}
+
+func (h *mergeHeap) push(p mergePair) {
+	*h = append(*h, p)
+	q := *h
+	for i := len(q) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !q.less(i, parent) {
+			break
+		}
+		q[i], q[parent] = q[parent], q[i]
+		i = parent
+	}
+}
+
+func (h *mergeHeap) pop() mergePair {
+	q := *h
+	top := q[0]
+	last := len(q) - 1
+	q[0] = q[last]
+	q = q[:last]
+	for i := 0; ; {
+		least, l, r := i, 2*i+1, 2*i+2
+		if l < len(q) && q.less(l, least) {
+			least = l
+		}
+		if r < len(q) && q.less(r, least) {
+			least = r
+		}
+		if least == i {
+			break
+		}
+		q[i], q[least] = q[least], q[i]
+		i = least
+	}
+	*h = q
+	return top
+}
+
+// ---------------------------------------------------------------------------
+// Pre-tokenization
+// ---------------------------------------------------------------------------
+
+// pieceRE is the cl100k_base split pattern without its `\s+(?!\S)`
+// alternative, which Go's regexp cannot express; split restores it.
+var pieceRE = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// split cuts text into the pieces BPE merges within. It matches the
+// reference pre-tokenizer on ordinary text: a run of spaces before a word
+// leaves its last space to the word.
+func split(text string) []string {
+	matches := pieceRE.FindAllString(text, -1)
+	pieces := make([]string, 0, len(matches))
+	for i := 0; i < len(matches); i++ {
+		m := matches[i]
+		if i+1 < len(matches) && strings.TrimSpace(m) == "" && !strings.ContainsAny(m, "\r\n") {
+			next := matches[i+1]
+			r, _ := utf8.DecodeRuneInString(next)
+			last, size := utf8.DecodeLastRuneInString(m)
+			if !unicode.IsSpace(r) && last == ' ' {
+				if size < len(m) {
+					pieces = append(pieces, m[:len(m)-size])
+				}
+				if unicode.IsNumber(r) {
+					pieces = append(pieces, " ")
+				} else {
+					matches[i+1] = " " + next
+				}
+				continue
+			}
+		}
+		pieces = append(pieces, m)
+	}
+	return pieces
+}
@@ -0,0 +1,273 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testMerges are the multi-byte tokens of the test vocabulary, in rank
+// order after the 256 single bytes.
+var testMerges = []string{"ab", "cd", "abcd", "bc", " w", "or", " wor", "ld", " world", "he", "ll", "hell", "hello", "é", "aa", "aaaa"}
+
+// writeRankFile writes a tiktoken rank file for the single bytes and
+// merges and returns its path.
+func writeRankFile(t *testing.T, name string, merges []string) string {
+	t.Helper()
+	var b strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, m := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), 256+i)
+	}
+	path := filepath.Join(t.TempDir(), name+".tiktoken")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadTestBPE(t *testing.T) *BPE {
+	t.Helper()
+	bpe, err := LoadBPE(writeRankFile(t, "test_base", testMerges))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bpe
+}
+
+// naiveMerge is the reference quadratic algorithm: merge the lowest-ranked
+// adjacent pair, leftmost first, until no pair is in the vocabulary.
+func naiveMerge(b *BPE, piece string) []int {
+	parts := make([]string, len(piece))
+	for i := range piece {
+		parts[i] = piece[i : i+1]
+	}
+	for {
+		best, at := -1, -1
+		for i := 0; i+1 < len(parts); i++ {
+			if rank, ok := b.ranks[parts[i]+parts[i+1]]; ok && (best < 0 || rank < best) {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		parts = append(parts[:at+1], parts[at+2:]...)
+		parts[at] = b.tokens[best]
+	}
+	tokens := make([]int, len(parts))
+	for i, p := range parts {
+		tokens[i] = b.ranks[p]
+	}
+	return tokens
+}
+
+func TestEncodePiece(t *testing.T) {
+	bpe := loadTestBPE(t)
+	rank := func(s string) int { return bpe.ranks[s] }
+
+	tests := []struct {
+		piece string
+		want  []int
+	}{
+		{"abcd", []int{rank("abcd")}},
+		{"bcd", []int{rank("b"), rank("cd")}},
+		{"abc", []int{rank("ab"), rank("c")}},
+		{"abcdabcd", []int{rank("abcd"), rank("abcd")}},
+		{"hello", []int{rank("hello")}},
+		{"helloo", []int{rank("hello"), rank("o")}},
+		{" world", []int{rank(" world")}},
+		{"aaaaa", []int{rank("aaaa"), rank("a")}},
+		{"aaaaaaa", []int{rank("aaaa"), rank("aa"), rank("a")}},
+		{"xyz", []int{rank("x"), rank("y"), rank("z")}},
+		{"é", []int{rank("é")}},
+		{"x", []int{rank("x")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.piece, func(t *testing.T) {
+			if got := bpe.encodePiece(tt.piece); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodePiece(%q) = %v, want %v", tt.piece, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeMatchesReference(t *testing.T) {
+	bpe := loadTestBPE(t)
+	rng := rand.New(rand.NewSource(1))
+	const alphabet = "abcdehlorw "
+	for i := 0; i < 2000; i++ {
+		b := make([]byte, 1+rng.Intn(40))
+		for j := range b {
+			b[j] = alphabet[rng.Intn(len(alphabet))]
+		}
+		piece := string(b)
+		if got, want := bpe.merge(piece), naiveMerge(bpe, piece); !reflect.DeepEqual(got, want) {
+			t.Fatalf("merge(%q) = %v, want %v", piece, got, want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"hello world", []string{"hello", " world"}},
+		{"hello  world", []string{"hello", " ", " world"}},
+		{"it's 12345!", []string{"it", "'s", " ", "123", "45", "!"}},
+		{"a\n\nb", []string{"a", "\n\n", "b"}},
+		{"end   ", []string{"end", "   "}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := split(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("split(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBPECount(t *testing.T) {
+	bpe := loadTestBPE(t)
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"hello  world", 3},
+		{"abcd bcd", 4}, // abcd, " ", b, cd: " bcd" has no merge for " b"
+		{strings.Repeat("x", maxPieceBytes+4), (maxPieceBytes + 5) / 4},
+	}
+	for _, tt := range tests {
+		name := tt.text
+		if len(name) > 20 {
+			name = name[:20] + "..."
+		}
+		t.Run(name, func(t *testing.T) {
+			if got := bpe.Count(tt.text); got != tt.want {
+				t.Errorf("Count(%q) = %d, want %d", name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBPEKeepLast(t *testing.T) {
+	bpe := loadTestBPE(t)
+	tests := []struct {
+		name string
+		text string
+		n    int
+		want string
+	}{
+		{"everything fits", "hello world", 5, "hello world"},
+		{"whole pieces", "hello world", 1, " world"},
+		{"cut inside a piece", "helloo", 1, "o"},
+		{"nothing", "hello world", 0, ""},
+		{"partial rune is dropped", "xü", 1, ""}, // ü is two single-byte tokens
+		{"cut between runes", "aé", 1, "é"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bpe.KeepLast(tt.text, tt.n)
+			if got != tt.want {
+				t.Errorf("KeepLast(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+			}
+			if c := bpe.Count(got); c > tt.n {
+				t.Errorf("KeepLast kept %d tokens, more than %d", c, tt.n)
+			}
+		})
+	}
+}
+
+func TestEstimator(t *testing.T) {
+	var e Estimator
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"hello world", 2}, // About a token per four bytes, rounded: "hello", " world"
+		{"internationalization", 5},
+		{"日本語", 3},
+		{"日本語です", 5},
+		{"a, b.", 4}, // At least one token per piece: "a", ",", " b", "."
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := e.Count(tt.text); got != tt.want {
+				t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+
+	if got := e.KeepLast("one two three four", 2); got != " three four" {
+		t.Errorf("KeepLast = %q, want %q", got, " three four")
+	}
+}
+
+func TestLoadBPE(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"missing", filepath.Join(dir, "missing.tiktoken"), "tokenizer: open"},
+		{"empty", write("empty.tiktoken", "\n"), "no tokens"},
+		{"bad base64", write("b64.tiktoken", "!!! 0\n"), ":1:"},
+		{"bad rank", write("rank.tiktoken", "YQ== x\n"), ":1:"},
+		{"extra field", write("fields.tiktoken", "YQ== 0\nYg== 1 2\n"), ":2: expected token and rank"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadBPE(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadBPE error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	bpe, err := LoadBPE(writeRankFile(t, "o200k_base", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bpe.Name() != "o200k_base" {
+		t.Errorf("Name = %q, want o200k_base", bpe.Name())
+	}
+}
+
+func TestSetFor(t *testing.T) {
+	s, err := LoadSet(filepath.Dir(writeRankFile(t, "cl100k_base", testMerges)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.For("cl100k_base").Name(); got != "cl100k_base" {
+		t.Errorf("For(cl100k_base) = %s", got)
+	}
+	if got := s.For("o200k_base").Name(); got != "estimate" {
+		t.Errorf("For(o200k_base) = %s, want the estimator", got)
+	}
+	var none *Set
+	if got := none.For("cl100k_base").Name(); got != "estimate" {
+		t.Errorf("nil Set For = %s, want the estimator", got)
+	}
+}
@@ -15,16 +15,143 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Priority is the scheduling class used when provider capacity is saturated.
+type Priority int32
+
+const (
+	Priority_PRIORITY_UNSPECIFIED Priority = 0 // Treated as NORMAL
+	Priority_PRIORITY_HIGH        Priority = 1 // Interactive user traffic
+	Priority_PRIORITY_NORMAL      Priority = 2
+	Priority_PRIORITY_LOW         Priority = 3 // Batch / back-office jobs
+)
+
+// Enum value maps for Priority.
+var (
+	Priority_name = map[int32]string{
+		0: "PRIORITY_UNSPECIFIED",
+		1: "PRIORITY_HIGH",
+		2: "PRIORITY_NORMAL",
+		3: "PRIORITY_LOW",
+	}
+	Priority_value = map[string]int32{
+		"PRIORITY_UNSPECIFIED": 0,
+		"PRIORITY_HIGH":        1,
+		"PRIORITY_NORMAL":      2,
+		"PRIORITY_LOW":         3,
+	}
+)
+
+func (x Priority) Enum() *Priority {
+	p := new(Priority)
+	*p = x
+	return p
+}
+
+func (x Priority) String() string {
+	if name, ok := Priority_name[int32(x)]; ok {
+		return name
+	}
+	return "PRIORITY_UNSPECIFIED"
+}
+
+// ContextPolicy is what happens when prompt_tokens + max_tokens exceeds the
+// model's context window.
+type ContextPolicy int32
+
+const (
+	ContextPolicy_CONTEXT_POLICY_UNSPECIFIED ContextPolicy = 0 // Server default (CONTEXT_OVERFLOW)
+	ContextPolicy_CONTEXT_POLICY_REJECT      ContextPolicy = 1 // Fail with INVALID_ARGUMENT
+	ContextPolicy_CONTEXT_POLICY_TRUNCATE    ContextPolicy = 2 // Drop the oldest turns, then the start of the prompt, until it fits
+)
+
+// Enum value maps for ContextPolicy.
+var (
+	ContextPolicy_name = map[int32]string{
+		0: "CONTEXT_POLICY_UNSPECIFIED",
+		1: "CONTEXT_POLICY_REJECT",
+		2: "CONTEXT_POLICY_TRUNCATE",
+	}
+	ContextPolicy_value = map[string]int32{
+		"CONTEXT_POLICY_UNSPECIFIED": 0,
+		"CONTEXT_POLICY_REJECT":      1,
+		"CONTEXT_POLICY_TRUNCATE":    2,
+	}
+)
+
+func (x ContextPolicy) Enum() *ContextPolicy {
+	p := new(ContextPolicy)
+	*p = x
+	return p
+}
+
+func (x ContextPolicy) String() string {
+	if name, ok := ContextPolicy_name[int32(x)]; ok {
+		return name
+	}
+	return "CONTEXT_POLICY_UNSPECIFIED"
+}
+
+// ChatAction is what a client message on a Chat stream asks for.
+type ChatAction int32
+
+const (
+	ChatAction_CHAT_ACTION_UNSPECIFIED ChatAction = 0 // Same as SEND
+	ChatAction_CHAT_ACTION_SEND        ChatAction = 1 // Send a user turn
+	ChatAction_CHAT_ACTION_CANCEL      ChatAction = 2 // Stop the reply being generated and drop turns queued behind it
+)
+
+// Enum value maps for ChatAction.
+var (
+	ChatAction_name = map[int32]string{
+		0: "CHAT_ACTION_UNSPECIFIED",
+		1: "CHAT_ACTION_SEND",
+		2: "CHAT_ACTION_CANCEL",
+	}
+	ChatAction_value = map[string]int32{
+		"CHAT_ACTION_UNSPECIFIED": 0,
+		"CHAT_ACTION_SEND":        1,
+		"CHAT_ACTION_CANCEL":      2,
+	}
+)
+
+func (x ChatAction) Enum() *ChatAction {
+	p := new(ChatAction)
+	*p = x
+	return p
+}
+
+func (x ChatAction) String() string {
+	if name, ok := ChatAction_name[int32(x)]; ok {
+		return name
+	}
+	return "CHAT_ACTION_UNSPECIFIED"
+}
+
 // InferenceRequest represents a client request to an LLM provider.
 type InferenceRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Model       string  `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
-	Prompt      string  `protobuf:"bytes,2,opt,name=prompt,proto3" json:"prompt,omitempty"`
-	Temperature float32 `protobuf:"fixed32,3,opt,name=temperature,proto3" json:"temperature,omitempty"`
-	MaxTokens   int32   `protobuf:"varint,4,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
+	Model            string             `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	Prompt           string             `protobuf:"bytes,2,opt,name=prompt,proto3" json:"prompt,omitempty"`
+	Temperature      *float32           `protobuf:"fixed32,3,opt,name=temperature,proto3,oneof" json:"temperature,omitempty"`
+	MaxTokens        int32              `protobuf:"varint,4,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
+	Priority         Priority           `protobuf:"varint,5,opt,name=priority,proto3,enum=inferenceproxy.Priority" json:"priority,omitempty"`
+	ResponseFormat   *ResponseFormat    `protobuf:"bytes,6,opt,name=response_format,json=responseFormat,proto3" json:"response_format,omitempty"`
+	TopP             *float32           `protobuf:"fixed32,7,opt,name=top_p,json=topP,proto3,oneof" json:"top_p,omitempty"`
+	TopK             *int32             `protobuf:"varint,8,opt,name=top_k,json=topK,proto3,oneof" json:"top_k,omitempty"`
+	Stop             []string           `protobuf:"bytes,9,rep,name=stop,proto3" json:"stop,omitempty"`
+	PresencePenalty  *float32           `protobuf:"fixed32,10,opt,name=presence_penalty,json=presencePenalty,proto3,oneof" json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32           `protobuf:"fixed32,11,opt,name=frequency_penalty,json=frequencyPenalty,proto3,oneof" json:"frequency_penalty,omitempty"`
+	Seed             *int64             `protobuf:"varint,12,opt,name=seed,proto3,oneof" json:"seed,omitempty"`
+	N                *int32             `protobuf:"varint,13,opt,name=n,proto3,oneof" json:"n,omitempty"`
+	LogitBias        map[string]float32 `protobuf:"bytes,14,rep,name=logit_bias,json=logitBias,proto3" json:"logit_bias,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed32,2,opt,name=value,proto3"`
+	Logprobs         bool               `protobuf:"varint,15,opt,name=logprobs,proto3" json:"logprobs,omitempty"`
+	TopLogprobs      *int32             `protobuf:"varint,16,opt,name=top_logprobs,json=topLogprobs,proto3,oneof" json:"top_logprobs,omitempty"`
+	Parts            []*ContentPart     `protobuf:"bytes,17,rep,name=parts,proto3" json:"parts,omitempty"`
+	ContextPolicy    ContextPolicy      `protobuf:"varint,18,opt,name=context_policy,json=contextPolicy,proto3,enum=inferenceproxy.ContextPolicy" json:"context_policy,omitempty"`
+	ConversationId   string             `protobuf:"bytes,19,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"` // Server-side conversation (Infer, InferStream): earlier turns are sent as history and this one is saved
 }
 
 func (x *InferenceRequest) Reset()         { *x = InferenceRequest{} }
@@ -50,8 +177,8 @@ func (x *InferenceRequest) GetPrompt() string {
 }
 
 func (x *InferenceRequest) GetTemperature() float32 {
-	if x != nil {
-		return x.Temperature
+	if x != nil && x.Temperature != nil {
+		return *x.Temperature
 	}
 	return 0
 }
@@ -63,17 +190,257 @@ func (x *InferenceRequest) GetMaxTokens() int32 {
 	return 0
 }
 
+func (x *InferenceRequest) GetPriority() Priority {
+	if x != nil {
+		return x.Priority
+	}
+	return Priority_PRIORITY_UNSPECIFIED
+}
+
+func (x *InferenceRequest) GetResponseFormat() *ResponseFormat {
+	if x != nil {
+		return x.ResponseFormat
+	}
+	return nil
+}
+
+func (x *InferenceRequest) GetTopP() float32 {
+	if x != nil && x.TopP != nil {
+		return *x.TopP
+	}
+	return 0
+}
+
+func (x *InferenceRequest) GetTopK() int32 {
+	if x != nil && x.TopK != nil {
+		return *x.TopK
+	}
+	return 0
+}
+
+func (x *InferenceRequest) GetStop() []string {
+	if x != nil {
+		return x.Stop
+	}
+	return nil
+}
+
+func (x *InferenceRequest) GetPresencePenalty() float32 {
+	if x != nil && x.PresencePenalty != nil {
+		return *x.PresencePenalty
+	}
+	return 0
+}
+
+func (x *InferenceRequest) GetFrequencyPenalty() float32 {
+	if x != nil && x.FrequencyPenalty != nil {
+		return *x.FrequencyPenalty
+	}
+	return 0
+}
+
+func (x *InferenceRequest) GetSeed() int64 {
+	if x != nil && x.Seed != nil {
+		return *x.Seed
+	}
+	return 0
+}
+
+func (x *InferenceRequest) GetN() int32 {
+	if x != nil && x.N != nil {
+		return *x.N
+	}
+	return 0
+}
+
+func (x *InferenceRequest) GetLogitBias() map[string]float32 {
+	if x != nil {
+		return x.LogitBias
+	}
+	return nil
+}
+
+func (x *InferenceRequest) GetLogprobs() bool {
+	if x != nil {
+		return x.Logprobs
+	}
+	return false
+}
+
+func (x *InferenceRequest) GetTopLogprobs() int32 {
+	if x != nil && x.TopLogprobs != nil {
+		return *x.TopLogprobs
+	}
+	return 0
+}
+
+func (x *InferenceRequest) GetParts() []*ContentPart {
+	if x != nil {
+		return x.Parts
+	}
+	return nil
+}
+
+func (x *InferenceRequest) GetContextPolicy() ContextPolicy {
+	if x != nil {
+		return x.ContextPolicy
+	}
+	return ContextPolicy_CONTEXT_POLICY_UNSPECIFIED
+}
+
+func (x *InferenceRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+// ContentPart is a non-text input to a prompt: inline bytes or a URL.
+// Exactly one of data and uri is set.
+type ContentPart struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MimeType string `protobuf:"bytes,1,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	Data     []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Uri      string `protobuf:"bytes,3,opt,name=uri,proto3" json:"uri,omitempty"`
+}
+
+func (x *ContentPart) Reset()         { *x = ContentPart{} }
+func (x *ContentPart) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ContentPart) ProtoMessage()  {}
+
+func (x *ContentPart) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ContentPart) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *ContentPart) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ContentPart) GetUri() string {
+	if x != nil {
+		return x.Uri
+	}
+	return ""
+}
+
+// ResponseFormat asks for JSON output, optionally conforming to a schema.
+type ResponseFormat struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type       ResponseFormat_Type `protobuf:"varint,1,opt,name=type,proto3,enum=inferenceproxy.ResponseFormat_Type" json:"type,omitempty"`
+	JsonSchema string              `protobuf:"bytes,2,opt,name=json_schema,json=jsonSchema,proto3" json:"json_schema,omitempty"`
+	SchemaName string              `protobuf:"bytes,3,opt,name=schema_name,json=schemaName,proto3" json:"schema_name,omitempty"`
+	Strict     bool                `protobuf:"varint,4,opt,name=strict,proto3" json:"strict,omitempty"`
+	MaxRepairs int32               `protobuf:"varint,5,opt,name=max_repairs,json=maxRepairs,proto3" json:"max_repairs,omitempty"`
+}
+
+func (x *ResponseFormat) Reset()         { *x = ResponseFormat{} }
+func (x *ResponseFormat) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ResponseFormat) ProtoMessage()  {}
+
+func (x *ResponseFormat) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ResponseFormat) GetType() ResponseFormat_Type {
+	if x != nil {
+		return x.Type
+	}
+	return ResponseFormat_TYPE_UNSPECIFIED
+}
+
+func (x *ResponseFormat) GetJsonSchema() string {
+	if x != nil {
+		return x.JsonSchema
+	}
+	return ""
+}
+
+func (x *ResponseFormat) GetSchemaName() string {
+	if x != nil {
+		return x.SchemaName
+	}
+	return ""
+}
+
+func (x *ResponseFormat) GetStrict() bool {
+	if x != nil {
+		return x.Strict
+	}
+	return false
+}
+
+func (x *ResponseFormat) GetMaxRepairs() int32 {
+	if x != nil {
+		return x.MaxRepairs
+	}
+	return 0
+}
+
+type ResponseFormat_Type int32
+
+const (
+	ResponseFormat_TYPE_UNSPECIFIED ResponseFormat_Type = 0 // Free text
+	ResponseFormat_TYPE_JSON_OBJECT ResponseFormat_Type = 1 // Any JSON object
+	ResponseFormat_TYPE_JSON_SCHEMA ResponseFormat_Type = 2 // JSON matching json_schema
+)
+
+// Enum value maps for ResponseFormat_Type.
+var (
+	ResponseFormat_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_JSON_OBJECT",
+		2: "TYPE_JSON_SCHEMA",
+	}
+	ResponseFormat_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"TYPE_JSON_OBJECT": 1,
+		"TYPE_JSON_SCHEMA": 2,
+	}
+)
+
+func (x ResponseFormat_Type) Enum() *ResponseFormat_Type {
+	p := new(ResponseFormat_Type)
+	*p = x
+	return p
+}
+
+func (x ResponseFormat_Type) String() string {
+	if name, ok := ResponseFormat_Type_name[int32(x)]; ok {
+		return name
+	}
+	return "TYPE_UNSPECIFIED"
+}
+
 // InferenceResponse represents the full response from an LLM provider.
 type InferenceResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Text         string  `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
-	PromptTokens int32   `protobuf:"varint,2,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
-	OutputTokens int32   `protobuf:"varint,3,opt,name=output_tokens,json=outputTokens,proto3" json:"output_tokens,omitempty"`
-	CacheHit     bool    `protobuf:"varint,4,opt,name=cache_hit,json=cacheHit,proto3" json:"cache_hit,omitempty"`
-	LatencyMs    float64 `protobuf:"fixed64,5,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	Text         string            `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	PromptTokens int32             `protobuf:"varint,2,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	OutputTokens int32             `protobuf:"varint,3,opt,name=output_tokens,json=outputTokens,proto3" json:"output_tokens,omitempty"`
+	CacheHit     bool              `protobuf:"varint,4,opt,name=cache_hit,json=cacheHit,proto3" json:"cache_hit,omitempty"`
+	LatencyMs    float64           `protobuf:"fixed64,5,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	CostUsd      float64           `protobuf:"fixed64,6,opt,name=cost_usd,json=costUsd,proto3" json:"cost_usd,omitempty"`
+	Metadata     *ResponseMetadata `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Candidates   []*Candidate      `protobuf:"bytes,8,rep,name=candidates,proto3" json:"candidates,omitempty"`
 }
 
 func (x *InferenceResponse) Reset()         { *x = InferenceResponse{} }
@@ -119,54 +486,1811 @@ func (x *InferenceResponse) GetLatencyMs() float64 {
 	return 0
 }
 
-// StreamChunk represents a single chunk in a streaming response.
-type StreamChunk struct {
+func (x *InferenceResponse) GetCostUsd() float64 {
+	if x != nil {
+		return x.CostUsd
+	}
+	return 0
+}
+
+func (x *InferenceResponse) GetMetadata() *ResponseMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *InferenceResponse) GetCandidates() []*Candidate {
+	if x != nil {
+		return x.Candidates
+	}
+	return nil
+}
+
+// Candidate is one of several completions generated for a request.
+type Candidate struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Text         string `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
-	Done         bool   `protobuf:"varint,2,opt,name=done,proto3" json:"done,omitempty"`
-	PromptTokens int32  `protobuf:"varint,3,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
-	OutputTokens int32  `protobuf:"varint,4,opt,name=output_tokens,json=outputTokens,proto3" json:"output_tokens,omitempty"`
+	Index              int32           `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Text               string          `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	FinishReason       FinishReason    `protobuf:"varint,3,opt,name=finish_reason,json=finishReason,proto3,enum=inferenceproxy.FinishReason" json:"finish_reason,omitempty"`
+	NativeFinishReason string          `protobuf:"bytes,4,opt,name=native_finish_reason,json=nativeFinishReason,proto3" json:"native_finish_reason,omitempty"`
+	Logprobs           []*TokenLogprob `protobuf:"bytes,5,rep,name=logprobs,proto3" json:"logprobs,omitempty"`
+	SafetyRatings      []*SafetyRating `protobuf:"bytes,6,rep,name=safety_ratings,json=safetyRatings,proto3" json:"safety_ratings,omitempty"`
 }
 
-func (x *StreamChunk) Reset()         { *x = StreamChunk{} }
-func (x *StreamChunk) String() string { return protoimpl.X.MessageStringOf(x) }
-func (x *StreamChunk) ProtoMessage()  {}
+func (x *Candidate) Reset()         { *x = Candidate{} }
+func (x *Candidate) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *Candidate) ProtoMessage()  {}
 
-func (x *StreamChunk) ProtoReflect() protoreflect.Message {
+func (x *Candidate) ProtoReflect() protoreflect.Message {
 	return nil // simplified stub
 }
 
-func (x *StreamChunk) GetText() string {
+func (x *Candidate) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Candidate) GetText() string {
 	if x != nil {
 		return x.Text
 	}
 	return ""
 }
 
-func (x *StreamChunk) GetDone() bool {
+func (x *Candidate) GetFinishReason() FinishReason {
 	if x != nil {
-		return x.Done
+		return x.FinishReason
 	}
-	return false
+	return FinishReason_FINISH_REASON_UNSPECIFIED
 }
 
-func (x *StreamChunk) GetPromptTokens() int32 {
+func (x *Candidate) GetNativeFinishReason() string {
 	if x != nil {
-		return x.PromptTokens
+		return x.NativeFinishReason
+	}
+	return ""
+}
+
+func (x *Candidate) GetLogprobs() []*TokenLogprob {
+	if x != nil {
+		return x.Logprobs
+	}
+	return nil
+}
+
+func (x *Candidate) GetSafetyRatings() []*SafetyRating {
+	if x != nil {
+		return x.SafetyRatings
+	}
+	return nil
+}
+
+// TokenLogprob is the log probability of one generated token.
+type TokenLogprob struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Token       string        `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Logprob     float32       `protobuf:"fixed32,2,opt,name=logprob,proto3" json:"logprob,omitempty"`
+	TopLogprobs []*TopLogprob `protobuf:"bytes,3,rep,name=top_logprobs,json=topLogprobs,proto3" json:"top_logprobs,omitempty"`
+}
+
+func (x *TokenLogprob) Reset()         { *x = TokenLogprob{} }
+func (x *TokenLogprob) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *TokenLogprob) ProtoMessage()  {}
+
+func (x *TokenLogprob) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *TokenLogprob) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *TokenLogprob) GetLogprob() float32 {
+	if x != nil {
+		return x.Logprob
 	}
 	return 0
 }
 
-func (x *StreamChunk) GetOutputTokens() int32 {
+func (x *TokenLogprob) GetTopLogprobs() []*TopLogprob {
 	if x != nil {
-		return x.OutputTokens
+		return x.TopLogprobs
+	}
+	return nil
+}
+
+// TopLogprob is an alternative token at a position.
+type TopLogprob struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Token   string  `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Logprob float32 `protobuf:"fixed32,2,opt,name=logprob,proto3" json:"logprob,omitempty"`
+}
+
+func (x *TopLogprob) Reset()         { *x = TopLogprob{} }
+func (x *TopLogprob) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *TopLogprob) ProtoMessage()  {}
+
+func (x *TopLogprob) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *TopLogprob) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *TopLogprob) GetLogprob() float32 {
+	if x != nil {
+		return x.Logprob
 	}
 	return 0
 }
 
+// FinishReason is why generation stopped, normalized across providers.
+type FinishReason int32
+
+const (
+	FinishReason_FINISH_REASON_UNSPECIFIED    FinishReason = 0 // Not reported
+	FinishReason_FINISH_REASON_STOP           FinishReason = 1 // Natural end or a stop sequence
+	FinishReason_FINISH_REASON_LENGTH         FinishReason = 2 // max_tokens reached; the text is truncated
+	FinishReason_FINISH_REASON_CONTENT_FILTER FinishReason = 3 // Stopped or blocked by the provider's safety filters
+	FinishReason_FINISH_REASON_TOOL_CALLS     FinishReason = 4 // The model called a tool
+	FinishReason_FINISH_REASON_OTHER          FinishReason = 5 // Any other reason; see native_finish_reason
+)
+
+// Enum value maps for FinishReason.
+var (
+	FinishReason_name = map[int32]string{
+		0: "FINISH_REASON_UNSPECIFIED",
+		1: "FINISH_REASON_STOP",
+		2: "FINISH_REASON_LENGTH",
+		3: "FINISH_REASON_CONTENT_FILTER",
+		4: "FINISH_REASON_TOOL_CALLS",
+		5: "FINISH_REASON_OTHER",
+	}
+	FinishReason_value = map[string]int32{
+		"FINISH_REASON_UNSPECIFIED":    0,
+		"FINISH_REASON_STOP":           1,
+		"FINISH_REASON_LENGTH":         2,
+		"FINISH_REASON_CONTENT_FILTER": 3,
+		"FINISH_REASON_TOOL_CALLS":     4,
+		"FINISH_REASON_OTHER":          5,
+	}
+)
+
+func (x FinishReason) Enum() *FinishReason {
+	p := new(FinishReason)
+	*p = x
+	return p
+}
+
+func (x FinishReason) String() string {
+	if name, ok := FinishReason_name[int32(x)]; ok {
+		return name
+	}
+	return "FINISH_REASON_UNSPECIFIED"
+}
+
+// SafetyRating is a provider's safety assessment for one harm category.
+type SafetyRating struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Category    string `protobuf:"bytes,1,opt,name=category,proto3" json:"category,omitempty"`
+	Probability string `protobuf:"bytes,2,opt,name=probability,proto3" json:"probability,omitempty"`
+	Blocked     bool   `protobuf:"varint,3,opt,name=blocked,proto3" json:"blocked,omitempty"`
+}
+
+func (x *SafetyRating) Reset()         { *x = SafetyRating{} }
+func (x *SafetyRating) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *SafetyRating) ProtoMessage()  {}
+
+func (x *SafetyRating) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *SafetyRating) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *SafetyRating) GetProbability() string {
+	if x != nil {
+		return x.Probability
+	}
+	return ""
+}
+
+func (x *SafetyRating) GetBlocked() bool {
+	if x != nil {
+		return x.Blocked
+	}
+	return false
+}
+
+// ResponseMetadata describes how a response ended and who served it.
+type ResponseMetadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RequestId          string          `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Provider           string          `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	Model              string          `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	UpstreamId         string          `protobuf:"bytes,4,opt,name=upstream_id,json=upstreamId,proto3" json:"upstream_id,omitempty"`
+	FinishReason       FinishReason    `protobuf:"varint,5,opt,name=finish_reason,json=finishReason,proto3,enum=inferenceproxy.FinishReason" json:"finish_reason,omitempty"`
+	NativeFinishReason string          `protobuf:"bytes,6,opt,name=native_finish_reason,json=nativeFinishReason,proto3" json:"native_finish_reason,omitempty"`
+	SafetyRatings      []*SafetyRating `protobuf:"bytes,7,rep,name=safety_ratings,json=safetyRatings,proto3" json:"safety_ratings,omitempty"`
+	BlockReason        string          `protobuf:"bytes,8,opt,name=block_reason,json=blockReason,proto3" json:"block_reason,omitempty"`
+}
+
+func (x *ResponseMetadata) Reset()         { *x = ResponseMetadata{} }
+func (x *ResponseMetadata) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ResponseMetadata) ProtoMessage()  {}
+
+func (x *ResponseMetadata) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ResponseMetadata) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *ResponseMetadata) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *ResponseMetadata) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ResponseMetadata) GetUpstreamId() string {
+	if x != nil {
+		return x.UpstreamId
+	}
+	return ""
+}
+
+func (x *ResponseMetadata) GetFinishReason() FinishReason {
+	if x != nil {
+		return x.FinishReason
+	}
+	return FinishReason_FINISH_REASON_UNSPECIFIED
+}
+
+func (x *ResponseMetadata) GetNativeFinishReason() string {
+	if x != nil {
+		return x.NativeFinishReason
+	}
+	return ""
+}
+
+func (x *ResponseMetadata) GetSafetyRatings() []*SafetyRating {
+	if x != nil {
+		return x.SafetyRatings
+	}
+	return nil
+}
+
+func (x *ResponseMetadata) GetBlockReason() string {
+	if x != nil {
+		return x.BlockReason
+	}
+	return ""
+}
+
+// StreamChunk represents a single chunk in a streaming response.
+type StreamChunk struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Text           string            `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Done           bool              `protobuf:"varint,2,opt,name=done,proto3" json:"done,omitempty"`
+	PromptTokens   int32             `protobuf:"varint,3,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	OutputTokens   int32             `protobuf:"varint,4,opt,name=output_tokens,json=outputTokens,proto3" json:"output_tokens,omitempty"`
+	CostUsd        float64           `protobuf:"fixed64,5,opt,name=cost_usd,json=costUsd,proto3" json:"cost_usd,omitempty"`
+	Metadata       *ResponseMetadata `protobuf:"bytes,6,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	CandidateIndex int32             `protobuf:"varint,7,opt,name=candidate_index,json=candidateIndex,proto3" json:"candidate_index,omitempty"`
+	Logprobs       []*TokenLogprob   `protobuf:"bytes,8,rep,name=logprobs,proto3" json:"logprobs,omitempty"`
+	FinishReason   FinishReason      `protobuf:"varint,9,opt,name=finish_reason,json=finishReason,proto3,enum=inferenceproxy.FinishReason" json:"finish_reason,omitempty"`
+}
+
+func (x *StreamChunk) Reset()         { *x = StreamChunk{} }
+func (x *StreamChunk) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *StreamChunk) ProtoMessage()  {}
+
+func (x *StreamChunk) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *StreamChunk) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *StreamChunk) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *StreamChunk) GetPromptTokens() int32 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *StreamChunk) GetOutputTokens() int32 {
+	if x != nil {
+		return x.OutputTokens
+	}
+	return 0
+}
+
+func (x *StreamChunk) GetCostUsd() float64 {
+	if x != nil {
+		return x.CostUsd
+	}
+	return 0
+}
+
+func (x *StreamChunk) GetMetadata() *ResponseMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *StreamChunk) GetCandidateIndex() int32 {
+	if x != nil {
+		return x.CandidateIndex
+	}
+	return 0
+}
+
+func (x *StreamChunk) GetLogprobs() []*TokenLogprob {
+	if x != nil {
+		return x.Logprobs
+	}
+	return nil
+}
+
+func (x *StreamChunk) GetFinishReason() FinishReason {
+	if x != nil {
+		return x.FinishReason
+	}
+	return FinishReason_FINISH_REASON_UNSPECIFIED
+}
+
+// EmbedRequest asks for vector embeddings of a batch of inputs.
+type EmbedRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Model      string   `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	Inputs     []string `protobuf:"bytes,2,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	Dimensions *int32   `protobuf:"varint,3,opt,name=dimensions,proto3,oneof" json:"dimensions,omitempty"`
+	Priority   Priority `protobuf:"varint,4,opt,name=priority,proto3,enum=inferenceproxy.Priority" json:"priority,omitempty"`
+}
+
+func (x *EmbedRequest) Reset()         { *x = EmbedRequest{} }
+func (x *EmbedRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *EmbedRequest) ProtoMessage()  {}
+
+func (x *EmbedRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *EmbedRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *EmbedRequest) GetInputs() []string {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *EmbedRequest) GetDimensions() int32 {
+	if x != nil && x.Dimensions != nil {
+		return *x.Dimensions
+	}
+	return 0
+}
+
+func (x *EmbedRequest) GetPriority() Priority {
+	if x != nil {
+		return x.Priority
+	}
+	return Priority_PRIORITY_UNSPECIFIED
+}
+
+// Embedding is the vector of one input.
+type Embedding struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Index    int32     `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Values   []float32 `protobuf:"fixed32,2,rep,packed,name=values,proto3" json:"values,omitempty"`
+	CacheHit bool      `protobuf:"varint,3,opt,name=cache_hit,json=cacheHit,proto3" json:"cache_hit,omitempty"`
+}
+
+func (x *Embedding) Reset()         { *x = Embedding{} }
+func (x *Embedding) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *Embedding) ProtoMessage()  {}
+
+func (x *Embedding) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *Embedding) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Embedding) GetValues() []float32 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *Embedding) GetCacheHit() bool {
+	if x != nil {
+		return x.CacheHit
+	}
+	return false
+}
+
+// EmbedResponse holds one embedding per input, in input order.
+type EmbedResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Embeddings   []*Embedding      `protobuf:"bytes,1,rep,name=embeddings,proto3" json:"embeddings,omitempty"`
+	PromptTokens int32             `protobuf:"varint,2,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	LatencyMs    float64           `protobuf:"fixed64,3,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	CostUsd      float64           `protobuf:"fixed64,4,opt,name=cost_usd,json=costUsd,proto3" json:"cost_usd,omitempty"`
+	Metadata     *ResponseMetadata `protobuf:"bytes,5,opt,name=metadata,proto3" json:"metadata,omitempty"`
+}
+
+func (x *EmbedResponse) Reset()         { *x = EmbedResponse{} }
+func (x *EmbedResponse) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *EmbedResponse) ProtoMessage()  {}
+
+func (x *EmbedResponse) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *EmbedResponse) GetEmbeddings() []*Embedding {
+	if x != nil {
+		return x.Embeddings
+	}
+	return nil
+}
+
+func (x *EmbedResponse) GetPromptTokens() int32 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *EmbedResponse) GetLatencyMs() float64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *EmbedResponse) GetCostUsd() float64 {
+	if x != nil {
+		return x.CostUsd
+	}
+	return 0
+}
+
+func (x *EmbedResponse) GetMetadata() *ResponseMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// CountTokensRequest asks how many tokens a prompt uses with a model.
+type CountTokensRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Model     string         `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	Prompt    string         `protobuf:"bytes,2,opt,name=prompt,proto3" json:"prompt,omitempty"`
+	Parts     []*ContentPart `protobuf:"bytes,3,rep,name=parts,proto3" json:"parts,omitempty"`
+	MaxTokens int32          `protobuf:"varint,4,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
+}
+
+func (x *CountTokensRequest) Reset()         { *x = CountTokensRequest{} }
+func (x *CountTokensRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *CountTokensRequest) ProtoMessage()  {}
+
+func (x *CountTokensRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *CountTokensRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *CountTokensRequest) GetPrompt() string {
+	if x != nil {
+		return x.Prompt
+	}
+	return ""
+}
+
+func (x *CountTokensRequest) GetParts() []*ContentPart {
+	if x != nil {
+		return x.Parts
+	}
+	return nil
+}
+
+func (x *CountTokensRequest) GetMaxTokens() int32 {
+	if x != nil {
+		return x.MaxTokens
+	}
+	return 0
+}
+
+// CountTokensResponse reports a prompt's size against the model's limits.
+// Limits are 0 for models missing from the registry.
+type CountTokensResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PromptTokens    int32  `protobuf:"varint,1,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	ContextWindow   int32  `protobuf:"varint,2,opt,name=context_window,json=contextWindow,proto3" json:"context_window,omitempty"`
+	MaxOutputTokens int32  `protobuf:"varint,3,opt,name=max_output_tokens,json=maxOutputTokens,proto3" json:"max_output_tokens,omitempty"`
+	RemainingTokens int32  `protobuf:"varint,4,opt,name=remaining_tokens,json=remainingTokens,proto3" json:"remaining_tokens,omitempty"`
+	Fits            bool   `protobuf:"varint,5,opt,name=fits,proto3" json:"fits,omitempty"`
+	Method          string `protobuf:"bytes,6,opt,name=method,proto3" json:"method,omitempty"`
+}
+
+func (x *CountTokensResponse) Reset()         { *x = CountTokensResponse{} }
+func (x *CountTokensResponse) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *CountTokensResponse) ProtoMessage()  {}
+
+func (x *CountTokensResponse) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *CountTokensResponse) GetPromptTokens() int32 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *CountTokensResponse) GetContextWindow() int32 {
+	if x != nil {
+		return x.ContextWindow
+	}
+	return 0
+}
+
+func (x *CountTokensResponse) GetMaxOutputTokens() int32 {
+	if x != nil {
+		return x.MaxOutputTokens
+	}
+	return 0
+}
+
+func (x *CountTokensResponse) GetRemainingTokens() int32 {
+	if x != nil {
+		return x.RemainingTokens
+	}
+	return 0
+}
+
+func (x *CountTokensResponse) GetFits() bool {
+	if x != nil {
+		return x.Fits
+	}
+	return false
+}
+
+func (x *CountTokensResponse) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+// ListModelsRequest filters the model catalog.
+type ListModelsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Provider string `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"` // Only this provider's models; empty for all
+}
+
+func (x *ListModelsRequest) Reset()         { *x = ListModelsRequest{} }
+func (x *ListModelsRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ListModelsRequest) ProtoMessage()  {}
+
+func (x *ListModelsRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ListModelsRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+// ModelFeatures lists what a model supports.
+type ModelFeatures struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Streaming  bool `protobuf:"varint,1,opt,name=streaming,proto3" json:"streaming,omitempty"`
+	Tools      bool `protobuf:"varint,2,opt,name=tools,proto3" json:"tools,omitempty"`                       // Function / tool calling
+	Vision     bool `protobuf:"varint,3,opt,name=vision,proto3" json:"vision,omitempty"`                     // Image and document parts
+	JsonMode   bool `protobuf:"varint,4,opt,name=json_mode,json=jsonMode,proto3" json:"json_mode,omitempty"` // response_format
+	Embeddings bool `protobuf:"varint,5,opt,name=embeddings,proto3" json:"embeddings,omitempty"`             // Served by Embed rather than Infer
+}
+
+func (x *ModelFeatures) Reset()         { *x = ModelFeatures{} }
+func (x *ModelFeatures) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ModelFeatures) ProtoMessage()  {}
+
+func (x *ModelFeatures) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ModelFeatures) GetStreaming() bool {
+	if x != nil {
+		return x.Streaming
+	}
+	return false
+}
+
+func (x *ModelFeatures) GetTools() bool {
+	if x != nil {
+		return x.Tools
+	}
+	return false
+}
+
+func (x *ModelFeatures) GetVision() bool {
+	if x != nil {
+		return x.Vision
+	}
+	return false
+}
+
+func (x *ModelFeatures) GetJsonMode() bool {
+	if x != nil {
+		return x.JsonMode
+	}
+	return false
+}
+
+func (x *ModelFeatures) GetEmbeddings() bool {
+	if x != nil {
+		return x.Embeddings
+	}
+	return false
+}
+
+// ModelPrice is a model's current list price in USD per million tokens.
+type ModelPrice struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	InputPerMillion       float64 `protobuf:"fixed64,1,opt,name=input_per_million,json=inputPerMillion,proto3" json:"input_per_million,omitempty"`
+	OutputPerMillion      float64 `protobuf:"fixed64,2,opt,name=output_per_million,json=outputPerMillion,proto3" json:"output_per_million,omitempty"`
+	CachedInputPerMillion float64 `protobuf:"fixed64,3,opt,name=cached_input_per_million,json=cachedInputPerMillion,proto3" json:"cached_input_per_million,omitempty"`
+}
+
+func (x *ModelPrice) Reset()         { *x = ModelPrice{} }
+func (x *ModelPrice) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ModelPrice) ProtoMessage()  {}
+
+func (x *ModelPrice) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ModelPrice) GetInputPerMillion() float64 {
+	if x != nil {
+		return x.InputPerMillion
+	}
+	return 0
+}
+
+func (x *ModelPrice) GetOutputPerMillion() float64 {
+	if x != nil {
+		return x.OutputPerMillion
+	}
+	return 0
+}
+
+func (x *ModelPrice) GetCachedInputPerMillion() float64 {
+	if x != nil {
+		return x.CachedInputPerMillion
+	}
+	return 0
+}
+
+// ModelHealth is the current state of the provider serving a model.
+type ModelHealth struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CircuitState  string `protobuf:"bytes,1,opt,name=circuit_state,json=circuitState,proto3" json:"circuit_state,omitempty"`     // "closed", "open" or "half_open"
+	AvailableKeys int32  `protobuf:"varint,2,opt,name=available_keys,json=availableKeys,proto3" json:"available_keys,omitempty"` // Keys neither rate-limited nor quarantined
+	TotalKeys     int32  `protobuf:"varint,3,opt,name=total_keys,json=totalKeys,proto3" json:"total_keys,omitempty"`
+	Healthy       bool   `protobuf:"varint,4,opt,name=healthy,proto3" json:"healthy,omitempty"` // Circuit not open and at least one key available
+}
+
+func (x *ModelHealth) Reset()         { *x = ModelHealth{} }
+func (x *ModelHealth) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ModelHealth) ProtoMessage()  {}
+
+func (x *ModelHealth) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ModelHealth) GetCircuitState() string {
+	if x != nil {
+		return x.CircuitState
+	}
+	return ""
+}
+
+func (x *ModelHealth) GetAvailableKeys() int32 {
+	if x != nil {
+		return x.AvailableKeys
+	}
+	return 0
+}
+
+func (x *ModelHealth) GetTotalKeys() int32 {
+	if x != nil {
+		return x.TotalKeys
+	}
+	return 0
+}
+
+func (x *ModelHealth) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+// ModelInfo describes one routable model.
+type ModelInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name            string         `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Provider        string         `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	Aliases         []string       `protobuf:"bytes,3,rep,name=aliases,proto3" json:"aliases,omitempty"`                                           // Other names that route to this model
+	ContextWindow   int32          `protobuf:"varint,4,opt,name=context_window,json=contextWindow,proto3" json:"context_window,omitempty"`         // 0 if unknown
+	MaxOutputTokens int32          `protobuf:"varint,5,opt,name=max_output_tokens,json=maxOutputTokens,proto3" json:"max_output_tokens,omitempty"` // 0 if unknown
+	Features        *ModelFeatures `protobuf:"bytes,6,opt,name=features,proto3" json:"features,omitempty"`
+	Price           *ModelPrice    `protobuf:"bytes,7,opt,name=price,proto3" json:"price,omitempty"` // Unset if the model has no price
+	Health          *ModelHealth   `protobuf:"bytes,8,opt,name=health,proto3" json:"health,omitempty"`
+	Source          string         `protobuf:"bytes,9,opt,name=source,proto3" json:"source,omitempty"` // "config" or "provider" (discovered from the provider's model list)
+}
+
+func (x *ModelInfo) Reset()         { *x = ModelInfo{} }
+func (x *ModelInfo) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ModelInfo) ProtoMessage()  {}
+
+func (x *ModelInfo) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ModelInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetAliases() []string {
+	if x != nil {
+		return x.Aliases
+	}
+	return nil
+}
+
+func (x *ModelInfo) GetContextWindow() int32 {
+	if x != nil {
+		return x.ContextWindow
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetMaxOutputTokens() int32 {
+	if x != nil {
+		return x.MaxOutputTokens
+	}
+	return 0
+}
+
+func (x *ModelInfo) GetFeatures() *ModelFeatures {
+	if x != nil {
+		return x.Features
+	}
+	return nil
+}
+
+func (x *ModelInfo) GetPrice() *ModelPrice {
+	if x != nil {
+		return x.Price
+	}
+	return nil
+}
+
+func (x *ModelInfo) GetHealth() *ModelHealth {
+	if x != nil {
+		return x.Health
+	}
+	return nil
+}
+
+func (x *ModelInfo) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+type ListModelsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Models []*ModelInfo `protobuf:"bytes,1,rep,name=models,proto3" json:"models,omitempty"` // Sorted by name
+}
+
+func (x *ListModelsResponse) Reset()         { *x = ListModelsResponse{} }
+func (x *ListModelsResponse) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ListModelsResponse) ProtoMessage()  {}
+
+func (x *ListModelsResponse) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ListModelsResponse) GetModels() []*ModelInfo {
+	if x != nil {
+		return x.Models
+	}
+	return nil
+}
+
+// ChatClientMessage is a message from the client on a Chat stream.
+type ChatClientMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Action    ChatAction        `protobuf:"varint,1,opt,name=action,proto3,enum=inferenceproxy.ChatAction" json:"action,omitempty"`
+	SessionId string            `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"` // Resume a stored session; empty on the first message to start one
+	Turn      *InferenceRequest `protobuf:"bytes,3,opt,name=turn,proto3" json:"turn,omitempty"`                            // SEND: the user's turn; model defaults to the session's
+}
+
+func (x *ChatClientMessage) Reset()         { *x = ChatClientMessage{} }
+func (x *ChatClientMessage) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ChatClientMessage) ProtoMessage()  {}
+
+func (x *ChatClientMessage) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ChatClientMessage) GetAction() ChatAction {
+	if x != nil {
+		return x.Action
+	}
+	return ChatAction(0)
+}
+
+func (x *ChatClientMessage) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ChatClientMessage) GetTurn() *InferenceRequest {
+	if x != nil {
+		return x.Turn
+	}
+	return nil
+}
+
+// ChatServerMessage is a message from the proxy on a Chat stream.
+type ChatServerMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SessionId    string            `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Turn         int32             `protobuf:"varint,2,opt,name=turn,proto3" json:"turn,omitempty"`                                                                                              // Index of the SEND message being answered, from 0 on this stream
+	Chunk        *StreamChunk      `protobuf:"bytes,3,opt,name=chunk,proto3" json:"chunk,omitempty"`                                                                                             // Reply text; the final chunk has done, usage and metadata set
+	Headers      map[string]string `protobuf:"bytes,4,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // The turn's response headers and trailers (x-request-id, guardrail flags, truncation, quota), on the next message after they are set
+	Cancelled    bool              `protobuf:"varint,5,opt,name=cancelled,proto3" json:"cancelled,omitempty"`                                                                                    // The reply was stopped by CHAT_ACTION_CANCEL; the text sent so far is kept in the session
+	ErrorCode    int32             `protobuf:"varint,6,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`                                                                   // gRPC status code of a failed turn; the stream stays open
+	ErrorMessage string            `protobuf:"bytes,7,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	ErrorReason  string            `protobuf:"bytes,8,opt,name=error_reason,json=errorReason,proto3" json:"error_reason,omitempty"` // google.rpc.ErrorInfo reason of a failed turn, if any
+}
+
+func (x *ChatServerMessage) Reset()         { *x = ChatServerMessage{} }
+func (x *ChatServerMessage) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ChatServerMessage) ProtoMessage()  {}
+
+func (x *ChatServerMessage) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ChatServerMessage) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ChatServerMessage) GetTurn() int32 {
+	if x != nil {
+		return x.Turn
+	}
+	return 0
+}
+
+func (x *ChatServerMessage) GetChunk() *StreamChunk {
+	if x != nil {
+		return x.Chunk
+	}
+	return nil
+}
+
+func (x *ChatServerMessage) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *ChatServerMessage) GetCancelled() bool {
+	if x != nil {
+		return x.Cancelled
+	}
+	return false
+}
+
+func (x *ChatServerMessage) GetErrorCode() int32 {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return 0
+}
+
+func (x *ChatServerMessage) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+func (x *ChatServerMessage) GetErrorReason() string {
+	if x != nil {
+		return x.ErrorReason
+	}
+	return ""
+}
+
+// GetConversationRequest names a stored conversation.
+type GetConversationRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ConversationId string `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+}
+
+func (x *GetConversationRequest) Reset()         { *x = GetConversationRequest{} }
+func (x *GetConversationRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *GetConversationRequest) ProtoMessage()  {}
+
+func (x *GetConversationRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *GetConversationRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+// ConversationTurn is one message of a stored conversation.
+type ConversationTurn struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Role      string `protobuf:"bytes,1,opt,name=role,proto3" json:"role,omitempty"`                             // "user", "assistant", or "system" for a summary of earlier turns
+	Text      string `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`                             // As the provider saw it, PII placeholders included
+	CreatedAt int64  `protobuf:"varint,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"` // Unix seconds
+}
+
+func (x *ConversationTurn) Reset()         { *x = ConversationTurn{} }
+func (x *ConversationTurn) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ConversationTurn) ProtoMessage()  {}
+
+func (x *ConversationTurn) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ConversationTurn) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *ConversationTurn) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *ConversationTurn) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+// Conversation is the stored transcript of a conversation.
+type Conversation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ConversationId string              `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	Model          string              `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`                           // Model of the latest turn
+	Turns          []*ConversationTurn `protobuf:"bytes,3,rep,name=turns,proto3" json:"turns,omitempty"`                           // Oldest first
+	CreatedAt      int64               `protobuf:"varint,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"` // Unix seconds
+	UpdatedAt      int64               `protobuf:"varint,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"` // Unix seconds
+}
+
+func (x *Conversation) Reset()         { *x = Conversation{} }
+func (x *Conversation) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *Conversation) ProtoMessage()  {}
+
+func (x *Conversation) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *Conversation) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *Conversation) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *Conversation) GetTurns() []*ConversationTurn {
+	if x != nil {
+		return x.Turns
+	}
+	return nil
+}
+
+func (x *Conversation) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Conversation) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+// DeleteConversationRequest names a stored conversation to delete.
+type DeleteConversationRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ConversationId string `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+}
+
+func (x *DeleteConversationRequest) Reset()         { *x = DeleteConversationRequest{} }
+func (x *DeleteConversationRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *DeleteConversationRequest) ProtoMessage()  {}
+
+func (x *DeleteConversationRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *DeleteConversationRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+// DeleteConversationResponse reports whether the conversation existed.
+type DeleteConversationResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Deleted bool `protobuf:"varint,1,opt,name=deleted,proto3" json:"deleted,omitempty"`
+}
+
+func (x *DeleteConversationResponse) Reset()         { *x = DeleteConversationResponse{} }
+func (x *DeleteConversationResponse) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *DeleteConversationResponse) ProtoMessage()  {}
+
+func (x *DeleteConversationResponse) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *DeleteConversationResponse) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+// BatchItem is one request of a batch job.
+type BatchItem struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CustomId string            `protobuf:"bytes,1,opt,name=custom_id,json=customId,proto3" json:"custom_id,omitempty"` // Client reference echoed in the item's result
+	Request  *InferenceRequest `protobuf:"bytes,2,opt,name=request,proto3" json:"request,omitempty"`                   // conversation_id is not supported; priority is always LOW
+}
+
+func (x *BatchItem) Reset()         { *x = BatchItem{} }
+func (x *BatchItem) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *BatchItem) ProtoMessage()  {}
+
+func (x *BatchItem) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *BatchItem) GetCustomId() string {
+	if x != nil {
+		return x.CustomId
+	}
+	return ""
+}
+
+func (x *BatchItem) GetRequest() *InferenceRequest {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+// SubmitBatchRequest starts a batch job from inline items or a JSONL file of BatchItem lines.
+type SubmitBatchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items     []*BatchItem `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	InputPath string       `protobuf:"bytes,2,opt,name=input_path,json=inputPath,proto3" json:"input_path,omitempty"` // JSONL file relative to the proxy's batch input directory, instead of items
+}
+
+func (x *SubmitBatchRequest) Reset()         { *x = SubmitBatchRequest{} }
+func (x *SubmitBatchRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *SubmitBatchRequest) ProtoMessage()  {}
+
+func (x *SubmitBatchRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *SubmitBatchRequest) GetItems() []*BatchItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *SubmitBatchRequest) GetInputPath() string {
+	if x != nil {
+		return x.InputPath
+	}
+	return ""
+}
+
+// Batch is the state of a batch job.
+type Batch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BatchId         string `protobuf:"bytes,1,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	Status          string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // "queued", "running", "completed", "failed" or "cancelled"
+	Total           int32  `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`  // Items in the job
+	Succeeded       int32  `protobuf:"varint,4,opt,name=succeeded,proto3" json:"succeeded,omitempty"`
+	Failed          int32  `protobuf:"varint,5,opt,name=failed,proto3" json:"failed,omitempty"`
+	Native          int32  `protobuf:"varint,6,opt,name=native,proto3" json:"native,omitempty"` // Items sent to a provider's native batch API
+	CancelRequested bool   `protobuf:"varint,7,opt,name=cancel_requested,json=cancelRequested,proto3" json:"cancel_requested,omitempty"`
+	Error           string `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`                            // Why the job failed, if it did
+	CreatedAt       int64  `protobuf:"varint,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`  // Unix seconds
+	UpdatedAt       int64  `protobuf:"varint,10,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"` // Unix seconds
+}
+
+func (x *Batch) Reset()         { *x = Batch{} }
+func (x *Batch) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *Batch) ProtoMessage()  {}
+
+func (x *Batch) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *Batch) GetBatchId() string {
+	if x != nil {
+		return x.BatchId
+	}
+	return ""
+}
+
+func (x *Batch) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Batch) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Batch) GetSucceeded() int32 {
+	if x != nil {
+		return x.Succeeded
+	}
+	return 0
+}
+
+func (x *Batch) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *Batch) GetNative() int32 {
+	if x != nil {
+		return x.Native
+	}
+	return 0
+}
+
+func (x *Batch) GetCancelRequested() bool {
+	if x != nil {
+		return x.CancelRequested
+	}
+	return false
+}
+
+func (x *Batch) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Batch) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Batch) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+// GetBatchRequest names a batch job and the page of its results to return.
+type GetBatchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BatchId        string `protobuf:"bytes,1,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	IncludeResults bool   `protobuf:"varint,2,opt,name=include_results,json=includeResults,proto3" json:"include_results,omitempty"`
+	ResultsOffset  int32  `protobuf:"varint,3,opt,name=results_offset,json=resultsOffset,proto3" json:"results_offset,omitempty"` // Results to skip
+	ResultsLimit   int32  `protobuf:"varint,4,opt,name=results_limit,json=resultsLimit,proto3" json:"results_limit,omitempty"`    // Results to return (default: 1000)
+}
+
+func (x *GetBatchRequest) Reset()         { *x = GetBatchRequest{} }
+func (x *GetBatchRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *GetBatchRequest) ProtoMessage()  {}
+
+func (x *GetBatchRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *GetBatchRequest) GetBatchId() string {
+	if x != nil {
+		return x.BatchId
+	}
+	return ""
+}
+
+func (x *GetBatchRequest) GetIncludeResults() bool {
+	if x != nil {
+		return x.IncludeResults
+	}
+	return false
+}
+
+func (x *GetBatchRequest) GetResultsOffset() int32 {
+	if x != nil {
+		return x.ResultsOffset
+	}
+	return 0
+}
+
+func (x *GetBatchRequest) GetResultsLimit() int32 {
+	if x != nil {
+		return x.ResultsLimit
+	}
+	return 0
+}
+
+// GetBatchResponse is a batch job with a page of its results.
+type GetBatchResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Batch             *Batch `protobuf:"bytes,1,opt,name=batch,proto3" json:"batch,omitempty"`
+	Results           []byte `protobuf:"bytes,2,opt,name=results,proto3" json:"results,omitempty"`                                                 // JSONL, one result per line in the order the items finished
+	NextResultsOffset int32  `protobuf:"varint,3,opt,name=next_results_offset,json=nextResultsOffset,proto3" json:"next_results_offset,omitempty"` // results_offset of the next page
+}
+
+func (x *GetBatchResponse) Reset()         { *x = GetBatchResponse{} }
+func (x *GetBatchResponse) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *GetBatchResponse) ProtoMessage()  {}
+
+func (x *GetBatchResponse) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *GetBatchResponse) GetBatch() *Batch {
+	if x != nil {
+		return x.Batch
+	}
+	return nil
+}
+
+func (x *GetBatchResponse) GetResults() []byte {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *GetBatchResponse) GetNextResultsOffset() int32 {
+	if x != nil {
+		return x.NextResultsOffset
+	}
+	return 0
+}
+
+// CancelBatchRequest names a batch job to cancel.
+type CancelBatchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BatchId string `protobuf:"bytes,1,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+}
+
+func (x *CancelBatchRequest) Reset()         { *x = CancelBatchRequest{} }
+func (x *CancelBatchRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *CancelBatchRequest) ProtoMessage()  {}
+
+func (x *CancelBatchRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *CancelBatchRequest) GetBatchId() string {
+	if x != nil {
+		return x.BatchId
+	}
+	return ""
+}
+
+// ListBatchesRequest pages through the caller's batch jobs.
+type ListBatchesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PageSize  int32  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"` // Default 50
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+}
+
+func (x *ListBatchesRequest) Reset()         { *x = ListBatchesRequest{} }
+func (x *ListBatchesRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ListBatchesRequest) ProtoMessage()  {}
+
+func (x *ListBatchesRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ListBatchesRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListBatchesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+// ListBatchesResponse is a page of batch jobs, newest first.
+type ListBatchesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Batches       []*Batch `protobuf:"bytes,1,rep,name=batches,proto3" json:"batches,omitempty"`
+	NextPageToken string   `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // Empty on the last page
+}
+
+func (x *ListBatchesResponse) Reset()         { *x = ListBatchesResponse{} }
+func (x *ListBatchesResponse) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ListBatchesResponse) ProtoMessage()  {}
+
+func (x *ListBatchesResponse) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ListBatchesResponse) GetBatches() []*Batch {
+	if x != nil {
+		return x.Batches
+	}
+	return nil
+}
+
+func (x *ListBatchesResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+// VirtualKey is a proxy-issued client credential. Only its hash is stored;
+// the secret is returned once, when the key is created.
+type VirtualKey struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id               string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Tenant           string   `protobuf:"bytes,2,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	Team             string   `protobuf:"bytes,3,opt,name=team,proto3" json:"team,omitempty"`
+	AllowedModels    []string `protobuf:"bytes,4,rep,name=allowed_models,json=allowedModels,proto3" json:"allowed_models,omitempty"`
+	AllowedProviders []string `protobuf:"bytes,5,rep,name=allowed_providers,json=allowedProviders,proto3" json:"allowed_providers,omitempty"`
+	CreatedAt        int64    `protobuf:"varint,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+}
+
+func (x *VirtualKey) Reset()         { *x = VirtualKey{} }
+func (x *VirtualKey) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *VirtualKey) ProtoMessage()  {}
+
+func (x *VirtualKey) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *VirtualKey) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *VirtualKey) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+func (x *VirtualKey) GetTeam() string {
+	if x != nil {
+		return x.Team
+	}
+	return ""
+}
+
+func (x *VirtualKey) GetAllowedModels() []string {
+	if x != nil {
+		return x.AllowedModels
+	}
+	return nil
+}
+
+func (x *VirtualKey) GetAllowedProviders() []string {
+	if x != nil {
+		return x.AllowedProviders
+	}
+	return nil
+}
+
+func (x *VirtualKey) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+type CreateVirtualKeyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Tenant           string   `protobuf:"bytes,1,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	Team             string   `protobuf:"bytes,2,opt,name=team,proto3" json:"team,omitempty"`
+	AllowedModels    []string `protobuf:"bytes,3,rep,name=allowed_models,json=allowedModels,proto3" json:"allowed_models,omitempty"`
+	AllowedProviders []string `protobuf:"bytes,4,rep,name=allowed_providers,json=allowedProviders,proto3" json:"allowed_providers,omitempty"`
+}
+
+func (x *CreateVirtualKeyRequest) Reset()         { *x = CreateVirtualKeyRequest{} }
+func (x *CreateVirtualKeyRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *CreateVirtualKeyRequest) ProtoMessage()  {}
+
+func (x *CreateVirtualKeyRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *CreateVirtualKeyRequest) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+func (x *CreateVirtualKeyRequest) GetTeam() string {
+	if x != nil {
+		return x.Team
+	}
+	return ""
+}
+
+func (x *CreateVirtualKeyRequest) GetAllowedModels() []string {
+	if x != nil {
+		return x.AllowedModels
+	}
+	return nil
+}
+
+func (x *CreateVirtualKeyRequest) GetAllowedProviders() []string {
+	if x != nil {
+		return x.AllowedProviders
+	}
+	return nil
+}
+
+type CreateVirtualKeyResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key    *VirtualKey `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Secret string      `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+}
+
+func (x *CreateVirtualKeyResponse) Reset()         { *x = CreateVirtualKeyResponse{} }
+func (x *CreateVirtualKeyResponse) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *CreateVirtualKeyResponse) ProtoMessage()  {}
+
+func (x *CreateVirtualKeyResponse) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *CreateVirtualKeyResponse) GetKey() *VirtualKey {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *CreateVirtualKeyResponse) GetSecret() string {
+	if x != nil {
+		return x.Secret
+	}
+	return ""
+}
+
+type ListVirtualKeysRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Tenant string `protobuf:"bytes,1,opt,name=tenant,proto3" json:"tenant,omitempty"`
+}
+
+func (x *ListVirtualKeysRequest) Reset()         { *x = ListVirtualKeysRequest{} }
+func (x *ListVirtualKeysRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ListVirtualKeysRequest) ProtoMessage()  {}
+
+func (x *ListVirtualKeysRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ListVirtualKeysRequest) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+type ListVirtualKeysResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Keys []*VirtualKey `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+}
+
+func (x *ListVirtualKeysResponse) Reset()         { *x = ListVirtualKeysResponse{} }
+func (x *ListVirtualKeysResponse) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *ListVirtualKeysResponse) ProtoMessage()  {}
+
+func (x *ListVirtualKeysResponse) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *ListVirtualKeysResponse) GetKeys() []*VirtualKey {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+type RevokeVirtualKeyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *RevokeVirtualKeyRequest) Reset()         { *x = RevokeVirtualKeyRequest{} }
+func (x *RevokeVirtualKeyRequest) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *RevokeVirtualKeyRequest) ProtoMessage()  {}
+
+func (x *RevokeVirtualKeyRequest) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *RevokeVirtualKeyRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type RevokeVirtualKeyResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Revoked bool `protobuf:"varint,1,opt,name=revoked,proto3" json:"revoked,omitempty"`
+}
+
+func (x *RevokeVirtualKeyResponse) Reset()         { *x = RevokeVirtualKeyResponse{} }
+func (x *RevokeVirtualKeyResponse) String() string { return protoimpl.X.MessageStringOf(x) }
+func (x *RevokeVirtualKeyResponse) ProtoMessage()  {}
+
+func (x *RevokeVirtualKeyResponse) ProtoReflect() protoreflect.Message {
+	return nil // simplified stub
+}
+
+func (x *RevokeVirtualKeyResponse) GetRevoked() bool {
+	if x != nil {
+		return x.Revoked
+	}
+	return false
+}
+
 // File descriptor stubs — in production, these would be generated by protoc.
 var _ protoreflect.Message
 var _ reflect.Type
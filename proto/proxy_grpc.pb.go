@@ -19,6 +19,16 @@ import (
 type InferenceServiceClient interface {
 	Infer(ctx context.Context, in *InferenceRequest, opts ...grpc.CallOption) (*InferenceResponse, error)
 	InferStream(ctx context.Context, in *InferenceRequest, opts ...grpc.CallOption) (InferenceService_InferStreamClient, error)
+	Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error)
+	CountTokens(ctx context.Context, in *CountTokensRequest, opts ...grpc.CallOption) (*CountTokensResponse, error)
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
+	Chat(ctx context.Context, opts ...grpc.CallOption) (InferenceService_ChatClient, error)
+	GetConversation(ctx context.Context, in *GetConversationRequest, opts ...grpc.CallOption) (*Conversation, error)
+	DeleteConversation(ctx context.Context, in *DeleteConversationRequest, opts ...grpc.CallOption) (*DeleteConversationResponse, error)
+	SubmitBatch(ctx context.Context, in *SubmitBatchRequest, opts ...grpc.CallOption) (*Batch, error)
+	GetBatch(ctx context.Context, in *GetBatchRequest, opts ...grpc.CallOption) (*GetBatchResponse, error)
+	CancelBatch(ctx context.Context, in *CancelBatchRequest, opts ...grpc.CallOption) (*Batch, error)
+	ListBatches(ctx context.Context, in *ListBatchesRequest, opts ...grpc.CallOption) (*ListBatchesResponse, error)
 }
 
 type inferenceServiceClient struct {
@@ -53,6 +63,95 @@ func (c *inferenceServiceClient) InferStream(ctx context.Context, in *InferenceR
 	return x, nil
 }
 
+func (c *inferenceServiceClient) Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error) {
+	out := new(EmbedResponse)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.InferenceService/Embed", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) CountTokens(ctx context.Context, in *CountTokensRequest, opts ...grpc.CallOption) (*CountTokensResponse, error) {
+	out := new(CountTokensResponse)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.InferenceService/CountTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.InferenceService/ListModels", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) Chat(ctx context.Context, opts ...grpc.CallOption) (InferenceService_ChatClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InferenceService_ServiceDesc.Streams[1], "/inferenceproxy.InferenceService/Chat", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &inferenceServiceChatClient{stream}, nil
+}
+
+func (c *inferenceServiceClient) GetConversation(ctx context.Context, in *GetConversationRequest, opts ...grpc.CallOption) (*Conversation, error) {
+	out := new(Conversation)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.InferenceService/GetConversation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) DeleteConversation(ctx context.Context, in *DeleteConversationRequest, opts ...grpc.CallOption) (*DeleteConversationResponse, error) {
+	out := new(DeleteConversationResponse)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.InferenceService/DeleteConversation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) SubmitBatch(ctx context.Context, in *SubmitBatchRequest, opts ...grpc.CallOption) (*Batch, error) {
+	out := new(Batch)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.InferenceService/SubmitBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) GetBatch(ctx context.Context, in *GetBatchRequest, opts ...grpc.CallOption) (*GetBatchResponse, error) {
+	out := new(GetBatchResponse)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.InferenceService/GetBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) CancelBatch(ctx context.Context, in *CancelBatchRequest, opts ...grpc.CallOption) (*Batch, error) {
+	out := new(Batch)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.InferenceService/CancelBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inferenceServiceClient) ListBatches(ctx context.Context, in *ListBatchesRequest, opts ...grpc.CallOption) (*ListBatchesResponse, error) {
+	out := new(ListBatchesResponse)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.InferenceService/ListBatches", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // InferenceService_InferStreamClient is the client-side streaming interface.
 type InferenceService_InferStreamClient interface {
 	Recv() (*StreamChunk, error)
@@ -71,6 +170,30 @@ func (x *inferenceServiceInferStreamClient) Recv() (*StreamChunk, error) {
 	return m, nil
 }
 
+// InferenceService_ChatClient is the client-side bidirectional streaming
+// interface.
+type InferenceService_ChatClient interface {
+	Send(*ChatClientMessage) error
+	Recv() (*ChatServerMessage, error)
+	grpc.ClientStream
+}
+
+type inferenceServiceChatClient struct {
+	grpc.ClientStream
+}
+
+func (x *inferenceServiceChatClient) Send(m *ChatClientMessage) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *inferenceServiceChatClient) Recv() (*ChatServerMessage, error) {
+	m := new(ChatServerMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ---------------------------------------------------------------------------
 // Server-side interface
 // ---------------------------------------------------------------------------
@@ -79,6 +202,16 @@ func (x *inferenceServiceInferStreamClient) Recv() (*StreamChunk, error) {
 type InferenceServiceServer interface {
 	Infer(context.Context, *InferenceRequest) (*InferenceResponse, error)
 	InferStream(*InferenceRequest, InferenceService_InferStreamServer) error
+	Embed(context.Context, *EmbedRequest) (*EmbedResponse, error)
+	CountTokens(context.Context, *CountTokensRequest) (*CountTokensResponse, error)
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
+	Chat(InferenceService_ChatServer) error
+	GetConversation(context.Context, *GetConversationRequest) (*Conversation, error)
+	DeleteConversation(context.Context, *DeleteConversationRequest) (*DeleteConversationResponse, error)
+	SubmitBatch(context.Context, *SubmitBatchRequest) (*Batch, error)
+	GetBatch(context.Context, *GetBatchRequest) (*GetBatchResponse, error)
+	CancelBatch(context.Context, *CancelBatchRequest) (*Batch, error)
+	ListBatches(context.Context, *ListBatchesRequest) (*ListBatchesResponse, error)
 	mustEmbedUnimplementedInferenceServiceServer()
 }
 
@@ -94,6 +227,46 @@ func (UnimplementedInferenceServiceServer) InferStream(*InferenceRequest, Infere
 	return status.Errorf(codes.Unimplemented, "method InferStream not implemented")
 }
 
+func (UnimplementedInferenceServiceServer) Embed(context.Context, *EmbedRequest) (*EmbedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Embed not implemented")
+}
+
+func (UnimplementedInferenceServiceServer) CountTokens(context.Context, *CountTokensRequest) (*CountTokensResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CountTokens not implemented")
+}
+
+func (UnimplementedInferenceServiceServer) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListModels not implemented")
+}
+
+func (UnimplementedInferenceServiceServer) Chat(InferenceService_ChatServer) error {
+	return status.Errorf(codes.Unimplemented, "method Chat not implemented")
+}
+
+func (UnimplementedInferenceServiceServer) GetConversation(context.Context, *GetConversationRequest) (*Conversation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConversation not implemented")
+}
+
+func (UnimplementedInferenceServiceServer) DeleteConversation(context.Context, *DeleteConversationRequest) (*DeleteConversationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteConversation not implemented")
+}
+
+func (UnimplementedInferenceServiceServer) SubmitBatch(context.Context, *SubmitBatchRequest) (*Batch, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitBatch not implemented")
+}
+
+func (UnimplementedInferenceServiceServer) GetBatch(context.Context, *GetBatchRequest) (*GetBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBatch not implemented")
+}
+
+func (UnimplementedInferenceServiceServer) CancelBatch(context.Context, *CancelBatchRequest) (*Batch, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelBatch not implemented")
+}
+
+func (UnimplementedInferenceServiceServer) ListBatches(context.Context, *ListBatchesRequest) (*ListBatchesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBatches not implemented")
+}
+
 func (UnimplementedInferenceServiceServer) mustEmbedUnimplementedInferenceServiceServer() {}
 
 // UnsafeInferenceServiceServer may be embedded to opt out of forward
@@ -116,6 +289,30 @@ func (x *inferenceServiceInferStreamServer) Send(m *StreamChunk) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+// InferenceService_ChatServer is the server-side bidirectional streaming
+// interface.
+type InferenceService_ChatServer interface {
+	Send(*ChatServerMessage) error
+	Recv() (*ChatClientMessage, error)
+	grpc.ServerStream
+}
+
+type inferenceServiceChatServer struct {
+	grpc.ServerStream
+}
+
+func (x *inferenceServiceChatServer) Send(m *ChatServerMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *inferenceServiceChatServer) Recv() (*ChatClientMessage, error) {
+	m := new(ChatClientMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ---------------------------------------------------------------------------
 // Service registration
 // ---------------------------------------------------------------------------
@@ -150,6 +347,172 @@ func _InferenceService_InferStream_Handler(srv interface{}, stream grpc.ServerSt
 	return srv.(InferenceServiceServer).InferStream(m, &inferenceServiceInferStreamServer{stream})
 }
 
+func _InferenceService_Chat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InferenceServiceServer).Chat(&inferenceServiceChatServer{stream})
+}
+
+func _InferenceService_Embed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmbedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).Embed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.InferenceService/Embed",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).Embed(ctx, req.(*EmbedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_CountTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountTokensRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).CountTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.InferenceService/CountTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).CountTokens(ctx, req.(*CountTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_ListModels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.InferenceService/ListModels",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_GetConversation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConversationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).GetConversation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.InferenceService/GetConversation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).GetConversation(ctx, req.(*GetConversationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_DeleteConversation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteConversationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).DeleteConversation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.InferenceService/DeleteConversation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).DeleteConversation(ctx, req.(*DeleteConversationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_SubmitBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).SubmitBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.InferenceService/SubmitBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).SubmitBatch(ctx, req.(*SubmitBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_GetBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).GetBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.InferenceService/GetBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).GetBatch(ctx, req.(*GetBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_CancelBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).CancelBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.InferenceService/CancelBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).CancelBatch(ctx, req.(*CancelBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InferenceService_ListBatches_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBatchesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InferenceServiceServer).ListBatches(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.InferenceService/ListBatches",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InferenceServiceServer).ListBatches(ctx, req.(*ListBatchesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // InferenceService_ServiceDesc is the grpc.ServiceDesc for InferenceService.
 var InferenceService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "inferenceproxy.InferenceService",
@@ -159,6 +522,42 @@ var InferenceService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Infer",
 			Handler:    _InferenceService_Infer_Handler,
 		},
+		{
+			MethodName: "Embed",
+			Handler:    _InferenceService_Embed_Handler,
+		},
+		{
+			MethodName: "CountTokens",
+			Handler:    _InferenceService_CountTokens_Handler,
+		},
+		{
+			MethodName: "ListModels",
+			Handler:    _InferenceService_ListModels_Handler,
+		},
+		{
+			MethodName: "GetConversation",
+			Handler:    _InferenceService_GetConversation_Handler,
+		},
+		{
+			MethodName: "DeleteConversation",
+			Handler:    _InferenceService_DeleteConversation_Handler,
+		},
+		{
+			MethodName: "SubmitBatch",
+			Handler:    _InferenceService_SubmitBatch_Handler,
+		},
+		{
+			MethodName: "GetBatch",
+			Handler:    _InferenceService_GetBatch_Handler,
+		},
+		{
+			MethodName: "CancelBatch",
+			Handler:    _InferenceService_CancelBatch_Handler,
+		},
+		{
+			MethodName: "ListBatches",
+			Handler:    _InferenceService_ListBatches_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -166,6 +565,164 @@ var InferenceService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _InferenceService_InferStream_Handler,
 			ServerStreams:  true,
 		},
+		{
+			StreamName:    "Chat",
+			Handler:       _InferenceService_Chat_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/proxy.proto",
+}
+
+// ===========================================================================
+// AdminService
+// ===========================================================================
+
+// AdminServiceClient is the client API for AdminService.
+type AdminServiceClient interface {
+	CreateVirtualKey(ctx context.Context, in *CreateVirtualKeyRequest, opts ...grpc.CallOption) (*CreateVirtualKeyResponse, error)
+	ListVirtualKeys(ctx context.Context, in *ListVirtualKeysRequest, opts ...grpc.CallOption) (*ListVirtualKeysResponse, error)
+	RevokeVirtualKey(ctx context.Context, in *RevokeVirtualKeyRequest, opts ...grpc.CallOption) (*RevokeVirtualKeyResponse, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) CreateVirtualKey(ctx context.Context, in *CreateVirtualKeyRequest, opts ...grpc.CallOption) (*CreateVirtualKeyResponse, error) {
+	out := new(CreateVirtualKeyResponse)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.AdminService/CreateVirtualKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListVirtualKeys(ctx context.Context, in *ListVirtualKeysRequest, opts ...grpc.CallOption) (*ListVirtualKeysResponse, error) {
+	out := new(ListVirtualKeysResponse)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.AdminService/ListVirtualKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) RevokeVirtualKey(ctx context.Context, in *RevokeVirtualKeyRequest, opts ...grpc.CallOption) (*RevokeVirtualKeyResponse, error) {
+	out := new(RevokeVirtualKeyResponse)
+	err := c.cc.Invoke(ctx, "/inferenceproxy.AdminService/RevokeVirtualKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService.
+type AdminServiceServer interface {
+	CreateVirtualKey(context.Context, *CreateVirtualKeyRequest) (*CreateVirtualKeyResponse, error)
+	ListVirtualKeys(context.Context, *ListVirtualKeysRequest) (*ListVirtualKeysResponse, error)
+	RevokeVirtualKey(context.Context, *RevokeVirtualKeyRequest) (*RevokeVirtualKeyResponse, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer should be embedded to have forward
+// compatible implementations.
+type UnimplementedAdminServiceServer struct{}
+
+func (UnimplementedAdminServiceServer) CreateVirtualKey(context.Context, *CreateVirtualKeyRequest) (*CreateVirtualKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateVirtualKey not implemented")
+}
+
+func (UnimplementedAdminServiceServer) ListVirtualKeys(context.Context, *ListVirtualKeysRequest) (*ListVirtualKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVirtualKeys not implemented")
+}
+
+func (UnimplementedAdminServiceServer) RevokeVirtualKey(context.Context, *RevokeVirtualKeyRequest) (*RevokeVirtualKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeVirtualKey not implemented")
+}
+
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_CreateVirtualKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateVirtualKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).CreateVirtualKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.AdminService/CreateVirtualKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).CreateVirtualKey(ctx, req.(*CreateVirtualKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListVirtualKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVirtualKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListVirtualKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.AdminService/ListVirtualKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListVirtualKeys(ctx, req.(*ListVirtualKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_RevokeVirtualKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeVirtualKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).RevokeVirtualKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/inferenceproxy.AdminService/RevokeVirtualKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).RevokeVirtualKey(ctx, req.(*RevokeVirtualKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService.
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "inferenceproxy.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateVirtualKey",
+			Handler:    _AdminService_CreateVirtualKey_Handler,
+		},
+		{
+			MethodName: "ListVirtualKeys",
+			Handler:    _AdminService_ListVirtualKeys_Handler,
+		},
+		{
+			MethodName: "RevokeVirtualKey",
+			Handler:    _AdminService_RevokeVirtualKey_Handler,
+		},
 	},
+	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/proxy.proto",
 }